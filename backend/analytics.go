@@ -0,0 +1,396 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// =============================================================================
+// ANALÍTICA DEL DASHBOARD
+// Series por día, semana o mes calculadas con una consulta por familia de
+// métricas (GROUPING SETS para los desgloses). El resultado se guarda en un
+// caché en memoria por combinación de parámetros: los paneles piden lo mismo
+// una y otra vez y unos minutos de retraso no importan.
+// =============================================================================
+
+var agrupacionesAnalytics = map[string]string{"dia": "day", "semana": "week", "mes": "month"}
+
+const maxCubetasAnalytics = 400
+
+// truncarCubeta lleva la fecha al inicio de su cubeta (la semana empieza el
+// lunes, igual que date_trunc('week') de Postgres).
+func truncarCubeta(t time.Time, agrupacion string) time.Time {
+	t = time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	switch agrupacion {
+	case "semana":
+		return t.AddDate(0, 0, -(int(t.Weekday())+6)%7)
+	case "mes":
+		return t.AddDate(0, 0, 1-t.Day())
+	}
+	return t
+}
+
+// cubetasAnalytics lista las cubetas del rango para que las series no tengan huecos.
+func cubetasAnalytics(desde, hasta time.Time, agrupacion string) []time.Time {
+	var cubetas []time.Time
+	for t := truncarCubeta(desde, agrupacion); !t.After(hasta); {
+		cubetas = append(cubetas, t)
+		switch agrupacion {
+		case "semana":
+			t = t.AddDate(0, 0, 7)
+		case "mes":
+			t = t.AddDate(0, 1, 0)
+		default:
+			t = t.AddDate(0, 0, 1)
+		}
+	}
+	return cubetas
+}
+
+// -----------------------------------------------------------------------------
+// Caché en memoria
+// -----------------------------------------------------------------------------
+
+type entradaAnalytics struct {
+	datos  gin.H
+	expira time.Time
+}
+
+type cacheAnalytics struct {
+	mu       sync.Mutex
+	entradas map[string]entradaAnalytics
+}
+
+var analyticsCache = &cacheAnalytics{entradas: make(map[string]entradaAnalytics)}
+
+func (c *cacheAnalytics) obtener(clave string, ahora time.Time) (gin.H, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entradas[clave]
+	if !ok || ahora.After(e.expira) {
+		return nil, false
+	}
+	return e.datos, true
+}
+
+func (c *cacheAnalytics) guardar(clave string, datos gin.H, ahora time.Time, ttl time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.entradas) >= 200 {
+		for k, e := range c.entradas {
+			if ahora.After(e.expira) {
+				delete(c.entradas, k)
+			}
+		}
+	}
+	if len(c.entradas) < 200 {
+		c.entradas[clave] = entradaAnalytics{datos: datos, expira: ahora.Add(ttl)}
+	}
+}
+
+// -----------------------------------------------------------------------------
+// Consultas
+// -----------------------------------------------------------------------------
+
+// consultaAnalytics arma los argumentos comunes: $1 agrupación, $2 desde,
+// $3 hasta; los filtros del listado se numeran a continuación.
+func consultaAnalytics(agrupacion string, desde, hasta time.Time, filtros FiltrosReclamos) (string, []interface{}) {
+	args := []interface{}{agrupacionesAnalytics[agrupacion], desde, hasta}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	return filtros.where(arg), args
+}
+
+func periodoAnalytics(t time.Time) string {
+	return t.Format("2006-01-02")
+}
+
+func calcularAnalytics(ctx context.Context, agrupacion string, desde, hasta time.Time, filtros FiltrosReclamos) (gin.H, error) {
+	where, args := consultaAnalytics(agrupacion, desde, hasta, filtros)
+
+	series := map[string]gin.H{}
+	var orden []string
+	for _, t := range cubetasAnalytics(desde, hasta, agrupacion) {
+		p := periodoAnalytics(t)
+		orden = append(orden, p)
+		series[p] = gin.H{
+			"periodo":                 p,
+			"registrados":             int64(0),
+			"por_tipo":                gin.H{},
+			"por_estado":              gin.H{},
+			"por_area":                gin.H{},
+			"por_categoria":           gin.H{},
+			"monto_reclamado":         0.0,
+			"resueltos":               int64(0),
+			"mediana_dias_resolucion": nil,
+			"p90_dias_resolucion":     nil,
+			"cumplimiento_plazo":      nil,
+			"reaperturas":             int64(0),
+			"tasa_reapertura":         nil,
+		}
+	}
+	serie := func(periodo time.Time) gin.H {
+		return series[periodoAnalytics(periodo)]
+	}
+
+	// 1. Volumen, montos y cumplimiento por fecha de registro
+	rows, err := pool.Query(ctx, `
+		WITH base AS (
+			SELECT date_trunc($1, r.fecha_registro)::date AS periodo, r.tipo_solicitud, r.estado,
+			       COALESCE(NULLIF(r.area_queja, ''), 'SIN_AREA') AS area,
+			       COALESCE(cat.raiz_nombre, 'SIN_CATEGORIA') AS categoria,
+			       COALESCE(r.monto_reclamado, 0)::float8 AS monto,
+			       r.fecha_respuesta::date <= `+sqlFechaLimite+` AS en_plazo,
+			       r.fecha_respuesta::date > `+sqlFechaLimite+` AS fuera_plazo,
+			       r.fecha_respuesta IS NULL AND `+sqlFechaLimite+` < CURRENT_DATE AS vencido
+			FROM reclamos r
+			LEFT JOIN v_categorias_ruta cat ON cat.id = r.categoria_id
+			WHERE r.fecha_registro >= $2::date AND r.fecha_registro < $3::date + 1`+where+`
+		)
+		SELECT periodo,
+		       CASE WHEN GROUPING(tipo_solicitud) = 0 THEN 'por_tipo'
+		            WHEN GROUPING(estado) = 0 THEN 'por_estado'
+		            WHEN GROUPING(area) = 0 THEN 'por_area'
+		            WHEN GROUPING(categoria) = 0 THEN 'por_categoria'
+		            ELSE '' END,
+		       COALESCE(tipo_solicitud, estado, area, categoria, ''),
+		       COUNT(*), SUM(monto),
+		       COUNT(*) FILTER (WHERE en_plazo), COUNT(*) FILTER (WHERE fuera_plazo), COUNT(*) FILTER (WHERE vencido)
+		FROM base
+		GROUP BY GROUPING SETS ((periodo), (periodo, tipo_solicitud), (periodo, estado), (periodo, area), (periodo, categoria))
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("volumen: %w", err)
+	}
+	for rows.Next() {
+		var periodo time.Time
+		var dimension, valor string
+		var n, enPlazo, fueraPlazo, vencidos int64
+		var monto float64
+		if err := rows.Scan(&periodo, &dimension, &valor, &n, &monto, &enPlazo, &fueraPlazo, &vencidos); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("volumen: %w", err)
+		}
+		s := serie(periodo)
+		if s == nil {
+			continue
+		}
+		if dimension != "" {
+			s[dimension].(gin.H)[valor] = n
+			continue
+		}
+		s["registrados"] = n
+		s["monto_reclamado"] = monto
+		s["cumplimiento_plazo"] = porcentajeCumplimiento(enPlazo, fueraPlazo, vencidos)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("volumen: %w", err)
+	}
+
+	// 2. Tiempos de resolución por fecha de respuesta (días corridos)
+	rows, err = pool.Query(ctx, `
+		SELECT date_trunc($1, r.fecha_respuesta)::date, COUNT(*),
+		       ROUND(percentile_cont(0.5) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM r.fecha_respuesta - r.fecha_registro) / 86400)::numeric, 1)::float8,
+		       ROUND(percentile_cont(0.9) WITHIN GROUP (ORDER BY EXTRACT(EPOCH FROM r.fecha_respuesta - r.fecha_registro) / 86400)::numeric, 1)::float8
+		FROM reclamos r
+		WHERE r.fecha_respuesta >= $2::date AND r.fecha_respuesta < $3::date + 1`+where+`
+		GROUP BY 1
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("resolución: %w", err)
+	}
+	for rows.Next() {
+		var periodo time.Time
+		var n int64
+		var mediana, p90 float64
+		if err := rows.Scan(&periodo, &n, &mediana, &p90); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("resolución: %w", err)
+		}
+		if s := serie(periodo); s != nil {
+			s["resueltos"] = n
+			s["mediana_dias_resolucion"] = mediana
+			s["p90_dias_resolucion"] = p90
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("resolución: %w", err)
+	}
+
+	// 3. Reaperturas: vuelta de RESUELTO/CERRADO a un estado abierto
+	rows, err = pool.Query(ctx, `
+		SELECT date_trunc($1, h.fecha_accion)::date, COUNT(*)
+		FROM historial_reclamos h
+		JOIN reclamos r ON r.id = h.reclamo_id
+		WHERE h.tipo_accion = 'CAMBIO_ESTADO'
+		  AND h.estado_anterior IN ('RESUELTO', 'CERRADO') AND h.estado_nuevo IN ('PENDIENTE', 'EN_PROCESO')
+		  AND h.fecha_accion >= $2::date AND h.fecha_accion < $3::date + 1`+where+`
+		GROUP BY 1
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("reaperturas: %w", err)
+	}
+	for rows.Next() {
+		var periodo time.Time
+		var n int64
+		if err := rows.Scan(&periodo, &n); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("reaperturas: %w", err)
+		}
+		if s := serie(periodo); s != nil {
+			s["reaperturas"] = n
+			if resueltos := s["resueltos"].(int64); resueltos > 0 {
+				s["tasa_reapertura"] = math.Round(float64(n)*1000/float64(resueltos)) / 10
+			}
+		}
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("reaperturas: %w", err)
+	}
+
+	// 4. Productividad por agente: resueltos y mediana, en total y por cubeta
+	rows, err = pool.Query(ctx, `
+		WITH base AS (
+			SELECT date_trunc($1, r.fecha_respuesta)::date AS periodo, ua.id::text AS agente, ua.nombre_completo AS nombre,
+			       EXTRACT(EPOCH FROM r.fecha_respuesta - r.fecha_registro) / 86400 AS dias
+			FROM reclamos r
+			JOIN usuarios_admin ua ON ua.id = r.atendido_por
+			WHERE r.fecha_respuesta >= $2::date AND r.fecha_respuesta < $3::date + 1`+where+`
+		)
+		SELECT agente, nombre, periodo, COUNT(*),
+		       ROUND(percentile_cont(0.5) WITHIN GROUP (ORDER BY dias)::numeric, 1)::float8
+		FROM base
+		GROUP BY GROUPING SETS ((agente, nombre), (agente, nombre, periodo))
+		ORDER BY agente, periodo NULLS FIRST
+	`, args...)
+	if err != nil {
+		return nil, fmt.Errorf("agentes: %w", err)
+	}
+	agentes := []gin.H{}
+	for rows.Next() {
+		var agente, nombre string
+		var periodo *time.Time
+		var n int64
+		var mediana float64
+		if err := rows.Scan(&agente, &nombre, &periodo, &n, &mediana); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("agentes: %w", err)
+		}
+		// La fila total de cada agente llega primero (periodo NULL)
+		if periodo == nil {
+			agentes = append(agentes, gin.H{
+				"id":                      agente,
+				"nombre":                  nombre,
+				"resueltos":               n,
+				"mediana_dias_resolucion": mediana,
+				"por_periodo":             gin.H{},
+			})
+			continue
+		}
+		agentes[len(agentes)-1]["por_periodo"].(gin.H)[periodoAnalytics(*periodo)] = n
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("agentes: %w", err)
+	}
+
+	lista := make([]gin.H, len(orden))
+	for i, p := range orden {
+		lista[i] = series[p]
+	}
+	return gin.H{
+		"desde":      periodoAnalytics(desde),
+		"hasta":      periodoAnalytics(hasta),
+		"agrupacion": agrupacion,
+		"series":     lista,
+		"agentes":    agentes,
+		"generado":   time.Now(),
+	}, nil
+}
+
+// GET /api/admin/analytics - Series de tiempo para el dashboard
+func analyticsHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	q := c.Request.URL.Query()
+	agrupacion := q.Get("agrupacion")
+	if agrupacion == "" {
+		agrupacion = "dia"
+	}
+	if _, ok := agrupacionesAnalytics[agrupacion]; !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "agrupacion debe ser dia, semana o mes"})
+		return
+	}
+
+	// Por defecto los últimos 30 días
+	hoy := time.Now()
+	hasta := time.Date(hoy.Year(), hoy.Month(), hoy.Day(), 0, 0, 0, 0, time.UTC)
+	desde := hasta.AddDate(0, 0, -29)
+	var err error
+	if v := q.Get("desde"); v != "" {
+		if desde, err = time.Parse("2006-01-02", v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "desde debe tener formato AAAA-MM-DD"})
+			return
+		}
+	}
+	if v := q.Get("hasta"); v != "" {
+		if hasta, err = time.Parse("2006-01-02", v); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "hasta debe tener formato AAAA-MM-DD"})
+			return
+		}
+	}
+	if hasta.Before(desde) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "hasta no puede ser anterior a desde"})
+		return
+	}
+	if len(cubetasAnalytics(desde, hasta, agrupacion)) > maxCubetasAnalytics {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "El rango es demasiado grande para esa agrupación"})
+		return
+	}
+
+	// El rango va aparte: los demás parámetros son los filtros del listado
+	filtrosQuery := url.Values{}
+	for k, v := range q {
+		if k != "desde" && k != "hasta" && k != "agrupacion" && k != "fecha_desde" && k != "fecha_hasta" && k != "search" && k != "orden" {
+			filtrosQuery[k] = v
+		}
+	}
+	filtros, err := parsearFiltros(filtrosQuery)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	clave := agrupacion + "|" + periodoAnalytics(desde) + "|" + periodoAnalytics(hasta) + "|" + parametrosGuardables(filtrosQuery).Encode()
+	ttl := time.Duration(config.AnalyticsCacheSegundos) * time.Second
+	if datos, ok := analyticsCache.obtener(clave, time.Now()); ok && ttl > 0 {
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": datos, "cache": true})
+		return
+	}
+
+	datos, err := calcularAnalytics(ctx, agrupacion, desde, hasta, filtros)
+	if err != nil {
+		log.Printf("❌ Error calculando analítica: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al calcular la analítica"})
+		return
+	}
+	if ttl > 0 {
+		analyticsCache.guardar(clave, datos, time.Now(), ttl)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": datos, "cache": false})
+}
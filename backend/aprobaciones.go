@@ -0,0 +1,839 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// =============================================================================
+// APROBACIÓN DE RESPUESTAS (CUATRO OJOS)
+// La respuesta al consumidor es un documento legal. Si alguna regla de
+// aprobación aplica al reclamo, responder crea una propuesta PENDIENTE; solo
+// cuando otro usuario con permiso (ADMIN o puede_aprobar) la aprueba se guarda
+// en respuestas, se resuelve el reclamo y se avisa al consumidor. Sin reglas
+// que apliquen, la respuesta se publica directamente como antes.
+// =============================================================================
+
+var errPropuestaNoPendiente = errors.New("la propuesta ya no está pendiente")
+
+type datosRespuesta struct {
+	RespuestaEmpresa     string `json:"respuesta_empresa"`
+	AccionTomada         string `json:"accion_tomada"`
+	CompensacionOfrecida string `json:"compensacion_ofrecida"`
+}
+
+// -----------------------------------------------------------------------------
+// Publicación
+// -----------------------------------------------------------------------------
+
+// publicarRespuestaEnTx guarda la respuesta oficial, resuelve el reclamo y
+// deja el historial. autorID queda como agente que atendió; aprobadoPor
+// es vacío si no hubo aprobación. Devuelve el estado anterior.
+func publicarRespuestaEnTx(ctx context.Context, tx pgx.Tx, id string, r datosRespuesta, autorID, autorEmail, aprobadoPor string) (string, error) {
+	var anterior string
+	err := tx.QueryRow(ctx, "SELECT estado FROM reclamos WHERE id = $1 FOR UPDATE", id).Scan(&anterior)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", errReclamoNoEncontrado
+	}
+	if err != nil {
+		return "", err
+	}
+	if err := validarTransicion(anterior, "RESUELTO", "ADMIN"); err != nil {
+		return anterior, err
+	}
+
+	if _, err := insertarVersionRespuestaEnTx(ctx, tx, id, r, autorEmail, aprobadoPor, ""); err != nil {
+		return anterior, err
+	}
+
+	// Actualizar estado, fecha de respuesta y REGISTRAR QUIÉN ATENDIÓ
+	_, err = tx.Exec(ctx, "UPDATE reclamos SET estado = 'RESUELTO', fecha_respuesta = NOW(), atendido_por = $2::uuid WHERE id = $1", id, autorID)
+	if err != nil {
+		return anterior, err
+	}
+
+	// El historial es público: la aprobación queda en la versión y en auditoría
+	_, err = tx.Exec(ctx, `
+		INSERT INTO historial_reclamos (reclamo_id, estado_anterior, estado_nuevo, tipo_accion, comentario, usuario_accion)
+		VALUES ($1, $2, 'RESUELTO', 'RESPUESTA', 'Respuesta enviada por la empresa', $3)
+	`, id, anterior, autorEmail)
+	return anterior, err
+}
+
+// respuestaPublicada hace lo que sigue al commit: evento en tiempo real,
+// cierre de la pausa del plazo y aviso al consumidor.
+func respuestaPublicada(ctx context.Context, id, anterior, usuario string) {
+	publicarEvento(id, eventoEstado, gin.H{"estado_anterior": anterior, "estado_nuevo": "RESUELTO", "respuesta": true})
+
+	if _, err := reanudarPlazo(ctx, id, "reclamo atendido", usuario); err != nil {
+		log.Printf("⚠️ Error cerrando pausa de %s: %v", id, err)
+	}
+
+	emailsEnCurso.Add(1)
+	go func() {
+		defer emailsEnCurso.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		notificarRespuestaConsumidor(ctx, id)
+	}()
+}
+
+// notificarRespuestaConsumidor envía la versión vigente de la respuesta
+// oficial al consumidor si aceptó copias por correo.
+func notificarRespuestaConsumidor(ctx context.Context, id string) {
+	var codigo, nombre, email, tipo, respuesta string
+	var aceptaCopia bool
+	var version int
+	var motivo *string
+	err := pool.QueryRow(ctx, `
+		SELECT r.codigo_reclamo, r.nombre_completo, r.email, r.tipo_solicitud, COALESCE(r.acepta_copia, true),
+		       res.respuesta_empresa, res.version, res.motivo_enmienda
+		FROM reclamos r
+		JOIN v_respuestas_vigentes res ON res.reclamo_id = r.id
+		WHERE r.id = $1
+	`, id).Scan(&codigo, &nombre, &email, &tipo, &aceptaCopia, &respuesta, &version, &motivo)
+	if err != nil {
+		log.Printf("⚠️ Error obteniendo respuesta de %s para notificar: %v", id, err)
+		return
+	}
+	if !aceptaCopia {
+		log.Printf("ℹ️ %s: el consumidor no acepta copias por email, respuesta sin aviso", codigo)
+		return
+	}
+
+	cuerpo := fmt.Sprintf(`<p style="margin: 0 0 12px 0; color: #1f2937;">Estimado/a %s,</p>
+<p style="margin: 0 0 16px 0;">Le informamos la respuesta a su %s <strong>%s</strong>:</p>
+<div style="margin: 0 0 12px 0; padding: 12px 15px; background-color: #f9fafb; border-left: 4px solid #059669; border-radius: 0 4px 4px 0;">
+<div style="white-space: pre-wrap; color: #1f2937; line-height: 1.6; word-break: break-word;">%s</div>
+</div>`, html.EscapeString(nombre), strings.ToLower(tipo), html.EscapeString(codigo), html.EscapeString(respuesta))
+
+	titulo := "📄 Respuesta a su " + strings.ToLower(tipo)
+	asunto := fmt.Sprintf("Respuesta a su %s %s", strings.ToLower(tipo), codigo)
+	if version > 1 {
+		titulo = "📄 Respuesta actualizada"
+		asunto = fmt.Sprintf("Respuesta actualizada a su %s %s", strings.ToLower(tipo), codigo)
+		if motivo != nil {
+			cuerpo += fmt.Sprintf(`<p style="margin: 0; color: #6b7280; font-size: 13px;">Esta respuesta reemplaza a la enviada anteriormente. Motivo: %s</p>`, html.EscapeString(*motivo))
+		}
+	}
+	contenido := generarEmailAviso(titulo, "#059669", cuerpo,
+		"Ver detalle", enlaceSeguimientoFirmado(codigo),
+		"Recibe este aviso porque aceptó copias por correo al registrar su reclamo.")
+	if err := enviarEmailHTMLReclamo(email, asunto, contenido, codigo); err != nil {
+		log.Printf("❌ Error enviando respuesta de %s al consumidor: %v", codigo, err)
+		return
+	}
+	log.Printf("✅ Respuesta de %s enviada al consumidor", codigo)
+}
+
+// -----------------------------------------------------------------------------
+// Reglas de aprobación
+// -----------------------------------------------------------------------------
+
+type reglaAprobacion struct {
+	ID            string   `json:"id"`
+	Nombre        string   `json:"nombre"`
+	TipoSolicitud *string  `json:"tipo_solicitud"`
+	MontoMin      *float64 `json:"monto_min"`
+	CategoriaID   *string  `json:"categoria_id"`
+}
+
+// aplica indica si la regla exige aprobación para el reclamo; una regla sin
+// criterios aplica a todos. ancestros incluye la categoría del reclamo.
+func (r reglaAprobacion) aplica(tipo string, monto float64, ancestros []string) bool {
+	if r.TipoSolicitud != nil && *r.TipoSolicitud != tipo {
+		return false
+	}
+	if r.MontoMin != nil && monto < *r.MontoMin {
+		return false
+	}
+	if r.CategoriaID != nil {
+		for _, a := range ancestros {
+			if a == *r.CategoriaID {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// reglaAprobacionAplicable devuelve el nombre de la primera regla activa que
+// exige aprobación para el reclamo, o "" si puede publicarse directamente.
+func reglaAprobacionAplicable(ctx context.Context, id string) (string, error) {
+	var tipo string
+	var monto float64
+	var ancestros []string
+	err := pool.QueryRow(ctx, `
+		SELECT r.tipo_solicitud, COALESCE(r.monto_reclamado, 0)::float8, COALESCE(cr.ancestros::text[], '{}')
+		FROM reclamos r
+		LEFT JOIN v_categorias_ruta cr ON cr.id = r.categoria_id
+		WHERE r.id = $1
+	`, id).Scan(&tipo, &monto, &ancestros)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", errReclamoNoEncontrado
+	}
+	if err != nil {
+		return "", err
+	}
+
+	rows, err := pool.Query(ctx, `
+		SELECT id, nombre, tipo_solicitud, monto_min::float8, categoria_id::text
+		FROM reglas_aprobacion WHERE activa ORDER BY nombre
+	`)
+	if err != nil {
+		return "", err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var r reglaAprobacion
+		if err := rows.Scan(&r.ID, &r.Nombre, &r.TipoSolicitud, &r.MontoMin, &r.CategoriaID); err != nil {
+			return "", err
+		}
+		if r.aplica(tipo, monto, ancestros) {
+			return r.Nombre, nil
+		}
+	}
+	return "", rows.Err()
+}
+
+type solicitudReglaAprobacion struct {
+	Nombre        string   `json:"nombre" binding:"required,max=100"`
+	TipoSolicitud *string  `json:"tipo_solicitud"`
+	MontoMin      *float64 `json:"monto_min"`
+	CategoriaID   *string  `json:"categoria_id"`
+	Activa        *bool    `json:"activa"`
+}
+
+func (s *solicitudReglaAprobacion) validar() error {
+	if s.TipoSolicitud != nil {
+		tipo := strings.ToUpper(*s.TipoSolicitud)
+		if !contieneTexto(tiposValidos, tipo) {
+			return errors.New("tipo_solicitud debe ser RECLAMO o QUEJA")
+		}
+		s.TipoSolicitud = &tipo
+	}
+	if s.MontoMin != nil && *s.MontoMin < 0 {
+		return errors.New("monto_min debe ser positivo")
+	}
+	if s.CategoriaID != nil && !reUUID.MatchString(*s.CategoriaID) {
+		return errors.New("categoria_id inválido")
+	}
+	if s.Activa == nil {
+		activa := true
+		s.Activa = &activa
+	}
+	return nil
+}
+
+// GET /api/admin/reglas-aprobacion - Reglas que exigen aprobación
+func listarReglasAprobacionHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	rows, err := pool.Query(ctx, `
+		SELECT ra.id, ra.nombre, ra.tipo_solicitud, ra.monto_min::float8, ra.categoria_id::text, cat.ruta, ra.activa, ra.fecha_creacion
+		FROM reglas_aprobacion ra
+		LEFT JOIN v_categorias_ruta cat ON cat.id = ra.categoria_id
+		ORDER BY ra.nombre
+	`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al obtener las reglas"})
+		return
+	}
+	defer rows.Close()
+
+	reglas := []gin.H{}
+	for rows.Next() {
+		var r reglaAprobacion
+		var categoria *string
+		var activa bool
+		var fecha time.Time
+		if err := rows.Scan(&r.ID, &r.Nombre, &r.TipoSolicitud, &r.MontoMin, &r.CategoriaID, &categoria, &activa, &fecha); err != nil {
+			continue
+		}
+		reglas = append(reglas, gin.H{
+			"id":             r.ID,
+			"nombre":         r.Nombre,
+			"tipo_solicitud": r.TipoSolicitud,
+			"monto_min":      r.MontoMin,
+			"categoria_id":   r.CategoriaID,
+			"categoria":      categoria,
+			"activa":         activa,
+			"fecha_creacion": fecha,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": reglas})
+}
+
+func registrarAuditoriaAprobacion(ctx context.Context, c *gin.Context, accion, entidad, id string, detalles interface{}) {
+	_, err := pool.Exec(ctx, `
+		INSERT INTO auditoria_admin (usuario_id, accion, entidad, entidad_id, detalles, ip_address)
+		VALUES ($1::uuid, $2, $3, $4, $5, $6)
+	`, fmt.Sprintf("%v", c.MustGet("user_id")), accion, entidad, id, detalles, c.ClientIP())
+	if err != nil {
+		log.Printf("⚠️ Error registrando auditoría: %v", err)
+	}
+}
+
+// POST /api/admin/reglas-aprobacion - Crear regla
+func crearReglaAprobacionHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var req solicitudReglaAprobacion
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Datos inválidos"})
+		return
+	}
+	if err := req.validar(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	var id string
+	err := pool.QueryRow(ctx, `
+		INSERT INTO reglas_aprobacion (nombre, tipo_solicitud, monto_min, categoria_id, activa, creado_por)
+		VALUES ($1, $2, $3, $4, $5, $6::uuid)
+		RETURNING id
+	`, strings.TrimSpace(req.Nombre), req.TipoSolicitud, req.MontoMin, req.CategoriaID, *req.Activa,
+		fmt.Sprintf("%v", c.MustGet("user_id"))).Scan(&id)
+	if err != nil {
+		log.Printf("❌ Error creando regla de aprobación: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al crear la regla"})
+		return
+	}
+
+	registrarAuditoriaAprobacion(ctx, c, "CREAR_REGLA_APROBACION", "REGLA_APROBACION", id, req)
+	c.JSON(http.StatusCreated, gin.H{"success": true, "message": "Regla creada", "data": gin.H{"id": id}})
+}
+
+// PUT /api/admin/reglas-aprobacion/:id - Modificar regla
+func actualizarReglaAprobacionHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var req solicitudReglaAprobacion
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Datos inválidos"})
+		return
+	}
+	if err := req.validar(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	id := c.Param("id")
+	tag, err := pool.Exec(ctx, `
+		UPDATE reglas_aprobacion SET nombre = $2, tipo_solicitud = $3, monto_min = $4, categoria_id = $5, activa = $6
+		WHERE id::text = $1
+	`, id, strings.TrimSpace(req.Nombre), req.TipoSolicitud, req.MontoMin, req.CategoriaID, *req.Activa)
+	if err != nil {
+		log.Printf("❌ Error actualizando regla de aprobación: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al actualizar la regla"})
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Regla no encontrada"})
+		return
+	}
+
+	registrarAuditoriaAprobacion(ctx, c, "EDITAR_REGLA_APROBACION", "REGLA_APROBACION", id, req)
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Regla actualizada"})
+}
+
+// DELETE /api/admin/reglas-aprobacion/:id - Eliminar regla
+func eliminarReglaAprobacionHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	id := c.Param("id")
+	tag, err := pool.Exec(ctx, "DELETE FROM reglas_aprobacion WHERE id::text = $1", id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al eliminar la regla"})
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Regla no encontrada"})
+		return
+	}
+
+	registrarAuditoriaAprobacion(ctx, c, "ELIMINAR_REGLA_APROBACION", "REGLA_APROBACION", id, gin.H{})
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Regla eliminada"})
+}
+
+// -----------------------------------------------------------------------------
+// Propuestas de respuesta
+// -----------------------------------------------------------------------------
+
+// puedeAprobarRespuestas: ADMIN o usuarios con el permiso puede_aprobar.
+func puedeAprobarRespuestas(ctx context.Context, userID, rol string) bool {
+	if rol == "ADMIN" {
+		return true
+	}
+	var puede bool
+	err := pool.QueryRow(ctx, "SELECT puede_aprobar FROM usuarios_admin WHERE id::text = $1 AND activo", userID).Scan(&puede)
+	return err == nil && puede
+}
+
+// proponerRespuestaEnTx deja la respuesta pendiente de aprobación. Con motivo
+// es una enmienda de la respuesta publicada. Solo puede haber una propuesta
+// pendiente por reclamo. El historial queda como RESPUESTA_PROPUESTA, que el
+// seguimiento público no muestra.
+func proponerRespuestaEnTx(ctx context.Context, tx pgx.Tx, id string, r datosRespuesta, motivo, autorID, autorEmail, regla string) (string, error) {
+	var estado string
+	err := tx.QueryRow(ctx, "SELECT estado FROM reclamos WHERE id = $1 FOR UPDATE", id).Scan(&estado)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", errReclamoNoEncontrado
+	}
+	if err != nil {
+		return "", err
+	}
+	if motivo != "" {
+		err = validarEnmienda(ctx, tx, id)
+	} else {
+		err = validarTransicion(estado, "RESUELTO", "ADMIN")
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var propuestaID string
+	err = tx.QueryRow(ctx, `
+		INSERT INTO propuestas_respuesta (reclamo_id, respuesta_empresa, accion_tomada, compensacion_ofrecida, motivo_enmienda, autor_id, autor_email, regla)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), $6::uuid, $7, $8)
+		ON CONFLICT DO NOTHING
+		RETURNING id
+	`, id, r.RespuestaEmpresa, nullString(&r.AccionTomada), nullString(&r.CompensacionOfrecida), motivo, autorID, autorEmail, regla).Scan(&propuestaID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", fmt.Errorf("%w: ya hay una respuesta pendiente de aprobación", errTransicionInvalida)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	comentario := "Respuesta propuesta, pendiente de aprobación (" + regla + ")"
+	if motivo != "" {
+		comentario = "Enmienda propuesta, pendiente de aprobación (" + regla + ")"
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO historial_reclamos (reclamo_id, estado_anterior, estado_nuevo, tipo_accion, comentario, usuario_accion)
+		VALUES ($1, $2, $2, 'RESPUESTA_PROPUESTA', $3, $4)
+	`, id, estado, comentario, autorEmail)
+	return propuestaID, err
+}
+
+// avisarRevisores avisa por email a quienes pueden aprobar, salvo al autor.
+func avisarRevisores(ctx context.Context, reclamoID, autorEmail, regla string) {
+	var codigo string
+	if err := pool.QueryRow(ctx, "SELECT codigo_reclamo FROM reclamos WHERE id = $1", reclamoID).Scan(&codigo); err != nil {
+		return
+	}
+	rows, err := pool.Query(ctx, `
+		SELECT email FROM usuarios_admin
+		WHERE activo AND (rol = 'ADMIN' OR puede_aprobar) AND LOWER(email) <> LOWER($1)
+	`, autorEmail)
+	if err != nil {
+		log.Printf("⚠️ Error obteniendo revisores: %v", err)
+		return
+	}
+	emails, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		log.Printf("⚠️ Error obteniendo revisores: %v", err)
+		return
+	}
+
+	cuerpo := fmt.Sprintf(`<p style="margin: 0 0 12px 0;"><strong>%s</strong> propuso la respuesta del reclamo <strong>%s</strong>.</p>
+<p style="margin: 0;">Requiere aprobación por la regla: %s.</p>`,
+		html.EscapeString(autorEmail), html.EscapeString(codigo), html.EscapeString(regla))
+	contenido := generarEmailAviso("✍️ Respuesta pendiente de aprobación", "#2563eb", cuerpo,
+		"Revisar respuesta", config.FrontendURL+"/admin/reclamos/"+reclamoID,
+		"Recibe este aviso porque puede aprobar respuestas en el Libro de Reclamaciones.")
+	for _, email := range emails {
+		if err := enviarEmailHTML(email, "✍️ Aprobar respuesta de "+codigo, contenido); err != nil {
+			log.Printf("❌ Error avisando a revisor %s: %v", email, err)
+		}
+	}
+}
+
+const sqlColumnasPropuesta = `p.id, p.reclamo_id, r.codigo_reclamo, r.tipo_solicitud, COALESCE(r.monto_reclamado, 0)::float8,
+	p.respuesta_empresa, COALESCE(p.accion_tomada, ''), COALESCE(p.compensacion_ofrecida, ''), p.motivo_enmienda,
+	p.estado, p.regla, p.autor_email, p.revisor_email, p.comentario_revision, p.editada_por_revisor,
+	p.fecha_creacion, p.fecha_revision`
+
+func escanearPropuesta(row pgx.Row) (gin.H, error) {
+	var id, reclamoID, codigo, tipo, estado, regla, autor string
+	var monto float64
+	var d datosRespuesta
+	var motivo, revisor, comentario *string
+	var editada bool
+	var creacion time.Time
+	var revision *time.Time
+	err := row.Scan(&id, &reclamoID, &codigo, &tipo, &monto, &d.RespuestaEmpresa, &d.AccionTomada, &d.CompensacionOfrecida, &motivo,
+		&estado, &regla, &autor, &revisor, &comentario, &editada, &creacion, &revision)
+	if err != nil {
+		return nil, err
+	}
+	return gin.H{
+		"id":                    id,
+		"reclamo_id":            reclamoID,
+		"codigo_reclamo":        codigo,
+		"tipo_solicitud":        tipo,
+		"monto_reclamado":       monto,
+		"respuesta_empresa":     d.RespuestaEmpresa,
+		"accion_tomada":         d.AccionTomada,
+		"compensacion_ofrecida": d.CompensacionOfrecida,
+		"enmienda":              motivo != nil,
+		"motivo_enmienda":       motivo,
+		"estado":                estado,
+		"regla":                 regla,
+		"autor_email":           autor,
+		"revisor_email":         revisor,
+		"comentario_revision":   comentario,
+		"editada_por_revisor":   editada,
+		"fecha_creacion":        creacion,
+		"fecha_revision":        revision,
+	}, nil
+}
+
+func listarPropuestas(c *gin.Context, where string, args ...interface{}) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	rows, err := pool.Query(ctx, `
+		SELECT `+sqlColumnasPropuesta+`
+		FROM propuestas_respuesta p
+		JOIN reclamos r ON r.id = p.reclamo_id
+		WHERE `+where+`
+		ORDER BY p.fecha_creacion DESC
+		LIMIT 200
+	`, args...)
+	if err != nil {
+		log.Printf("❌ Error listando propuestas de respuesta: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al obtener las propuestas"})
+		return
+	}
+	defer rows.Close()
+
+	propuestas := []gin.H{}
+	for rows.Next() {
+		if p, err := escanearPropuesta(rows); err == nil {
+			propuestas = append(propuestas, p)
+		}
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": propuestas})
+}
+
+// GET /api/admin/propuestas-respuesta - Bandeja de aprobación (?estado=PENDIENTE por defecto)
+func listarPropuestasHandler(c *gin.Context) {
+	estado := strings.ToUpper(c.DefaultQuery("estado", "PENDIENTE"))
+	if !contieneTexto([]string{"PENDIENTE", "APROBADA", "RECHAZADA"}, estado) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "estado debe ser PENDIENTE, APROBADA o RECHAZADA"})
+		return
+	}
+	listarPropuestas(c, "p.estado = $1", estado)
+}
+
+// GET /api/admin/reclamos/:id/propuestas - Propuestas de respuesta del reclamo
+func listarPropuestasReclamoHandler(c *gin.Context) {
+	listarPropuestas(c, "p.reclamo_id::text = $1", c.Param("id"))
+}
+
+// propuestaPendiente es la propuesta tomada para revisarla o editarla.
+// Motivo no es vacío si es una enmienda; EditadaPor es quien escribió el
+// texto actual si no fue el autor.
+type propuestaPendiente struct {
+	ReclamoID  string
+	AutorID    string
+	AutorEmail string
+	Motivo     string
+	EditadaPor string
+	Datos      datosRespuesta
+}
+
+// bloquearPropuesta toma la propuesta pendiente con FOR UPDATE.
+func bloquearPropuesta(ctx context.Context, tx pgx.Tx, id string) (propuestaPendiente, error) {
+	var p propuestaPendiente
+	var estado string
+	err := tx.QueryRow(ctx, `
+		SELECT reclamo_id, COALESCE(autor_id::text, ''), autor_email, COALESCE(motivo_enmienda, ''), COALESCE(editada_por::text, ''), estado,
+		       respuesta_empresa, COALESCE(accion_tomada, ''), COALESCE(compensacion_ofrecida, '')
+		FROM propuestas_respuesta WHERE id::text = $1 FOR UPDATE
+	`, id).Scan(&p.ReclamoID, &p.AutorID, &p.AutorEmail, &p.Motivo, &p.EditadaPor, &estado,
+		&p.Datos.RespuestaEmpresa, &p.Datos.AccionTomada, &p.Datos.CompensacionOfrecida)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return p, errReclamoNoEncontrado
+	}
+	if err == nil && estado != "PENDIENTE" {
+		err = errPropuestaNoPendiente
+	}
+	return p, err
+}
+
+// guardarEdicionEnTx reemplaza el texto de la propuesta y registra quién lo
+// escribió: esa persona ya no puede aprobarlo.
+func guardarEdicionEnTx(ctx context.Context, tx pgx.Tx, id string, d datosRespuesta, userID string, porRevisor bool) error {
+	_, err := tx.Exec(ctx, `
+		UPDATE propuestas_respuesta
+		SET respuesta_empresa = $2, accion_tomada = NULLIF($3, ''), compensacion_ofrecida = NULLIF($4, ''),
+		    editada_por_revisor = editada_por_revisor OR $5, editada_por = $6::uuid
+		WHERE id::text = $1
+	`, id, d.RespuestaEmpresa, d.AccionTomada, d.CompensacionOfrecida, porRevisor, userID)
+	return err
+}
+
+// aplicarEdicion reemplaza los campos indicados; devuelve si hubo cambios.
+func aplicarEdicion(d *datosRespuesta, e *datosRespuesta) bool {
+	if e == nil {
+		return false
+	}
+	cambio := false
+	if e.RespuestaEmpresa != "" && e.RespuestaEmpresa != d.RespuestaEmpresa {
+		d.RespuestaEmpresa, cambio = e.RespuestaEmpresa, true
+	}
+	if e.AccionTomada != "" && e.AccionTomada != d.AccionTomada {
+		d.AccionTomada, cambio = e.AccionTomada, true
+	}
+	if e.CompensacionOfrecida != "" && e.CompensacionOfrecida != d.CompensacionOfrecida {
+		d.CompensacionOfrecida, cambio = e.CompensacionOfrecida, true
+	}
+	return cambio
+}
+
+// responderErrorRespuesta traduce los errores de publicar o revisar una
+// respuesta; noEncontrado es el mensaje del 404.
+func responderErrorRespuesta(c *gin.Context, id, noEncontrado string, err error) {
+	switch {
+	case errors.Is(err, errReclamoNoEncontrado):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": noEncontrado})
+	case errors.Is(err, errSinPermiso):
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": err.Error()})
+	case errors.Is(err, errPropuestaNoPendiente), errors.Is(err, errTransicionInvalida):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "message": err.Error()})
+	default:
+		log.Printf("❌ Error procesando respuesta de %s: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error guardando respuesta"})
+	}
+}
+
+// PUT /api/admin/propuestas-respuesta/:id - Editar una propuesta pendiente (autor o revisor)
+func editarPropuestaHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var req datosRespuesta
+	if err := c.ShouldBindJSON(&req); err != nil || (req.RespuestaEmpresa != "" && len(req.RespuestaEmpresa) < 10) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Datos inválidos"})
+		return
+	}
+	id := c.Param("id")
+	userID := fmt.Sprintf("%v", c.MustGet("user_id"))
+	rol := fmt.Sprintf("%v", c.MustGet("rol"))
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		responderErrorRespuesta(c, id, "Propuesta no encontrada", err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	p, err := bloquearPropuesta(ctx, tx, id)
+	esAutor := p.AutorID == userID
+	if err == nil && !esAutor && !puedeAprobarRespuestas(ctx, userID, rol) {
+		err = fmt.Errorf("%w: solo el autor o un revisor pueden editar la propuesta", errSinPermiso)
+	}
+	d, anterior := p.Datos, p.Datos
+	if err == nil && !aplicarEdicion(&d, &req) {
+		err = fmt.Errorf("%w: no hay cambios", errTransicionInvalida)
+	}
+	if err == nil {
+		err = guardarEdicionEnTx(ctx, tx, id, d, userID, !esAutor)
+	}
+	if err == nil {
+		err = tx.Commit(ctx)
+	}
+	if err != nil {
+		responderErrorRespuesta(c, id, "Propuesta no encontrada", err)
+		return
+	}
+
+	registrarAuditoriaAprobacion(ctx, c, "EDITAR_PROPUESTA_RESPUESTA", "PROPUESTA_RESPUESTA", id, gin.H{"anterior": anterior, "nueva": d})
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Propuesta actualizada"})
+}
+
+// POST /api/admin/propuestas-respuesta/:id/aprobar - Aprobar (con edición opcional) y publicar
+func aprobarPropuestaHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var req struct {
+		Edicion    *datosRespuesta `json:"edicion"`
+		Comentario string          `json:"comentario"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Edicion != nil && req.Edicion.RespuestaEmpresa != "" && len(req.Edicion.RespuestaEmpresa) < 10) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Datos inválidos"})
+		return
+	}
+	id := c.Param("id")
+	userID := fmt.Sprintf("%v", c.MustGet("user_id"))
+	email := fmt.Sprintf("%v", c.MustGet("email"))
+	rol := fmt.Sprintf("%v", c.MustGet("rol"))
+
+	if !puedeAprobarRespuestas(ctx, userID, rol) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "No tiene permiso para aprobar respuestas"})
+		return
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		responderErrorRespuesta(c, id, "Propuesta no encontrada", err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	var estadoAnterior string
+	var version int
+	p, err := bloquearPropuesta(ctx, tx, id)
+	reclamoID, autorEmail, motivo, d := p.ReclamoID, p.AutorEmail, p.Motivo, p.Datos
+	switch {
+	case err != nil:
+	case p.AutorID == userID:
+		err = fmt.Errorf("%w: no puede aprobar su propia respuesta", errSinPermiso)
+	case p.EditadaPor == userID:
+		err = fmt.Errorf("%w: editó esta propuesta, debe aprobarla otro revisor", errSinPermiso)
+	}
+
+	// Cuatro ojos: quien cambia el texto no puede aprobarlo. Una edición al
+	// aprobar se guarda y la propuesta sigue pendiente de otro revisor.
+	if anterior := d; err == nil && aplicarEdicion(&d, req.Edicion) {
+		err = guardarEdicionEnTx(ctx, tx, id, d, userID, true)
+		if err == nil {
+			err = tx.Commit(ctx)
+		}
+		if err != nil {
+			responderErrorRespuesta(c, id, "Propuesta no encontrada", err)
+			return
+		}
+		registrarAuditoriaAprobacion(ctx, c, "EDITAR_PROPUESTA_RESPUESTA", "PROPUESTA_RESPUESTA", id, gin.H{"anterior": anterior, "nueva": d})
+		c.JSON(http.StatusAccepted, gin.H{
+			"success": true,
+			"message": "Propuesta editada; debe aprobarla otro revisor",
+			"data":    gin.H{"estado": "PENDIENTE"},
+		})
+		return
+	}
+
+	if err == nil {
+		if motivo != "" {
+			version, err = enmendarRespuestaEnTx(ctx, tx, reclamoID, d, motivo, autorEmail, email)
+		} else {
+			estadoAnterior, err = publicarRespuestaEnTx(ctx, tx, reclamoID, d, p.AutorID, autorEmail, email)
+		}
+	}
+	if err == nil {
+		_, err = tx.Exec(ctx, `
+			UPDATE propuestas_respuesta
+			SET estado = 'APROBADA', revisor_id = $2::uuid, revisor_email = $3, comentario_revision = NULLIF($4, ''), fecha_revision = NOW()
+			WHERE id::text = $1
+		`, id, userID, email, strings.TrimSpace(req.Comentario))
+	}
+	if err == nil {
+		err = tx.Commit(ctx)
+	}
+	if err != nil {
+		responderErrorRespuesta(c, id, "Propuesta no encontrada", err)
+		return
+	}
+
+	if motivo != "" {
+		respuestaEnmendada(reclamoID, version)
+	} else {
+		respuestaPublicada(ctx, reclamoID, estadoAnterior, email)
+	}
+	registrarAuditoriaAprobacion(ctx, c, "APROBAR_RESPUESTA", "RECLAMO", reclamoID, gin.H{"propuesta_id": id, "autor": autorEmail, "editada_por": p.EditadaPor, "enmienda": motivo != ""})
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Respuesta aprobada y enviada al consumidor"})
+}
+
+// POST /api/admin/propuestas-respuesta/:id/rechazar - Devolver al autor con un comentario
+func rechazarPropuestaHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var req struct {
+		Comentario string `json:"comentario" binding:"required,min=5,max=2000"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Indique el motivo del rechazo (mín. 5 caracteres)"})
+		return
+	}
+	id := c.Param("id")
+	userID := fmt.Sprintf("%v", c.MustGet("user_id"))
+	email := fmt.Sprintf("%v", c.MustGet("email"))
+	rol := fmt.Sprintf("%v", c.MustGet("rol"))
+
+	if !puedeAprobarRespuestas(ctx, userID, rol) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "No tiene permiso para revisar respuestas"})
+		return
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		responderErrorRespuesta(c, id, "Propuesta no encontrada", err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	p, err := bloquearPropuesta(ctx, tx, id)
+	reclamoID, autorEmail := p.ReclamoID, p.AutorEmail
+	if err == nil && p.AutorID == userID {
+		err = fmt.Errorf("%w: no puede revisar su propia respuesta", errSinPermiso)
+	}
+	if err == nil {
+		_, err = tx.Exec(ctx, `
+			UPDATE propuestas_respuesta
+			SET estado = 'RECHAZADA', revisor_id = $2::uuid, revisor_email = $3, comentario_revision = $4, fecha_revision = NOW()
+			WHERE id::text = $1
+		`, id, userID, email, strings.TrimSpace(req.Comentario))
+	}
+	if err == nil {
+		_, err = tx.Exec(ctx, `
+			INSERT INTO historial_reclamos (reclamo_id, estado_anterior, estado_nuevo, tipo_accion, comentario, usuario_accion)
+			SELECT id, estado, estado, 'RESPUESTA_RECHAZADA', $2, $3 FROM reclamos WHERE id = $1
+		`, reclamoID, "Respuesta devuelta al autor: "+strings.TrimSpace(req.Comentario), email)
+	}
+	if err == nil {
+		err = tx.Commit(ctx)
+	}
+	if err != nil {
+		responderErrorRespuesta(c, id, "Propuesta no encontrada", err)
+		return
+	}
+
+	emailsEnCurso.Add(1)
+	go func() {
+		defer emailsEnCurso.Done()
+		cuerpo := fmt.Sprintf(`<p style="margin: 0 0 12px 0;">%s devolvió su respuesta propuesta con este comentario:</p>
+<div style="white-space: pre-wrap; padding: 12px 15px; background-color: #f9fafb; border-left: 4px solid #dc2626;">%s</div>`,
+			html.EscapeString(email), html.EscapeString(strings.TrimSpace(req.Comentario)))
+		contenido := generarEmailAviso("↩️ Respuesta devuelta", "#dc2626", cuerpo,
+			"Ver reclamo", config.FrontendURL+"/admin/reclamos/"+reclamoID,
+			"Puede corregir la respuesta y enviarla de nuevo a aprobación.")
+		if err := enviarEmailHTML(autorEmail, "↩️ Respuesta devuelta para corregir", contenido); err != nil {
+			log.Printf("❌ Error avisando rechazo a %s: %v", autorEmail, err)
+		}
+	}()
+
+	publicarEventoInterno(reclamoID, eventoEstado, gin.H{"propuesta_rechazada": id})
+	registrarAuditoriaAprobacion(ctx, c, "RECHAZAR_RESPUESTA", "RECLAMO", reclamoID, gin.H{"propuesta_id": id, "autor": autorEmail, "comentario": strings.TrimSpace(req.Comentario)})
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Respuesta devuelta al autor"})
+}
@@ -0,0 +1,311 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"unicode"
+)
+
+// =============================================================================
+// BÚSQUEDA DE TEXTO COMPLETO
+// En PostgreSQL se usa tsvector con la configuración es_unaccent (stemming en
+// español sin tildes) e índices GIN por expresión (ver schema.sql). CockroachDB
+// no tiene unaccent ni ts_headline: ahí se busca cada término con ILIKE y el
+// fragmento se resalta en Go.
+// =============================================================================
+
+var busquedaTextoCompleto bool
+
+// detectarBusquedaTextoCompleto decide el modo según BUSQUEDA_BACKEND
+// (auto, postgres o basica); en auto se usa texto completo si existe la
+// configuración es_unaccent creada por schema.sql.
+func detectarBusquedaTextoCompleto(ctx context.Context) bool {
+	switch config.BusquedaBackend {
+	case "postgres":
+		return true
+	case "basica":
+		return false
+	}
+
+	var existe bool
+	err := pool.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM pg_ts_config WHERE cfgname = 'es_unaccent')").Scan(&existe)
+	if err != nil || !existe {
+		log.Println("ℹ️ Búsqueda básica (ILIKE): no se encontró la configuración es_unaccent")
+		return false
+	}
+	log.Println("✅ Búsqueda de texto completo: PostgreSQL es_unaccent")
+	return true
+}
+
+// Las expresiones deben coincidir con los índices GIN de schema.sql
+const (
+	sqlDocumentoReclamo = `(setweight(to_tsvector('es_unaccent', coalesce(r.codigo_reclamo, '') || ' ' || coalesce(r.nombre_completo, '')), 'A') || ` +
+		`setweight(to_tsvector('es_unaccent', coalesce(r.descripcion_bien, '') || ' ' || coalesce(r.detalle_reclamo, '') || ' ' || coalesce(r.pedido_consumidor, '')), 'B'))`
+	sqlDocumentoRespuesta = `to_tsvector('es_unaccent', coalesce(res.respuesta_empresa, '') || ' ' || coalesce(res.accion_tomada, '') || ' ' || coalesce(res.compensacion_ofrecida, ''))`
+	sqlDocumentoMensaje   = `to_tsvector('es_unaccent', m.mensaje)`
+
+	sqlTextoReclamo = `coalesce(r.detalle_reclamo, '') || ' — ' || coalesce(r.pedido_consumidor, '')`
+
+	opcionesFragmento = `StartSel=` + marcaInicio + `, StopSel=` + marcaFin + `, MaxWords=30, MinWords=12, MaxFragments=2, FragmentDelimiter=" … "`
+)
+
+// Marcadores de resaltado: no pueden venir en el texto del usuario y
+// sobreviven al escape HTML
+const (
+	marcaInicio = "\x01"
+	marcaFin    = "\x02"
+)
+
+// consultaBusqueda son las piezas SQL que la búsqueda agrega al listado. El
+// JOIN introduce los parámetros una sola vez (alias b) y el resto de la
+// consulta los referencia por nombre.
+type consultaBusqueda struct {
+	Join       string
+	Where      string
+	Relevancia string // expresión, para ordenar y paginar por cursor
+	Columnas   string // relevancia, fragmento, coincide_en
+	Terminos   []string
+	Resaltado  bool // el fragmento ya viene resaltado desde la BD
+}
+
+// Columnas neutras cuando no hay búsqueda, para no cambiar el Scan
+const columnasSinBusqueda = `0::float8 AS relevancia, NULL::text AS fragmento, NULL::text AS coincide_en`
+
+// terminosBusqueda separa la búsqueda en términos para el modo básico: sin
+// comillas ni operadores y con un máximo de 5 términos.
+func terminosBusqueda(texto string) []string {
+	var terminos []string
+	for _, t := range strings.Fields(texto) {
+		t = strings.Trim(t, `"'()+-*:&|!`)
+		if len([]rune(t)) < 2 {
+			continue
+		}
+		terminos = append(terminos, t)
+		if len(terminos) == 5 {
+			break
+		}
+	}
+	return terminos
+}
+
+// escaparLike evita que % y _ del usuario actúen como comodines.
+func escaparLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// construirBusqueda arma la búsqueda; arg registra un parámetro y devuelve su
+// placeholder ($N).
+func construirBusqueda(texto string, arg func(interface{}) string) consultaBusqueda {
+	if busquedaTextoCompleto {
+		return construirBusquedaTextoCompleto(texto, arg)
+	}
+	return construirBusquedaBasica(texto, arg)
+}
+
+func construirBusquedaTextoCompleto(texto string, arg func(interface{}) string) consultaBusqueda {
+	join := fmt.Sprintf(" CROSS JOIN (SELECT websearch_to_tsquery('es_unaccent', %s) AS q, %s::text AS patron) b",
+		arg(texto), arg("%"+escaparLike(strings.TrimSpace(texto))+"%"))
+
+	coincideRespuesta := `EXISTS (SELECT 1 FROM v_respuestas_vigentes res WHERE res.reclamo_id = r.id AND ` + sqlDocumentoRespuesta + ` @@ b.q)`
+	coincideMensaje := `EXISTS (SELECT 1 FROM mensajes_seguimiento m WHERE m.reclamo_id = r.id AND ` + sqlDocumentoMensaje + ` @@ b.q)`
+	coincideIdentificador := `(r.codigo_reclamo ILIKE b.patron OR r.email ILIKE b.patron)`
+
+	where := fmt.Sprintf(" AND (%s @@ b.q OR %s OR %s OR %s)", sqlDocumentoReclamo, coincideIdentificador, coincideRespuesta, coincideMensaje)
+
+	relevancia := `(ts_rank(` + sqlDocumentoReclamo + `, b.q)
+		+ 0.8 * COALESCE((SELECT MAX(ts_rank(` + sqlDocumentoRespuesta + `, b.q)) FROM v_respuestas_vigentes res WHERE res.reclamo_id = r.id), 0)
+		+ 0.5 * COALESCE((SELECT MAX(ts_rank(` + sqlDocumentoMensaje + `, b.q)) FROM mensajes_seguimiento m WHERE m.reclamo_id = r.id), 0)
+		+ CASE WHEN ` + coincideIdentificador + ` THEN 1 ELSE 0 END)::float8`
+
+	fragmento := `CASE
+			WHEN ` + sqlDocumentoReclamo + ` @@ b.q THEN ts_headline('es_unaccent', ` + sqlTextoReclamo + `, b.q, '` + opcionesFragmento + `')
+			WHEN ` + coincideRespuesta + ` THEN (SELECT ts_headline('es_unaccent', res.respuesta_empresa, b.q, '` + opcionesFragmento + `')
+				FROM v_respuestas_vigentes res WHERE res.reclamo_id = r.id AND ` + sqlDocumentoRespuesta + ` @@ b.q
+				ORDER BY res.fecha_respuesta DESC LIMIT 1)
+			WHEN ` + coincideMensaje + ` THEN (SELECT ts_headline('es_unaccent', m.mensaje, b.q, '` + opcionesFragmento + `')
+				FROM mensajes_seguimiento m WHERE m.reclamo_id = r.id AND ` + sqlDocumentoMensaje + ` @@ b.q
+				ORDER BY m.fecha_mensaje DESC LIMIT 1)
+			ELSE left(` + sqlTextoReclamo + `, 200)
+		END`
+
+	coincideEn := `CASE
+			WHEN ` + sqlDocumentoReclamo + ` @@ b.q OR ` + coincideIdentificador + ` THEN 'reclamo'
+			WHEN ` + coincideRespuesta + ` THEN 'respuesta'
+			ELSE 'mensaje'
+		END`
+
+	return consultaBusqueda{
+		Join:       join,
+		Where:      where,
+		Relevancia: relevancia,
+		Columnas:   relevancia + ` AS relevancia, ` + fragmento + ` AS fragmento, ` + coincideEn + ` AS coincide_en`,
+		Resaltado:  true,
+	}
+}
+
+func construirBusquedaBasica(texto string, arg func(interface{}) string) consultaBusqueda {
+	terminos := terminosBusqueda(texto)
+	if len(terminos) == 0 {
+		terminos = []string{strings.TrimSpace(texto)}
+	}
+
+	var campos, condiciones, puntajes []string
+	for i, t := range terminos {
+		campos = append(campos, fmt.Sprintf("%s::text AS t%d", arg("%"+escaparLike(t)+"%"), i))
+		p := fmt.Sprintf("b.t%d", i)
+		condiciones = append(condiciones, fmt.Sprintf(`(r.codigo_reclamo ILIKE %[1]s OR r.nombre_completo ILIKE %[1]s OR r.email ILIKE %[1]s
+			OR r.descripcion_bien ILIKE %[1]s OR r.detalle_reclamo ILIKE %[1]s OR r.pedido_consumidor ILIKE %[1]s
+			OR EXISTS (SELECT 1 FROM v_respuestas_vigentes res WHERE res.reclamo_id = r.id AND res.respuesta_empresa ILIKE %[1]s)
+			OR EXISTS (SELECT 1 FROM mensajes_seguimiento m WHERE m.reclamo_id = r.id AND m.mensaje ILIKE %[1]s))`, p))
+		puntajes = append(puntajes, fmt.Sprintf(`CASE WHEN r.codigo_reclamo ILIKE %[1]s OR r.nombre_completo ILIKE %[1]s OR r.email ILIKE %[1]s THEN 3 ELSE 0 END
+			+ CASE WHEN r.detalle_reclamo ILIKE %[1]s OR r.pedido_consumidor ILIKE %[1]s OR r.descripcion_bien ILIKE %[1]s THEN 2 ELSE 0 END
+			+ CASE WHEN EXISTS (SELECT 1 FROM v_respuestas_vigentes res WHERE res.reclamo_id = r.id AND res.respuesta_empresa ILIKE %[1]s) THEN 1.5 ELSE 0 END
+			+ CASE WHEN EXISTS (SELECT 1 FROM mensajes_seguimiento m WHERE m.reclamo_id = r.id AND m.mensaje ILIKE %[1]s) THEN 1 ELSE 0 END`, p))
+	}
+
+	// El fragmento sale del primer texto que contiene el primer término
+	fragmento := `CASE
+			WHEN ` + sqlTextoReclamo + ` ILIKE b.t0 THEN ` + sqlTextoReclamo + `
+			WHEN EXISTS (SELECT 1 FROM v_respuestas_vigentes res WHERE res.reclamo_id = r.id AND res.respuesta_empresa ILIKE b.t0)
+				THEN (SELECT res.respuesta_empresa FROM v_respuestas_vigentes res WHERE res.reclamo_id = r.id AND res.respuesta_empresa ILIKE b.t0 ORDER BY res.fecha_respuesta DESC LIMIT 1)
+			WHEN EXISTS (SELECT 1 FROM mensajes_seguimiento m WHERE m.reclamo_id = r.id AND m.mensaje ILIKE b.t0)
+				THEN (SELECT m.mensaje FROM mensajes_seguimiento m WHERE m.reclamo_id = r.id AND m.mensaje ILIKE b.t0 ORDER BY m.fecha_mensaje DESC LIMIT 1)
+			ELSE ` + sqlTextoReclamo + `
+		END`
+	coincideEn := `CASE
+			WHEN r.codigo_reclamo ILIKE b.t0 OR r.nombre_completo ILIKE b.t0 OR r.email ILIKE b.t0
+				OR r.descripcion_bien ILIKE b.t0 OR ` + sqlTextoReclamo + ` ILIKE b.t0 THEN 'reclamo'
+			WHEN EXISTS (SELECT 1 FROM v_respuestas_vigentes res WHERE res.reclamo_id = r.id AND res.respuesta_empresa ILIKE b.t0) THEN 'respuesta'
+			ELSE 'mensaje'
+		END`
+
+	relevancia := "(" + strings.Join(puntajes, " + ") + ")::float8"
+	return consultaBusqueda{
+		Join:       " CROSS JOIN (SELECT " + strings.Join(campos, ", ") + ") b",
+		Where:      " AND " + strings.Join(condiciones, " AND "),
+		Relevancia: relevancia,
+		Columnas:   relevancia + " AS relevancia, " + fragmento + " AS fragmento, " + coincideEn + " AS coincide_en",
+		Terminos:   terminos,
+	}
+}
+
+// =============================================================================
+// FRAGMENTOS RESALTADOS
+// =============================================================================
+
+// plegar normaliza una runa para comparar sin mayúsculas ni tildes,
+// manteniendo una runa por runa para que los índices coincidan.
+func plegar(r rune) rune {
+	r = unicode.ToLower(r)
+	switch r {
+	case 'á', 'à', 'ä', 'â':
+		return 'a'
+	case 'é', 'è', 'ë', 'ê':
+		return 'e'
+	case 'í', 'ì', 'ï', 'î':
+		return 'i'
+	case 'ó', 'ò', 'ö', 'ô':
+		return 'o'
+	case 'ú', 'ù', 'ü', 'û':
+		return 'u'
+	case 'ñ':
+		return 'n'
+	}
+	return r
+}
+
+func plegarTexto(runas []rune) []rune {
+	plegado := make([]rune, len(runas))
+	for i, r := range runas {
+		plegado[i] = plegar(r)
+	}
+	return plegado
+}
+
+func indiceRunas(texto, patron []rune, desde int) int {
+	for i := desde; i+len(patron) <= len(texto); i++ {
+		coincide := true
+		for j := range patron {
+			if texto[i+j] != patron[j] {
+				coincide = false
+				break
+			}
+		}
+		if coincide {
+			return i
+		}
+	}
+	return -1
+}
+
+// resaltarTerminos recorta el texto alrededor de la primera coincidencia
+// (hasta maximo runas) y marca cada término encontrado en el recorte.
+func resaltarTerminos(texto string, terminos []string, maximo int) string {
+	runas := []rune(strings.Join(strings.Fields(texto), " "))
+	plegado := plegarTexto(runas)
+
+	var patrones [][]rune
+	primera := -1
+	for _, t := range terminos {
+		p := plegarTexto([]rune(t))
+		if len(p) == 0 {
+			continue
+		}
+		patrones = append(patrones, p)
+		if i := indiceRunas(plegado, p, 0); i >= 0 && (primera < 0 || i < primera) {
+			primera = i
+		}
+	}
+
+	inicio, fin := 0, len(runas)
+	if fin > maximo {
+		if primera > maximo/3 {
+			inicio = primera - maximo/3
+		}
+		fin = inicio + maximo
+		if fin > len(runas) {
+			fin, inicio = len(runas), len(runas)-maximo
+		}
+	}
+
+	marcas := make([]int, fin-inicio+1) // +1 abre, -1 cierra, por posición
+	for _, p := range patrones {
+		for i := indiceRunas(plegado[:fin], p, inicio); i >= 0; i = indiceRunas(plegado[:fin], p, i+len(p)) {
+			marcas[i-inicio]++
+			marcas[i-inicio+len(p)]--
+		}
+	}
+
+	var b strings.Builder
+	if inicio > 0 {
+		b.WriteString("… ")
+	}
+	abiertas := 0
+	for i := inicio; i <= fin; i++ {
+		if m := marcas[i-inicio]; m != 0 {
+			antes := abiertas
+			abiertas += m
+			if antes > 0 && abiertas <= 0 {
+				b.WriteString(marcaFin)
+			} else if antes <= 0 && abiertas > 0 {
+				b.WriteString(marcaInicio)
+			}
+		}
+		if i < fin {
+			b.WriteRune(runas[i])
+		}
+	}
+	if fin < len(runas) {
+		b.WriteString(" …")
+	}
+	return b.String()
+}
+
+// fragmentoHTML escapa el fragmento y convierte los marcadores en <mark>.
+func fragmentoHTML(fragmento string) string {
+	escapado := html.EscapeString(fragmento)
+	return strings.NewReplacer(marcaInicio, "<mark>", marcaFin, "</mark>").Replace(escapado)
+}
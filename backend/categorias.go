@@ -0,0 +1,619 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+// =============================================================================
+// CATEGORÍAS Y ETIQUETAS
+// Las categorías forman un árbol administrado por ADMIN (hasta tres niveles) y
+// cada reclamo tiene a lo sumo una. Las etiquetas son libres y múltiples. La
+// vista v_categorias_ruta resuelve la ruta, la raíz y los ancestros de cada
+// categoría para los filtros y los desgloses.
+// =============================================================================
+
+const maxNivelCategoria = 3
+
+type categoriaNodo struct {
+	ID          string
+	PadreID     *string
+	Nombre      string
+	Descripcion *string
+	Nivel       int
+	Ruta        string
+	Activa      bool
+	Reclamos    int64
+}
+
+func (n categoriaNodo) json() gin.H {
+	return gin.H{
+		"id":          n.ID,
+		"padre_id":    n.PadreID,
+		"nombre":      n.Nombre,
+		"descripcion": n.Descripcion,
+		"nivel":       n.Nivel,
+		"ruta":        n.Ruta,
+		"activa":      n.Activa,
+		"reclamos":    n.Reclamos,
+	}
+}
+
+// construirArbolCategorias anida la lista plana (ordenada por ruta). Un nodo
+// cuyo padre no está en la lista queda en la raíz.
+func construirArbolCategorias(nodos []categoriaNodo) []gin.H {
+	porID := map[string]gin.H{}
+	for _, n := range nodos {
+		item := n.json()
+		item["hijos"] = []gin.H{}
+		porID[n.ID] = item
+	}
+
+	arbol := []gin.H{}
+	// Se recorre de abajo hacia arriba para anidar hijos antes que padres
+	for i := len(nodos) - 1; i >= 0; i-- {
+		n := nodos[i]
+		item := porID[n.ID]
+		if n.PadreID != nil {
+			if padre, ok := porID[*n.PadreID]; ok {
+				padre["hijos"] = append([]gin.H{item}, padre["hijos"].([]gin.H)...)
+				continue
+			}
+		}
+		arbol = append([]gin.H{item}, arbol...)
+	}
+	return arbol
+}
+
+func normalizarNombreCategoria(nombre string) (string, error) {
+	nombre = strings.Join(strings.Fields(nombre), " ")
+	if nombre == "" || len([]rune(nombre)) > 100 {
+		return "", errors.New("el nombre es obligatorio (máx 100 caracteres)")
+	}
+	if strings.Contains(nombre, ">") {
+		return "", errors.New("el nombre no puede contener '>'")
+	}
+	return nombre, nil
+}
+
+// validarPadreCategoria comprueba que el padre exista, esté activo, no sea la
+// propia categoría ni una descendiente y que el subárbol no supere el máximo
+// de niveles. id vacío al crear.
+func validarPadreCategoria(ctx context.Context, id string, padreID *string) error {
+	if padreID == nil {
+		return nil
+	}
+	if !reUUID.MatchString(*padreID) {
+		return errors.New("padre_id inválido")
+	}
+
+	var nivel int
+	var activa bool
+	var ancestros []string
+	err := pool.QueryRow(ctx, "SELECT nivel, activa, ancestros::text[] FROM v_categorias_ruta WHERE id = $1", *padreID).
+		Scan(&nivel, &activa, &ancestros)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return errors.New("la categoría padre no existe")
+	}
+	if err != nil {
+		return err
+	}
+	if !activa {
+		return errors.New("la categoría padre está inactiva")
+	}
+
+	altura := 1
+	if id != "" {
+		for _, a := range ancestros {
+			if a == id {
+				return errors.New("una categoría no puede moverse debajo de sí misma")
+			}
+		}
+		err := pool.QueryRow(ctx, `
+			SELECT MAX(d.nivel) - MIN(c.nivel) + 1
+			FROM v_categorias_ruta c
+			JOIN v_categorias_ruta d ON c.id = ANY(d.ancestros)
+			WHERE c.id = $1
+		`, id).Scan(&altura)
+		if err != nil {
+			return err
+		}
+	}
+	if nivel+altura > maxNivelCategoria {
+		return fmt.Errorf("se permiten como máximo %d niveles de categorías", maxNivelCategoria)
+	}
+	return nil
+}
+
+func registrarAuditoriaCategoria(ctx context.Context, c *gin.Context, accion, id string, detalles gin.H) {
+	_, err := pool.Exec(ctx, `
+		INSERT INTO auditoria_admin (usuario_id, accion, entidad, entidad_id, detalles, ip_address)
+		VALUES ($1::uuid, $2, 'CATEGORIA', $3, $4, $5)
+	`, fmt.Sprintf("%v", c.MustGet("user_id")), accion, id, detalles, c.ClientIP())
+	if err != nil {
+		log.Printf("⚠️ Error registrando auditoría: %v", err)
+	}
+}
+
+// GET /api/admin/categorias - Árbol de categorías (?formato=lista, ?incluir_inactivas=true)
+func listarCategoriasHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	rows, err := pool.Query(ctx, `
+		SELECT v.id, v.padre_id, v.nombre, c.descripcion, v.nivel, v.ruta, v.activa,
+		       (SELECT COUNT(*) FROM reclamos r WHERE r.categoria_id = v.id)
+		FROM v_categorias_ruta v
+		JOIN categorias c ON c.id = v.id
+		WHERE v.activa OR $1
+		ORDER BY v.ruta
+	`, c.Query("incluir_inactivas") == "true")
+	if err != nil {
+		log.Printf("❌ Error listando categorías: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al obtener categorías"})
+		return
+	}
+	defer rows.Close()
+
+	var nodos []categoriaNodo
+	for rows.Next() {
+		var n categoriaNodo
+		if err := rows.Scan(&n.ID, &n.PadreID, &n.Nombre, &n.Descripcion, &n.Nivel, &n.Ruta, &n.Activa, &n.Reclamos); err != nil {
+			continue
+		}
+		nodos = append(nodos, n)
+	}
+
+	if c.Query("formato") == "lista" {
+		lista := []gin.H{}
+		for _, n := range nodos {
+			lista = append(lista, n.json())
+		}
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": lista})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": construirArbolCategorias(nodos)})
+}
+
+type solicitudCategoria struct {
+	Nombre      string  `json:"nombre" binding:"required"`
+	PadreID     *string `json:"padre_id"`
+	Descripcion string  `json:"descripcion" binding:"max=500"`
+	Activa      *bool   `json:"activa"`
+}
+
+// POST /api/admin/categorias - Crear categoría
+func crearCategoriaHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var req solicitudCategoria
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Datos inválidos"})
+		return
+	}
+	nombre, err := normalizarNombreCategoria(req.Nombre)
+	if err == nil {
+		err = validarPadreCategoria(ctx, "", req.PadreID)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	var id string
+	err = pool.QueryRow(ctx, `
+		INSERT INTO categorias (nombre, padre_id, descripcion, creado_por)
+		VALUES ($1, $2, NULLIF($3, ''), $4::uuid)
+		ON CONFLICT DO NOTHING
+		RETURNING id
+	`, nombre, req.PadreID, strings.TrimSpace(req.Descripcion), fmt.Sprintf("%v", c.MustGet("user_id"))).Scan(&id)
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusConflict, gin.H{"success": false, "message": "Ya existe una categoría con ese nombre en el mismo nivel"})
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Error creando categoría: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al crear la categoría"})
+		return
+	}
+
+	registrarAuditoriaCategoria(ctx, c, "CREAR_CATEGORIA", id, gin.H{"nombre": nombre, "padre_id": req.PadreID})
+	c.JSON(http.StatusCreated, gin.H{"success": true, "message": "Categoría creada", "data": gin.H{"id": id}})
+}
+
+// PUT /api/admin/categorias/:id - Renombrar, mover o (des)activar
+func actualizarCategoriaHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	id := c.Param("id")
+	var req solicitudCategoria
+	if err := c.ShouldBindJSON(&req); err != nil || !reUUID.MatchString(id) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Datos inválidos"})
+		return
+	}
+	nombre, err := normalizarNombreCategoria(req.Nombre)
+	if err == nil {
+		err = validarPadreCategoria(ctx, id, req.PadreID)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+	activa := req.Activa == nil || *req.Activa
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al actualizar la categoría"})
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	tag, err := tx.Exec(ctx, `
+		UPDATE categorias SET nombre = $2, padre_id = $3, descripcion = NULLIF($4, ''), activa = $5
+		WHERE id = $1
+	`, id, nombre, req.PadreID, strings.TrimSpace(req.Descripcion), activa)
+	if err != nil {
+		var pgErr *pgconn.PgError
+		if errors.As(err, &pgErr) && pgErr.Code == "23505" {
+			c.JSON(http.StatusConflict, gin.H{"success": false, "message": "Ya existe una categoría con ese nombre en el mismo nivel"})
+			return
+		}
+		log.Printf("❌ Error actualizando categoría: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al actualizar la categoría"})
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Categoría no encontrada"})
+		return
+	}
+	// Desactivar una categoría desactiva todo su subárbol
+	if !activa {
+		_, err = tx.Exec(ctx, `
+			UPDATE categorias SET activa = false
+			WHERE id IN (SELECT id FROM v_categorias_ruta WHERE $1::uuid = ANY(ancestros))
+		`, id)
+	}
+	if err == nil {
+		err = tx.Commit(ctx)
+	}
+	if err != nil {
+		log.Printf("❌ Error actualizando categoría: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al actualizar la categoría"})
+		return
+	}
+
+	registrarAuditoriaCategoria(ctx, c, "EDITAR_CATEGORIA", id, gin.H{"nombre": nombre, "padre_id": req.PadreID, "activa": activa})
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Categoría actualizada"})
+}
+
+// DELETE /api/admin/categorias/:id - Eliminar (o desactivar si ya se usó)
+func eliminarCategoriaHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	id := c.Param("id")
+	if !reUUID.MatchString(id) {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Categoría no encontrada"})
+		return
+	}
+
+	var existe bool
+	var hijos, reclamos int64
+	err := pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM categorias WHERE id = $1),
+		       (SELECT COUNT(*) FROM categorias WHERE padre_id = $1 AND activa),
+		       (SELECT COUNT(*) FROM reclamos WHERE categoria_id = $1)
+	`, id).Scan(&existe, &hijos, &reclamos)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al eliminar la categoría"})
+		return
+	}
+	if !existe {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Categoría no encontrada"})
+		return
+	}
+	if hijos > 0 {
+		c.JSON(http.StatusConflict, gin.H{"success": false, "message": "La categoría tiene subcategorías activas"})
+		return
+	}
+
+	// Con reclamos asociados se conserva para no perder la clasificación histórica
+	mensaje := "Categoría eliminada"
+	if reclamos > 0 {
+		_, err = pool.Exec(ctx, "UPDATE categorias SET activa = false WHERE id = $1", id)
+		mensaje = fmt.Sprintf("La categoría tiene %d reclamos: se desactivó en lugar de eliminarse", reclamos)
+	} else {
+		_, err = pool.Exec(ctx, "DELETE FROM categorias WHERE id = $1", id)
+	}
+	if err != nil {
+		log.Printf("❌ Error eliminando categoría: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al eliminar la categoría"})
+		return
+	}
+
+	registrarAuditoriaCategoria(ctx, c, "ELIMINAR_CATEGORIA", id, gin.H{"desactivada": reclamos > 0})
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": mensaje})
+}
+
+// -----------------------------------------------------------------------------
+// Clasificación de reclamos
+// -----------------------------------------------------------------------------
+
+// categorizarEnTx asigna (o quita, con nil) la categoría del reclamo. La usan
+// la ruta individual, las operaciones masivas y las reglas; el historial
+// queda como CATEGORIZACION, que el seguimiento público no muestra.
+func categorizarEnTx(ctx context.Context, tx pgx.Tx, id string, categoriaID *string, usuario string) (string, gin.H, error) {
+	var codigo, estado string
+	var actual *string
+	err := tx.QueryRow(ctx, "SELECT codigo_reclamo, estado, categoria_id FROM reclamos WHERE id = $1 FOR UPDATE", id).
+		Scan(&codigo, &estado, &actual)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil, errReclamoNoEncontrado
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	if (actual == nil && categoriaID == nil) || (actual != nil && categoriaID != nil && *actual == *categoriaID) {
+		return codigo, nil, fmt.Errorf("%w: el reclamo ya tiene esa categoría", errTransicionInvalida)
+	}
+
+	var ruta *string
+	if categoriaID != nil {
+		var r string
+		err := tx.QueryRow(ctx, "SELECT ruta FROM v_categorias_ruta WHERE id = $1 AND activa", *categoriaID).Scan(&r)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return codigo, nil, fmt.Errorf("%w: la categoría no existe o está inactiva", errTransicionInvalida)
+		}
+		if err != nil {
+			return codigo, nil, err
+		}
+		ruta = &r
+	}
+
+	if _, err := tx.Exec(ctx, "UPDATE reclamos SET categoria_id = $1 WHERE id = $2", categoriaID, id); err != nil {
+		return codigo, nil, err
+	}
+
+	comentario := "Categoría quitada"
+	if ruta != nil {
+		comentario = "Categoría: " + *ruta
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO historial_reclamos (reclamo_id, estado_anterior, estado_nuevo, tipo_accion, comentario, usuario_accion)
+		VALUES ($1, $2, $2, 'CATEGORIZACION', $3, $4)
+	`, id, estado, comentario, usuario)
+	if err != nil {
+		return codigo, nil, err
+	}
+	return codigo, gin.H{"categoria_id": categoriaID, "categoria": ruta}, nil
+}
+
+// quitarEtiquetaEnTx elimina una etiqueta del reclamo.
+func quitarEtiquetaEnTx(ctx context.Context, tx pgx.Tx, id, etiqueta, usuario string) (string, gin.H, error) {
+	var codigo, estado string
+	err := tx.QueryRow(ctx, "SELECT codigo_reclamo, estado FROM reclamos WHERE id = $1 FOR UPDATE", id).Scan(&codigo, &estado)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil, errReclamoNoEncontrado
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	tag, err := tx.Exec(ctx, "DELETE FROM etiquetas_reclamos WHERE reclamo_id = $1 AND etiqueta = $2", id, etiqueta)
+	if err != nil {
+		return codigo, nil, err
+	}
+	if tag.RowsAffected() == 0 {
+		return codigo, nil, fmt.Errorf("%w: el reclamo no tiene la etiqueta %q", errTransicionInvalida, etiqueta)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO historial_reclamos (reclamo_id, estado_anterior, estado_nuevo, tipo_accion, comentario, usuario_accion)
+		VALUES ($1, $2, $2, 'ETIQUETADO', $3, $4)
+	`, id, estado, "Etiqueta quitada: "+etiqueta, usuario)
+	if err != nil {
+		return codigo, nil, err
+	}
+	return codigo, gin.H{"etiqueta_quitada": etiqueta}, nil
+}
+
+// aplicarOperacionReclamo ejecuta una operación de clasificación sobre un
+// reclamo, responde y deja evento interno y auditoría.
+func aplicarOperacionReclamo(c *gin.Context, ctx context.Context, accion, mensaje string, operacion operacionMasiva) {
+	id := c.Param("id")
+	if !reUUID.MatchString(id) {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Reclamo no encontrado"})
+		return
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al actualizar el reclamo"})
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	_, detalles, err := operacion(ctx, tx, id)
+	if err == nil {
+		err = tx.Commit(ctx)
+	}
+	switch {
+	case errors.Is(err, errReclamoNoEncontrado):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Reclamo no encontrado"})
+		return
+	case errors.Is(err, errTransicionInvalida):
+		c.JSON(http.StatusConflict, gin.H{"success": false, "message": err.Error()})
+		return
+	case err != nil:
+		log.Printf("❌ Error en %s sobre %s: %v", accion, id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al actualizar el reclamo"})
+		return
+	}
+
+	publicarEventoInterno(id, eventoEstado, detalles)
+	_, errAudit := pool.Exec(ctx, `
+		INSERT INTO auditoria_admin (usuario_id, accion, entidad, entidad_id, detalles, ip_address)
+		VALUES ($1::uuid, $2, 'RECLAMO', $3, $4, $5)
+	`, fmt.Sprintf("%v", c.MustGet("user_id")), accion, id, detalles, c.ClientIP())
+	if errAudit != nil {
+		log.Printf("⚠️ Error registrando auditoría: %v", errAudit)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": mensaje, "data": detalles})
+}
+
+// PUT /api/admin/reclamos/:id/categoria - Asignar o quitar (null) la categoría
+func asignarCategoriaHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var req struct {
+		CategoriaID *string `json:"categoria_id"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || (req.CategoriaID != nil && !reUUID.MatchString(*req.CategoriaID)) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "categoria_id debe ser un ID de categoría o null"})
+		return
+	}
+
+	email := fmt.Sprintf("%v", c.MustGet("email"))
+	aplicarOperacionReclamo(c, ctx, "CATEGORIZAR", "Categoría actualizada",
+		func(ctx context.Context, tx pgx.Tx, id string) (string, gin.H, error) {
+			return categorizarEnTx(ctx, tx, id, req.CategoriaID, email)
+		})
+}
+
+// POST /api/admin/reclamos/:id/etiquetas - Agregar etiquetas
+func agregarEtiquetasHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var req struct {
+		Etiquetas []string `json:"etiquetas"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Datos inválidos"})
+		return
+	}
+	etiquetas, err := normalizarEtiquetas(req.Etiquetas)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	email := fmt.Sprintf("%v", c.MustGet("email"))
+	aplicarOperacionReclamo(c, ctx, "ETIQUETAR", "Etiquetas agregadas",
+		func(ctx context.Context, tx pgx.Tx, id string) (string, gin.H, error) {
+			return etiquetarEnTx(ctx, tx, id, etiquetas, email)
+		})
+}
+
+// DELETE /api/admin/reclamos/:id/etiquetas/:etiqueta - Quitar una etiqueta
+func quitarEtiquetaHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	etiqueta := strings.ToLower(strings.Join(strings.Fields(c.Param("etiqueta")), " "))
+	email := fmt.Sprintf("%v", c.MustGet("email"))
+	aplicarOperacionReclamo(c, ctx, "QUITAR_ETIQUETA", "Etiqueta quitada",
+		func(ctx context.Context, tx pgx.Tx, id string) (string, gin.H, error) {
+			return quitarEtiquetaEnTx(ctx, tx, id, etiqueta, email)
+		})
+}
+
+// GET /api/admin/etiquetas - Etiquetas en uso (?q= para autocompletar)
+func listarEtiquetasHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	rows, err := pool.Query(ctx, `
+		SELECT etiqueta, COUNT(*)
+		FROM etiquetas_reclamos
+		WHERE etiqueta LIKE $1 || '%'
+		GROUP BY etiqueta
+		ORDER BY COUNT(*) DESC, etiqueta
+		LIMIT 50
+	`, strings.ToLower(strings.TrimSpace(c.Query("q"))))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al obtener etiquetas"})
+		return
+	}
+	defer rows.Close()
+
+	etiquetas := []gin.H{}
+	for rows.Next() {
+		var etiqueta string
+		var n int64
+		if err := rows.Scan(&etiqueta, &n); err == nil {
+			etiquetas = append(etiquetas, gin.H{"etiqueta": etiqueta, "reclamos": n})
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": etiquetas})
+}
+
+// desgloseCategorias cuenta los reclamos por categoría; cada categoría incluye
+// los de sus subcategorías.
+func desgloseCategorias(ctx context.Context) ([]gin.H, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT * FROM (
+		SELECT c.id, c.padre_id, c.ruta, c.nivel, c.activa,
+		       COUNT(r.id),
+		       COUNT(r.id) FILTER (WHERE r.estado IN ('PENDIENTE', 'EN_PROCESO')),
+		       COUNT(r.id) FILTER (WHERE r.estado IN ('PENDIENTE', 'EN_PROCESO') AND `+sqlFechaLimite+` < CURRENT_DATE),
+		       COUNT(r.id) FILTER (WHERE r.fecha_registro >= date_trunc('month', CURRENT_DATE))
+		FROM v_categorias_ruta c
+		JOIN v_categorias_ruta d ON c.id = ANY(d.ancestros)
+		LEFT JOIN reclamos r ON r.categoria_id = d.id
+		GROUP BY c.id, c.padre_id, c.ruta, c.nivel, c.activa
+		HAVING c.activa OR COUNT(r.id) > 0
+		UNION ALL
+		SELECT NULL, NULL, 'Sin categoría', 0, true,
+		       COUNT(*),
+		       COUNT(*) FILTER (WHERE r.estado IN ('PENDIENTE', 'EN_PROCESO')),
+		       COUNT(*) FILTER (WHERE r.estado IN ('PENDIENTE', 'EN_PROCESO') AND `+sqlFechaLimite+` < CURRENT_DATE),
+		       COUNT(*) FILTER (WHERE r.fecha_registro >= date_trunc('month', CURRENT_DATE))
+		FROM reclamos r
+		WHERE r.categoria_id IS NULL
+		) d (id, padre_id, ruta, nivel, activa, total, abiertos, vencidos, mes)
+		ORDER BY id IS NULL, ruta
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	desglose := []gin.H{}
+	for rows.Next() {
+		var id, padreID *string
+		var ruta string
+		var nivel int
+		var activa bool
+		var total, abiertos, vencidos, mes int64
+		if err := rows.Scan(&id, &padreID, &ruta, &nivel, &activa, &total, &abiertos, &vencidos, &mes); err != nil {
+			return nil, err
+		}
+		desglose = append(desglose, gin.H{
+			"categoria_id": id,
+			"padre_id":     padreID,
+			"categoria":    ruta,
+			"nivel":        nivel,
+			"activa":       activa,
+			"total":        total,
+			"abiertos":     abiertos,
+			"vencidos":     vencidos,
+			"mes":          mes,
+		})
+	}
+	return desglose, rows.Err()
+}
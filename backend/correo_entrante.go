@@ -0,0 +1,564 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/http"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"golang.org/x/text/encoding/charmap"
+)
+
+// =============================================================================
+// RESPUESTAS POR EMAIL
+// Los emails al consumidor llevan Reply-To: reclamo+<token>@dominio, donde el
+// token es el código del reclamo más un HMAC. Las respuestas llegan por el
+// webhook del proveedor de correo (o el importador mbox/Maildir) y se guardan
+// como mensajes CLIENTE, sin el historial citado.
+// =============================================================================
+
+const (
+	maxCorreoEntrante  = 15 * 1024 * 1024
+	maxAdjuntoEntrante = 5 * 1024 * 1024
+	maxMensajeEntrante = 1000
+)
+
+var (
+	errSinTokenRespuesta = errors.New("ningún destinatario tiene un token de respuesta válido")
+	errCorreoDuplicado   = errors.New("correo ya procesado")
+	errRespuestaAuto     = errors.New("respuesta automática ignorada")
+)
+
+func claveTokensRespuesta() []byte {
+	return []byte(jwtSecret + "|respuestas")
+}
+
+// tokenRespuesta es estable por reclamo y va en minúsculas porque muchos
+// servidores no respetan mayúsculas en la parte local de la dirección.
+func tokenRespuesta(codigo string) string {
+	codigo = strings.ToLower(codigo)
+	mac := hmac.New(sha256.New, claveTokensRespuesta())
+	mac.Write([]byte(codigo))
+	return codigo + "." + hex.EncodeToString(mac.Sum(nil))[:20]
+}
+
+// codigoDesdeTokenRespuesta devuelve el código (en mayúsculas) si el token es válido.
+func codigoDesdeTokenRespuesta(token string) (string, bool) {
+	token = strings.ToLower(token)
+	codigo, _, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(tokenRespuesta(codigo)), []byte(token)) {
+		return "", false
+	}
+	return strings.ToUpper(codigo), true
+}
+
+// direccionRespuesta arma reclamo+<token>@dominio a partir de EMAIL_RESPUESTAS.
+// Vacía si la función no está configurada.
+func direccionRespuesta(codigo string) string {
+	local, dominio, ok := strings.Cut(config.EmailRespuestas, "@")
+	if !ok || local == "" || dominio == "" {
+		return ""
+	}
+	return fmt.Sprintf("%s+%s@%s", local, tokenRespuesta(codigo), dominio)
+}
+
+// =============================================================================
+// PARSEO MIME
+// =============================================================================
+
+type adjuntoCorreo struct {
+	Nombre      string
+	ContentType string
+	Datos       []byte
+}
+
+type correoEntrante struct {
+	MessageID     string
+	De            string
+	Asunto        string
+	Destinatarios []string
+	Texto         string
+	HTML          string
+	Adjuntos      []adjuntoCorreo
+	Automatico    bool
+}
+
+var decodificadorPalabras = &mime.WordDecoder{CharsetReader: lectorCharset}
+
+func lectorCharset(charset string, input io.Reader) (io.Reader, error) {
+	switch strings.ToLower(charset) {
+	case "", "utf-8", "us-ascii":
+		return input, nil
+	case "iso-8859-1", "latin1":
+		return charmap.ISO8859_1.NewDecoder().Reader(input), nil
+	case "windows-1252", "cp1252":
+		return charmap.Windows1252.NewDecoder().Reader(input), nil
+	}
+	return nil, fmt.Errorf("charset no soportado: %s", charset)
+}
+
+func parsearCorreo(r io.Reader) (*correoEntrante, error) {
+	msg, err := mail.ReadMessage(bufio.NewReader(io.LimitReader(r, maxCorreoEntrante)))
+	if err != nil {
+		return nil, err
+	}
+
+	h := msg.Header
+	c := &correoEntrante{MessageID: strings.Trim(h.Get("Message-Id"), "<> ")}
+	if asunto, err := decodificadorPalabras.DecodeHeader(h.Get("Subject")); err == nil {
+		c.Asunto = asunto
+	}
+	if de, err := mail.ParseAddress(h.Get("From")); err == nil {
+		c.De = strings.ToLower(de.Address)
+	}
+	for _, campo := range []string{"To", "Cc", "Delivered-To", "X-Original-To", "Envelope-To"} {
+		if h.Get(campo) == "" {
+			continue
+		}
+		lista, err := mail.ParseAddressList(h.Get(campo))
+		if err != nil {
+			// Delivered-To y similares a veces vienen sin formato RFC 5322
+			c.Destinatarios = append(c.Destinatarios, strings.Trim(h.Get(campo), "<> "))
+			continue
+		}
+		for _, a := range lista {
+			c.Destinatarios = append(c.Destinatarios, a.Address)
+		}
+	}
+
+	// RFC 3834: no responder ni registrar avisos de vacaciones, rebotes, etc.
+	auto := strings.ToLower(h.Get("Auto-Submitted"))
+	precedencia := strings.ToLower(h.Get("Precedence"))
+	c.Automatico = (auto != "" && auto != "no") || precedencia == "bulk" || precedencia == "junk" ||
+		precedencia == "auto_reply" || h.Get("X-Autoreply") != "" || h.Get("X-Autorespond") != ""
+
+	if err := c.leerParte(h, msg.Body); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *correoEntrante) leerParte(h map[string][]string, cuerpo io.Reader) error {
+	get := func(k string) string {
+		if v := h[k]; len(v) > 0 {
+			return v[0]
+		}
+		return ""
+	}
+
+	tipo, params, err := mime.ParseMediaType(get("Content-Type"))
+	if err != nil {
+		tipo, params = "text/plain", map[string]string{}
+	}
+
+	if strings.HasPrefix(tipo, "multipart/") {
+		mr := multipart.NewReader(cuerpo, params["boundary"])
+		for {
+			p, err := mr.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := c.leerParte(p.Header, p); err != nil {
+				return err
+			}
+		}
+	}
+
+	datos, err := io.ReadAll(decodificarTransferencia(get("Content-Transfer-Encoding"), cuerpo))
+	if err != nil {
+		return err
+	}
+
+	disposicion, dparams, _ := mime.ParseMediaType(get("Content-Disposition"))
+	nombre := dparams["filename"]
+	if nombre == "" {
+		nombre = params["name"]
+	}
+	if n, err := decodificadorPalabras.DecodeHeader(nombre); err == nil {
+		nombre = n
+	}
+
+	if disposicion == "attachment" || nombre != "" || (tipo != "text/plain" && tipo != "text/html") {
+		if nombre == "" {
+			nombre = "adjunto"
+		}
+		c.Adjuntos = append(c.Adjuntos, adjuntoCorreo{Nombre: filepath.Base(nombre), ContentType: tipo, Datos: datos})
+		return nil
+	}
+
+	texto, err := aUTF8(params["charset"], datos)
+	if err != nil {
+		texto = string(datos)
+	}
+	if tipo == "text/html" {
+		if c.HTML == "" {
+			c.HTML = texto
+		}
+	} else if c.Texto == "" {
+		c.Texto = texto
+	}
+	return nil
+}
+
+func decodificarTransferencia(codificacion string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(codificacion)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, &filtroBase64{r: r})
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	}
+	return r
+}
+
+// filtroBase64 descarta los saltos de línea que el decodificador no acepta.
+type filtroBase64 struct{ r io.Reader }
+
+func (f *filtroBase64) Read(p []byte) (int, error) {
+	n, err := f.r.Read(p)
+	j := 0
+	for _, b := range p[:n] {
+		if b != '\r' && b != '\n' && b != ' ' && b != '\t' {
+			p[j] = b
+			j++
+		}
+	}
+	return j, err
+}
+
+func aUTF8(charset string, datos []byte) (string, error) {
+	r, err := lectorCharset(charset, bytes.NewReader(datos))
+	if err != nil {
+		return "", err
+	}
+	out, err := io.ReadAll(r)
+	return string(out), err
+}
+
+// =============================================================================
+// LIMPIEZA DEL TEXTO DE LA RESPUESTA
+// =============================================================================
+
+var (
+	reEncabezadoCita = regexp.MustCompile(`(?i)^(el|on|le|am)\s.+(escribió|wrote|a écrit|schrieb)\s*:?\s*$`)
+	reSeparadorCita  = regexp.MustCompile(`(?i)^(-{2,}\s*(mensaje original|original message|mensaje reenviado|forwarded message)\s*-{2,}|_{10,})\s*$`)
+	reCabeceraCitada = regexp.MustCompile(`(?i)^(de|from|enviado|sent|para|to|asunto|subject)\s*:`)
+	reEtiquetasHTML  = regexp.MustCompile(`(?s)<(script|style)[^>]*>.*?</(script|style)>|<[^>]+>`)
+	reSaltosHTML     = regexp.MustCompile(`(?i)<br\s*/?>|</p>|</div>`)
+)
+
+// limpiarRespuesta se queda con lo que escribió el consumidor: corta en la
+// primera cita ("El ... escribió:", "> ...", "-----Mensaje original-----",
+// bloque De:/Enviado:) y en la firma "-- ".
+func limpiarRespuesta(texto string) string {
+	texto = strings.ReplaceAll(texto, "\r\n", "\n")
+	lineas := strings.Split(texto, "\n")
+
+	var salida []string
+	for i, linea := range lineas {
+		l := strings.TrimSpace(linea)
+		if strings.HasPrefix(l, ">") || reSeparadorCita.MatchString(l) || linea == "-- " {
+			break
+		}
+		if reEncabezadoCita.MatchString(l) {
+			break
+		}
+		// Gmail parte "El ... <correo>\nescribió:" en dos líneas
+		if i+1 < len(lineas) && reEncabezadoCita.MatchString(l+" "+strings.TrimSpace(lineas[i+1])) {
+			break
+		}
+		// Outlook: bloque "De: ... / Enviado: ..." sin separador
+		if reCabeceraCitada.MatchString(l) && i+1 < len(lineas) && reCabeceraCitada.MatchString(strings.TrimSpace(lineas[i+1])) {
+			break
+		}
+		salida = append(salida, strings.TrimRight(linea, " \t"))
+	}
+
+	return strings.TrimSpace(strings.Join(salida, "\n"))
+}
+
+func textoDesdeHTML(h string) string {
+	h = reSaltosHTML.ReplaceAllString(h, "\n")
+	h = reEtiquetasHTML.ReplaceAllString(h, "")
+	return html.UnescapeString(h)
+}
+
+// =============================================================================
+// PROCESAMIENTO
+// =============================================================================
+
+// procesarCorreoEntrante registra un correo crudo como mensaje CLIENTE y
+// devuelve el código del reclamo al que se asoció.
+func procesarCorreoEntrante(ctx context.Context, raw []byte) (string, error) {
+	correo, err := parsearCorreo(bytes.NewReader(raw))
+	if err != nil {
+		return "", fmt.Errorf("correo inválido: %w", err)
+	}
+	if correo.Automatico || strings.EqualFold(correo.De, config.SMTPFrom) {
+		return "", errRespuestaAuto
+	}
+
+	codigo := ""
+	for _, d := range correo.Destinatarios {
+		local, _, _ := strings.Cut(d, "@")
+		if _, token, ok := strings.Cut(local, "+"); ok {
+			if c, valido := codigoDesdeTokenRespuesta(token); valido {
+				codigo = c
+				break
+			}
+		}
+	}
+	if codigo == "" {
+		return "", errSinTokenRespuesta
+	}
+
+	var reclamoID, emailReclamo string
+	if err := pool.QueryRow(ctx, "SELECT id, email FROM reclamos WHERE codigo_reclamo = $1", codigo).Scan(&reclamoID, &emailReclamo); err != nil {
+		return "", fmt.Errorf("reclamo %s no encontrado: %w", codigo, err)
+	}
+	if correo.De != "" && !strings.EqualFold(correo.De, emailReclamo) {
+		// El token basta para asociarlo, pero soporte debe saber que vino de otra dirección
+		log.Printf("⚠️ Respuesta a %s enviada desde %s (registrado: %s)", codigo, correo.De, emailReclamo)
+	}
+
+	// Atajo para reintentos; el ON CONFLICT de registrarMensajeCliente es el
+	// que resuelve dos entregas simultáneas del mismo correo
+	if correo.MessageID != "" {
+		var existe bool
+		pool.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM mensajes_seguimiento WHERE message_id_email = $1)", correo.MessageID).Scan(&existe)
+		if existe {
+			return codigo, errCorreoDuplicado
+		}
+	}
+
+	texto := correo.Texto
+	if strings.TrimSpace(texto) == "" {
+		texto = textoDesdeHTML(correo.HTML)
+	}
+	mensaje := limpiarRespuesta(texto)
+	if mensaje == "" && len(correo.Adjuntos) == 0 {
+		return codigo, errors.New("respuesta vacía")
+	}
+	if mensaje == "" {
+		mensaje = "(Respuesta por email sin texto)"
+	}
+	if r := []rune(mensaje); len(r) > maxMensajeEntrante {
+		mensaje = string(r[:maxMensajeEntrante-1]) + "…"
+	}
+
+	// La tabla guarda un adjunto por mensaje: el primero va con el texto y los
+	// demás como mensajes propios
+	adjuntos := make([]adjuntoCorreo, 0, len(correo.Adjuntos))
+	for _, a := range correo.Adjuntos {
+		if len(a.Datos) > maxAdjuntoEntrante {
+			mensaje += fmt.Sprintf("\n[Adjunto omitido por tamaño: %s]", a.Nombre)
+			continue
+		}
+		adjuntos = append(adjuntos, a)
+	}
+
+	var archivo, nombre string
+	if len(adjuntos) > 0 {
+		archivo, nombre = adjuntos[0].dataURL(), adjuntos[0].Nombre
+	}
+	if _, err := registrarMensajeCliente(ctx, reclamoID, mensaje, archivo, nombre, "", correo.MessageID); err != nil {
+		return codigo, err
+	}
+	for i, a := range adjuntos[min(1, len(adjuntos)):] {
+		messageID := ""
+		if correo.MessageID != "" {
+			messageID = fmt.Sprintf("%s#%d", correo.MessageID, i+1)
+		}
+		if _, err := registrarMensajeCliente(ctx, reclamoID, "📎 "+a.Nombre, a.dataURL(), a.Nombre, "", messageID); err != nil {
+			log.Printf("⚠️ Error guardando adjunto %s de %s: %v", a.Nombre, codigo, err)
+		}
+	}
+
+	log.Printf("✅ Respuesta por email registrada en %s", codigo)
+	return codigo, nil
+}
+
+func (a adjuntoCorreo) dataURL() string {
+	tipo := a.ContentType
+	if tipo == "" {
+		tipo = "application/octet-stream"
+	}
+	return "data:" + tipo + ";base64," + base64.StdEncoding.EncodeToString(a.Datos)
+}
+
+// =============================================================================
+// WEBHOOK E IMPORTADORES
+// =============================================================================
+
+// POST /api/correo/entrante - Webhook del proveedor de correo
+// Acepta el MIME crudo como cuerpo (message/rfc822), un formulario con el campo
+// "email" (SendGrid Inbound Parse en modo raw) o JSON {"raw": "..."}.
+func correoEntranteHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	// Solo en cabecera: en la URL quedaría en los logs de acceso y proxies
+	secreto := c.GetHeader("X-Inbound-Secret")
+	if config.InboundEmailSecret == "" || subtle.ConstantTimeCompare([]byte(secreto), []byte(config.InboundEmailSecret)) != 1 {
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "message": "No autorizado"})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxCorreoEntrante)
+
+	var raw []byte
+	var err error
+	switch tipo := c.ContentType(); {
+	case tipo == "multipart/form-data" || tipo == "application/x-www-form-urlencoded":
+		raw = []byte(c.PostForm("email"))
+	case tipo == "application/json":
+		var req struct {
+			Raw string `json:"raw"`
+		}
+		err = c.ShouldBindJSON(&req)
+		raw = []byte(req.Raw)
+	default:
+		raw, err = c.GetRawData()
+	}
+	if err != nil || len(bytes.TrimSpace(raw)) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Correo vacío o inválido"})
+		return
+	}
+
+	codigo, err := procesarCorreoEntrante(ctx, raw)
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"codigo_reclamo": codigo}})
+	case errors.Is(err, errCorreoDuplicado), errors.Is(err, errRespuestaAuto), errors.Is(err, errSinTokenRespuesta):
+		// 200 para que el proveedor no reintente algo que nunca se va a aceptar
+		c.JSON(http.StatusOK, gin.H{"success": false, "message": err.Error()})
+	default:
+		log.Printf("❌ Error procesando correo entrante: %v", err)
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"success": false, "message": err.Error()})
+	}
+}
+
+// leerMbox separa un archivo mbox (mboxrd) en correos crudos.
+func leerMbox(r io.Reader, procesar func(raw []byte) error) error {
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), maxCorreoEntrante)
+
+	var actual bytes.Buffer
+	enCorreo := false
+	emitir := func() error {
+		if !enCorreo {
+			return nil
+		}
+		raw := bytes.TrimRight(actual.Bytes(), "\n")
+		err := procesar(append([]byte(nil), raw...))
+		actual.Reset()
+		return err
+	}
+
+	for sc.Scan() {
+		linea := sc.Text()
+		if strings.HasPrefix(linea, "From ") {
+			if err := emitir(); err != nil {
+				return err
+			}
+			enCorreo = true
+			continue
+		}
+		// mboxrd: ">From " escapado pierde un ">"
+		if strings.HasPrefix(strings.TrimLeft(linea, ">"), "From ") && strings.HasPrefix(linea, ">") {
+			linea = linea[1:]
+		}
+		actual.WriteString(linea)
+		actual.WriteByte('\n')
+	}
+	if err := sc.Err(); err != nil {
+		return err
+	}
+	return emitir()
+}
+
+// leerMaildir procesa los correos de new/ y cur/ en orden de nombre.
+func leerMaildir(dir string, procesar func(raw []byte) error) error {
+	var archivos []string
+	for _, sub := range []string{"new", "cur"} {
+		entradas, err := os.ReadDir(filepath.Join(dir, sub))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		for _, e := range entradas {
+			if !e.IsDir() {
+				archivos = append(archivos, filepath.Join(dir, sub, e.Name()))
+			}
+		}
+	}
+	sort.Strings(archivos)
+
+	for _, ruta := range archivos {
+		raw, err := os.ReadFile(ruta)
+		if err != nil {
+			return err
+		}
+		if err := procesar(raw); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// importarCorreos es el modo de línea de comandos:
+//
+//	libro-reclamaciones importar-correo <archivo.mbox | directorio Maildir>
+func importarCorreos(ctx context.Context, ruta string) error {
+	var registrados, omitidos int
+	procesar := func(raw []byte) error {
+		codigo, err := procesarCorreoEntrante(ctx, raw)
+		if err != nil {
+			omitidos++
+			log.Printf("⚠️ Correo omitido (%s): %v", codigo, err)
+			return nil
+		}
+		registrados++
+		return nil
+	}
+
+	info, err := os.Stat(ruta)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		err = leerMaildir(ruta, procesar)
+	} else {
+		var f *os.File
+		if f, err = os.Open(ruta); err != nil {
+			return err
+		}
+		defer f.Close()
+		err = leerMbox(f, procesar)
+	}
+
+	log.Printf("📥 Importación terminada: %d registrados, %d omitidos", registrados, omitidos)
+	return err
+}
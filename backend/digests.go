@@ -0,0 +1,590 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// =============================================================================
+// RESÚMENES PROGRAMADOS PARA GERENCIA
+// Cada digest tiene su frecuencia (diaria o semanal), hora de envío, lista de
+// destinatarios y secciones. La tarea horaria envía los que tocan; el lease
+// por digest y periodo evita duplicados entre réplicas y reinicios.
+// =============================================================================
+
+var seccionesDigest = []string{"nuevos", "vencidos", "sla", "categorias", "carga"}
+
+type digest struct {
+	ID            string
+	Nombre        string
+	Frecuencia    string // DIARIO o SEMANAL
+	DiaSemana     int    // 1 = lunes ... 7 = domingo (solo SEMANAL)
+	Hora          int
+	Destinatarios []string
+	Secciones     []string
+}
+
+// periodoDigest devuelve el periodo a informar si el digest toca en este
+// momento: el día anterior (diario) o los 7 días anteriores (semanal).
+func periodoDigest(d digest, ahora time.Time) (time.Time, time.Time, bool) {
+	hoy := time.Date(ahora.Year(), ahora.Month(), ahora.Day(), 0, 0, 0, 0, ahora.Location())
+	if ahora.Hour() < d.Hora {
+		return time.Time{}, time.Time{}, false
+	}
+	if d.Frecuencia == "SEMANAL" {
+		dia := int(ahora.Weekday())
+		if dia == 0 {
+			dia = 7
+		}
+		if dia != d.DiaSemana {
+			return time.Time{}, time.Time{}, false
+		}
+		return hoy.AddDate(0, 0, -7), hoy, true
+	}
+	return hoy.AddDate(0, 0, -1), hoy, true
+}
+
+// -----------------------------------------------------------------------------
+// Datos del resumen
+// -----------------------------------------------------------------------------
+
+type cargaAgente struct {
+	Nombre                        string
+	Abiertos, Vencidos, Resueltos int64
+}
+
+type datosDigest struct {
+	Nuevos, NuevosReclamos, NuevosQuejas int64
+	Vencidos                             int64
+	VencidosLista                        []reclamoSLA
+	RespondidosEnPlazo, RespondidosFuera int64
+	VencidosEnPeriodo                    int64
+	Categorias                           [][2]string // nombre, cantidad
+	Agentes                              []cargaAgente
+	SinAsignar                           int64
+}
+
+func calcularDatosDigest(ctx context.Context, desde, hasta time.Time) (datosDigest, error) {
+	var d datosDigest
+
+	err := pool.QueryRow(ctx, `
+		SELECT COUNT(*) FILTER (WHERE r.fecha_registro >= $1 AND r.fecha_registro < $2),
+		       COUNT(*) FILTER (WHERE r.fecha_registro >= $1 AND r.fecha_registro < $2 AND r.tipo_solicitud = 'RECLAMO'),
+		       COUNT(*) FILTER (WHERE r.fecha_registro >= $1 AND r.fecha_registro < $2 AND r.tipo_solicitud = 'QUEJA'),
+		       COUNT(*) FILTER (WHERE r.estado IN ('PENDIENTE', 'EN_PROCESO') AND `+sqlFechaLimite+` < CURRENT_DATE),
+		       COUNT(*) FILTER (WHERE r.fecha_respuesta >= $1 AND r.fecha_respuesta < $2 AND r.fecha_respuesta::date <= `+sqlFechaLimite+`),
+		       COUNT(*) FILTER (WHERE r.fecha_respuesta >= $1 AND r.fecha_respuesta < $2 AND r.fecha_respuesta::date > `+sqlFechaLimite+`),
+		       COUNT(*) FILTER (WHERE r.fecha_respuesta IS NULL AND `+sqlFechaLimite+` >= $1::date AND `+sqlFechaLimite+` < $2::date),
+		       COUNT(*) FILTER (WHERE r.estado IN ('PENDIENTE', 'EN_PROCESO') AND r.atendido_por IS NULL)
+		FROM reclamos r
+	`, desde, hasta).Scan(&d.Nuevos, &d.NuevosReclamos, &d.NuevosQuejas, &d.Vencidos,
+		&d.RespondidosEnPlazo, &d.RespondidosFuera, &d.VencidosEnPeriodo, &d.SinAsignar)
+	if err != nil {
+		return d, fmt.Errorf("contadores: %w", err)
+	}
+
+	// Los vencidos más antiguos, con el mismo formato que las alertas SLA
+	rows, err := pool.Query(ctx, `
+		SELECT r.id, r.codigo_reclamo, r.estado, (`+sqlFechaLimite+` - CURRENT_DATE)::int, ua.nombre_completo
+		FROM reclamos r
+		LEFT JOIN usuarios_admin ua ON ua.id = r.atendido_por
+		WHERE r.estado IN ('PENDIENTE', 'EN_PROCESO') AND `+sqlFechaLimite+` < CURRENT_DATE
+		ORDER BY `+sqlFechaLimite+` ASC
+		LIMIT 10
+	`)
+	if err != nil {
+		return d, fmt.Errorf("vencidos: %w", err)
+	}
+	for rows.Next() {
+		var r reclamoSLA
+		if err := rows.Scan(&r.ID, &r.Codigo, &r.Estado, &r.DiasRestantes, &r.AgenteNombre); err != nil {
+			rows.Close()
+			return d, fmt.Errorf("vencidos: %w", err)
+		}
+		d.VencidosLista = append(d.VencidosLista, r)
+	}
+	rows.Close()
+
+	rows, err = pool.Query(ctx, `
+		SELECT COALESCE(cat.ruta, 'Sin categoría'), COUNT(*)
+		FROM reclamos r
+		LEFT JOIN v_categorias_ruta cat ON cat.id = r.categoria_id
+		WHERE r.fecha_registro >= $1 AND r.fecha_registro < $2
+		GROUP BY 1
+		ORDER BY 2 DESC, 1
+		LIMIT 5
+	`, desde, hasta)
+	if err != nil {
+		return d, fmt.Errorf("categorías: %w", err)
+	}
+	for rows.Next() {
+		var nombre string
+		var n int64
+		if err := rows.Scan(&nombre, &n); err != nil {
+			rows.Close()
+			return d, fmt.Errorf("categorías: %w", err)
+		}
+		d.Categorias = append(d.Categorias, [2]string{nombre, fmt.Sprint(n)})
+	}
+	rows.Close()
+
+	rows, err = pool.Query(ctx, `
+		SELECT ua.nombre_completo,
+		       COUNT(r.id) FILTER (WHERE r.estado IN ('PENDIENTE', 'EN_PROCESO')),
+		       COUNT(r.id) FILTER (WHERE r.estado IN ('PENDIENTE', 'EN_PROCESO') AND `+sqlFechaLimite+` < CURRENT_DATE),
+		       COUNT(r.id) FILTER (WHERE r.fecha_respuesta >= $1 AND r.fecha_respuesta < $2)
+		FROM usuarios_admin ua
+		LEFT JOIN reclamos r ON r.atendido_por = ua.id
+		WHERE ua.activo = true
+		GROUP BY ua.id, ua.nombre_completo
+		ORDER BY 2 DESC, ua.nombre_completo
+	`, desde, hasta)
+	if err != nil {
+		return d, fmt.Errorf("carga: %w", err)
+	}
+	for rows.Next() {
+		var a cargaAgente
+		if err := rows.Scan(&a.Nombre, &a.Abiertos, &a.Vencidos, &a.Resueltos); err != nil {
+			rows.Close()
+			return d, fmt.Errorf("carga: %w", err)
+		}
+		d.Agentes = append(d.Agentes, a)
+	}
+	rows.Close()
+
+	return d, rows.Err()
+}
+
+// -----------------------------------------------------------------------------
+// Email
+// -----------------------------------------------------------------------------
+
+const (
+	estiloTablaDigest = `width: 100%; border-collapse: collapse; font-size: 13px; margin-bottom: 16px;`
+	estiloCabDigest   = `background-color: #f3f4f6; text-align: left;`
+	estiloCeldaDigest = `padding: 6px 8px; border-top: 1px solid #e5e7eb;`
+)
+
+func tieneSeccion(secciones []string, s string) bool {
+	for _, x := range secciones {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
+
+func generarEmailDigest(d digest, datos datosDigest, desde, hasta time.Time) string {
+	var b strings.Builder
+	periodo := desde.Format("02/01/2006")
+	if hasta.Sub(desde) > 24*time.Hour {
+		periodo += " al " + hasta.AddDate(0, 0, -1).Format("02/01/2006")
+	}
+	fmt.Fprintf(&b, `<p style="margin: 0 0 16px 0;">Resumen del %s.</p>`, periodo)
+
+	seccion := func(titulo string) {
+		fmt.Fprintf(&b, `<h3 style="margin: 16px 0 8px 0; font-size: 15px; color: #1f2937;">%s</h3>`, titulo)
+	}
+
+	if tieneSeccion(d.Secciones, "nuevos") {
+		seccion("Nuevos registros")
+		fmt.Fprintf(&b, `<p style="margin: 0;"><strong>%d</strong> en el periodo: %d reclamos y %d quejas.</p>`,
+			datos.Nuevos, datos.NuevosReclamos, datos.NuevosQuejas)
+	}
+
+	if tieneSeccion(d.Secciones, "vencidos") {
+		seccion(fmt.Sprintf("Vencidos sin respuesta: %d", datos.Vencidos))
+		if len(datos.VencidosLista) > 0 {
+			b.WriteString(`<table style="` + estiloTablaDigest + `"><tr style="` + estiloCabDigest + `"><th style="padding: 6px 8px;">Código</th><th style="padding: 6px 8px;">Atraso</th><th style="padding: 6px 8px;">Agente</th></tr>`)
+			for _, r := range datos.VencidosLista {
+				agente := "sin asignar"
+				if r.AgenteNombre != nil {
+					agente = *r.AgenteNombre
+				}
+				fmt.Fprintf(&b, `<tr><td style="%s"><a href="%s/admin/reclamos/%s">%s</a></td><td style="%s color: #dc2626;">%s</td><td style="%s">%s</td></tr>`,
+					estiloCeldaDigest, config.FrontendURL, r.ID, html.EscapeString(r.Codigo),
+					estiloCeldaDigest, describirPlazo(r.DiasRestantes), estiloCeldaDigest, html.EscapeString(agente))
+			}
+			b.WriteString(`</table>`)
+		}
+	}
+
+	if tieneSeccion(d.Secciones, "sla") {
+		seccion("Cumplimiento del plazo")
+		cumplimiento := "sin plazos vencidos en el periodo"
+		if p := porcentajeCumplimiento(datos.RespondidosEnPlazo, datos.RespondidosFuera, datos.VencidosEnPeriodo); p != nil {
+			cumplimiento = fmt.Sprintf("<strong>%.1f %%</strong>", *p)
+		}
+		fmt.Fprintf(&b, `<p style="margin: 0;">%s — %d respondidos en plazo, %d fuera de plazo y %d vencidos sin respuesta.</p>`,
+			cumplimiento, datos.RespondidosEnPlazo, datos.RespondidosFuera, datos.VencidosEnPeriodo)
+	}
+
+	if tieneSeccion(d.Secciones, "categorias") && len(datos.Categorias) > 0 {
+		seccion("Principales categorías")
+		b.WriteString(`<table style="` + estiloTablaDigest + `">`)
+		for _, c := range datos.Categorias {
+			fmt.Fprintf(&b, `<tr><td style="%s">%s</td><td style="%s text-align: right;">%s</td></tr>`,
+				estiloCeldaDigest, html.EscapeString(c[0]), estiloCeldaDigest, c[1])
+		}
+		b.WriteString(`</table>`)
+	}
+
+	if tieneSeccion(d.Secciones, "carga") {
+		seccion("Carga por agente")
+		b.WriteString(`<table style="` + estiloTablaDigest + `"><tr style="` + estiloCabDigest + `"><th style="padding: 6px 8px;">Agente</th><th style="padding: 6px 8px;">Abiertos</th><th style="padding: 6px 8px;">Vencidos</th><th style="padding: 6px 8px;">Resueltos</th></tr>`)
+		for _, a := range datos.Agentes {
+			fmt.Fprintf(&b, `<tr><td style="%s">%s</td><td style="%s">%d</td><td style="%s">%d</td><td style="%s">%d</td></tr>`,
+				estiloCeldaDigest, html.EscapeString(a.Nombre), estiloCeldaDigest, a.Abiertos,
+				estiloCeldaDigest, a.Vencidos, estiloCeldaDigest, a.Resueltos)
+		}
+		fmt.Fprintf(&b, `<tr><td style="%s"><em>Sin asignar</em></td><td style="%s">%d</td><td style="%s"></td><td style="%s"></td></tr></table>`,
+			estiloCeldaDigest, estiloCeldaDigest, datos.SinAsignar, estiloCeldaDigest, estiloCeldaDigest)
+	}
+
+	titulo := "📊 Resumen diario"
+	if d.Frecuencia == "SEMANAL" {
+		titulo = "📊 Resumen semanal"
+	}
+	return generarEmailAviso(titulo, "#1e40af", b.String(),
+		"Ir al panel", config.FrontendURL+"/admin/dashboard",
+		html.EscapeString(d.Nombre)+" — resumen automático del Libro de Reclamaciones.")
+}
+
+// -----------------------------------------------------------------------------
+// Envío
+// -----------------------------------------------------------------------------
+
+// enviarDigest arma y envía el digest a cada destinatario y registra el envío.
+func enviarDigest(ctx context.Context, d digest, desde, hasta time.Time) error {
+	datos, err := calcularDatosDigest(ctx, desde, hasta)
+	if err != nil {
+		registrarEnvioDigest(ctx, d.ID, desde, hasta, nil, "ERROR", err.Error())
+		return err
+	}
+
+	contenido := generarEmailDigest(d, datos, desde, hasta)
+	asunto := fmt.Sprintf("📊 %s: %d nuevos, %d vencidos", d.Nombre, datos.Nuevos, datos.Vencidos)
+
+	var enviados, fallidos []string
+	for _, email := range d.Destinatarios {
+		if err := enviarEmailHTML(email, asunto, contenido); err != nil {
+			log.Printf("❌ Error enviando digest %s a %s: %v", d.Nombre, email, err)
+			fallidos = append(fallidos, email)
+			continue
+		}
+		enviados = append(enviados, email)
+	}
+
+	estado, detalle := "ENVIADO", ""
+	switch {
+	case len(enviados) == 0:
+		estado, detalle = "ERROR", "No se pudo enviar a ningún destinatario"
+	case len(fallidos) > 0:
+		estado, detalle = "PARCIAL", "Fallaron: "+strings.Join(fallidos, ", ")
+	}
+	registrarEnvioDigest(ctx, d.ID, desde, hasta, enviados, estado, detalle)
+	pool.Exec(ctx, "UPDATE digests SET ultimo_envio = NOW() WHERE id = $1", d.ID)
+
+	log.Printf("📊 Digest %s enviado a %d de %d destinatarios", d.Nombre, len(enviados), len(d.Destinatarios))
+	if estado == "ERROR" {
+		return errors.New(detalle)
+	}
+	return nil
+}
+
+func registrarEnvioDigest(ctx context.Context, digestID string, desde, hasta time.Time, destinatarios []string, estado, detalle string) {
+	_, err := pool.Exec(ctx, `
+		INSERT INTO digests_envios (digest_id, periodo_desde, periodo_hasta, destinatarios, estado, error)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''))
+	`, digestID, desde, hasta, destinatarios, estado, detalle)
+	if err != nil {
+		log.Printf("⚠️ Error registrando envío de digest %s: %v", digestID, err)
+	}
+}
+
+const sqlColumnasDigest = `id, nombre, frecuencia, COALESCE(dia_semana, 0), hora, destinatarios, secciones`
+
+func escanearDigest(row pgx.Row) (digest, error) {
+	var d digest
+	err := row.Scan(&d.ID, &d.Nombre, &d.Frecuencia, &d.DiaSemana, &d.Hora, &d.Destinatarios, &d.Secciones)
+	return d, err
+}
+
+// enviarDigestsProgramados es la tarea horaria.
+func enviarDigestsProgramados(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 10*time.Minute)
+	defer cancel()
+
+	rows, err := pool.Query(ctx, "SELECT "+sqlColumnasDigest+" FROM digests WHERE activo = true")
+	if err != nil {
+		log.Printf("⚠️ Error obteniendo digests: %v", err)
+		return
+	}
+	var digests []digest
+	for rows.Next() {
+		d, err := escanearDigest(rows)
+		if err != nil {
+			log.Printf("⚠️ Error leyendo digest: %v", err)
+			continue
+		}
+		digests = append(digests, d)
+	}
+	rows.Close()
+
+	ahora := time.Now()
+	for _, d := range digests {
+		desde, hasta, toca := periodoDigest(d, ahora)
+		if !toca {
+			continue
+		}
+		// Un envío por digest y periodo, aunque haya varias réplicas
+		ok, err := adquirirLease(ctx, "digest:"+d.ID+":"+hasta.Format("2006-01-02"), 8*24*time.Hour)
+		if err != nil || !ok {
+			continue
+		}
+		enviarDigest(ctx, d, desde, hasta)
+	}
+}
+
+// -----------------------------------------------------------------------------
+// API
+// -----------------------------------------------------------------------------
+
+type solicitudDigest struct {
+	Nombre        string   `json:"nombre" binding:"required,max=100"`
+	Frecuencia    string   `json:"frecuencia" binding:"required,oneof=DIARIO SEMANAL"`
+	DiaSemana     int      `json:"dia_semana" binding:"min=0,max=7"`
+	Hora          int      `json:"hora" binding:"min=0,max=23"`
+	Destinatarios []string `json:"destinatarios" binding:"required,min=1,max=20,dive,email"`
+	Secciones     []string `json:"secciones"`
+	Activo        *bool    `json:"activo"`
+}
+
+// validar completa los valores por defecto y revisa lo que binding no cubre.
+func (s *solicitudDigest) validar() error {
+	if s.Frecuencia == "SEMANAL" && s.DiaSemana == 0 {
+		return errors.New("indique dia_semana (1 = lunes ... 7 = domingo) para un digest semanal")
+	}
+	if s.Frecuencia == "DIARIO" {
+		s.DiaSemana = 0
+	}
+	if len(s.Secciones) == 0 {
+		s.Secciones = seccionesDigest
+	}
+	for _, sec := range s.Secciones {
+		if !tieneSeccion(seccionesDigest, sec) {
+			return fmt.Errorf("sección desconocida: %s", sec)
+		}
+	}
+	if s.Activo == nil {
+		activo := true
+		s.Activo = &activo
+	}
+	return nil
+}
+
+// GET /api/admin/digests - Digests configurados con su último envío
+func listarDigestsHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	rows, err := pool.Query(ctx, `
+		SELECT d.id, d.nombre, d.frecuencia, d.dia_semana, d.hora, d.destinatarios, d.secciones, d.activo,
+		       d.ultimo_envio, e.estado
+		FROM digests d
+		LEFT JOIN LATERAL (
+			SELECT estado FROM digests_envios WHERE digest_id = d.id ORDER BY fecha_envio DESC LIMIT 1
+		) e ON true
+		ORDER BY d.nombre
+	`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al obtener los digests"})
+		return
+	}
+	defer rows.Close()
+
+	digests := []gin.H{}
+	for rows.Next() {
+		var id, nombre, frecuencia string
+		var diaSemana *int
+		var hora int
+		var destinatarios, secciones []string
+		var activo bool
+		var ultimoEnvio *time.Time
+		var ultimoEstado *string
+		if err := rows.Scan(&id, &nombre, &frecuencia, &diaSemana, &hora, &destinatarios, &secciones, &activo, &ultimoEnvio, &ultimoEstado); err != nil {
+			continue
+		}
+		digests = append(digests, gin.H{
+			"id":            id,
+			"nombre":        nombre,
+			"frecuencia":    frecuencia,
+			"dia_semana":    diaSemana,
+			"hora":          hora,
+			"destinatarios": destinatarios,
+			"secciones":     secciones,
+			"activo":        activo,
+			"ultimo_envio":  ultimoEnvio,
+			"ultimo_estado": ultimoEstado,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": digests})
+}
+
+// POST /api/admin/digests - Crear un digest
+func crearDigestHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var req solicitudDigest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Datos inválidos: revise nombre, frecuencia, hora y destinatarios"})
+		return
+	}
+	if err := req.validar(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	var id string
+	err := pool.QueryRow(ctx, `
+		INSERT INTO digests (nombre, frecuencia, dia_semana, hora, destinatarios, secciones, activo, creado_por)
+		VALUES ($1, $2, NULLIF($3, 0), $4, $5, $6, $7, $8::uuid)
+		RETURNING id
+	`, req.Nombre, req.Frecuencia, req.DiaSemana, req.Hora, req.Destinatarios, req.Secciones, *req.Activo,
+		fmt.Sprintf("%v", c.MustGet("user_id"))).Scan(&id)
+	if err != nil {
+		log.Printf("❌ Error creando digest: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al crear el digest"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"success": true, "message": "Digest creado", "data": gin.H{"id": id}})
+}
+
+// PUT /api/admin/digests/:id - Modificar un digest
+func actualizarDigestHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var req solicitudDigest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Datos inválidos: revise nombre, frecuencia, hora y destinatarios"})
+		return
+	}
+	if err := req.validar(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	tag, err := pool.Exec(ctx, `
+		UPDATE digests
+		SET nombre = $2, frecuencia = $3, dia_semana = NULLIF($4, 0), hora = $5, destinatarios = $6, secciones = $7, activo = $8
+		WHERE id::text = $1
+	`, c.Param("id"), req.Nombre, req.Frecuencia, req.DiaSemana, req.Hora, req.Destinatarios, req.Secciones, *req.Activo)
+	if err != nil {
+		log.Printf("❌ Error actualizando digest: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al actualizar el digest"})
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Digest no encontrado"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Digest actualizado"})
+}
+
+// DELETE /api/admin/digests/:id - Eliminar un digest y su registro de envíos
+func eliminarDigestHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	tag, err := pool.Exec(ctx, "DELETE FROM digests WHERE id::text = $1", c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al eliminar el digest"})
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Digest no encontrado"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Digest eliminado"})
+}
+
+// GET /api/admin/digests/:id/envios - Registro de envíos
+func listarEnviosDigestHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	rows, err := pool.Query(ctx, `
+		SELECT id, periodo_desde, periodo_hasta, destinatarios, estado, error, fecha_envio
+		FROM digests_envios
+		WHERE digest_id::text = $1
+		ORDER BY fecha_envio DESC
+		LIMIT 100
+	`, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al obtener los envíos"})
+		return
+	}
+	defer rows.Close()
+
+	envios := []gin.H{}
+	for rows.Next() {
+		var id, estado string
+		var desde, hasta, fecha time.Time
+		var destinatarios []string
+		var errorMsg *string
+		if err := rows.Scan(&id, &desde, &hasta, &destinatarios, &estado, &errorMsg, &fecha); err != nil {
+			continue
+		}
+		envios = append(envios, gin.H{
+			"id":            id,
+			"periodo_desde": desde,
+			"periodo_hasta": hasta,
+			"destinatarios": destinatarios,
+			"estado":        estado,
+			"error":         errorMsg,
+			"fecha_envio":   fecha,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": envios})
+}
+
+// POST /api/admin/digests/:id/enviar - Enviar ahora el periodo más reciente (prueba)
+func enviarDigestAhoraHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
+	defer cancel()
+
+	d, err := escanearDigest(pool.QueryRow(ctx, "SELECT "+sqlColumnasDigest+" FROM digests WHERE id::text = $1", c.Param("id")))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Digest no encontrado"})
+		return
+	}
+
+	ahora := time.Now()
+	hasta := time.Date(ahora.Year(), ahora.Month(), ahora.Day(), 0, 0, 0, 0, ahora.Location())
+	desde := hasta.AddDate(0, 0, -1)
+	if d.Frecuencia == "SEMANAL" {
+		desde = hasta.AddDate(0, 0, -7)
+	}
+	if err := enviarDigest(ctx, d, desde, hasta); err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"success": false, "message": "No se pudo enviar el digest"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Digest enviado"})
+}
@@ -0,0 +1,387 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// =============================================================================
+// EXPORTACIÓN DE RECLAMOS (CSV / XLSX)
+// Respeta los mismos filtros que el listado y escribe fila por fila mientras
+// se leen de la BD. Los datos personales se enmascaran para roles sin acceso
+// completo y cada exportación queda en auditoria_admin.
+// =============================================================================
+
+// Cómo se enmascara una columna con datos personales
+const (
+	mascaraNinguna = iota
+	mascaraNombre
+	mascaraEmail
+	mascaraFinal // deja visibles los últimos caracteres
+	mascaraOculta
+)
+
+type columnaExport struct {
+	Clave   string
+	Titulo  string
+	Expr    string // siempre devuelve texto
+	Numero  bool
+	Mascara int
+}
+
+var columnasExport = []columnaExport{
+	{"codigo", "Código", "r.codigo_reclamo", false, mascaraNinguna},
+	{"tipo_solicitud", "Tipo", "r.tipo_solicitud", false, mascaraNinguna},
+	{"estado", "Estado", "r.estado", false, mascaraNinguna},
+	{"prioridad", "Prioridad", sqlPrioridad, false, mascaraNinguna},
+	{"fecha_registro", "Fecha registro", "to_char(r.fecha_registro, 'DD/MM/YYYY HH24:MI')", false, mascaraNinguna},
+	{"fecha_limite", "Fecha límite", "to_char(" + sqlFechaLimite + ", 'DD/MM/YYYY')", false, mascaraNinguna},
+	{"dias_restantes", "Días restantes", "(" + sqlFechaLimite + " - CURRENT_DATE)::text", true, mascaraNinguna},
+	{"fecha_respuesta", "Fecha respuesta", "COALESCE(to_char(r.fecha_respuesta, 'DD/MM/YYYY HH24:MI'), '')", false, mascaraNinguna},
+	{"nombre", "Nombre", "r.nombre_completo", false, mascaraNombre},
+	{"tipo_documento", "Tipo documento", "r.tipo_documento", false, mascaraNinguna},
+	{"numero_documento", "Documento", "r.numero_documento", false, mascaraFinal},
+	{"email", "Email", "r.email", false, mascaraEmail},
+	{"telefono", "Teléfono", "r.telefono", false, mascaraFinal},
+	{"domicilio", "Domicilio", "concat_ws(', ', r.domicilio, r.distrito, r.provincia, r.departamento)", false, mascaraOculta},
+	{"tipo_bien", "Tipo de bien", "COALESCE(r.tipo_bien, '')", false, mascaraNinguna},
+	{"monto", "Monto reclamado", "COALESCE(r.monto_reclamado, 0)::text", true, mascaraNinguna},
+	{"descripcion_bien", "Descripción del bien", "COALESCE(r.descripcion_bien, '')", false, mascaraNinguna},
+	{"detalle_reclamo", "Detalle", "r.detalle_reclamo", false, mascaraNinguna},
+	{"pedido_consumidor", "Pedido", "r.pedido_consumidor", false, mascaraNinguna},
+	{"establecimiento", "Establecimiento", "COALESCE(r.establecimiento, '')", false, mascaraNinguna},
+	{"atendido_por", "Atendido por", "COALESCE(ua.nombre_completo, '')", false, mascaraNinguna},
+	{"prioridad_asignada", "Prioridad asignada", "COALESCE(r.prioridad_asignada, '')", false, mascaraNinguna},
+	{"categoria", "Categoría", "COALESCE((SELECT cr.ruta FROM v_categorias_ruta cr WHERE cr.id = r.categoria_id), '')", false, mascaraNinguna},
+	{"etiquetas", "Etiquetas", "COALESCE((SELECT string_agg(er.etiqueta, ', ' ORDER BY er.etiqueta) FROM etiquetas_reclamos er WHERE er.reclamo_id = r.id), '')", false, mascaraNinguna},
+}
+
+var columnasExportPorDefecto = "codigo,tipo_solicitud,estado,prioridad,fecha_registro,fecha_limite,nombre,numero_documento,email,telefono,tipo_bien,monto,atendido_por"
+
+// parsearColumnasExport valida la lista ?columnas=a,b,c respetando su orden.
+func parsearColumnasExport(valor string) ([]columnaExport, error) {
+	if strings.TrimSpace(valor) == "" {
+		valor = columnasExportPorDefecto
+	}
+	var columnas []columnaExport
+	vistas := map[string]bool{}
+	for _, clave := range strings.Split(valor, ",") {
+		clave = strings.TrimSpace(clave)
+		if clave == "" || vistas[clave] {
+			continue
+		}
+		encontrada := false
+		for _, col := range columnasExport {
+			if col.Clave == clave {
+				columnas = append(columnas, col)
+				encontrada = true
+				break
+			}
+		}
+		if !encontrada {
+			return nil, fmt.Errorf("columna desconocida: %s", clave)
+		}
+		vistas[clave] = true
+	}
+	return columnas, nil
+}
+
+// rolAccesoCompleto indica si el rol puede ver los datos personales sin máscara.
+func rolAccesoCompleto(rol string) bool {
+	return rol == "ADMIN"
+}
+
+// enmascarar oculta el dato personal dejando lo justo para reconocerlo.
+func enmascarar(valor string, mascara int) string {
+	if valor == "" {
+		return ""
+	}
+	switch mascara {
+	case mascaraNombre:
+		// "Juan Pérez Gómez" → "Juan P. G."
+		partes := strings.Fields(valor)
+		for i := 1; i < len(partes); i++ {
+			r, _ := utf8.DecodeRuneInString(partes[i])
+			partes[i] = string(r) + "."
+		}
+		return strings.Join(partes, " ")
+	case mascaraEmail:
+		usuario, dominio, ok := strings.Cut(valor, "@")
+		if !ok {
+			return strings.Repeat("*", utf8.RuneCountInString(valor))
+		}
+		visibles := []rune(usuario)
+		if len(visibles) > 2 {
+			visibles = visibles[:2]
+		}
+		return string(visibles) + "***@" + dominio
+	case mascaraFinal:
+		r := []rune(valor)
+		if len(r) <= 3 {
+			return strings.Repeat("*", len(r))
+		}
+		return strings.Repeat("*", len(r)-3) + string(r[len(r)-3:])
+	case mascaraOculta:
+		return "[oculto]"
+	}
+	return valor
+}
+
+// neutralizarFormula evita que Excel interprete como fórmula un texto
+// ingresado por el consumidor (inyección CSV).
+func neutralizarFormula(valor string) string {
+	if valor != "" && strings.ContainsRune("=+-@\t\r", rune(valor[0])) {
+		return "'" + valor
+	}
+	return valor
+}
+
+// escritorExport abstrae el formato de salida.
+type escritorExport interface {
+	Encabezado(titulos []string) error
+	Fila(valores []string, numericos []bool) error
+	Cerrar() error
+}
+
+type csvExport struct{ w *csv.Writer }
+
+func nuevoCSV(w io.Writer) *csvExport {
+	io.WriteString(w, "\ufeff") // BOM para que Excel respete las tildes
+	return &csvExport{w: csv.NewWriter(w)}
+}
+
+func (e *csvExport) Encabezado(titulos []string) error { return e.w.Write(titulos) }
+
+func (e *csvExport) Fila(valores []string, numericos []bool) error {
+	for i, v := range valores {
+		if i >= len(numericos) || !numericos[i] {
+			valores[i] = neutralizarFormula(v)
+		}
+	}
+	return e.w.Write(valores)
+}
+
+func (e *csvExport) Cerrar() error {
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// solicitudExport reúne lo necesario para exportar un conjunto de reclamos.
+type solicitudExport struct {
+	Formato  string
+	Columnas []columnaExport
+	Where    string // condiciones sobre r (empiezan con " AND ")
+	Join     string
+	Orden    string
+	Args     []interface{}
+	Detalles gin.H // se agregan a la auditoría
+}
+
+// exportarReclamos escribe la respuesta en el formato pedido y audita la exportación.
+func exportarReclamos(ctx context.Context, c *gin.Context, s solicitudExport) {
+	userID := fmt.Sprintf("%v", c.MustGet("user_id"))
+	rol := fmt.Sprintf("%v", c.MustGet("rol"))
+	completo := rolAccesoCompleto(rol)
+
+	exprs := make([]string, len(s.Columnas))
+	titulos := make([]string, len(s.Columnas))
+	numericos := make([]bool, len(s.Columnas))
+	claves := make([]string, len(s.Columnas))
+	for i, col := range s.Columnas {
+		exprs[i] = col.Expr
+		titulos[i] = col.Titulo
+		numericos[i] = col.Numero
+		claves[i] = col.Clave
+	}
+
+	origen := `
+		FROM reclamos r
+		LEFT JOIN usuarios_admin ua ON ua.id = r.atendido_por` +
+		s.Join + " WHERE 1=1" + s.Where
+	query := "SELECT " + strings.Join(exprs, ", ") + origen + " ORDER BY " + s.Orden
+
+	// Un archivo recortado parece completo: si la selección supera el máximo
+	// se rechaza antes de empezar a escribir
+	if config.ExportMaxFilas > 0 {
+		var total int
+		err := pool.QueryRow(ctx, fmt.Sprintf("SELECT COUNT(*) FROM (SELECT 1 %s LIMIT %d) t", origen, config.ExportMaxFilas+1), s.Args...).Scan(&total)
+		if err != nil {
+			log.Printf("❌ Error contando reclamos a exportar: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al exportar"})
+			return
+		}
+		if total > config.ExportMaxFilas {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"success": false,
+				"message": fmt.Sprintf("La exportación supera el máximo de %d reclamos; acote los filtros", config.ExportMaxFilas),
+			})
+			return
+		}
+		// Por si entran reclamos entre el conteo y la consulta
+		query += fmt.Sprintf(" LIMIT %d", config.ExportMaxFilas+1)
+	}
+
+	rows, err := pool.Query(ctx, query, s.Args...)
+	if err != nil {
+		log.Printf("❌ Error exportando reclamos: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al exportar"})
+		return
+	}
+	defer rows.Close()
+
+	// El WriteTimeout del servidor cortaría a los 15s las exportaciones grandes
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("⚠️ No se pudo quitar el timeout de escritura de la exportación: %v", err)
+	}
+
+	nombre := "reclamos_" + time.Now().Format("20060102_150405")
+	var escritor escritorExport
+	if s.Formato == "xlsx" {
+		c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.xlsx"`, nombre))
+		libro, err := nuevoXLSX(c.Writer)
+		if err != nil {
+			log.Printf("❌ Error iniciando XLSX: %v", err)
+			return
+		}
+		escritor = libro
+	} else {
+		c.Header("Content-Type", "text/csv; charset=utf-8")
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.csv"`, nombre))
+		escritor = nuevoCSV(c.Writer)
+	}
+	c.Status(http.StatusOK)
+	escritor.Encabezado(titulos)
+
+	filas := 0
+	truncado := false
+	var errExport error
+	valores := make([]string, len(s.Columnas))
+	destinos := make([]interface{}, len(s.Columnas))
+	for i := range valores {
+		destinos[i] = &valores[i]
+	}
+	for rows.Next() {
+		if config.ExportMaxFilas > 0 && filas >= config.ExportMaxFilas {
+			truncado = true
+			break
+		}
+		if err := rows.Scan(destinos...); err != nil {
+			errExport = fmt.Errorf("leyendo fila: %w", err)
+			break
+		}
+		if !completo {
+			for i, col := range s.Columnas {
+				valores[i] = enmascarar(valores[i], col.Mascara)
+			}
+		}
+		if err := escritor.Fila(valores, numericos); err != nil {
+			// Normalmente el cliente cortó la descarga
+			errExport = fmt.Errorf("escribiendo fila: %w", err)
+			break
+		}
+		filas++
+	}
+	if err := rows.Err(); err != nil && errExport == nil {
+		errExport = fmt.Errorf("leyendo reclamos: %w", err)
+	}
+	// Con error no se cierra el archivo: un XLSX sin cerrar no abre y el CSV
+	// queda sin volcar su último bloque, en vez de parecer completos
+	if errExport == nil {
+		if err := escritor.Cerrar(); err != nil {
+			errExport = fmt.Errorf("cerrando archivo: %w", err)
+		}
+	}
+	if errExport != nil {
+		log.Printf("⚠️ Exportación interrumpida tras %d filas: %v", filas, errExport)
+	}
+	if truncado {
+		log.Printf("⚠️ Exportación recortada a %d filas: entraron reclamos durante la descarga", filas)
+	}
+
+	detalles := gin.H{
+		"formato":     s.Formato,
+		"columnas":    claves,
+		"filas":       filas,
+		"enmascarado": !completo,
+		"truncado":    truncado,
+		"completa":    errExport == nil && !truncado,
+	}
+	if errExport != nil {
+		detalles["error"] = errExport.Error()
+	}
+	for k, v := range s.Detalles {
+		detalles[k] = v
+	}
+	_, errAudit := pool.Exec(context.Background(), `
+		INSERT INTO auditoria_admin (usuario_id, accion, entidad, detalles, ip_address)
+		VALUES ($1::uuid, 'EXPORTAR', 'RECLAMO', $2, $3)
+	`, userID, detalles, c.ClientIP())
+	if errAudit != nil {
+		log.Printf("⚠️ Error registrando auditoría: %v", errAudit)
+	}
+
+	log.Printf("📤 Exportación %s de %d reclamos por %v", s.Formato, filas, c.MustGet("email"))
+}
+
+// GET /api/admin/reclamos/export?format=csv|xlsx - Exportar con los filtros del listado
+func exportarReclamosHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 5*time.Minute)
+	defer cancel()
+
+	formato := c.DefaultQuery("format", "csv")
+	if formato != "csv" && formato != "xlsx" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Formato inválido: use csv o xlsx"})
+		return
+	}
+
+	q, err := aplicarFiltroGuardado(ctx, fmt.Sprintf("%v", c.MustGet("user_id")), c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+	filtros, err := parsearFiltros(q)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+	columnas, err := parsearColumnasExport(q.Get("columnas"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	var busqueda consultaBusqueda
+	if filtros.Busqueda != "" {
+		busqueda = construirBusqueda(filtros.Busqueda, arg)
+	}
+	claves, err := parsearOrden(q.Get("orden"), busqueda.Relevancia)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	exportarReclamos(ctx, c, solicitudExport{
+		Formato:  formato,
+		Columnas: columnas,
+		Where:    filtros.where(arg) + busqueda.Where,
+		Join:     busqueda.Join,
+		Orden:    sqlOrden(claves),
+		Args:     args,
+		Detalles: gin.H{"filtros": parametrosGuardables(q).Encode()},
+	})
+}
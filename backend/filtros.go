@@ -0,0 +1,568 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// =============================================================================
+// FILTROS DEL LISTADO DE RECLAMOS
+// Se parsean una sola vez desde la query (o desde un filtro guardado) y
+// generan el WHERE con parámetros numerados. Los reutilizan el listado, las
+// operaciones masivas y la exportación.
+// =============================================================================
+
+// Prioridad del reclamo con el plazo vigente (mismos cortes que las vistas)
+const sqlPrioridad = `CASE
+		WHEN r.estado IN ('RESUELTO', 'CERRADO') THEN 'COMPLETADO'
+		WHEN ` + sqlFechaLimite + ` < CURRENT_DATE THEN 'VENCIDO'
+		WHEN ` + sqlFechaLimite + ` - CURRENT_DATE <= 3 THEN 'URGENTE'
+		ELSE 'EN_TIEMPO'
+	END`
+
+var (
+	estadosValidos     = []string{"PENDIENTE", "EN_PROCESO", "RESUELTO", "CERRADO"}
+	tiposValidos       = []string{"RECLAMO", "QUEJA"}
+	tiposBienValidos   = []string{"PRODUCTO", "SERVICIO"}
+	prioridadesValidas = []string{"VENCIDO", "URGENTE", "EN_TIEMPO", "COMPLETADO"}
+	reUUID             = regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`)
+)
+
+type FiltrosReclamos struct {
+	Estados             []string
+	Tipos               []string
+	TiposBien           []string
+	Prioridades         []string
+	PrioridadAsignada   []string // la que fija el triaje automático
+	Desde, Hasta        *time.Time
+	MontoMin, MontoMax  *float64
+	Agente              string // UUID o "sin_asignar"
+	Establecimiento     string
+	NumeroDocumento     string
+	Etiqueta            string
+	Categoria           string // UUID (incluye subcategorías) o "sin_categoria"
+	EsperandoRespuesta  bool
+	EsperandoConsumidor bool
+	Busqueda            string
+}
+
+// Parámetros que forman parte de un filtro (y de un filtro guardado)
+var parametrosFiltro = []string{
+	"estado", "tipo_solicitud", "tipo_bien", "prioridad", "prioridad_asignada", "fecha_desde", "fecha_hasta",
+	"monto_min", "monto_max", "agente", "establecimiento", "numero_documento", "etiqueta",
+	"categoria", "esperando_respuesta", "esperando_consumidor", "search", "orden",
+}
+
+// listaValores separa "A,B" y valida contra los permitidos (en mayúsculas).
+func listaValores(valor, nombre string, permitidos []string) ([]string, error) {
+	if strings.TrimSpace(valor) == "" {
+		return nil, nil
+	}
+	var valores []string
+	for _, v := range strings.Split(valor, ",") {
+		v = strings.ToUpper(strings.TrimSpace(v))
+		if v == "" {
+			continue
+		}
+		valido := false
+		for _, p := range permitidos {
+			if v == p {
+				valido = true
+				break
+			}
+		}
+		if !valido {
+			return nil, fmt.Errorf("%s inválido: %s", nombre, v)
+		}
+		valores = append(valores, v)
+	}
+	return valores, nil
+}
+
+func parsearFecha(valor, nombre string) (*time.Time, error) {
+	if valor == "" {
+		return nil, nil
+	}
+	f, err := time.Parse("2006-01-02", valor)
+	if err != nil {
+		return nil, fmt.Errorf("%s debe tener formato AAAA-MM-DD", nombre)
+	}
+	return &f, nil
+}
+
+func parsearMonto(valor, nombre string) (*float64, error) {
+	if valor == "" {
+		return nil, nil
+	}
+	m, err := strconv.ParseFloat(valor, 64)
+	if err != nil || m < 0 {
+		return nil, fmt.Errorf("%s debe ser un número positivo", nombre)
+	}
+	return &m, nil
+}
+
+// parsearFiltros valida los parámetros; el error se muestra tal cual al usuario.
+func parsearFiltros(q url.Values) (FiltrosReclamos, error) {
+	var f FiltrosReclamos
+	var err error
+
+	if f.Estados, err = listaValores(q.Get("estado"), "estado", estadosValidos); err != nil {
+		return f, err
+	}
+	if f.Tipos, err = listaValores(q.Get("tipo_solicitud"), "tipo_solicitud", tiposValidos); err != nil {
+		return f, err
+	}
+	if f.TiposBien, err = listaValores(q.Get("tipo_bien"), "tipo_bien", tiposBienValidos); err != nil {
+		return f, err
+	}
+	if f.Prioridades, err = listaValores(q.Get("prioridad"), "prioridad", prioridadesValidas); err != nil {
+		return f, err
+	}
+	if f.PrioridadAsignada, err = listaValores(q.Get("prioridad_asignada"), "prioridad_asignada", prioridadesAsignables); err != nil {
+		return f, err
+	}
+	if f.Desde, err = parsearFecha(q.Get("fecha_desde"), "fecha_desde"); err != nil {
+		return f, err
+	}
+	if f.Hasta, err = parsearFecha(q.Get("fecha_hasta"), "fecha_hasta"); err != nil {
+		return f, err
+	}
+	if f.Desde != nil && f.Hasta != nil && f.Hasta.Before(*f.Desde) {
+		return f, errors.New("fecha_hasta no puede ser anterior a fecha_desde")
+	}
+	if f.MontoMin, err = parsearMonto(q.Get("monto_min"), "monto_min"); err != nil {
+		return f, err
+	}
+	if f.MontoMax, err = parsearMonto(q.Get("monto_max"), "monto_max"); err != nil {
+		return f, err
+	}
+
+	f.Agente = strings.TrimSpace(q.Get("agente"))
+	if f.Agente != "" && f.Agente != "sin_asignar" && !reUUID.MatchString(f.Agente) {
+		return f, errors.New("agente debe ser un ID de usuario o sin_asignar")
+	}
+
+	f.Establecimiento = strings.TrimSpace(q.Get("establecimiento"))
+	f.NumeroDocumento = strings.TrimSpace(q.Get("numero_documento"))
+	f.Etiqueta = strings.ToLower(strings.TrimSpace(q.Get("etiqueta")))
+	f.Categoria = strings.ToLower(strings.TrimSpace(q.Get("categoria")))
+	if f.Categoria != "" && f.Categoria != "sin_categoria" && !reUUID.MatchString(f.Categoria) {
+		return f, errors.New("categoria debe ser un ID de categoría o sin_categoria")
+	}
+	f.EsperandoRespuesta = q.Get("esperando_respuesta") == "true"
+	f.EsperandoConsumidor = q.Get("esperando_consumidor") == "true"
+	f.Busqueda = strings.TrimSpace(q.Get("search"))
+	return f, nil
+}
+
+// where devuelve las condiciones (cada una empieza con " AND "); arg registra
+// un parámetro y devuelve su placeholder.
+func (f FiltrosReclamos) where(arg func(interface{}) string) string {
+	var b strings.Builder
+
+	if len(f.Estados) > 0 {
+		fmt.Fprintf(&b, " AND r.estado = ANY(%s)", arg(f.Estados))
+	}
+	if len(f.Tipos) > 0 {
+		fmt.Fprintf(&b, " AND r.tipo_solicitud = ANY(%s)", arg(f.Tipos))
+	}
+	if len(f.TiposBien) > 0 {
+		fmt.Fprintf(&b, " AND r.tipo_bien = ANY(%s)", arg(f.TiposBien))
+	}
+	if len(f.Prioridades) > 0 {
+		fmt.Fprintf(&b, " AND (%s) = ANY(%s)", sqlPrioridad, arg(f.Prioridades))
+	}
+	if len(f.PrioridadAsignada) > 0 {
+		fmt.Fprintf(&b, " AND r.prioridad_asignada = ANY(%s)", arg(f.PrioridadAsignada))
+	}
+	if f.Desde != nil {
+		fmt.Fprintf(&b, " AND r.fecha_registro >= %s::date", arg(f.Desde.Format("2006-01-02")))
+	}
+	if f.Hasta != nil {
+		// Hasta es inclusivo: todo el día indicado
+		fmt.Fprintf(&b, " AND r.fecha_registro < %s::date + 1", arg(f.Hasta.Format("2006-01-02")))
+	}
+	if f.MontoMin != nil {
+		fmt.Fprintf(&b, " AND COALESCE(r.monto_reclamado, 0) >= %s", arg(*f.MontoMin))
+	}
+	if f.MontoMax != nil {
+		fmt.Fprintf(&b, " AND COALESCE(r.monto_reclamado, 0) <= %s", arg(*f.MontoMax))
+	}
+	switch f.Agente {
+	case "":
+	case "sin_asignar":
+		b.WriteString(" AND r.atendido_por IS NULL")
+	default:
+		fmt.Fprintf(&b, " AND r.atendido_por = %s::uuid", arg(f.Agente))
+	}
+	if f.Establecimiento != "" {
+		fmt.Fprintf(&b, " AND r.establecimiento = %s", arg(f.Establecimiento))
+	}
+	if f.NumeroDocumento != "" {
+		fmt.Fprintf(&b, " AND r.numero_documento = %s", arg(f.NumeroDocumento))
+	}
+	if f.Etiqueta != "" {
+		fmt.Fprintf(&b, " AND EXISTS (SELECT 1 FROM etiquetas_reclamos er WHERE er.reclamo_id = r.id AND er.etiqueta = %s)", arg(f.Etiqueta))
+	}
+	switch f.Categoria {
+	case "":
+	case "sin_categoria":
+		b.WriteString(" AND r.categoria_id IS NULL")
+	default:
+		fmt.Fprintf(&b, " AND r.categoria_id IN (SELECT id FROM v_categorias_ruta WHERE %s::uuid = ANY(ancestros))", arg(f.Categoria))
+	}
+	// Reclamos cuyo último mensaje es del consumidor: esperan nuestra respuesta
+	if f.EsperandoRespuesta {
+		b.WriteString(" AND " + sqlUltimoMensajeTipo + " = 'CLIENTE'")
+	}
+	// Reclamos con el plazo en pausa esperando información del consumidor
+	if f.EsperandoConsumidor {
+		b.WriteString(" AND r.pausado_desde IS NOT NULL")
+	}
+	return b.String()
+}
+
+// =============================================================================
+// ORDEN MULTICOLUMNA
+// orden=prioridad,-fecha_registro: lista de campos, "-" para descendente.
+// Siempre se agrega r.id como desempate para que el cursor sea estable.
+// =============================================================================
+
+type campoOrden struct {
+	Expr string
+	Tipo string // cast del valor en el cursor
+}
+
+var camposOrden = map[string]campoOrden{
+	"fecha_registro": {"r.fecha_registro", "timestamp"},
+	"fecha_limite":   {sqlFechaLimite, "date"},
+	"dias_restantes": {"(" + sqlFechaLimite + " - CURRENT_DATE)::int", "int"},
+	"monto":          {"COALESCE(r.monto_reclamado, 0)::float8", "float8"},
+	"estado":         {"r.estado", "text"},
+	"tipo_solicitud": {"r.tipo_solicitud", "text"},
+	"codigo":         {"r.codigo_reclamo", "text"},
+	"nombre":         {"r.nombre_completo", "text"},
+	"prioridad": {`CASE
+		WHEN r.estado IN ('RESUELTO', 'CERRADO') THEN 3
+		WHEN ` + sqlFechaLimite + ` < CURRENT_DATE THEN 0
+		WHEN ` + sqlFechaLimite + ` - CURRENT_DATE <= 3 THEN 1
+		ELSE 2 END`, "int"},
+}
+
+type claveOrden struct {
+	campoOrden
+	Desc bool
+}
+
+// parsearOrden valida el orden pedido; "relevancia" solo existe con búsqueda.
+func parsearOrden(valor, relevancia string) ([]claveOrden, error) {
+	if strings.TrimSpace(valor) == "" {
+		valor = "-fecha_registro"
+		if relevancia != "" {
+			valor = "-relevancia,-fecha_registro"
+		}
+	}
+
+	var claves []claveOrden
+	vistos := map[string]bool{}
+	for _, campo := range strings.Split(valor, ",") {
+		campo = strings.TrimSpace(campo)
+		desc := strings.HasPrefix(campo, "-")
+		campo = strings.TrimPrefix(campo, "-")
+		if campo == "" || vistos[campo] {
+			continue
+		}
+		vistos[campo] = true
+
+		def, ok := camposOrden[campo]
+		if campo == "relevancia" && relevancia != "" {
+			def, ok = campoOrden{relevancia, "float8"}, true
+		}
+		if !ok {
+			return nil, fmt.Errorf("no se puede ordenar por %s", campo)
+		}
+		claves = append(claves, claveOrden{def, desc})
+		if len(claves) == 4 {
+			break
+		}
+	}
+	return append(claves, claveOrden{campoOrden{"r.id", "uuid"}, false}), nil
+}
+
+func sqlOrden(claves []claveOrden) string {
+	partes := make([]string, len(claves))
+	for i, k := range claves {
+		partes[i] = k.Expr + " ASC"
+		if k.Desc {
+			partes[i] = k.Expr + " DESC"
+		}
+	}
+	return strings.Join(partes, ", ")
+}
+
+// sqlValoresCursor devuelve los valores de orden de la fila como arreglo JSON;
+// el listado lo usa para armar el siguiente cursor.
+func sqlValoresCursor(claves []claveOrden) string {
+	exprs := make([]string, len(claves))
+	for i, k := range claves {
+		exprs[i] = k.Expr
+	}
+	return "json_build_array(" + strings.Join(exprs, ", ") + ")::text"
+}
+
+// =============================================================================
+// PAGINACIÓN POR CURSOR (KEYSET)
+// El cursor es opaco para el cliente: base64 del orden y los valores de la
+// última fila. Solo es válido con el mismo orden con el que se generó.
+// =============================================================================
+
+type cursorListado struct {
+	Orden   string            `json:"o"`
+	Valores []json.RawMessage `json:"v"`
+}
+
+func codificarCursor(orden, valores string) string {
+	var v []json.RawMessage
+	if json.Unmarshal([]byte(valores), &v) != nil {
+		return ""
+	}
+	datos, _ := json.Marshal(cursorListado{Orden: orden, Valores: v})
+	return base64.RawURLEncoding.EncodeToString(datos)
+}
+
+func decodificarCursor(cursor, orden string, claves []claveOrden) ([]string, error) {
+	errCursor := errors.New("cursor inválido o de otro orden")
+
+	datos, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return nil, errCursor
+	}
+	var cur cursorListado
+	if json.Unmarshal(datos, &cur) != nil || cur.Orden != orden || len(cur.Valores) != len(claves) {
+		return nil, errCursor
+	}
+
+	valores := make([]string, len(cur.Valores))
+	for i, raw := range cur.Valores {
+		var s string
+		if json.Unmarshal(raw, &s) == nil {
+			valores[i] = s
+		} else {
+			valores[i] = string(raw) // números
+		}
+	}
+	return valores, nil
+}
+
+// sqlKeyset arma la condición "fila posterior al cursor" respetando la
+// dirección de cada clave: (k1 > v1) OR (k1 = v1 AND k2 < v2) OR ...
+func sqlKeyset(claves []claveOrden, valores []string, arg func(interface{}) string) string {
+	placeholders := make([]string, len(claves))
+	for i, k := range claves {
+		placeholders[i] = fmt.Sprintf("%s::text::%s", arg(valores[i]), k.Tipo)
+	}
+
+	var ramas []string
+	for i, k := range claves {
+		var partes []string
+		for j := 0; j < i; j++ {
+			partes = append(partes, fmt.Sprintf("%s = %s", claves[j].Expr, placeholders[j]))
+		}
+		op := ">"
+		if k.Desc {
+			op = "<"
+		}
+		partes = append(partes, fmt.Sprintf("%s %s %s", k.Expr, op, placeholders[i]))
+		ramas = append(ramas, "("+strings.Join(partes, " AND ")+")")
+	}
+	return " AND (" + strings.Join(ramas, " OR ") + ")"
+}
+
+// =============================================================================
+// FILTROS GUARDADOS POR USUARIO
+// =============================================================================
+
+// parametrosGuardables deja solo los parámetros de filtro y orden.
+func parametrosGuardables(q url.Values) url.Values {
+	limpios := url.Values{}
+	for _, p := range parametrosFiltro {
+		if v := strings.TrimSpace(q.Get(p)); v != "" {
+			limpios.Set(p, v)
+		}
+	}
+	return limpios
+}
+
+// aplicarFiltroGuardado combina el filtro ?filtro=<id> del usuario con la
+// query: lo que venga explícito en la query tiene prioridad.
+func aplicarFiltroGuardado(ctx context.Context, userID string, q url.Values) (url.Values, error) {
+	id := q.Get("filtro")
+	if id == "" {
+		return q, nil
+	}
+
+	var parametros string
+	err := pool.QueryRow(ctx, `
+		SELECT parametros FROM filtros_guardados WHERE id::text = $1 AND usuario_id = $2::uuid
+	`, id, userID).Scan(&parametros)
+	if err != nil {
+		return nil, errors.New("filtro guardado no encontrado")
+	}
+
+	guardados, _ := url.ParseQuery(parametros)
+	combinados := url.Values{}
+	for k, v := range guardados {
+		combinados[k] = v
+	}
+	for k, v := range q {
+		if k != "filtro" && len(v) > 0 && v[0] != "" {
+			combinados[k] = v
+		}
+	}
+	return combinados, nil
+}
+
+// GET /api/admin/filtros - Filtros guardados del usuario
+func listarFiltrosGuardadosHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	rows, err := pool.Query(ctx, `
+		SELECT id, nombre, parametros, predeterminado, fecha_creacion
+		FROM filtros_guardados
+		WHERE usuario_id = $1::uuid
+		ORDER BY predeterminado DESC, nombre ASC
+	`, fmt.Sprintf("%v", c.MustGet("user_id")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al obtener los filtros"})
+		return
+	}
+	defer rows.Close()
+
+	filtros := []gin.H{}
+	for rows.Next() {
+		var id, nombre, parametros string
+		var predeterminado bool
+		var fecha time.Time
+		if err := rows.Scan(&id, &nombre, &parametros, &predeterminado, &fecha); err != nil {
+			continue
+		}
+		valores, _ := url.ParseQuery(parametros)
+		campos := gin.H{}
+		for k := range valores {
+			campos[k] = valores.Get(k)
+		}
+		filtros = append(filtros, gin.H{
+			"id":             id,
+			"nombre":         nombre,
+			"parametros":     campos,
+			"query":          parametros,
+			"predeterminado": predeterminado,
+			"fecha_creacion": fecha,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": filtros})
+}
+
+// POST /api/admin/filtros - Guardar (o reemplazar por nombre) un filtro
+func guardarFiltroHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	userID := fmt.Sprintf("%v", c.MustGet("user_id"))
+
+	var req struct {
+		Nombre         string            `json:"nombre" binding:"required,min=1,max=100"`
+		Parametros     map[string]string `json:"parametros" binding:"required"`
+		Predeterminado bool              `json:"predeterminado"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Indique un nombre y los parámetros del filtro"})
+		return
+	}
+
+	q := url.Values{}
+	for k, v := range req.Parametros {
+		q.Set(k, v)
+	}
+	q = parametrosGuardables(q)
+	if len(q) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "El filtro no tiene parámetros válidos"})
+		return
+	}
+	filtros, err := parsearFiltros(q)
+	if err == nil {
+		_, err = parsearOrden(q.Get("orden"), filtros.Busqueda)
+	}
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al guardar el filtro"})
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	// Un solo filtro predeterminado por usuario
+	if req.Predeterminado {
+		if _, err := tx.Exec(ctx, "UPDATE filtros_guardados SET predeterminado = false WHERE usuario_id = $1::uuid", userID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al guardar el filtro"})
+			return
+		}
+	}
+
+	var id string
+	err = tx.QueryRow(ctx, `
+		INSERT INTO filtros_guardados (usuario_id, nombre, parametros, predeterminado)
+		VALUES ($1::uuid, $2, $3, $4)
+		ON CONFLICT (usuario_id, nombre) DO UPDATE
+		SET parametros = EXCLUDED.parametros, predeterminado = EXCLUDED.predeterminado
+		RETURNING id
+	`, userID, strings.TrimSpace(req.Nombre), q.Encode(), req.Predeterminado).Scan(&id)
+	if err != nil {
+		log.Printf("❌ Error guardando filtro: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al guardar el filtro"})
+		return
+	}
+	if err := tx.Commit(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al guardar el filtro"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"success": true, "message": "Filtro guardado", "data": gin.H{"id": id, "query": q.Encode()}})
+}
+
+// DELETE /api/admin/filtros/:id - Eliminar un filtro guardado propio
+func eliminarFiltroHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	tag, err := pool.Exec(ctx, "DELETE FROM filtros_guardados WHERE id::text = $1 AND usuario_id = $2::uuid",
+		c.Param("id"), fmt.Sprintf("%v", c.MustGet("user_id")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al eliminar el filtro"})
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Filtro no encontrado"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Filtro eliminado"})
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// =============================================================================
+// ACCESO PÚBLICO A RECLAMOS
+// Los códigos son secuenciales (CODEPLEX-2026-00001), así que por sí solos no
+// prueban nada. Las rutas públicas exigen el documento del consumidor o un
+// token firmado y con vencimiento que se envía por email.
+// =============================================================================
+
+const (
+	alcanceReclamo = "reclamo" // datos, firma y seguimiento
+	alcanceFirma   = "firma"   // solo la imagen de la firma (link a soporte)
+	alcanceEventos = "eventos" // solo el stream SSE (token corto)
+)
+
+type claimsAccesoReclamo struct {
+	Codigo  string `json:"codigo"`
+	Alcance string `json:"alcance"`
+	jwt.RegisteredClaims
+}
+
+// claveTokensConsumidor deriva una clave distinta a la de los JWT de admin,
+// para que un token de consumidor nunca sirva como Bearer del panel.
+func claveTokensConsumidor() []byte {
+	return []byte(jwtSecret + "|consumidor")
+}
+
+func firmarTokenAcceso(codigo, alcance string, duracion time.Duration) (string, error) {
+	ahora := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claimsAccesoReclamo{
+		Codigo:  codigo,
+		Alcance: alcance,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(ahora),
+			ExpiresAt: jwt.NewNumericDate(ahora.Add(duracion)),
+		},
+	})
+	return token.SignedString(claveTokensConsumidor())
+}
+
+// validarTokenAcceso acepta el token si es para este código y su alcance
+// cubre el solicitado (un token de reclamo también permite ver la firma).
+func validarTokenAcceso(tokenString, codigo, alcance string) bool {
+	var claims claimsAccesoReclamo
+	token, err := jwt.ParseWithClaims(tokenString, &claims, func(t *jwt.Token) (interface{}, error) {
+		return claveTokensConsumidor(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}), jwt.WithExpirationRequired())
+	if err != nil || !token.Valid {
+		return false
+	}
+	return claims.Codigo == codigo && (claims.Alcance == alcance || claims.Alcance == alcanceReclamo)
+}
+
+func duracionTokenAcceso() time.Duration {
+	return time.Duration(config.TokenAccesoDias) * 24 * time.Hour
+}
+
+// enlaceSeguimientoFirmado es el link que recibe el consumidor por email.
+func enlaceSeguimientoFirmado(codigo string) string {
+	token, err := firmarTokenAcceso(codigo, alcanceReclamo, duracionTokenAcceso())
+	if err != nil {
+		log.Printf("⚠️ Error firmando token de acceso: %v", err)
+		return fmt.Sprintf("%s/seguimiento?codigo=%s", config.FrontendURL, url.QueryEscape(codigo))
+	}
+	return fmt.Sprintf("%s/seguimiento?codigo=%s&token=%s", config.FrontendURL, url.QueryEscape(codigo), token)
+}
+
+// enlaceFirmaFirmado es el link "Ver Firma" del email a soporte.
+func enlaceFirmaFirmado(codigo string) string {
+	base := fmt.Sprintf("%s/api/reclamos/%s/firma", config.BackendURL, url.PathEscape(codigo))
+	token, err := firmarTokenAcceso(codigo, alcanceFirma, duracionTokenAcceso())
+	if err != nil {
+		log.Printf("⚠️ Error firmando token de firma: %v", err)
+		return base
+	}
+	return base + "?token=" + token
+}
+
+// =============================================================================
+// AUTORIZACIÓN Y BLOQUEO DE CONSULTAS FALLIDAS
+// =============================================================================
+
+// politicaConsultasFallidas se consume solo cuando una consulta falla; al
+// agotarse, la IP recibe 429 incluso antes de comprobar el documento.
+var politicaConsultasFallidas = PoliticaRateLimit{Nombre: "consultas_fallidas", Capacidad: 10, Periodo: 15 * time.Minute, Clave: clavePorIP}
+
+// autorizarConsultaPublica comprueba ?documento=, ?token= o la sesión del
+// portal (Authorization: Bearer) para el código. Si no autoriza, ya respondió
+// al cliente y el handler debe retornar.
+func autorizarConsultaPublica(c *gin.Context, ctx context.Context, codigo, alcance string) bool {
+	documento := c.Query("documento")
+	token := c.Query("token")
+	sesion, haySesion := sesionConsumidor(c)
+
+	if documento == "" && token == "" && !haySesion {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Número de documento o token de acceso requerido"})
+		return false
+	}
+
+	clave := politicaConsultasFallidas.Nombre + "|" + politicaConsultasFallidas.Clave(c)
+	if limitador != nil {
+		res, err := limitador.Consultar(ctx, clave, politicaConsultasFallidas, time.Now())
+		if err == nil && !res.Permitido {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(res.Reintentar.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"success": false, "message": "Demasiados intentos fallidos, intente más tarde"})
+			return false
+		}
+	}
+
+	autorizado := false
+	if token != "" {
+		autorizado = validarTokenAcceso(token, codigo, alcance)
+	} else if documento == "" {
+		var err error
+		if autorizado, err = sesionCubreReclamo(ctx, sesion, codigo); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al consultar el reclamo"})
+			return false
+		}
+	} else {
+		err := pool.QueryRow(ctx, `
+			SELECT EXISTS (SELECT 1 FROM reclamos WHERE codigo_reclamo = $1 AND numero_documento = $2)
+		`, codigo, documento).Scan(&autorizado)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al consultar el reclamo"})
+			return false
+		}
+	}
+
+	if !autorizado {
+		if limitador != nil {
+			if _, err := limitador.Consumir(ctx, clave, politicaConsultasFallidas, time.Now()); err != nil {
+				log.Printf("⚠️ Error en rate limit %s: %v", politicaConsultasFallidas.Nombre, err)
+			}
+		}
+		registrarIntentoRechazado("SEGUIMIENTO", "CONSULTA_FALLIDA", c.ClientIP(), c.GetHeader("User-Agent"), documento, c.Request.URL.Path)
+		// Misma respuesta exista o no el código, para no revelar cuáles son válidos
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Reclamo no encontrado o documento no coincide"})
+		return false
+	}
+
+	return true
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"math"     // <--- NUEVO
@@ -13,6 +14,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/jackc/pgx/v5"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -162,78 +164,143 @@ func listarReclamosAdminHandler(c *gin.Context) {
 	// 1. Obtener parámetros de paginación
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "30")) // 30 registros por defecto
-	estado := c.Query("estado")
-	search := c.Query("search") // Opcional: Preparado para búsqueda futura
 
 	if page < 1 { page = 1 }
 	if limit < 1 { limit = 30 }
+	if limit > 200 { limit = 200 }
 	offset := (page - 1) * limit
 
-	// 2. Construir filtros dinámicos (WHERE)
-	whereClause := " WHERE 1=1"
+	// Con cursor (o paginacion=cursor) se pagina por keyset y no se cuenta el total
+	cursor := c.Query("cursor")
+	porCursor := cursor != "" || c.Query("paginacion") == "cursor"
+
+	// 2. Filtros: los de la query, sobre un filtro guardado si se indica ?filtro=<id>
+	q, err := aplicarFiltroGuardado(ctx, fmt.Sprintf("%v", c.MustGet("user_id")), c.Request.URL.Query())
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+	filtros, err := parsearFiltros(q)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
 	args := []interface{}{}
 	argID := 1
 
-	if estado != "" {
-		whereClause += fmt.Sprintf(" AND r.estado = $%d", argID)
-		args = append(args, estado)
+	// arg registra un parámetro y devuelve su placeholder: cada valor se usa una vez
+	arg := func(valor interface{}) string {
+		args = append(args, valor)
 		argID++
+		return fmt.Sprintf("$%d", argID-1)
 	}
 
-	if search != "" {
-		searchPattern := "%" + search + "%"
-		whereClause += fmt.Sprintf(" AND (r.codigo_reclamo ILIKE $%d OR r.nombre_completo ILIKE $%d OR r.email ILIKE $%d)", argID, argID, argID)
-		args = append(args, searchPattern) // Se usa el mismo argumento 3 veces en el OR, pero PG prefiere índices únicos si es driver estricto, aquí simplificamos uso secuencial
-		argID++
+	// Búsqueda de texto completo: ordena por relevancia y devuelve un fragmento
+	busqueda := consultaBusqueda{Columnas: columnasSinBusqueda}
+	if filtros.Busqueda != "" {
+		busqueda = construirBusqueda(filtros.Busqueda, arg)
 	}
+	whereClause := " WHERE 1=1" + filtros.where(arg) + busqueda.Where
 
-	// 3. CONSULTA 1: Contar el total de registros (para saber cuántas páginas hay)
-	var total int64
-	countQuery := "SELECT COUNT(*) FROM reclamos r" + whereClause
-	
-	// Nota: Si usas el driver pgx puro a veces requiere reajuste de argumentos posicionales ($1, $2...) 
-	// Para simplificar, asumimos que el filtro de búsqueda simple no choca.
-	// Si search se complica, mejor hacerlo condicional.
-	
-	err := pool.QueryRow(ctx, countQuery, args...).Scan(&total)
+	claves, err := parsearOrden(q.Get("orden"), busqueda.Relevancia)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error contando registros"})
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
 		return
 	}
+	ordenTexto := q.Get("orden")
+
+	// 3. CONSULTA 1: Contar el total de registros (para saber cuántas páginas hay)
+	var total int64
+	if !porCursor {
+		countQuery := "SELECT COUNT(*) FROM reclamos r" + busqueda.Join + whereClause
+		if err := pool.QueryRow(ctx, countQuery, args...).Scan(&total); err != nil {
+			log.Printf("❌ Error contando reclamos: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error contando registros"})
+			return
+		}
+	}
+
+	var paginacion string
+	if !porCursor {
+		paginacion = fmt.Sprintf(" LIMIT %s OFFSET %s", arg(limit), arg(offset))
+	} else {
+		if cursor != "" {
+			valores, err := decodificarCursor(cursor, ordenTexto, claves)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+				return
+			}
+			whereClause += sqlKeyset(claves, valores, arg)
+		}
+		// Una fila de más indica si hay página siguiente
+		paginacion = fmt.Sprintf(" LIMIT %s", arg(limit+1))
+	}
 
 	// 4. CONSULTA 2: Obtener los datos paginados
 	query := `
 		SELECT r.id, r.codigo_reclamo, r.tipo_solicitud, r.estado, r.nombre_completo, 
 			   r.email, r.telefono, r.descripcion_bien, r.fecha_registro, 
-			   r.fecha_limite_respuesta,
-			   (r.fecha_limite_respuesta - CURRENT_DATE)::int AS dias_restantes,
-			   ua.nombre_completo as nombre_admin_atendio
+			   ` + sqlFechaLimite + `,
+			   (` + sqlFechaLimite + ` - CURRENT_DATE)::int AS dias_restantes,
+			   ua.nombre_completo as nombre_admin_atendio,
+			   ` + sqlMensajesNoLeidos + ` AS mensajes_no_leidos,
+			   ` + sqlUltimoMensajeTipo + ` AS ultimo_mensaje_tipo,
+			   r.pausado_desde IS NOT NULL AS esperando_consumidor,
+			   r.tipo_bien, COALESCE(r.monto_reclamado, 0)::float8, r.establecimiento,
+			   ` + sqlPrioridad + ` AS prioridad,
+			   r.categoria_id, cat.ruta, r.prioridad_asignada,
+			   COALESCE((SELECT array_agg(er.etiqueta ORDER BY er.etiqueta) FROM etiquetas_reclamos er WHERE er.reclamo_id = r.id), '{}'),
+			   ` + busqueda.Columnas + `,
+			   ` + sqlValoresCursor(claves) + ` AS valores_cursor
 		FROM reclamos r
 		LEFT JOIN usuarios_admin ua ON r.atendido_por = ua.id
-	` + whereClause + fmt.Sprintf(" ORDER BY r.fecha_registro DESC LIMIT $%d OFFSET $%d", argID, argID+1)
-	
-	// Agregamos limit y offset a los argumentos
-	args = append(args, limit, offset)
+		LEFT JOIN v_categorias_ruta cat ON cat.id = r.categoria_id
+	` + busqueda.Join + whereClause + " ORDER BY " + sqlOrden(claves) + paginacion
 
 	rows, err := pool.Query(ctx, query, args...)
 	if err != nil {
+		log.Printf("❌ Error consultando reclamos: %v", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al consultar reclamos"})
 		return
 	}
 	defer rows.Close()
 
 	var reclamos []gin.H
+	var ultimoCursor string
+	hayMas := false
 	for rows.Next() {
 		var r struct {
 			ID, CodigoReclamo, TipoSolicitud, Estado, NombreCompleto, Email, Telefono, DescripcionBien string
 			FechaRegistro, FechaLimiteRespuesta time.Time
 			DiasRestantes *int
 			NombreAdminAtendio sql.NullString
+			MensajesNoLeidos int64
+			UltimoMensajeTipo sql.NullString
+			EsperandoConsumidor bool
+			TipoBien, Establecimiento sql.NullString
+			Monto float64
+			Prioridad string
+			CategoriaID, Categoria, PrioridadAsignada sql.NullString
+			Etiquetas []string
+			Relevancia float64
+			Fragmento, CoincideEn sql.NullString
+			ValoresCursor string
 		}
 		if err := rows.Scan(&r.ID, &r.CodigoReclamo, &r.TipoSolicitud, &r.Estado, &r.NombreCompleto,
-			&r.Email, &r.Telefono, &r.DescripcionBien, &r.FechaRegistro, &r.FechaLimiteRespuesta, &r.DiasRestantes, &r.NombreAdminAtendio); err == nil {
+			&r.Email, &r.Telefono, &r.DescripcionBien, &r.FechaRegistro, &r.FechaLimiteRespuesta, &r.DiasRestantes, &r.NombreAdminAtendio,
+			&r.MensajesNoLeidos, &r.UltimoMensajeTipo, &r.EsperandoConsumidor,
+			&r.TipoBien, &r.Monto, &r.Establecimiento, &r.Prioridad,
+			&r.CategoriaID, &r.Categoria, &r.PrioridadAsignada, &r.Etiquetas,
+			&r.Relevancia, &r.Fragmento, &r.CoincideEn, &r.ValoresCursor); err == nil {
 			
-			reclamos = append(reclamos, gin.H{
+			if porCursor && len(reclamos) == limit {
+				hayMas = true // la fila extra solo confirma que hay más
+				break
+			}
+			ultimoCursor = r.ValoresCursor
+
+			item := gin.H{
 				"id":                     r.ID,
 				"codigo_reclamo":         r.CodigoReclamo,
 				"tipo_solicitud":         r.TipoSolicitud,
@@ -242,11 +309,32 @@ func listarReclamosAdminHandler(c *gin.Context) {
 				"email":                  r.Email,
 				"telefono":               r.Telefono,
 				"descripcion_bien":       r.DescripcionBien,
+				"tipo_bien":              nullToInterface(r.TipoBien),
+				"monto_reclamado":        r.Monto,
+				"establecimiento":        nullToInterface(r.Establecimiento),
 				"fecha_registro":         r.FechaRegistro,
 				"fecha_limite_respuesta": r.FechaLimiteRespuesta,
 				"dias_restantes":         r.DiasRestantes,
+				"prioridad":              r.Prioridad,
+				"prioridad_asignada":     nullToInterface(r.PrioridadAsignada),
+				"categoria_id":           nullToInterface(r.CategoriaID),
+				"categoria":              nullToInterface(r.Categoria),
+				"etiquetas":              r.Etiquetas,
 				"nombre_admin_atendio":   nullToInterface(r.NombreAdminAtendio),
-			})
+				"mensajes_no_leidos":     r.MensajesNoLeidos,
+				"esperando_respuesta":    r.UltimoMensajeTipo.String == "CLIENTE",
+				"esperando_consumidor":   r.EsperandoConsumidor,
+			}
+			if filtros.Busqueda != "" {
+				fragmento := r.Fragmento.String
+				if !busqueda.Resaltado {
+					fragmento = resaltarTerminos(fragmento, busqueda.Terminos, 200)
+				}
+				item["relevancia"] = r.Relevancia
+				item["fragmento"] = fragmentoHTML(fragmento)
+				item["coincide_en"] = r.CoincideEn.String
+			}
+			reclamos = append(reclamos, item)
 		}
 	}
 
@@ -254,6 +342,23 @@ func listarReclamosAdminHandler(c *gin.Context) {
 		reclamos = []gin.H{}
 	}
 
+	if porCursor {
+		var siguiente interface{}
+		if hayMas {
+			siguiente = codificarCursor(ordenTexto, ultimoCursor)
+		}
+		c.JSON(http.StatusOK, gin.H{
+			"success": true,
+			"data":    reclamos,
+			"pagination": gin.H{
+				"limit":       limit,
+				"next_cursor": siguiente,
+				"has_next":    hayMas,
+			},
+		})
+		return
+	}
+
 	// 5. Calcular metadatos de paginación
 	totalPages := int(math.Ceil(float64(total) / float64(limit)))
 
@@ -271,6 +376,68 @@ func listarReclamosAdminHandler(c *gin.Context) {
 	})
 }
 
+// Máquina de estados del reclamo: CERRADO es final y un reclamo RESUELTO
+// solo puede reabrirse (EN_PROCESO) o cerrarse.
+var transicionesEstado = map[string][]string{
+	"PENDIENTE":  {"EN_PROCESO", "RESUELTO", "CERRADO"},
+	"EN_PROCESO": {"PENDIENTE", "RESUELTO", "CERRADO"},
+	"RESUELTO":   {"EN_PROCESO", "CERRADO"},
+	"CERRADO":    {},
+}
+
+var (
+	errReclamoNoEncontrado = errors.New("reclamo no encontrado")
+	errSinPermiso          = errors.New("sin permisos")
+	errTransicionInvalida  = errors.New("cambio de estado no permitido")
+)
+
+// validarTransicion aplica la máquina de estados y los permisos por rol.
+func validarTransicion(anterior, nuevo, rol string) error {
+	// SOPORTE no puede cerrar reclamos
+	if rol == "SOPORTE" && nuevo == "CERRADO" {
+		return fmt.Errorf("%w: no tiene permisos para cerrar reclamos", errSinPermiso)
+	}
+	if anterior == nuevo {
+		return fmt.Errorf("%w: el reclamo ya está %s", errTransicionInvalida, anterior)
+	}
+	for _, e := range transicionesEstado[anterior] {
+		if e == nuevo {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: de %s a %s", errTransicionInvalida, anterior, nuevo)
+}
+
+// cambiarEstadoEnTx aplica el cambio de estado con su historial. Si se pasa
+// validar, se consulta con el estado anterior antes de escribir nada.
+// Devuelve el estado anterior; eventos y auditoría quedan para el llamador.
+func cambiarEstadoEnTx(ctx context.Context, tx pgx.Tx, id, estado, comentario, usuario string, validar func(anterior string) error) (string, error) {
+	var anterior string
+	err := tx.QueryRow(ctx, "SELECT estado FROM reclamos WHERE id = $1 FOR UPDATE", id).Scan(&anterior)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", errReclamoNoEncontrado
+	}
+	if err != nil {
+		return "", err
+	}
+	if validar != nil {
+		if err := validar(anterior); err != nil {
+			return anterior, err
+		}
+	}
+
+	if _, err := tx.Exec(ctx, "UPDATE reclamos SET estado = $1 WHERE id = $2", estado, id); err != nil {
+		return anterior, err
+	}
+
+	// Registrar en historial
+	_, err = tx.Exec(ctx, `
+		INSERT INTO historial_reclamos (reclamo_id, estado_anterior, estado_nuevo, tipo_accion, comentario, usuario_accion)
+		VALUES ($1, $2, $3, 'CAMBIO_ESTADO', $4, $5)
+	`, id, anterior, estado, comentario, usuario)
+	return anterior, err
+}
+
 func cambiarEstadoReclamoHandler(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
 	defer cancel()
@@ -295,24 +462,37 @@ func cambiarEstadoReclamoHandler(c *gin.Context) {
 		return
 	}
 
-	var estadoAnterior string
-	err := pool.QueryRow(ctx, "SELECT estado FROM reclamos WHERE id = $1", id).Scan(&estadoAnterior)
+	tx, err := pool.Begin(ctx)
 	if err != nil {
-		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Reclamo no encontrado"})
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error actualizando estado"})
 		return
 	}
+	defer tx.Rollback(ctx)
 
-	_, err = pool.Exec(ctx, "UPDATE reclamos SET estado = $1 WHERE id = $2", req.Estado, id)
-	if err != nil {
+	// El cambio individual conserva su semántica: cualquier estado salvo el
+	// cierre por SOPORTE, ya controlado arriba
+	estadoAnterior, err := cambiarEstadoEnTx(ctx, tx, id, req.Estado, req.Comentario, fmt.Sprintf("%v", userID), nil)
+	if err == nil {
+		err = tx.Commit(ctx)
+	}
+	switch {
+	case errors.Is(err, errReclamoNoEncontrado):
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Reclamo no encontrado"})
+		return
+	case err != nil:
+		log.Printf("❌ Error cambiando estado de %s: %v", id, err)
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error actualizando estado"})
 		return
 	}
 
-	// Registrar en historial
-	pool.Exec(ctx, `
-		INSERT INTO historial_reclamos (reclamo_id, estado_anterior, estado_nuevo, tipo_accion, comentario, usuario_accion)
-		VALUES ($1, $2, $3, 'CAMBIO_ESTADO', $4, $5)
-	`, id, estadoAnterior, req.Estado, req.Comentario, userID)
+	publicarEvento(id, eventoEstado, gin.H{"estado_anterior": estadoAnterior, "estado_nuevo": req.Estado})
+
+	// Un reclamo atendido ya no espera al consumidor
+	if req.Estado == "RESUELTO" || req.Estado == "CERRADO" {
+		if _, err := reanudarPlazo(ctx, id, "reclamo atendido", fmt.Sprintf("%v", c.MustGet("email"))); err != nil {
+			log.Printf("⚠️ Error cerrando pausa de %s: %v", id, err)
+		}
+	}
 
 	// Registrar auditoría
 	userIDStr := fmt.Sprintf("%v", userID)
@@ -335,8 +515,8 @@ func responderReclamoHandler(c *gin.Context) {
 	defer cancel()
 
 	id := c.Param("id")
-	userID, _ := c.Get("user_id")
-	email, _ := c.Get("email")
+	userID := fmt.Sprintf("%v", c.MustGet("user_id"))
+	email := fmt.Sprintf("%v", c.MustGet("email"))
 
 	var req struct {
 		RespuestaEmpresa     string `json:"respuesta_empresa" binding:"required,min=10"`
@@ -348,39 +528,62 @@ func responderReclamoHandler(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Datos inválidos"})
 		return
 	}
+	datos := datosRespuesta(req)
 
-	_, err := pool.Exec(ctx, `
-		INSERT INTO respuestas (reclamo_id, respuesta_empresa, accion_tomada, compensacion_ofrecida, respondido_por)
-		VALUES ($1, $2, $3, $4, $5)
-	`, id, req.RespuestaEmpresa, nullString(&req.AccionTomada), nullString(&req.CompensacionOfrecida), email)
-
+	// Cuatro ojos: si alguna regla aplica, la respuesta queda pendiente de aprobación
+	regla, err := reglaAprobacionAplicable(ctx, id)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error guardando respuesta"})
+		responderErrorRespuesta(c, id, "Reclamo no encontrado", err)
 		return
 	}
 
-	// Actualizar estado, fecha de respuesta y REGISTRAR QUIÉN ATENDIÓ
-    pool.Exec(ctx, "UPDATE reclamos SET estado = 'RESUELTO', fecha_respuesta = NOW(), atendido_por = $2 WHERE id = $1", id, userID)
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		responderErrorRespuesta(c, id, "Reclamo no encontrado", err)
+		return
+	}
+	defer tx.Rollback(ctx)
 
-    // Historial
+	if regla != "" {
+		propuestaID, err := proponerRespuestaEnTx(ctx, tx, id, datos, "", userID, email, regla)
+		if err == nil {
+			err = tx.Commit(ctx)
+		}
+		if err != nil {
+			responderErrorRespuesta(c, id, "Reclamo no encontrado", err)
+			return
+		}
 
-	// Historial
-	pool.Exec(ctx, `
-		INSERT INTO historial_reclamos (reclamo_id, estado_anterior, estado_nuevo, tipo_accion, comentario, usuario_accion)
-		SELECT estado, 'RESUELTO', 'RESPUESTA', 'Respuesta enviada por la empresa', $2
-		FROM reclamos WHERE id = $1
-	`, id, email)
+		publicarEventoInterno(id, eventoEstado, gin.H{"propuesta_respuesta": propuestaID})
+		emailsEnCurso.Add(1)
+		go func() {
+			defer emailsEnCurso.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			avisarRevisores(ctx, id, email, regla)
+		}()
+		registrarAuditoriaAprobacion(ctx, c, "PROPONER_RESPUESTA", "RECLAMO", id, gin.H{"propuesta_id": propuestaID, "regla": regla})
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"success": true,
+			"message": "Respuesta enviada a aprobación",
+			"data":    gin.H{"estado": "PENDIENTE_APROBACION", "propuesta_id": propuestaID, "regla": regla},
+		})
+		return
+	}
 
-	// Auditoría
-	userIDStr := fmt.Sprintf("%v", userID)
-	_, errAudit := pool.Exec(ctx, `
-		INSERT INTO auditoria_admin (usuario_id, accion, entidad, entidad_id, ip_address)
-		VALUES ($1::uuid, 'RESPONDER', 'RECLAMO', $2, $3)
-	`, userIDStr, id, c.ClientIP())
-	if errAudit != nil {
-		fmt.Printf("⚠️ Error registrando auditoría: %v\n", errAudit)
+	anterior, err := publicarRespuestaEnTx(ctx, tx, id, datos, userID, email, "")
+	if err == nil {
+		err = tx.Commit(ctx)
+	}
+	if err != nil {
+		responderErrorRespuesta(c, id, "Reclamo no encontrado", err)
+		return
 	}
 
+	respuestaPublicada(ctx, id, anterior, email)
+	registrarAuditoriaAprobacion(ctx, c, "RESPONDER", "RECLAMO", id, gin.H{"estado_anterior": anterior})
+
 	c.JSON(http.StatusCreated, gin.H{"success": true, "message": "Respuesta enviada correctamente"})
 }
 
@@ -407,6 +610,24 @@ func obtenerEstadisticasHandler(c *gin.Context) {
 		return
 	}
 
+	// Badges de mensajes: no leídos del cliente y reclamos esperando respuesta
+	var mensajesNoLeidos, esperandoRespuesta int64
+	err = pool.QueryRow(ctx, `
+		SELECT COALESCE(SUM(` + sqlMensajesNoLeidos + `), 0)::bigint,
+		       COUNT(*) FILTER (WHERE ` + sqlUltimoMensajeTipo + ` = 'CLIENTE')
+		FROM reclamos r
+	`).Scan(&mensajesNoLeidos, &esperandoRespuesta)
+	if err != nil {
+		log.Printf("⚠️ Error contando mensajes no leídos: %v", err)
+	}
+
+	// Desglose por categoría (cada una incluye sus subcategorías)
+	porCategoria, err := desgloseCategorias(ctx)
+	if err != nil {
+		log.Printf("⚠️ Error obteniendo desglose por categoría: %v", err)
+		porCategoria = []gin.H{}
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"success": true,
 		"data": gin.H{
@@ -419,6 +640,9 @@ func obtenerEstadisticasHandler(c *gin.Context) {
 			"reclamos_semana":          stats.ReclamosSemana,
 			"reclamos_mes":             stats.ReclamosMes,
 			"promedio_dias_resolucion": stats.PromedioDiasResolucion,
+			"mensajes_no_leidos":       mensajesNoLeidos,
+			"esperando_respuesta":      esperandoRespuesta,
+			"por_categoria":            porCategoria,
 		},
 	})
 }
\ No newline at end of file
@@ -26,12 +26,16 @@ func obtenerReclamoAdminHandler(c *gin.Context) {
             r.tipo_bien, r.monto_reclamado, r.descripcion_bien,
             r.area_queja, r.descripcion_situacion,
             r.fecha_incidente, r.detalle_reclamo, r.pedido_consumidor,
-            r.fecha_registro, r.fecha_limite_respuesta,
-            res.respuesta_empresa, res.respondido_por,
-            ua.nombre_completo as nombre_admin_atendio -- Nuevo campo
+            r.fecha_registro, ` + sqlFechaLimite + `, r.fecha_limite_respuesta,
+            r.fecha_limite_ampliada IS NOT NULL, r.pausado_desde IS NOT NULL, r.dias_pausados,
+            res.respuesta_empresa, res.respondido_por, res.version, res.aprobado_por,
+            ua.nombre_completo as nombre_admin_atendio, -- Nuevo campo
+            r.categoria_id, cat.ruta, r.prioridad_asignada,
+            COALESCE((SELECT array_agg(er.etiqueta ORDER BY er.etiqueta) FROM etiquetas_reclamos er WHERE er.reclamo_id = r.id), '{}')
         FROM reclamos r
-        LEFT JOIN respuestas res ON r.id = res.reclamo_id
+        LEFT JOIN v_respuestas_vigentes res ON r.id = res.reclamo_id -- Solo la última versión
         LEFT JOIN usuarios_admin ua ON r.atendido_por = ua.id -- Join para sacar el nombre
+        LEFT JOIN v_categorias_ruta cat ON cat.id = r.categoria_id
         WHERE r.id = $1
     `
 
@@ -42,15 +46,23 @@ func obtenerReclamoAdminHandler(c *gin.Context) {
         DescBien, AreaQueja, DescSit                                  sql.NullString
         FechaInc                                                      time.Time
         Detalle, Pedido                                               string
-        FechaReg, FechaLim                                            time.Time
+        FechaReg, FechaLim, FechaLimOriginal                          time.Time
+        PlazoAmpliado, EsperandoConsumidor                            bool
+        DiasPausados                                                  int
         Respuesta, RespondidoPor, NombreAdminAtendio                  sql.NullString // Agregado aquí
+        VersionRespuesta                                              *int
+        AprobadoPor                                                   sql.NullString
+        CategoriaID, Categoria, PrioridadAsignada                     sql.NullString
+        Etiquetas                                                     []string
     }
 
     err := pool.QueryRow(ctx, query, id).Scan(
         &r.ID, &r.Codigo, &r.Tipo, &r.Estado, &r.Nombre, &r.TipoDoc, &r.NumDoc, &r.Tel, &r.Email,
         &r.Dom, &r.Dep, &r.Prov, &r.Dist, &r.TipoBien, &r.Monto, &r.DescBien,
         &r.AreaQueja, &r.DescSit, &r.FechaInc, &r.Detalle, &r.Pedido,
-        &r.FechaReg, &r.FechaLim, &r.Respuesta, &r.RespondidoPor, &r.NombreAdminAtendio, // Y aquí
+        &r.FechaReg, &r.FechaLim, &r.FechaLimOriginal,
+        &r.PlazoAmpliado, &r.EsperandoConsumidor, &r.DiasPausados, &r.Respuesta, &r.RespondidoPor, &r.VersionRespuesta, &r.AprobadoPor, &r.NombreAdminAtendio, // Y aquí
+        &r.CategoriaID, &r.Categoria, &r.PrioridadAsignada, &r.Etiquetas,
     )
 
     if err != nil {
@@ -86,10 +98,34 @@ func obtenerReclamoAdminHandler(c *gin.Context) {
         "fecha_incidente":        r.FechaInc,
         "fecha_registro":         r.FechaReg,
         "fecha_limite_respuesta": r.FechaLim,
+        "fecha_limite_original":  r.FechaLimOriginal,
+        "plazo_ampliado":         r.PlazoAmpliado,
+        "esperando_consumidor":   r.EsperandoConsumidor,
+        "dias_pausados":          r.DiasPausados,
         "respuesta_empresa":      nullToInterface(r.Respuesta),
         "respondido_por":         nullToInterface(r.RespondidoPor),
+        "version_respuesta":      r.VersionRespuesta,
+        "aprobado_por":           nullToInterface(r.AprobadoPor),
         "nombre_admin_atendio":   nullToInterface(r.NombreAdminAtendio), // Nuevo campo en JSON
+        "prioridad_asignada":     nullToInterface(r.PrioridadAsignada),
+        "categoria_id":           nullToInterface(r.CategoriaID),
+        "categoria":              nullToInterface(r.Categoria),
+        "etiquetas":              r.Etiquetas,
     }
 
     c.JSON(http.StatusOK, gin.H{"success": true, "data": data})
-}
\ No newline at end of file
+}
+// GET /api/admin/reclamos/:id/firma
+func obtenerFirmaAdminHandler(c *gin.Context) {
+    ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+    defer cancel()
+
+    var firma string
+    err := pool.QueryRow(ctx, "SELECT firma_digital FROM reclamos WHERE id = $1", c.Param("id")).Scan(&firma)
+    if err != nil {
+        c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Reclamo no encontrado"})
+        return
+    }
+
+    escribirFirma(c, firma)
+}
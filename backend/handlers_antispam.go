@@ -0,0 +1,433 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// =============================================================================
+// ANTI-SPAM PARA ENDPOINTS PÚBLICOS
+// Cada reclamo o mensaje escribe en BD y dispara emails, así que los endpoints
+// públicos pasan por: honeypot, tiempo mínimo de llenado, límites por IP y por
+// documento, y CAPTCHA. Los intentos rechazados quedan en intentos_rechazados.
+// =============================================================================
+
+// VerificadorCaptcha valida el token que el widget CAPTCHA entrega al navegador.
+type VerificadorCaptcha interface {
+	Verificar(ctx context.Context, token, ip string) (bool, error)
+}
+
+// verificadorSiteverify sirve para hCaptcha y Turnstile: ambos exponen la
+// misma API "siteverify" (secret, response, remoteip) y responden {success}.
+type verificadorSiteverify struct {
+	url    string
+	secret string
+	client *http.Client
+}
+
+func (v *verificadorSiteverify) Verificar(ctx context.Context, token, ip string) (bool, error) {
+	if token == "" {
+		return false, nil
+	}
+
+	form := url.Values{"secret": {v.secret}, "response": {token}, "remoteip": {ip}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, v.url, strings.NewReader(form.Encode()))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := v.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+
+	var out struct {
+		Success bool `json:"success"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&out); err != nil {
+		return false, err
+	}
+	return out.Success, nil
+}
+
+// captchaStub acepta cualquier token no vacío. Solo para desarrollo y tests.
+type captchaStub struct{}
+
+func (captchaStub) Verificar(_ context.Context, token, _ string) (bool, error) {
+	return token != "", nil
+}
+
+// nuevoVerificadorCaptcha devuelve nil si no hay proveedor configurado.
+func nuevoVerificadorCaptcha(cfg Config) VerificadorCaptcha {
+	client := &http.Client{Timeout: 5 * time.Second}
+
+	switch strings.ToLower(cfg.CaptchaProveedor) {
+	case "hcaptcha":
+		return &verificadorSiteverify{url: "https://api.hcaptcha.com/siteverify", secret: cfg.CaptchaSecret, client: client}
+	case "turnstile":
+		return &verificadorSiteverify{url: "https://challenges.cloudflare.com/turnstile/v0/siteverify", secret: cfg.CaptchaSecret, client: client}
+	case "stub":
+		return captchaStub{}
+	case "":
+		return nil
+	default:
+		log.Printf("⚠️ CAPTCHA_PROVEEDOR desconocido: %q (CAPTCHA desactivado)", cfg.CaptchaProveedor)
+		return nil
+	}
+}
+
+var captcha VerificadorCaptcha
+
+// =============================================================================
+// TOKEN DE FORMULARIO (tiempo mínimo de llenado)
+// El frontend pide un token al abrir el formulario y lo devuelve al enviarlo.
+// Va firmado con HMAC para que el bot no pueda fabricar una hora de inicio.
+// =============================================================================
+
+const maxEdadTokenFormulario = 2 * time.Hour
+
+func firmarTokenFormulario(emitido time.Time) string {
+	ts := strconv.FormatInt(emitido.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(jwtSecret))
+	mac.Write([]byte("formulario:" + ts))
+	return ts + "." + hex.EncodeToString(mac.Sum(nil))
+}
+
+// validarTokenFormulario devuelve la hora de emisión si la firma es válida.
+func validarTokenFormulario(token string, ahora time.Time) (time.Time, bool) {
+	ts, _, ok := strings.Cut(token, ".")
+	if !ok {
+		return time.Time{}, false
+	}
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+	emitido := time.Unix(unix, 0)
+	if !hmac.Equal([]byte(token), []byte(firmarTokenFormulario(emitido))) {
+		return time.Time{}, false
+	}
+	if ahora.Sub(emitido) > maxEdadTokenFormulario || emitido.After(ahora) {
+		return time.Time{}, false
+	}
+	return emitido, true
+}
+
+// GET /api/antispam/formulario - Token para medir el tiempo de llenado
+func tokenFormularioHandler(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"form_token":   firmarTokenFormulario(time.Now()),
+			"min_segundos": config.AntispamMinSegundos,
+		},
+	})
+}
+
+// =============================================================================
+// POLÍTICAS Y MIDDLEWARE
+// =============================================================================
+
+type politicaAntispam struct {
+	endpoint        string
+	maxPorIP        int
+	maxPorDocumento int
+	ventanaIP       time.Duration
+	ventanaDoc      time.Duration
+	contarPorIP     func(ctx context.Context, ip string, ventana time.Duration) (int, error)
+	contarPorDoc    func(ctx context.Context, doc string, ventana time.Duration) (int, error)
+}
+
+func politicaAntispamReclamos() politicaAntispam {
+	return politicaAntispam{
+		endpoint:        "RECLAMO",
+		maxPorIP:        config.AntispamReclamosPorIP,
+		maxPorDocumento: config.AntispamReclamosPorDocumento,
+		ventanaIP:       time.Hour,
+		ventanaDoc:      24 * time.Hour,
+		contarPorIP: func(ctx context.Context, ip string, ventana time.Duration) (n int, err error) {
+			err = pool.QueryRow(ctx, `
+				SELECT COUNT(*) FROM reclamos
+				WHERE ip_address = $1::inet AND fecha_registro > NOW() - ($2::int * INTERVAL '1 second')
+			`, ip, int(ventana.Seconds())).Scan(&n)
+			return
+		},
+		contarPorDoc: func(ctx context.Context, doc string, ventana time.Duration) (n int, err error) {
+			err = pool.QueryRow(ctx, `
+				SELECT COUNT(*) FROM reclamos
+				WHERE numero_documento = $1 AND fecha_registro > NOW() - ($2::int * INTERVAL '1 second')
+			`, doc, int(ventana.Seconds())).Scan(&n)
+			return
+		},
+	}
+}
+
+func politicaAntispamMensajes() politicaAntispam {
+	return politicaAntispam{
+		endpoint:        "MENSAJE",
+		maxPorIP:        config.AntispamMensajesPorIP,
+		maxPorDocumento: config.AntispamMensajesPorDocumento,
+		ventanaIP:       time.Hour,
+		ventanaDoc:      time.Hour,
+		contarPorIP: func(ctx context.Context, ip string, ventana time.Duration) (n int, err error) {
+			err = pool.QueryRow(ctx, `
+				SELECT COUNT(*) FROM historial_reclamos
+				WHERE tipo_accion = 'MENSAJE_CLIENTE' AND ip_address = $1::inet
+				  AND fecha_accion > NOW() - ($2::int * INTERVAL '1 second')
+			`, ip, int(ventana.Seconds())).Scan(&n)
+			return
+		},
+		contarPorDoc: func(ctx context.Context, doc string, ventana time.Duration) (n int, err error) {
+			err = pool.QueryRow(ctx, `
+				SELECT COUNT(*) FROM mensajes_seguimiento m
+				JOIN reclamos r ON r.id = m.reclamo_id
+				WHERE m.tipo_mensaje = 'CLIENTE' AND r.numero_documento = $1
+				  AND m.fecha_mensaje > NOW() - ($2::int * INTERVAL '1 second')
+			`, doc, int(ventana.Seconds())).Scan(&n)
+			return
+		},
+	}
+}
+
+// politicaAntispamBorradores limita por IP los borradores guardados; el límite
+// por email lo aplica el handler al enviar el enlace.
+func politicaAntispamBorradores() politicaAntispam {
+	return politicaAntispam{
+		endpoint:  "BORRADOR",
+		maxPorIP:  config.AntispamBorradoresPorIP,
+		ventanaIP: time.Hour,
+		contarPorIP: func(ctx context.Context, ip string, ventana time.Duration) (n int, err error) {
+			err = pool.QueryRow(ctx, `
+				SELECT COUNT(*) FROM borradores_reclamos
+				WHERE ip_address = $1 AND fecha_creacion > NOW() - ($2::int * INTERVAL '1 second')
+			`, ip, int(ventana.Seconds())).Scan(&n)
+			return
+		},
+	}
+}
+
+// camposAntispam son los campos extra que el frontend agrega al cuerpo JSON.
+// Los handlers los ignoran al hacer ShouldBindJSON.
+type camposAntispam struct {
+	Website         string `json:"website"` // honeypot: oculto para humanos
+	CaptchaToken    string `json:"captcha_token"`
+	FormToken       string `json:"form_token"`
+	NumeroDocumento string `json:"numero_documento"`
+}
+
+// antispamMiddleware aplica la política antes del handler. Lee el cuerpo y lo
+// restaura para que el handler pueda volver a leerlo.
+func antispamMiddleware(p politicaAntispam) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+		defer cancel()
+
+		body, err := io.ReadAll(c.Request.Body)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Datos inválidos"})
+			c.Abort()
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		var campos camposAntispam
+		if len(bytes.TrimSpace(body)) > 0 {
+			// Un JSON mal formado lo rechaza el propio handler
+			_ = json.Unmarshal(body, &campos)
+		}
+
+		ip := c.ClientIP()
+		rechazar := func(status int, motivo, mensaje string) {
+			registrarIntentoRechazado(p.endpoint, motivo, ip, c.GetHeader("User-Agent"), campos.NumeroDocumento, c.Request.URL.Path)
+			c.JSON(status, gin.H{"success": false, "message": mensaje})
+			c.Abort()
+		}
+
+		if campos.Website != "" {
+			rechazar(http.StatusBadRequest, "HONEYPOT", "Solicitud rechazada")
+			return
+		}
+
+		if config.AntispamMinSegundos > 0 {
+			emitido, ok := validarTokenFormulario(campos.FormToken, time.Now())
+			if !ok {
+				rechazar(http.StatusBadRequest, "TOKEN_FORMULARIO", "Formulario expirado, recargue la página")
+				return
+			}
+			if time.Since(emitido) < time.Duration(config.AntispamMinSegundos)*time.Second {
+				rechazar(http.StatusBadRequest, "LLENADO_RAPIDO", "Solicitud rechazada")
+				return
+			}
+		}
+
+		if p.maxPorIP > 0 {
+			n, err := p.contarPorIP(ctx, ip, p.ventanaIP)
+			if err != nil {
+				log.Printf("⚠️ Error contando solicitudes por IP: %v", err)
+			} else if n >= p.maxPorIP {
+				rechazar(http.StatusTooManyRequests, "LIMITE_IP", "Demasiadas solicitudes, intente más tarde")
+				return
+			}
+		}
+
+		if p.rechazarPorDocumento(c, ctx, campos.NumeroDocumento) {
+			return
+		}
+
+		if captcha != nil {
+			ok, err := captcha.Verificar(ctx, campos.CaptchaToken, ip)
+			if err != nil {
+				log.Printf("⚠️ Error verificando CAPTCHA: %v", err)
+			}
+			if !ok {
+				rechazar(http.StatusBadRequest, "CAPTCHA", "Verificación CAPTCHA fallida")
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// rechazarPorDocumento aplica el límite por documento; si se superó ya
+// respondió 429 y el llamador debe retornar. Los mensajes con sesión del
+// portal no traen el documento en el cuerpo, así que el handler lo llama con
+// el de la sesión.
+func (p politicaAntispam) rechazarPorDocumento(c *gin.Context, ctx context.Context, doc string) bool {
+	if p.maxPorDocumento <= 0 || doc == "" {
+		return false
+	}
+	n, err := p.contarPorDoc(ctx, doc, p.ventanaDoc)
+	if err != nil {
+		log.Printf("⚠️ Error contando solicitudes por documento: %v", err)
+		return false
+	}
+	if n < p.maxPorDocumento {
+		return false
+	}
+	registrarIntentoRechazado(p.endpoint, "LIMITE_DOCUMENTO", c.ClientIP(), c.GetHeader("User-Agent"), doc, c.Request.URL.Path)
+	c.JSON(http.StatusTooManyRequests, gin.H{"success": false, "message": "Demasiadas solicitudes para este documento, intente más tarde"})
+	c.Abort()
+	return true
+}
+
+func registrarIntentoRechazado(endpoint, motivo, ip, userAgent, documento, ruta string) {
+	log.Printf("🛡️ Intento rechazado [%s/%s] desde %s", endpoint, motivo, ip)
+
+	// El pool se toma antes de la goroutine: el registro no debe ver un pool
+	// reemplazado mientras tanto (p. ej. por los tests)
+	db := pool
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		_, err := db.Exec(ctx, `
+			INSERT INTO intentos_rechazados (endpoint, motivo, ip_address, user_agent, numero_documento, ruta)
+			VALUES ($1, $2, $3, $4, $5, $6)
+		`, endpoint, motivo, ip, userAgent, nullString(&documento), ruta)
+		if err != nil {
+			log.Printf("⚠️ Error registrando intento rechazado: %v", err)
+		}
+	}()
+}
+
+// =============================================================================
+// REVISIÓN DE INTENTOS RECHAZADOS (ADMIN)
+// =============================================================================
+
+// GET /api/admin/antispam/rechazos
+func listarIntentosRechazadosHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	whereClause := " WHERE 1=1"
+	args := []interface{}{}
+	if motivo := c.Query("motivo"); motivo != "" {
+		args = append(args, motivo)
+		whereClause += fmt.Sprintf(" AND motivo = $%d", len(args))
+	}
+	if endpoint := c.Query("endpoint"); endpoint != "" {
+		args = append(args, endpoint)
+		whereClause += fmt.Sprintf(" AND endpoint = $%d", len(args))
+	}
+	if ip := c.Query("ip"); ip != "" {
+		args = append(args, ip)
+		whereClause += fmt.Sprintf(" AND ip_address = $%d", len(args))
+	}
+
+	var total int64
+	if err := pool.QueryRow(ctx, "SELECT COUNT(*) FROM intentos_rechazados"+whereClause, args...).Scan(&total); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error contando registros"})
+		return
+	}
+
+	args = append(args, limit, (page-1)*limit)
+	rows, err := pool.Query(ctx, `
+		SELECT id, endpoint, motivo, ip_address, user_agent, numero_documento, ruta, fecha
+		FROM intentos_rechazados`+whereClause+fmt.Sprintf(" ORDER BY fecha DESC LIMIT $%d OFFSET $%d", len(args)-1, len(args)),
+		args...)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al consultar intentos"})
+		return
+	}
+	defer rows.Close()
+
+	intentos := []gin.H{}
+	for rows.Next() {
+		var id, endpoint, motivo, ip string
+		var userAgent, documento, ruta *string
+		var fecha time.Time
+		if err := rows.Scan(&id, &endpoint, &motivo, &ip, &userAgent, &documento, &ruta, &fecha); err == nil {
+			intentos = append(intentos, gin.H{
+				"id":               id,
+				"endpoint":         endpoint,
+				"motivo":           motivo,
+				"ip_address":       ip,
+				"user_agent":       userAgent,
+				"numero_documento": documento,
+				"ruta":             ruta,
+				"fecha":            fecha,
+			})
+		}
+	}
+
+	totalPages := int(math.Ceil(float64(total) / float64(limit)))
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data":    intentos,
+		"pagination": gin.H{
+			"total":       total,
+			"page":        page,
+			"limit":       limit,
+			"total_pages": totalPages,
+			"has_next":    page < totalPages,
+			"has_prev":    page > 1,
+		},
+	})
+}
@@ -0,0 +1,377 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// =============================================================================
+// BORRADORES DE RECLAMOS
+// El consumidor puede guardar el formulario a medias y reanudarlo desde el
+// enlace enviado a su correo. Un borrador no tiene código legal: el código se
+// asigna recién al enviarlo, por el mismo camino que crearReclamoHandler.
+// =============================================================================
+
+// politicaEnlacesBorrador limita los enlaces enviados a un mismo correo, para
+// que el formulario no sirva para llenar de emails una dirección ajena.
+var politicaEnlacesBorrador = PoliticaRateLimit{Nombre: "enlaces_borrador", Capacidad: 3, Periodo: time.Hour}
+
+// permitirEnlaceBorrador consume la política del email. Si está agotada ya
+// respondió 429 y el handler debe retornar.
+func permitirEnlaceBorrador(c *gin.Context, ctx context.Context, email string) bool {
+	p := politicaEnlacesBorrador
+	if limitador == nil || p.Capacidad <= 0 {
+		return true
+	}
+	res, err := limitador.Consumir(ctx, p.Nombre+"|email:"+strings.ToLower(strings.TrimSpace(email)), p, time.Now())
+	if err != nil {
+		log.Printf("⚠️ Error en rate limit %s: %v", p.Nombre, err)
+		return true
+	}
+	if !res.Permitido {
+		c.Header("Retry-After", strconv.Itoa(int(math.Ceil(res.Reintentar.Seconds()))))
+		c.JSON(http.StatusTooManyRequests, gin.H{"success": false, "message": "Demasiados enlaces enviados a este correo, intente más tarde"})
+		return false
+	}
+	return true
+}
+
+// validarBorrador es más permisiva que validarReclamo: solo exige un email
+// válido (para enviar el enlace) y que lo ya llenado respete los límites.
+func validarBorrador(req *CrearReclamoRequest) string {
+	if !emailRegex.MatchString(req.Email) {
+		return "Se requiere un correo electrónico válido para guardar el borrador"
+	}
+	if req.TipoSolicitud != "" && req.TipoSolicitud != "RECLAMO" && req.TipoSolicitud != "QUEJA" {
+		return "Tipo de solicitud inválido"
+	}
+	if req.MontoReclamado > 9999999.99 {
+		return "El monto reclamado excede el límite permitido"
+	}
+	if excedeLimitesReclamo(req) {
+		return "Uno de los campos excede el límite permitido de caracteres."
+	}
+	return ""
+}
+
+// aplicarCambiosBorrador superpone el cuerpo JSON (si lo hay) sobre los datos
+// guardados, de modo que el cliente puede enviar solo los campos modificados.
+func aplicarCambiosBorrador(c *gin.Context, req *CrearReclamoRequest) error {
+	body, err := c.GetRawData()
+	if err != nil {
+		return err
+	}
+	if len(bytes.TrimSpace(body)) == 0 {
+		return nil
+	}
+	return json.Unmarshal(body, req)
+}
+
+func enlaceBorrador(token string) string {
+	return fmt.Sprintf("%s/?borrador=%s", config.FrontendURL, token)
+}
+
+// POST /api/borradores - Guardar un borrador nuevo
+func crearBorradorHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var req CrearReclamoRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Datos inválidos: " + err.Error()})
+		return
+	}
+
+	if msg := validarBorrador(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": msg})
+		return
+	}
+	if !permitirEnlaceBorrador(c, ctx, req.Email) {
+		return
+	}
+
+	token, err := generarTokenSeguro()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error interno"})
+		return
+	}
+
+	datos, _ := json.Marshal(req)
+
+	var id string
+	var fechaExpiracion time.Time
+	err = pool.QueryRow(ctx, `
+		INSERT INTO borradores_reclamos (token_hash, email, datos, ip_address, fecha_expiracion)
+		VALUES ($1, $2, $3::jsonb, $4, NOW() + ($5::int * INTERVAL '1 day'))
+		RETURNING id, fecha_expiracion
+	`, hashToken(token), req.Email, string(datos), c.ClientIP(), config.BorradorDiasExpiracion).Scan(&id, &fechaExpiracion)
+	if err != nil {
+		log.Printf("❌ Error guardando borrador: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al guardar el borrador"})
+		return
+	}
+
+	go enviarEmailBorrador(req.Email, req.NombreCompleto, token, fechaExpiracion)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Borrador guardado. Le enviamos un enlace para continuar más tarde.",
+		"data": gin.H{
+			"token":            token,
+			"fecha_expiracion": fechaExpiracion,
+		},
+	})
+}
+
+// GET /api/borradores/:token - Reanudar un borrador desde el enlace
+func obtenerBorradorHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var datos []byte
+	var fechaExpiracion time.Time
+	err := pool.QueryRow(ctx, `
+		SELECT datos, fecha_expiracion FROM borradores_reclamos
+		WHERE token_hash = $1 AND fecha_expiracion > NOW()
+	`, hashToken(c.Param("token"))).Scan(&datos, &fechaExpiracion)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Borrador no encontrado o expirado"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"datos":            json.RawMessage(datos),
+			"fecha_expiracion": fechaExpiracion,
+		},
+	})
+}
+
+// PUT /api/borradores/:token - Actualizar un borrador (campos parciales)
+func actualizarBorradorHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	token := c.Param("token")
+
+	var id string
+	var datos []byte
+	err := pool.QueryRow(ctx, `
+		SELECT id, datos FROM borradores_reclamos
+		WHERE token_hash = $1 AND fecha_expiracion > NOW()
+	`, hashToken(token)).Scan(&id, &datos)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Borrador no encontrado o expirado"})
+		return
+	}
+
+	var req CrearReclamoRequest
+	if err := json.Unmarshal(datos, &req); err != nil {
+		log.Printf("⚠️ Borrador %s con datos corruptos: %v", id, err)
+	}
+	emailAnterior := req.Email
+
+	if err := aplicarCambiosBorrador(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Datos inválidos: " + err.Error()})
+		return
+	}
+
+	if msg := validarBorrador(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": msg})
+		return
+	}
+	if req.Email != emailAnterior && !permitirEnlaceBorrador(c, ctx, req.Email) {
+		return
+	}
+
+	nuevosDatos, _ := json.Marshal(req)
+
+	var fechaExpiracion time.Time
+	err = pool.QueryRow(ctx, `
+		UPDATE borradores_reclamos
+		SET datos = $2::jsonb, email = $3, fecha_actualizacion = NOW(),
+		    fecha_expiracion = NOW() + ($4::int * INTERVAL '1 day')
+		WHERE id = $1
+		RETURNING fecha_expiracion
+	`, id, string(nuevosDatos), req.Email, config.BorradorDiasExpiracion).Scan(&fechaExpiracion)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al actualizar el borrador"})
+		return
+	}
+
+	// Si cambió el correo, el enlace debe llegar a la nueva dirección
+	if req.Email != emailAnterior {
+		go enviarEmailBorrador(req.Email, req.NombreCompleto, token, fechaExpiracion)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Borrador actualizado",
+		"data":    gin.H{"fecha_expiracion": fechaExpiracion},
+	})
+}
+
+// POST /api/borradores/:token/enviar - Convertir el borrador en reclamo
+func enviarBorradorHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Error al iniciar transacción"})
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	// FOR UPDATE evita que un doble clic registre dos reclamos
+	var id string
+	var datos []byte
+	err = tx.QueryRow(ctx, `
+		SELECT id, datos FROM borradores_reclamos
+		WHERE token_hash = $1 AND fecha_expiracion > NOW()
+		FOR UPDATE
+	`, hashToken(c.Param("token"))).Scan(&id, &datos)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Borrador no encontrado o expirado"})
+		return
+	}
+
+	var req CrearReclamoRequest
+	if err := json.Unmarshal(datos, &req); err != nil {
+		log.Printf("⚠️ Borrador %s con datos corruptos: %v", id, err)
+	}
+
+	if err := aplicarCambiosBorrador(c, &req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Datos inválidos: " + err.Error()})
+		return
+	}
+
+	if msg := validarReclamo(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": msg})
+		return
+	}
+
+	// El documento vive en el borrador, no en el cuerpo que vio el middleware
+	if politicaAntispamReclamos().rechazarPorDocumento(c, ctx, req.NumeroDocumento) {
+		return
+	}
+
+	reclamo, err := registrarReclamo(ctx, tx, req, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		responderErrorRegistro(c, err)
+		return
+	}
+
+	// El borrador ya no se necesita: sus datos viven en el reclamo
+	if _, err := tx.Exec(ctx, "DELETE FROM borradores_reclamos WHERE id = $1", id); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al cerrar el borrador"})
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Error al confirmar transacción"})
+		return
+	}
+
+	notificarReclamoRegistrado(reclamo, req, c.ClientIP(), c.GetHeader("User-Agent"))
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Reclamo registrado exitosamente",
+		"data":    datosReclamoCreado(reclamo),
+	})
+}
+
+// DELETE /api/borradores/:token - Descartar un borrador
+func eliminarBorradorHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	tag, err := pool.Exec(ctx, "DELETE FROM borradores_reclamos WHERE token_hash = $1", hashToken(c.Param("token")))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al eliminar el borrador"})
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Borrador no encontrado o expirado"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Borrador eliminado"})
+}
+
+// =============================================================================
+// PURGA DE BORRADORES VENCIDOS
+// =============================================================================
+
+// iniciarTareasPeriodicas ejecuta cada hora las tareas de mantenimiento
+// indicadas (purgas, reintentos de avisos) hasta que ctx se cancele.
+func iniciarTareasPeriodicas(ctx context.Context, tareas ...func(context.Context)) {
+	ticker := time.NewTicker(time.Hour)
+	defer ticker.Stop()
+
+	for {
+		for _, tarea := range tareas {
+			tarea(ctx)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func purgarBorradoresVencidos(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tag, err := pool.Exec(ctx, "DELETE FROM borradores_reclamos WHERE fecha_expiracion <= NOW()")
+	if err != nil {
+		log.Printf("⚠️ Error purgando borradores: %v", err)
+		return
+	}
+	if n := tag.RowsAffected(); n > 0 {
+		log.Printf("🧹 Borradores vencidos eliminados: %d", n)
+	}
+}
+
+// =============================================================================
+// EMAIL DEL ENLACE MÁGICO
+// =============================================================================
+
+func enviarEmailBorrador(email, nombre string, token string, fechaExpiracion time.Time) {
+	if nombre == "" {
+		nombre = "consumidor"
+	}
+
+	cuerpo := fmt.Sprintf(`<p style="margin: 0 0 12px 0; color: #1f2937;">Estimado/a %s,</p>
+<p style="margin: 0 0 12px 0;">Guardamos el avance de su formulario en el Libro de Reclamaciones Virtual. Puede continuar desde el siguiente enlace hasta el <strong>%s</strong>.</p>
+<p style="margin: 0; color: #6b7280; font-size: 12px;">El borrador aún no ha sido registrado: no tiene código ni plazo legal hasta que lo envíe.</p>`,
+		html.EscapeString(nombre),
+		fechaExpiracion.Format("02/01/2006 15:04"),
+	)
+
+	contenido := generarEmailAviso("📝 Su borrador fue guardado", "#1e40af", cuerpo,
+		"Continuar mi reclamo", enlaceBorrador(token),
+		"Si usted no inició este formulario, ignore este mensaje.")
+
+	if err := enviarEmailHTML(email, "Continúe su reclamo - Libro de Reclamaciones", contenido); err != nil {
+		log.Printf("❌ Error enviando enlace de borrador: %v", err)
+		return
+	}
+	log.Printf("✅ Enlace de borrador enviado a: %s", email)
+}
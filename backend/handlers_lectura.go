@@ -0,0 +1,107 @@
+package main
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// =============================================================================
+// CONFIRMACIONES DE LECTURA
+// Un mensaje se marca leído cuando lo abre su destinatario: los del CLIENTE
+// cuando un agente abre el hilo, los de la EMPRESA cuando el consumidor abre
+// el seguimiento. El otro lado se entera por el evento "leido" del SSE.
+// =============================================================================
+
+const eventoLeido = "leido"
+
+// Subconsultas reutilizadas por el listado y las estadísticas del panel
+const (
+	sqlMensajesNoLeidos = `(SELECT COUNT(*) FROM mensajes_seguimiento m
+		WHERE m.reclamo_id = r.id AND m.tipo_mensaje = 'CLIENTE' AND m.leido = false)`
+	sqlUltimoMensajeTipo = `(SELECT m.tipo_mensaje FROM mensajes_seguimiento m
+		WHERE m.reclamo_id = r.id ORDER BY m.fecha_mensaje DESC LIMIT 1)`
+)
+
+// marcarMensajesLeidos marca como leídos los mensajes de tipoAutor del reclamo
+// (o solo mensajeID si no está vacío) y avisa por el bus de eventos.
+func marcarMensajesLeidos(ctx context.Context, reclamoID, tipoAutor, mensajeID string) (int64, error) {
+	rows, err := pool.Query(ctx, `
+		UPDATE mensajes_seguimiento
+		SET leido = true, fecha_lectura = NOW()
+		WHERE reclamo_id = $1 AND tipo_mensaje = $2 AND leido = false
+		  AND ($3 = '' OR id::text = $3)
+		RETURNING id, fecha_lectura
+	`, reclamoID, tipoAutor, mensajeID)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	var ids []string
+	var fechaLectura time.Time
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id, &fechaLectura); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	if len(ids) > 0 {
+		publicarEvento(reclamoID, eventoLeido, gin.H{"tipo_mensaje": tipoAutor, "ids": ids, "fecha_lectura": fechaLectura})
+	}
+	return int64(len(ids)), nil
+}
+
+// PUT /api/admin/reclamos/:id/mensajes/:mensaje_id/leido - Marcar mensaje del cliente como leído
+func marcarMensajeLeidoAdminHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	responderMarcaLectura(c, ctx, c.Param("id"), "CLIENTE", c.Param("mensaje_id"))
+}
+
+// PUT /api/seguimiento/:codigo/mensajes/:id/leido - Marcar mensaje de la empresa como leído
+func marcarMensajeLeidoSeguimientoHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	codigo := c.Param("codigo")
+	if !autorizarConsultaPublica(c, ctx, codigo, alcanceReclamo) {
+		return
+	}
+
+	var reclamoID string
+	if err := pool.QueryRow(ctx, "SELECT id FROM reclamos WHERE codigo_reclamo = $1", codigo).Scan(&reclamoID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Reclamo no encontrado"})
+		return
+	}
+
+	responderMarcaLectura(c, ctx, reclamoID, "EMPRESA", c.Param("id"))
+}
+
+func responderMarcaLectura(c *gin.Context, ctx context.Context, reclamoID, tipoAutor, mensajeID string) {
+	var existe bool
+	err := pool.QueryRow(ctx, `
+		SELECT EXISTS (SELECT 1 FROM mensajes_seguimiento WHERE reclamo_id = $1 AND id::text = $2 AND tipo_mensaje = $3)
+	`, reclamoID, mensajeID, tipoAutor).Scan(&existe)
+	if err != nil || !existe {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Mensaje no encontrado"})
+		return
+	}
+
+	// Marcar dos veces no es error: la primera fecha de lectura se conserva
+	if _, err := marcarMensajesLeidos(ctx, reclamoID, tipoAutor, mensajeID); err != nil {
+		log.Printf("❌ Error marcando mensaje %s como leído: %v", mensajeID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al marcar el mensaje"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Mensaje marcado como leído"})
+}
@@ -0,0 +1,504 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// =============================================================================
+// OPERACIONES MASIVAS SOBRE RECLAMOS
+// La selección es una lista de IDs o un filtro con los mismos parámetros del
+// listado. Cada reclamo se valida y se procesa en su propia transacción (o
+// todos en una sola con "atomico") y se informa el resultado por reclamo.
+// =============================================================================
+
+type solicitudMasiva struct {
+	Accion     string            `json:"accion" binding:"required,oneof=estado asignar etiquetar categorizar exportar"`
+	IDs        []string          `json:"ids"`
+	Filtro     map[string]string `json:"filtro"`
+	Atomico    bool              `json:"atomico"`
+	Estado     string            `json:"estado"`
+	Comentario string            `json:"comentario"`
+	AgenteID   string            `json:"agente_id"` // UUID o "sin_asignar"
+	Etiquetas  []string          `json:"etiquetas"`
+	Categoria  string            `json:"categoria_id"` // UUID o "sin_categoria"
+	Formato    string            `json:"formato"`      // exportar: csv o xlsx
+	Columnas   string            `json:"columnas"`     // exportar: como ?columnas= del export
+}
+
+// resultadoMasivo es el resultado de un reclamo dentro de la operación.
+type resultadoMasivo struct {
+	ID      string `json:"id"`
+	Codigo  string `json:"codigo_reclamo,omitempty"`
+	OK      bool   `json:"ok"`
+	Mensaje string `json:"mensaje,omitempty"`
+}
+
+// operacionMasiva aplica la acción a un reclamo dentro de tx y devuelve su
+// código y los detalles para auditoría.
+type operacionMasiva func(ctx context.Context, tx pgx.Tx, id string) (string, gin.H, error)
+
+// normalizarEtiquetas deja las etiquetas en minúsculas, sin repetir.
+func normalizarEtiquetas(etiquetas []string) ([]string, error) {
+	var limpias []string
+	vistas := map[string]bool{}
+	for _, e := range etiquetas {
+		e = strings.ToLower(strings.Join(strings.Fields(e), " "))
+		if e == "" || vistas[e] {
+			continue
+		}
+		if len([]rune(e)) > 50 {
+			return nil, fmt.Errorf("la etiqueta %q supera los 50 caracteres", e)
+		}
+		vistas[e] = true
+		limpias = append(limpias, e)
+	}
+	if len(limpias) == 0 || len(limpias) > 10 {
+		return nil, errors.New("indique entre 1 y 10 etiquetas")
+	}
+	return limpias, nil
+}
+
+// seleccionarReclamos resuelve la selección a IDs, sin superar el máximo.
+func seleccionarReclamos(ctx context.Context, userID string, req solicitudMasiva) ([]string, error) {
+	maximo := config.MasivoMaxReclamos
+
+	if len(req.IDs) > 0 {
+		var ids []string
+		vistos := map[string]bool{}
+		for _, id := range req.IDs {
+			id = strings.ToLower(strings.TrimSpace(id))
+			if !reUUID.MatchString(id) {
+				return nil, fmt.Errorf("ID de reclamo inválido: %s", id)
+			}
+			if !vistos[id] {
+				vistos[id] = true
+				ids = append(ids, id)
+			}
+		}
+		if len(ids) > maximo {
+			return nil, fmt.Errorf("se pueden procesar como máximo %d reclamos por operación", maximo)
+		}
+		return ids, nil
+	}
+
+	if len(req.Filtro) == 0 {
+		return nil, errors.New("indique los IDs o un filtro")
+	}
+
+	q := url.Values{}
+	for k, v := range req.Filtro {
+		q.Set(k, v)
+	}
+	q, err := aplicarFiltroGuardado(ctx, userID, q)
+	if err != nil {
+		return nil, err
+	}
+	filtros, err := parsearFiltros(q)
+	if err != nil {
+		return nil, err
+	}
+
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	var busqueda consultaBusqueda
+	if filtros.Busqueda != "" {
+		busqueda = construirBusqueda(filtros.Busqueda, arg)
+	}
+	query := "SELECT r.id::text FROM reclamos r" + busqueda.Join +
+		" WHERE 1=1" + filtros.where(arg) + busqueda.Where +
+		" ORDER BY r.fecha_registro ASC, r.id LIMIT " + arg(maximo+1)
+
+	rows, err := pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	ids, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) > maximo {
+		return nil, fmt.Errorf("el filtro selecciona más de %d reclamos; acótelo", maximo)
+	}
+	return ids, nil
+}
+
+// POST /api/admin/reclamos/masivo - Cambiar estado, asignar, etiquetar o exportar varios reclamos
+func operacionMasivaHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 120*time.Second)
+	defer cancel()
+
+	userID := fmt.Sprintf("%v", c.MustGet("user_id"))
+	email := fmt.Sprintf("%v", c.MustGet("email"))
+	rol := fmt.Sprintf("%v", c.MustGet("rol"))
+
+	var req solicitudMasiva
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Indique una acción válida: estado, asignar, etiquetar o exportar"})
+		return
+	}
+
+	// Validar la acción antes de tocar la selección
+	var operacion operacionMasiva
+	var accionAuditoria string
+	var columnas []columnaExport
+	var err error
+	switch req.Accion {
+	case "estado":
+		if _, ok := transicionesEstado[req.Estado]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Estado inválido"})
+			return
+		}
+		if rol == "SOPORTE" && req.Estado == "CERRADO" {
+			c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "No tiene permisos para cerrar reclamos"})
+			return
+		}
+		accionAuditoria = "CAMBIO_ESTADO"
+		operacion = func(ctx context.Context, tx pgx.Tx, id string) (string, gin.H, error) {
+			// En lote se aplica la máquina de estados: una selección amplia no
+			// debe reabrir cerrados ni duplicar historial de reclamos ya en ese estado
+			anterior, err := cambiarEstadoEnTx(ctx, tx, id, req.Estado, req.Comentario, email, func(anterior string) error {
+				return validarTransicion(anterior, req.Estado, rol)
+			})
+			if err != nil {
+				return "", nil, err
+			}
+			return "", gin.H{"estado_anterior": anterior, "estado_nuevo": req.Estado}, nil
+		}
+
+	case "asignar":
+		agente, nombreAgente, err := agenteAsignable(ctx, req.AgenteID, userID, rol)
+		if err != nil {
+			status := http.StatusBadRequest
+			if errors.Is(err, errSinPermiso) {
+				status = http.StatusForbidden
+			}
+			c.JSON(status, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		accionAuditoria = "ASIGNAR"
+		operacion = func(ctx context.Context, tx pgx.Tx, id string) (string, gin.H, error) {
+			return asignarEnTx(ctx, tx, id, agente, nombreAgente, email)
+		}
+
+	case "etiquetar":
+		etiquetas, err := normalizarEtiquetas(req.Etiquetas)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		accionAuditoria = "ETIQUETAR"
+		operacion = func(ctx context.Context, tx pgx.Tx, id string) (string, gin.H, error) {
+			return etiquetarEnTx(ctx, tx, id, etiquetas, email)
+		}
+
+	case "categorizar":
+		var categoria *string
+		switch {
+		case req.Categoria == "sin_categoria":
+		case reUUID.MatchString(req.Categoria):
+			categoria = &req.Categoria
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "categoria_id debe ser un ID de categoría o sin_categoria"})
+			return
+		}
+		accionAuditoria = "CATEGORIZAR"
+		operacion = func(ctx context.Context, tx pgx.Tx, id string) (string, gin.H, error) {
+			return categorizarEnTx(ctx, tx, id, categoria, email)
+		}
+
+	case "exportar":
+		if req.Formato == "" {
+			req.Formato = "csv"
+		}
+		if req.Formato != "csv" && req.Formato != "xlsx" {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Formato inválido: use csv o xlsx"})
+			return
+		}
+		columnas, err = parsearColumnasExport(req.Columnas)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+	}
+
+	ids, err := seleccionarReclamos(ctx, userID, req)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+	if len(ids) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "La selección no contiene reclamos"})
+		return
+	}
+
+	if req.Accion == "exportar" {
+		exportarSeleccion(ctx, c, ids, req, columnas)
+		return
+	}
+
+	lote, _ := generarTokenSeguro()
+	lote = lote[:12]
+
+	var resultados []resultadoMasivo
+	var aplicados []int // índices de resultados confirmados
+	detalles := make([]gin.H, len(ids))
+
+	if req.Atomico {
+		resultados, err = procesarAtomico(ctx, ids, operacion, detalles)
+		if err == nil {
+			for i := range resultados {
+				aplicados = append(aplicados, i)
+			}
+		}
+	} else {
+		for i, id := range ids {
+			r := procesarUno(ctx, id, operacion, &detalles[i])
+			if r.OK {
+				aplicados = append(aplicados, i)
+			}
+			resultados = append(resultados, r)
+		}
+	}
+
+	// Eventos, plazo y auditoría solo de lo confirmado
+	for _, i := range aplicados {
+		id := resultados[i].ID
+		switch req.Accion {
+		case "estado":
+			// El consumidor solo ve el estado; el resto del lote es interno
+			publicarEvento(id, eventoEstado, gin.H{"estado_anterior": detalles[i]["estado_anterior"], "estado_nuevo": req.Estado})
+			if req.Estado == "RESUELTO" || req.Estado == "CERRADO" {
+				if _, err := reanudarPlazo(ctx, id, "reclamo atendido", email); err != nil {
+					log.Printf("⚠️ Error cerrando pausa de %s: %v", id, err)
+				}
+			}
+		case "asignar":
+			publicarEventoInterno(id, eventoEstado, gin.H{"agente_anterior": detalles[i]["agente_anterior"], "agente_nuevo": detalles[i]["agente_nuevo"]})
+		}
+
+		detalles[i]["lote"] = lote
+		_, errAudit := pool.Exec(ctx, `
+			INSERT INTO auditoria_admin (usuario_id, accion, entidad, entidad_id, detalles, ip_address)
+			VALUES ($1::uuid, $2, 'RECLAMO', $3, $4, $5)
+		`, userID, accionAuditoria, id, detalles[i], c.ClientIP())
+		if errAudit != nil {
+			log.Printf("⚠️ Error registrando auditoría: %v", errAudit)
+		}
+	}
+
+	log.Printf("📦 Operación masiva %s (%s) por %s: %d de %d reclamos", req.Accion, lote, email, len(aplicados), len(ids))
+
+	status := http.StatusOK
+	mensaje := fmt.Sprintf("Operación aplicada a %d de %d reclamos", len(aplicados), len(ids))
+	if req.Atomico && err != nil {
+		status = http.StatusConflict
+		mensaje = "No se aplicó ningún cambio: " + err.Error()
+	}
+
+	c.JSON(status, gin.H{
+		"success": len(aplicados) > 0,
+		"message": mensaje,
+		"data": gin.H{
+			"lote":       lote,
+			"total":      len(ids),
+			"aplicados":  len(aplicados),
+			"fallidos":   len(ids) - len(aplicados),
+			"resultados": resultados,
+		},
+	})
+}
+
+// procesarUno aplica la operación a un reclamo en su propia transacción.
+func procesarUno(ctx context.Context, id string, operacion operacionMasiva, detalle *gin.H) resultadoMasivo {
+	r := resultadoMasivo{ID: id}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		r.Mensaje = "error interno"
+		return r
+	}
+	defer tx.Rollback(ctx)
+
+	codigo, d, err := operacion(ctx, tx, id)
+	if err == nil {
+		err = tx.Commit(ctx)
+	}
+	r.Codigo = codigo
+	if err != nil {
+		r.Mensaje = mensajeErrorMasivo(id, err)
+		return r
+	}
+	r.OK = true
+	*detalle = d
+	return r
+}
+
+// procesarAtomico aplica la operación a todos en una transacción: ante el
+// primer error se revierte todo y se informa qué reclamo lo causó.
+func procesarAtomico(ctx context.Context, ids []string, operacion operacionMasiva, detalles []gin.H) ([]resultadoMasivo, error) {
+	resultados := make([]resultadoMasivo, len(ids))
+	for i, id := range ids {
+		resultados[i] = resultadoMasivo{ID: id, Mensaje: "no aplicado"}
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return resultados, errors.New("error interno")
+	}
+	defer tx.Rollback(ctx)
+
+	for i, id := range ids {
+		codigo, d, err := operacion(ctx, tx, id)
+		resultados[i].Codigo = codigo
+		if err != nil {
+			resultados[i].Mensaje = mensajeErrorMasivo(id, err)
+			return resultados, fmt.Errorf("reclamo %s: %s", id, resultados[i].Mensaje)
+		}
+		detalles[i] = d
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return resultados, errors.New("error interno")
+	}
+
+	for i := range resultados {
+		resultados[i].OK = true
+		resultados[i].Mensaje = ""
+	}
+	return resultados, nil
+}
+
+// mensajeErrorMasivo muestra los errores de validación y oculta los internos.
+func mensajeErrorMasivo(id string, err error) string {
+	if errors.Is(err, errReclamoNoEncontrado) || errors.Is(err, errSinPermiso) || errors.Is(err, errTransicionInvalida) {
+		return err.Error()
+	}
+	log.Printf("❌ Error en operación masiva sobre %s: %v", id, err)
+	return "error interno"
+}
+
+// agenteAsignable valida el agente destino: ADMIN asigna a cualquiera y
+// SOPORTE solo puede tomar reclamos para sí mismo.
+func agenteAsignable(ctx context.Context, agenteID, userID, rol string) (*string, string, error) {
+	switch {
+	case agenteID == "sin_asignar":
+		if rol != "ADMIN" {
+			return nil, "", fmt.Errorf("%w: solo un administrador puede quitar asignaciones", errSinPermiso)
+		}
+		return nil, "", nil
+	case !reUUID.MatchString(agenteID):
+		return nil, "", errors.New("agente_id debe ser un ID de usuario o sin_asignar")
+	case rol != "ADMIN" && agenteID != userID:
+		return nil, "", fmt.Errorf("%w: solo puede asignarse reclamos a sí mismo", errSinPermiso)
+	}
+
+	var nombre string
+	err := pool.QueryRow(ctx, "SELECT nombre_completo FROM usuarios_admin WHERE id = $1 AND activo = true", agenteID).Scan(&nombre)
+	if err != nil {
+		return nil, "", errors.New("el agente no existe o está inactivo")
+	}
+	return &agenteID, nombre, nil
+}
+
+// asignarEnTx asigna (o desasigna con agente nil) un reclamo no cerrado. El
+// historial ASIGNACION es interno: el seguimiento público lo omite.
+func asignarEnTx(ctx context.Context, tx pgx.Tx, id string, agente *string, nombreAgente, usuario string) (string, gin.H, error) {
+	var codigo, estado string
+	var actual *string
+	err := tx.QueryRow(ctx, `
+		SELECT codigo_reclamo, estado, atendido_por::text FROM reclamos WHERE id = $1 FOR UPDATE
+	`, id).Scan(&codigo, &estado, &actual)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil, errReclamoNoEncontrado
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	if estado == "CERRADO" {
+		return codigo, nil, fmt.Errorf("%w: el reclamo está cerrado", errTransicionInvalida)
+	}
+	if (actual == nil && agente == nil) || (actual != nil && agente != nil && *actual == *agente) {
+		return codigo, nil, fmt.Errorf("%w: el reclamo ya tiene esa asignación", errTransicionInvalida)
+	}
+
+	if _, err := tx.Exec(ctx, "UPDATE reclamos SET atendido_por = $2::uuid WHERE id = $1", id, agente); err != nil {
+		return codigo, nil, err
+	}
+
+	comentario := "Asignación retirada"
+	if agente != nil {
+		comentario = "Asignado a " + nombreAgente
+	}
+	_, err = tx.Exec(ctx, `
+		INSERT INTO historial_reclamos (reclamo_id, estado_anterior, estado_nuevo, tipo_accion, comentario, usuario_accion)
+		VALUES ($1, $2, $2, 'ASIGNACION', $3, $4)
+	`, id, estado, comentario, usuario)
+	if err != nil {
+		return codigo, nil, err
+	}
+	return codigo, gin.H{"agente_anterior": actual, "agente_nuevo": agente, "nombre_agente": nombreAgente}, nil
+}
+
+// etiquetarEnTx agrega etiquetas; las que el reclamo ya tenía se ignoran.
+// Como la asignación, deja historial interno ETIQUETADO.
+func etiquetarEnTx(ctx context.Context, tx pgx.Tx, id string, etiquetas []string, usuario string) (string, gin.H, error) {
+	var codigo, estado string
+	err := tx.QueryRow(ctx, "SELECT codigo_reclamo, estado FROM reclamos WHERE id = $1 FOR UPDATE", id).Scan(&codigo, &estado)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return "", nil, errReclamoNoEncontrado
+	}
+	if err != nil {
+		return "", nil, err
+	}
+
+	rows, err := tx.Query(ctx, `
+		INSERT INTO etiquetas_reclamos (reclamo_id, etiqueta, agregada_por)
+		SELECT $1, e, $3 FROM unnest($2::text[]) AS e
+		ON CONFLICT DO NOTHING
+		RETURNING etiqueta
+	`, id, etiquetas, usuario)
+	if err != nil {
+		return codigo, nil, err
+	}
+	nuevas, err := pgx.CollectRows(rows, pgx.RowTo[string])
+	if err != nil {
+		return codigo, nil, err
+	}
+	if len(nuevas) == 0 {
+		return codigo, nil, fmt.Errorf("%w: el reclamo ya tiene esas etiquetas", errTransicionInvalida)
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO historial_reclamos (reclamo_id, estado_anterior, estado_nuevo, tipo_accion, comentario, usuario_accion)
+		VALUES ($1, $2, $2, 'ETIQUETADO', $3, $4)
+	`, id, estado, "Etiquetas: "+strings.Join(nuevas, ", "), usuario)
+	if err != nil {
+		return codigo, nil, err
+	}
+	return codigo, gin.H{"etiquetas": nuevas}, nil
+}
+
+// exportarSeleccion descarga la selección con el mismo formato y columnas
+// que la exportación del listado.
+func exportarSeleccion(ctx context.Context, c *gin.Context, ids []string, req solicitudMasiva, columnas []columnaExport) {
+	exportarReclamos(ctx, c, solicitudExport{
+		Formato:  req.Formato,
+		Columnas: columnas,
+		Where:    " AND r.id = ANY($1::uuid[])",
+		Orden:    "r.fecha_registro ASC, r.id",
+		Args:     []interface{}{ids},
+		Detalles: gin.H{"masivo": true},
+	})
+}
@@ -0,0 +1,367 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// =============================================================================
+// NOTAS INTERNAS
+// Van en su propia tabla (no en mensajes_seguimiento) para que ninguna ruta
+// pública pueda mostrarlas por error. El autor puede editarlas dentro de la
+// ventana configurada; cada edición guarda el texto anterior.
+// =============================================================================
+
+const maxNotaInterna = 5000
+
+// @jperez (parte local del email) o @jperez@codeplex.pe
+var reMencion = regexp.MustCompile(`(?:^|[^\w@])@([A-Za-z0-9._%+-]+(?:@[A-Za-z0-9.-]+\.[A-Za-z]{2,})?)`)
+
+// extraerMenciones devuelve los identificadores mencionados, en minúsculas y
+// sin repetir.
+func extraerMenciones(texto string) []string {
+	vistos := map[string]bool{}
+	var menciones []string
+	for _, m := range reMencion.FindAllStringSubmatch(texto, -1) {
+		id := strings.ToLower(strings.TrimRight(m[1], "."))
+		if id != "" && !vistos[id] {
+			vistos[id] = true
+			menciones = append(menciones, id)
+		}
+	}
+	return menciones
+}
+
+type usuarioMencionado struct {
+	ID, Email, Nombre string
+}
+
+func resolverMenciones(ctx context.Context, menciones []string) ([]usuarioMencionado, error) {
+	if len(menciones) == 0 {
+		return nil, nil
+	}
+	rows, err := pool.Query(ctx, `
+		SELECT id, email, nombre_completo FROM usuarios_admin
+		WHERE activo = true
+		  AND (LOWER(email) = ANY($1) OR LOWER(split_part(email, '@', 1)) = ANY($1))
+	`, menciones)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var usuarios []usuarioMencionado
+	for rows.Next() {
+		var u usuarioMencionado
+		if err := rows.Scan(&u.ID, &u.Email, &u.Nombre); err == nil {
+			usuarios = append(usuarios, u)
+		}
+	}
+	return usuarios, rows.Err()
+}
+
+// GET /api/admin/reclamos/:id/notas - Listar notas internas del reclamo
+func listarNotasHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	rows, err := pool.Query(ctx, `
+		SELECT n.id, n.contenido, n.autor_id, n.autor_email, COALESCE(ua.nombre_completo, n.autor_email),
+		       n.fecha_creacion, n.fecha_edicion,
+		       n.fecha_creacion > NOW() - ($2::int * INTERVAL '1 minute') AS en_ventana,
+		       (SELECT COUNT(*) FROM notas_internas_ediciones e WHERE e.nota_id = n.id) AS ediciones,
+		       COALESCE((SELECT json_agg(u.email) FROM notas_internas_menciones nm
+		                 JOIN usuarios_admin u ON u.id = nm.usuario_id WHERE nm.nota_id = n.id), '[]')::text
+		FROM notas_internas n
+		LEFT JOIN usuarios_admin ua ON ua.id = n.autor_id
+		WHERE n.reclamo_id = $1
+		ORDER BY n.fecha_creacion ASC
+	`, c.Param("id"), config.NotasVentanaEdicionMinutos)
+	if err != nil {
+		log.Printf("❌ Error listando notas: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al obtener notas"})
+		return
+	}
+	defer rows.Close()
+
+	userID := fmt.Sprintf("%v", c.MustGet("user_id"))
+
+	notas := []gin.H{}
+	for rows.Next() {
+		var id, contenido, autorEmail, autorNombre, menciones string
+		var autorID *string
+		var fechaCreacion time.Time
+		var fechaEdicion *time.Time
+		var enVentana bool
+		var ediciones int64
+		if err := rows.Scan(&id, &contenido, &autorID, &autorEmail, &autorNombre, &fechaCreacion, &fechaEdicion, &enVentana, &ediciones, &menciones); err != nil {
+			continue
+		}
+		esAutor := autorID != nil && *autorID == userID
+		notas = append(notas, gin.H{
+			"id":             id,
+			"contenido":      contenido,
+			"autor_email":    autorEmail,
+			"autor_nombre":   autorNombre,
+			"fecha_creacion": fechaCreacion,
+			"fecha_edicion":  fechaEdicion,
+			"ediciones":      ediciones,
+			"menciones":      json.RawMessage(menciones),
+			"editable":       esAutor && enVentana,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": notas})
+}
+
+// POST /api/admin/reclamos/:id/notas - Crear nota interna
+func crearNotaHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	reclamoID := c.Param("id")
+	userID := fmt.Sprintf("%v", c.MustGet("user_id"))
+	email, _ := c.Get("email")
+
+	var req struct {
+		Contenido string `json:"contenido" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Contenido) == "" || len([]rune(req.Contenido)) > maxNotaInterna {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": fmt.Sprintf("Contenido inválido (máx %d caracteres)", maxNotaInterna)})
+		return
+	}
+
+	var codigo string
+	if err := pool.QueryRow(ctx, "SELECT codigo_reclamo FROM reclamos WHERE id = $1", reclamoID).Scan(&codigo); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Reclamo no encontrado"})
+		return
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al iniciar transacción"})
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	var notaID string
+	var fechaCreacion time.Time
+	err = tx.QueryRow(ctx, `
+		INSERT INTO notas_internas (reclamo_id, autor_id, autor_email, contenido)
+		VALUES ($1, $2::uuid, $3, $4)
+		RETURNING id, fecha_creacion
+	`, reclamoID, userID, fmt.Sprintf("%v", email), req.Contenido).Scan(&notaID, &fechaCreacion)
+	if err != nil {
+		log.Printf("❌ Error creando nota: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al guardar la nota"})
+		return
+	}
+
+	mencionados, err := resolverMenciones(ctx, extraerMenciones(req.Contenido))
+	if err != nil {
+		log.Printf("⚠️ Error resolviendo menciones: %v", err)
+	}
+	for _, u := range mencionados {
+		tx.Exec(ctx, "INSERT INTO notas_internas_menciones (nota_id, usuario_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", notaID, u.ID)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al guardar la nota"})
+		return
+	}
+
+	registrarAuditoriaNota(ctx, userID, "CREAR_NOTA", notaID, reclamoID, c.ClientIP())
+	emailsEnCurso.Add(1)
+	go func() {
+		defer emailsEnCurso.Done()
+		notificarMenciones(mencionados, userID, fmt.Sprintf("%v", email), codigo, reclamoID, req.Contenido)
+	}()
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Nota guardada",
+		"data":    gin.H{"id": notaID, "fecha_creacion": fechaCreacion, "menciones": emailsMencionados(mencionados)},
+	})
+}
+
+// PUT /api/admin/reclamos/:id/notas/:nota_id - Editar nota (solo el autor, dentro de la ventana)
+func editarNotaHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	reclamoID := c.Param("id")
+	notaID := c.Param("nota_id")
+	userID := fmt.Sprintf("%v", c.MustGet("user_id"))
+	email, _ := c.Get("email")
+
+	var req struct {
+		Contenido string `json:"contenido" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Contenido) == "" || len([]rune(req.Contenido)) > maxNotaInterna {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": fmt.Sprintf("Contenido inválido (máx %d caracteres)", maxNotaInterna)})
+		return
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al iniciar transacción"})
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	// La ventana se evalúa en la BD, con el mismo reloj que fecha_creacion
+	var contenidoAnterior, codigo string
+	var autorID *string
+	var enVentana bool
+	err = tx.QueryRow(ctx, `
+		SELECT n.contenido, n.autor_id, n.fecha_creacion > NOW() - ($3::int * INTERVAL '1 minute'), r.codigo_reclamo
+		FROM notas_internas n JOIN reclamos r ON r.id = n.reclamo_id
+		WHERE n.id = $1 AND n.reclamo_id = $2
+		FOR UPDATE OF n
+	`, notaID, reclamoID, config.NotasVentanaEdicionMinutos).Scan(&contenidoAnterior, &autorID, &enVentana, &codigo)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Nota no encontrada"})
+		return
+	}
+
+	if autorID == nil || *autorID != userID {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "Solo el autor puede editar la nota"})
+		return
+	}
+	if !enVentana {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": fmt.Sprintf("La nota solo se puede editar durante %d minutos", config.NotasVentanaEdicionMinutos)})
+		return
+	}
+	if contenidoAnterior == req.Contenido {
+		c.JSON(http.StatusOK, gin.H{"success": true, "message": "Sin cambios"})
+		return
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO notas_internas_ediciones (nota_id, contenido_anterior, editado_por)
+		VALUES ($1, $2, $3::uuid)
+	`, notaID, contenidoAnterior, userID)
+	if err == nil {
+		_, err = tx.Exec(ctx, "UPDATE notas_internas SET contenido = $2, fecha_edicion = NOW() WHERE id = $1", notaID, req.Contenido)
+	}
+	if err != nil {
+		log.Printf("❌ Error editando nota %s: %v", notaID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al editar la nota"})
+		return
+	}
+
+	// Solo se avisa a quienes no estaban mencionados antes
+	anteriores := map[string]bool{}
+	for _, m := range extraerMenciones(contenidoAnterior) {
+		anteriores[m] = true
+	}
+	var nuevas []string
+	for _, m := range extraerMenciones(req.Contenido) {
+		if !anteriores[m] {
+			nuevas = append(nuevas, m)
+		}
+	}
+
+	mencionados, err := resolverMenciones(ctx, extraerMenciones(req.Contenido))
+	if err != nil {
+		log.Printf("⚠️ Error resolviendo menciones: %v", err)
+	}
+	tx.Exec(ctx, "DELETE FROM notas_internas_menciones WHERE nota_id = $1", notaID)
+	for _, u := range mencionados {
+		tx.Exec(ctx, "INSERT INTO notas_internas_menciones (nota_id, usuario_id) VALUES ($1, $2) ON CONFLICT DO NOTHING", notaID, u.ID)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al editar la nota"})
+		return
+	}
+
+	registrarAuditoriaNota(ctx, userID, "EDITAR_NOTA", notaID, reclamoID, c.ClientIP())
+	if nuevosMencionados, err := resolverMenciones(ctx, nuevas); err == nil {
+		emailsEnCurso.Add(1)
+		go func() {
+			defer emailsEnCurso.Done()
+			notificarMenciones(nuevosMencionados, userID, fmt.Sprintf("%v", email), codigo, reclamoID, req.Contenido)
+		}()
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Nota actualizada", "data": gin.H{"menciones": emailsMencionados(mencionados)}})
+}
+
+// GET /api/admin/reclamos/:id/notas/:nota_id/historial - Versiones anteriores de la nota
+func historialNotaHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	rows, err := pool.Query(ctx, `
+		SELECT e.contenido_anterior, COALESCE(ua.email, ''), e.fecha_edicion
+		FROM notas_internas_ediciones e
+		JOIN notas_internas n ON n.id = e.nota_id
+		LEFT JOIN usuarios_admin ua ON ua.id = e.editado_por
+		WHERE e.nota_id = $1 AND n.reclamo_id = $2
+		ORDER BY e.fecha_edicion DESC
+	`, c.Param("nota_id"), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al obtener el historial"})
+		return
+	}
+	defer rows.Close()
+
+	historial := []gin.H{}
+	for rows.Next() {
+		var contenido, editadoPor string
+		var fecha time.Time
+		if err := rows.Scan(&contenido, &editadoPor, &fecha); err == nil {
+			historial = append(historial, gin.H{"contenido_anterior": contenido, "editado_por": editadoPor, "fecha_edicion": fecha})
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": historial})
+}
+
+func emailsMencionados(usuarios []usuarioMencionado) []string {
+	emails := []string{}
+	for _, u := range usuarios {
+		emails = append(emails, u.Email)
+	}
+	return emails
+}
+
+func registrarAuditoriaNota(ctx context.Context, userID, accion, notaID, reclamoID, ip string) {
+	_, err := pool.Exec(ctx, `
+		INSERT INTO auditoria_admin (usuario_id, accion, entidad, entidad_id, detalles, ip_address)
+		VALUES ($1::uuid, $2, 'NOTA_INTERNA', $3, jsonb_build_object('reclamo_id', $4::text), $5)
+	`, userID, accion, notaID, reclamoID, ip)
+	if err != nil {
+		log.Printf("⚠️ Error registrando auditoría: %v", err)
+	}
+}
+
+// notificarMenciones avisa por email a los agentes mencionados (no al autor).
+func notificarMenciones(usuarios []usuarioMencionado, autorID, autorEmail, codigo, reclamoID, contenido string) {
+	for _, u := range usuarios {
+		if u.ID == autorID {
+			continue
+		}
+		cuerpo := fmt.Sprintf(`<p style="margin: 0 0 12px 0;">%s le mencionó en una nota interna del reclamo <strong>%s</strong>:</p>
+<div style="padding: 12px 15px; background-color: #f9fafb; border-left: 4px solid #1e40af; white-space: pre-wrap; word-break: break-word;">%s</div>`,
+			html.EscapeString(autorEmail), html.EscapeString(codigo), html.EscapeString(contenido))
+
+		contenidoEmail := generarEmailAviso("📝 Le mencionaron en una nota", "#1e40af", cuerpo,
+			"Abrir reclamo", fmt.Sprintf("%s/admin/reclamos/%s", config.FrontendURL, reclamoID),
+			"Nota interna: no es visible para el consumidor.")
+
+		if err := enviarEmailHTML(u.Email, fmt.Sprintf("Mención en %s", codigo), contenidoEmail); err != nil {
+			log.Printf("❌ Error avisando mención a %s: %v", u.Email, err)
+		}
+	}
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// =============================================================================
+// AMPLIACIÓN Y PAUSA DEL PLAZO DE RESPUESTA
+// fecha_limite_respuesta es una columna generada y no puede cambiar; la
+// ampliación se guarda en fecha_limite_ampliada y los días en espera del
+// consumidor se suman al plazo. Todas las consultas usan sqlFechaLimite.
+// =============================================================================
+
+const eventoPlazo = "plazo"
+
+// Plazo sin pausas: el legal o el ampliado
+const sqlFechaLimiteBase = `COALESCE(r.fecha_limite_ampliada, r.fecha_limite_respuesta)`
+
+// Plazo vigente del reclamo (alias r); usar siempre en lugar de la columna.
+// Una pausa abierta corre el plazo día a día hasta que el consumidor responde.
+const sqlFechaLimite = `(` + sqlFechaLimiteBase + ` + r.dias_pausados + COALESCE(CURRENT_DATE - r.pausado_desde::date, 0))`
+
+// POST /api/admin/reclamos/:id/ampliar-plazo - Ampliar el plazo con justificación
+func ampliarPlazoHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+
+	id := c.Param("id")
+	userID := fmt.Sprintf("%v", c.MustGet("user_id"))
+	email := fmt.Sprintf("%v", c.MustGet("email"))
+	rol, _ := c.Get("rol")
+
+	var req struct {
+		Dias          int    `json:"dias" binding:"required,min=1"`
+		Justificacion string `json:"justificacion" binding:"required,min=20,max=2000"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Indique los días y una justificación (mínimo 20 caracteres)"})
+		return
+	}
+	req.Justificacion = strings.TrimSpace(req.Justificacion)
+	if req.Dias > config.PlazoAmpliacionMaxDias {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": fmt.Sprintf("La ampliación no puede superar %d días", config.PlazoAmpliacionMaxDias),
+		})
+		return
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al ampliar el plazo"})
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	var codigo, estado, nombre, emailConsumidor, tipo string
+	var atendidoPor *string
+	var fechaAnterior, fechaBase time.Time
+	var yaAmpliado, vencido bool
+	err = tx.QueryRow(ctx, `
+		SELECT r.codigo_reclamo, r.estado, r.nombre_completo, r.email, r.tipo_solicitud, r.atendido_por,
+		       `+sqlFechaLimite+`, `+sqlFechaLimiteBase+`,
+		       r.fecha_limite_ampliada IS NOT NULL, `+sqlFechaLimite+` < CURRENT_DATE
+		FROM reclamos r WHERE r.id = $1
+		FOR UPDATE
+	`, id).Scan(&codigo, &estado, &nombre, &emailConsumidor, &tipo, &atendidoPor, &fechaAnterior, &fechaBase, &yaAmpliado, &vencido)
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Reclamo no encontrado"})
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Error obteniendo reclamo %s para ampliar plazo: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al ampliar el plazo"})
+		return
+	}
+
+	// Solo ADMIN o el agente asignado al reclamo
+	if rol != "ADMIN" && (atendidoPor == nil || *atendidoPor != userID) {
+		c.JSON(http.StatusForbidden, gin.H{"success": false, "message": "Solo un administrador o el agente asignado puede ampliar el plazo"})
+		return
+	}
+
+	switch {
+	case estado == "RESUELTO" || estado == "CERRADO":
+		c.JSON(http.StatusConflict, gin.H{"success": false, "message": "El reclamo ya fue atendido"})
+		return
+	case yaAmpliado:
+		c.JSON(http.StatusConflict, gin.H{"success": false, "message": "El plazo de este reclamo ya fue ampliado"})
+		return
+	case vencido:
+		// La ampliación debe comunicarse al consumidor antes del vencimiento
+		c.JSON(http.StatusConflict, gin.H{"success": false, "message": "El plazo ya venció y no puede ampliarse"})
+		return
+	}
+
+	// Se amplía la base: los días pausados se siguen sumando aparte
+	fechaNueva := fechaAnterior.AddDate(0, 0, req.Dias)
+
+	if _, err := tx.Exec(ctx, "UPDATE reclamos SET fecha_limite_ampliada = $2 WHERE id = $1", id, fechaBase.AddDate(0, 0, req.Dias)); err != nil {
+		log.Printf("❌ Error ampliando plazo de %s: %v", codigo, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al ampliar el plazo"})
+		return
+	}
+
+	var ampliacionID string
+	err = tx.QueryRow(ctx, `
+		INSERT INTO ampliaciones_plazo (reclamo_id, dias, fecha_limite_anterior, fecha_limite_nueva, justificacion, solicitado_por, solicitado_por_email)
+		VALUES ($1, $2, $3, $4, $5, $6::uuid, $7)
+		RETURNING id
+	`, id, req.Dias, fechaAnterior, fechaNueva, req.Justificacion, userID, email).Scan(&ampliacionID)
+	if err != nil {
+		log.Printf("❌ Error registrando ampliación de %s: %v", codigo, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al ampliar el plazo"})
+		return
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO historial_reclamos (reclamo_id, estado_anterior, estado_nuevo, tipo_accion, comentario, usuario_accion)
+		VALUES ($1, $2, $2, 'AMPLIACION_PLAZO', $3, $4)
+	`, id, estado, fmt.Sprintf("Plazo ampliado %d días: %s → %s. %s",
+		req.Dias, fechaAnterior.Format("02/01/2006"), fechaNueva.Format("02/01/2006"), req.Justificacion), email)
+	if err != nil {
+		log.Printf("❌ Error registrando historial de ampliación de %s: %v", codigo, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al ampliar el plazo"})
+		return
+	}
+
+	// Con el nuevo plazo las alertas de vencimiento vuelven a empezar
+	if _, err := tx.Exec(ctx, "DELETE FROM alertas_sla WHERE reclamo_id = $1", id); err != nil {
+		log.Printf("⚠️ Error reiniciando alertas SLA de %s: %v", codigo, err)
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al ampliar el plazo"})
+		return
+	}
+
+	_, errAudit := pool.Exec(ctx, `
+		INSERT INTO auditoria_admin (usuario_id, accion, entidad, entidad_id, detalles, ip_address)
+		VALUES ($1::uuid, 'AMPLIAR_PLAZO', 'RECLAMO', $2, jsonb_build_object('dias', $3::int, 'fecha_limite_nueva', $4::text), $5)
+	`, userID, id, req.Dias, fechaNueva.Format("2006-01-02"), c.ClientIP())
+	if errAudit != nil {
+		log.Printf("⚠️ Error registrando auditoría: %v", errAudit)
+	}
+
+	publicarEvento(id, eventoPlazo, gin.H{"fecha_limite_respuesta": fechaNueva, "plazo_ampliado": true})
+
+	emailsEnCurso.Add(1)
+	go func() {
+		defer emailsEnCurso.Done()
+		enviarEmailAmpliacionPlazo(ampliacionID, codigo, tipo, nombre, emailConsumidor, req.Justificacion, fechaAnterior, fechaNueva)
+	}()
+
+	log.Printf("✅ Plazo de %s ampliado %d días hasta %s por %s", codigo, req.Dias, fechaNueva.Format("02/01/2006"), email)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Plazo ampliado; se notificará al consumidor por email",
+		"data": gin.H{
+			"id":                     ampliacionID,
+			"fecha_limite_anterior":  fechaAnterior,
+			"fecha_limite_respuesta": fechaNueva,
+			"dias":                   req.Dias,
+		},
+	})
+}
+
+// GET /api/admin/reclamos/:id/ampliaciones - Ampliaciones de plazo del reclamo
+func listarAmpliacionesHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	rows, err := pool.Query(ctx, `
+		SELECT id, dias, fecha_limite_anterior, fecha_limite_nueva, justificacion,
+		       solicitado_por_email, fecha_solicitud, fecha_notificacion
+		FROM ampliaciones_plazo
+		WHERE reclamo_id = $1
+		ORDER BY fecha_solicitud ASC
+	`, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al obtener las ampliaciones"})
+		return
+	}
+	defer rows.Close()
+
+	ampliaciones := []gin.H{}
+	for rows.Next() {
+		var id, justificacion, solicitadoPor string
+		var dias int
+		var anterior, nueva, fechaSolicitud time.Time
+		var fechaNotificacion *time.Time
+		if err := rows.Scan(&id, &dias, &anterior, &nueva, &justificacion, &solicitadoPor, &fechaSolicitud, &fechaNotificacion); err != nil {
+			continue
+		}
+		ampliaciones = append(ampliaciones, gin.H{
+			"id":                    id,
+			"dias":                  dias,
+			"fecha_limite_anterior": anterior,
+			"fecha_limite_nueva":    nueva,
+			"justificacion":         justificacion,
+			"solicitado_por":        solicitadoPor,
+			"fecha_solicitud":       fechaSolicitud,
+			"fecha_notificacion":    fechaNotificacion,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": ampliaciones})
+}
+
+// enviarEmailAmpliacionPlazo comunica la ampliación al consumidor. Es una
+// notificación obligatoria: se envía aunque no haya aceptado copias.
+func enviarEmailAmpliacionPlazo(ampliacionID, codigo, tipo, nombre, email, justificacion string, anterior, nueva time.Time) {
+	cuerpo := fmt.Sprintf(`<p style="margin: 0 0 12px 0; color: #1f2937;">Estimado/a %s,</p>
+<p style="margin: 0 0 12px 0;">Le informamos que el plazo de respuesta de su %s <strong>%s</strong> ha sido ampliado del <strong>%s</strong> al <strong>%s</strong>, debido a la complejidad del caso.</p>
+<div style="margin: 0 0 12px 0; padding: 12px 15px; background-color: #f9fafb; border-left: 4px solid #d97706; border-radius: 0 4px 4px 0;">
+<p style="margin: 0 0 6px 0; color: #6b7280; font-size: 12px;">Motivo</p>
+<div style="white-space: pre-wrap; color: #1f2937; line-height: 1.6; word-break: break-word;">%s</div>
+</div>`,
+		html.EscapeString(nombre), strings.ToLower(tipo), html.EscapeString(codigo),
+		anterior.Format("02/01/2006"), nueva.Format("02/01/2006"), html.EscapeString(justificacion))
+
+	contenido := generarEmailAviso("📅 Ampliación del plazo de respuesta", "#d97706", cuerpo,
+		"Ver seguimiento", enlaceSeguimientoFirmado(codigo),
+		"Esta comunicación se realiza conforme al Reglamento del Libro de Reclamaciones.")
+
+	asunto := fmt.Sprintf("Ampliación del plazo de respuesta - %s", codigo)
+	if err := enviarEmailHTMLReclamo(email, asunto, contenido, codigo); err != nil {
+		log.Printf("❌ Error notificando ampliación de plazo de %s: %v", codigo, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	pool.Exec(ctx, "UPDATE ampliaciones_plazo SET fecha_notificacion = NOW() WHERE id = $1", ampliacionID)
+	log.Printf("✅ Ampliación de plazo notificada al consumidor de %s", codigo)
+}
+
+// =============================================================================
+// PAUSA: ESPERANDO INFORMACIÓN DEL CONSUMIDOR
+// Un mensaje del agente con esperar_respuesta=true abre una pausa; la primera
+// respuesta del consumidor (web o email) la cierra. Los días se cuentan por
+// fecha calendario y se acumulan en reclamos.dias_pausados.
+// =============================================================================
+
+// pausarPlazo abre una pausa si el reclamo está abierto y no está ya pausado.
+func pausarPlazo(ctx context.Context, reclamoID, mensajeID, motivo, usuario string) (bool, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	var estado string
+	err = tx.QueryRow(ctx, `
+		UPDATE reclamos SET pausado_desde = NOW()
+		WHERE id = $1 AND pausado_desde IS NULL AND estado IN ('PENDIENTE', 'EN_PROCESO')
+		RETURNING estado
+	`, reclamoID).Scan(&estado)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO pausas_plazo (reclamo_id, mensaje_id, motivo, iniciado_por)
+		VALUES ($1, NULLIF($2, '')::uuid, $3, $4)
+	`, reclamoID, mensajeID, motivo, usuario); err != nil {
+		return false, err
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO historial_reclamos (reclamo_id, estado_anterior, estado_nuevo, tipo_accion, comentario, usuario_accion)
+		VALUES ($1, $2, $2, 'PAUSA_PLAZO', $3, $4)
+	`, reclamoID, estado, "Plazo en pausa: esperando información del consumidor", usuario); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, err
+	}
+
+	publicarEvento(reclamoID, eventoPlazo, gin.H{"esperando_consumidor": true})
+	return true, nil
+}
+
+// reanudarPlazo cierra la pausa abierta y suma sus días al plazo. Es idempotente:
+// sin pausa abierta no hace nada.
+func reanudarPlazo(ctx context.Context, reclamoID, motivo, usuario string) (bool, error) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback(ctx)
+
+	var estado string
+	var dias int
+	err = tx.QueryRow(ctx, `
+		SELECT estado, (CURRENT_DATE - pausado_desde::date)::int
+		FROM reclamos WHERE id = $1 AND pausado_desde IS NOT NULL
+		FOR UPDATE
+	`, reclamoID).Scan(&estado, &dias)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE reclamos SET dias_pausados = dias_pausados + $2, pausado_desde = NULL WHERE id = $1
+	`, reclamoID, dias); err != nil {
+		return false, err
+	}
+
+	if _, err := tx.Exec(ctx, `
+		UPDATE pausas_plazo SET fecha_fin = NOW(), dias = $2, motivo_fin = $3
+		WHERE reclamo_id = $1 AND fecha_fin IS NULL
+	`, reclamoID, dias, motivo); err != nil {
+		return false, err
+	}
+
+	if _, err := tx.Exec(ctx, `
+		INSERT INTO historial_reclamos (reclamo_id, estado_anterior, estado_nuevo, tipo_accion, comentario, usuario_accion)
+		VALUES ($1, $2, $2, 'REANUDACION_PLAZO', $3, $4)
+	`, reclamoID, estado, fmt.Sprintf("Plazo reanudado (%s); %d día(s) en pausa", motivo, dias), usuario); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+		return false, err
+	}
+
+	publicarEvento(reclamoID, eventoPlazo, gin.H{"esperando_consumidor": false, "dias_pausa": dias})
+	return true, nil
+}
+
+// DELETE /api/admin/reclamos/:id/pausa - Reanudar el plazo manualmente
+func reanudarPlazoHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	email := fmt.Sprintf("%v", c.MustGet("email"))
+	reanudado, err := reanudarPlazo(ctx, c.Param("id"), "reanudado por el agente", email)
+	if err != nil {
+		log.Printf("❌ Error reanudando plazo de %s: %v", c.Param("id"), err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al reanudar el plazo"})
+		return
+	}
+	if !reanudado {
+		c.JSON(http.StatusConflict, gin.H{"success": false, "message": "El plazo del reclamo no está en pausa"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Plazo reanudado"})
+}
+
+// GET /api/admin/reclamos/:id/pausas - Periodos de pausa del plazo
+func listarPausasHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	rows, err := pool.Query(ctx, `
+		SELECT id, motivo, iniciado_por, fecha_inicio, fecha_fin,
+		       COALESCE(dias, (CURRENT_DATE - fecha_inicio::date)::int), motivo_fin
+		FROM pausas_plazo
+		WHERE reclamo_id = $1
+		ORDER BY fecha_inicio ASC
+	`, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al obtener las pausas"})
+		return
+	}
+	defer rows.Close()
+
+	pausas := []gin.H{}
+	for rows.Next() {
+		var id, motivo, iniciadoPor string
+		var inicio time.Time
+		var fin *time.Time
+		var dias int
+		var motivoFin *string
+		if err := rows.Scan(&id, &motivo, &iniciadoPor, &inicio, &fin, &dias, &motivoFin); err != nil {
+			continue
+		}
+		pausas = append(pausas, gin.H{
+			"id":           id,
+			"motivo":       motivo,
+			"iniciado_por": iniciadoPor,
+			"fecha_inicio": inicio,
+			"fecha_fin":    fin,
+			"dias":         dias,
+			"motivo_fin":   motivoFin,
+			"abierta":      fin == nil,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": pausas})
+}
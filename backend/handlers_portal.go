@@ -0,0 +1,447 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"html"
+	"log"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// =============================================================================
+// PORTAL DEL CONSUMIDOR
+// En lugar de escribir código y documento en cada visita, el consumidor pide
+// un enlace de un solo uso a su email registrado. Al canjearlo recibe un token
+// de sesión corto que cubre todos sus reclamos (mismo email y documento).
+// =============================================================================
+
+const tipoSesionConsumidor = "sesion_consumidor"
+
+type claimsSesionConsumidor struct {
+	Email           string `json:"email"`
+	NumeroDocumento string `json:"numero_documento"`
+	Tipo            string `json:"tipo"`
+	jwt.RegisteredClaims
+}
+
+func firmarSesionConsumidor(email, documento string, duracion time.Duration) (string, error) {
+	ahora := time.Now()
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claimsSesionConsumidor{
+		Email:           strings.ToLower(email),
+		NumeroDocumento: documento,
+		Tipo:            tipoSesionConsumidor,
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(ahora),
+			ExpiresAt: jwt.NewNumericDate(ahora.Add(duracion)),
+		},
+	})
+	return token.SignedString(claveTokensConsumidor())
+}
+
+func validarSesionConsumidor(tokenString string) (*claimsSesionConsumidor, bool) {
+	var claims claimsSesionConsumidor
+	token, err := jwt.ParseWithClaims(tokenString, &claims, func(t *jwt.Token) (interface{}, error) {
+		return claveTokensConsumidor(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}), jwt.WithExpirationRequired())
+	if err != nil || !token.Valid || claims.Tipo != tipoSesionConsumidor || claims.NumeroDocumento == "" {
+		return nil, false
+	}
+	return &claims, true
+}
+
+// sesionConsumidor lee el token de sesión del header Authorization, si hay uno.
+func sesionConsumidor(c *gin.Context) (*claimsSesionConsumidor, bool) {
+	authHeader := c.GetHeader("Authorization")
+	if !strings.HasPrefix(authHeader, "Bearer ") {
+		return nil, false
+	}
+	return validarSesionConsumidor(strings.TrimPrefix(authHeader, "Bearer "))
+}
+
+func sesionConsumidorMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sesion, ok := sesionConsumidor(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"success": false, "message": "Sesión inválida o expirada"})
+			c.Abort()
+			return
+		}
+		c.Set("sesion_consumidor", sesion)
+		c.Next()
+	}
+}
+
+// sesionCubreReclamo indica si el reclamo pertenece al par email/documento de
+// la sesión.
+func sesionCubreReclamo(ctx context.Context, sesion *claimsSesionConsumidor, codigo string) (bool, error) {
+	var existe bool
+	err := pool.QueryRow(ctx, `
+		SELECT EXISTS (
+			SELECT 1 FROM reclamos
+			WHERE codigo_reclamo = $1 AND numero_documento = $2 AND LOWER(email) = $3
+		)
+	`, codigo, sesion.NumeroDocumento, sesion.Email).Scan(&existe)
+	return existe, err
+}
+
+func enlaceAccesoPortal(token string) string {
+	return fmt.Sprintf("%s/seguimiento?acceso=%s", config.FrontendURL, token)
+}
+
+// POST /api/seguimiento/acceso - Enviar enlace de acceso al email registrado
+func solicitarAccesoPortalHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var req struct {
+		NumeroDocumento string `json:"numero_documento" binding:"required,max=50"`
+		Email           string `json:"email"`
+		CodigoReclamo   string `json:"codigo_reclamo"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Email == "" && req.CodigoReclamo == "") {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Se requiere el número de documento y el email o código del reclamo"})
+		return
+	}
+
+	// La respuesta es siempre la misma para no revelar qué documentos tienen reclamos
+	respuesta := gin.H{"success": true, "message": "Si los datos coinciden con un reclamo registrado, enviaremos un enlace de acceso a su correo."}
+
+	// El enlace va al email guardado en el reclamo, nunca al que escribió el usuario
+	var email, nombre string
+	err := pool.QueryRow(ctx, `
+		SELECT email, nombre_completo FROM reclamos
+		WHERE numero_documento = $1
+		  AND (($2 <> '' AND LOWER(email) = LOWER($2)) OR ($3 <> '' AND codigo_reclamo = $3))
+		ORDER BY fecha_registro DESC
+		LIMIT 1
+	`, req.NumeroDocumento, strings.TrimSpace(req.Email), strings.TrimSpace(req.CodigoReclamo)).Scan(&email, &nombre)
+	if err != nil {
+		registrarIntentoRechazado("ACCESO_PORTAL", "CONSULTA_FALLIDA", c.ClientIP(), c.GetHeader("User-Agent"), req.NumeroDocumento, c.Request.URL.Path)
+		c.JSON(http.StatusOK, respuesta)
+		return
+	}
+
+	token, err := generarTokenSeguro()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error interno"})
+		return
+	}
+
+	var fechaExpiracion time.Time
+	err = pool.QueryRow(ctx, `
+		INSERT INTO accesos_consumidor (token_hash, email, numero_documento, ip_solicitud, fecha_expiracion)
+		VALUES ($1, LOWER($2), $3, $4, NOW() + ($5::int * INTERVAL '1 minute'))
+		RETURNING fecha_expiracion
+	`, hashToken(token), email, req.NumeroDocumento, c.ClientIP(), config.AccesoPortalMinutos).Scan(&fechaExpiracion)
+	if err != nil {
+		log.Printf("❌ Error creando acceso al portal: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al generar el enlace de acceso"})
+		return
+	}
+
+	go enviarEmailAccesoPortal(email, nombre, token)
+
+	c.JSON(http.StatusOK, respuesta)
+}
+
+// POST /api/seguimiento/acceso/canjear - Canjear el enlace por un token de sesión
+func canjearAccesoPortalHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var req struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Token requerido"})
+		return
+	}
+
+	// El UPDATE condicionado garantiza un solo uso aunque lleguen dos canjes a la vez
+	var email, documento string
+	err := pool.QueryRow(ctx, `
+		UPDATE accesos_consumidor
+		SET fecha_uso = NOW()
+		WHERE token_hash = $1 AND fecha_uso IS NULL AND fecha_expiracion > NOW()
+		RETURNING email, numero_documento
+	`, hashToken(req.Token)).Scan(&email, &documento)
+	if err != nil {
+		registrarIntentoRechazado("ACCESO_PORTAL", "CONSULTA_FALLIDA", c.ClientIP(), c.GetHeader("User-Agent"), "", c.Request.URL.Path)
+		c.JSON(http.StatusUnauthorized, gin.H{"success": false, "message": "El enlace no es válido, ya fue usado o expiró"})
+		return
+	}
+
+	duracion := time.Duration(config.SesionConsumidorMinutos) * time.Minute
+	sesion, err := firmarSesionConsumidor(email, documento, duracion)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al iniciar sesión"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"token":            sesion,
+			"email":            email,
+			"fecha_expiracion": time.Now().Add(duracion),
+		},
+	})
+}
+
+// GET /api/seguimiento/mis-reclamos - Reclamos del email/documento de la sesión
+func misReclamosHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	sesion := c.MustGet("sesion_consumidor").(*claimsSesionConsumidor)
+
+	rows, err := pool.Query(ctx, `
+		SELECT r.codigo_reclamo, r.tipo_solicitud, r.estado, r.descripcion_bien,
+		       r.fecha_registro, `+sqlFechaLimite+`,
+		       (`+sqlFechaLimite+` - CURRENT_DATE)::int AS dias_restantes,
+		       (SELECT COUNT(*) FROM mensajes_seguimiento m WHERE m.reclamo_id = r.id) AS total_mensajes
+		FROM reclamos r
+		WHERE r.numero_documento = $1 AND LOWER(r.email) = $2
+		ORDER BY r.fecha_registro DESC
+	`, sesion.NumeroDocumento, sesion.Email)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al obtener sus reclamos"})
+		return
+	}
+	defer rows.Close()
+
+	reclamos := []gin.H{}
+	for rows.Next() {
+		var codigo, tipo, estado, descripcion string
+		var fechaRegistro, fechaLimite time.Time
+		var diasRestantes, totalMensajes int
+		if err := rows.Scan(&codigo, &tipo, &estado, &descripcion, &fechaRegistro, &fechaLimite, &diasRestantes, &totalMensajes); err != nil {
+			continue
+		}
+		reclamos = append(reclamos, gin.H{
+			"codigo_reclamo":         codigo,
+			"tipo_solicitud":         tipo,
+			"estado":                 estado,
+			"descripcion_bien":       descripcion,
+			"fecha_registro":         fechaRegistro,
+			"fecha_limite_respuesta": fechaLimite,
+			"dias_restantes":         diasRestantes,
+			"total_mensajes":         totalMensajes,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": reclamos})
+}
+
+// GET /api/seguimiento/:codigo/mensajes/:id/adjunto - Descargar adjunto de un mensaje
+func descargarAdjuntoSeguimientoHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+
+	codigo := c.Param("codigo")
+	if !autorizarConsultaPublica(c, ctx, codigo, alcanceReclamo) {
+		return
+	}
+
+	var archivo, nombre *string
+	err := pool.QueryRow(ctx, `
+		SELECT m.archivo_adjunto, m.nombre_archivo
+		FROM mensajes_seguimiento m
+		JOIN reclamos r ON r.id = m.reclamo_id
+		WHERE r.codigo_reclamo = $1 AND m.id = $2
+	`, codigo, c.Param("id")).Scan(&archivo, &nombre)
+	if err != nil || archivo == nil || *archivo == "" {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Adjunto no encontrado"})
+		return
+	}
+
+	nombreArchivo := "adjunto"
+	if nombre != nil && *nombre != "" {
+		nombreArchivo = *nombre
+	}
+	escribirAdjunto(c, *archivo, nombreArchivo)
+}
+
+// escribirAdjunto envía un adjunto guardado como data URL (o base64 pelado).
+func escribirAdjunto(c *gin.Context, archivo, nombre string) {
+	contentType := "application/octet-stream"
+	datos := archivo
+	if strings.HasPrefix(archivo, "data:") {
+		if cabecera, resto, ok := strings.Cut(archivo, ","); ok {
+			datos = resto
+			if tipo := strings.TrimSuffix(strings.TrimPrefix(cabecera, "data:"), ";base64"); tipo != "" {
+				contentType = tipo
+			}
+		}
+	}
+
+	contenido, err := base64.StdEncoding.DecodeString(datos)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al decodificar el adjunto"})
+		return
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": nombre}))
+	c.Header("Cache-Control", "private, no-store")
+	c.Data(http.StatusOK, contentType, contenido)
+}
+
+// GET /api/seguimiento/:codigo/pdf - Descargar la hoja de reclamación en PDF
+func descargarPDFSeguimientoHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
+	defer cancel()
+
+	codigo := c.Param("codigo")
+	if !autorizarConsultaPublica(c, ctx, codigo, alcanceReclamo) {
+		return
+	}
+
+	pdf, err := generarHojaReclamoPDF(ctx, codigo)
+	if err != nil {
+		log.Printf("❌ Error generando PDF de %s: %v", codigo, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al generar el PDF"})
+		return
+	}
+
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": codigo + ".pdf"}))
+	c.Header("Cache-Control", "private, no-store")
+	c.Data(http.StatusOK, "application/pdf", pdf)
+}
+
+// generarHojaReclamoPDF arma la hoja de reclamación con los datos exigidos por
+// el D.S. 011-2011-PCM y, si existe, la última respuesta de la empresa.
+func generarHojaReclamoPDF(ctx context.Context, codigo string) ([]byte, error) {
+	var r struct {
+		ID, Codigo, Tipo, Estado, Nombre, TipoDoc, NumDoc, Telefono, Email string
+		Domicilio, Departamento, Provincia, Distrito                       *string
+		RazonSocial, RUC, DireccionProveedor                               *string
+		TipoBien, AreaQueja, DescripcionSituacion                          *string
+		Monto                                                              float64
+		DescripcionBien, Detalle, Pedido                                   string
+		FechaIncidente, FechaRegistro, FechaLimite                         time.Time
+	}
+	err := pool.QueryRow(ctx, `
+		SELECT r.id, r.codigo_reclamo, r.tipo_solicitud, r.estado, r.nombre_completo, r.tipo_documento,
+		       r.numero_documento, r.telefono, r.email, r.domicilio, r.departamento, r.provincia, r.distrito,
+		       r.razon_social, r.ruc, r.direccion_proveedor, r.tipo_bien, r.area_queja, r.descripcion_situacion,
+		       COALESCE(r.monto_reclamado, 0)::float8, r.descripcion_bien, r.detalle_reclamo, r.pedido_consumidor,
+		       r.fecha_incidente, r.fecha_registro, `+sqlFechaLimite+`
+		FROM reclamos r WHERE r.codigo_reclamo = $1
+	`, codigo).Scan(
+		&r.ID, &r.Codigo, &r.Tipo, &r.Estado, &r.Nombre, &r.TipoDoc,
+		&r.NumDoc, &r.Telefono, &r.Email, &r.Domicilio, &r.Departamento, &r.Provincia, &r.Distrito,
+		&r.RazonSocial, &r.RUC, &r.DireccionProveedor, &r.TipoBien, &r.AreaQueja, &r.DescripcionSituacion,
+		&r.Monto, &r.DescripcionBien, &r.Detalle, &r.Pedido,
+		&r.FechaIncidente, &r.FechaRegistro, &r.FechaLimite,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	texto := func(s *string) string {
+		if s == nil {
+			return ""
+		}
+		return *s
+	}
+
+	d := nuevoPDF()
+	d.Titulo("HOJA DE RECLAMACIÓN - " + r.Codigo)
+	d.Campo("Tipo de solicitud", r.Tipo)
+	d.Campo("Estado", r.Estado)
+	d.Campo("Fecha de registro", r.FechaRegistro.Format("02/01/2006 15:04"))
+	d.Campo("Fecha límite de respuesta", r.FechaLimite.Format("02/01/2006"))
+
+	d.Seccion("1. Proveedor")
+	d.Campo("Razón social", texto(r.RazonSocial))
+	d.Campo("RUC", texto(r.RUC))
+	d.Campo("Dirección", texto(r.DireccionProveedor))
+
+	d.Seccion("2. Consumidor")
+	d.Campo("Nombre", r.Nombre)
+	d.Campo("Documento", r.TipoDoc+" "+r.NumDoc)
+	d.Campo("Teléfono", r.Telefono)
+	d.Campo("Email", r.Email)
+	d.Campo("Domicilio", texto(r.Domicilio))
+	d.Campo("Ubicación", strings.Trim(strings.Join([]string{texto(r.Distrito), texto(r.Provincia), texto(r.Departamento)}, ", "), ", "))
+
+	d.Seccion("3. Bien contratado")
+	d.Campo("Tipo", texto(r.TipoBien))
+	d.Campo("Monto reclamado", fmt.Sprintf("S/ %.2f", r.Monto))
+	d.Campo("Descripción", r.DescripcionBien)
+
+	d.Seccion("4. Detalle de la " + strings.ToLower(r.Tipo))
+	d.Campo("Fecha del incidente", r.FechaIncidente.Format("02/01/2006"))
+	if r.Tipo == "QUEJA" {
+		d.Campo("Área", texto(r.AreaQueja))
+		d.Campo("Situación", texto(r.DescripcionSituacion))
+	}
+	d.Campo("Detalle", r.Detalle)
+	d.Campo("Pedido del consumidor", r.Pedido)
+
+	var respuesta, respondidoPor string
+	var accion, compensacion, motivo *string
+	var fechaRespuesta time.Time
+	var version int
+	err = pool.QueryRow(ctx, `
+		SELECT respuesta_empresa, accion_tomada, compensacion_ofrecida, respondido_por, fecha_respuesta, version, motivo_enmienda
+		FROM v_respuestas_vigentes WHERE reclamo_id = $1
+	`, r.ID).Scan(&respuesta, &accion, &compensacion, &respondidoPor, &fechaRespuesta, &version, &motivo)
+	if err == nil {
+		d.Seccion("5. Respuesta del proveedor")
+		d.Campo("Fecha", fechaRespuesta.Format("02/01/2006 15:04"))
+		if version > 1 {
+			d.Campo("Versión", fmt.Sprintf("%d (%s)", version, texto(motivo)))
+		}
+		d.Campo("Respondido por", respondidoPor)
+		d.Campo("Respuesta", respuesta)
+		d.Campo("Acción tomada", texto(accion))
+		d.Campo("Compensación", texto(compensacion))
+	}
+
+	d.Espacio()
+	d.Texto("La formulación del reclamo no impide acudir a otras vías de solución de controversias ni es requisito previo para interponer una denuncia ante el INDECOPI.")
+
+	return d.Bytes(), nil
+}
+
+// =============================================================================
+// EMAIL Y PURGA DE ENLACES DE ACCESO
+// =============================================================================
+
+func enviarEmailAccesoPortal(email, nombre, token string) {
+	cuerpo := fmt.Sprintf(`<p style="margin: 0 0 12px 0; color: #1f2937;">Estimado/a %s,</p>
+<p style="margin: 0 0 12px 0;">Recibimos una solicitud para ingresar al seguimiento de sus reclamos. El enlace es válido por <strong>%d minutos</strong> y solo puede usarse una vez.</p>`,
+		html.EscapeString(nombre), config.AccesoPortalMinutos)
+
+	contenido := generarEmailAviso("🔐 Acceso a sus reclamos", "#7c3aed", cuerpo,
+		"Ingresar al seguimiento", enlaceAccesoPortal(token),
+		"Si usted no solicitó este acceso, ignore este mensaje: nadie podrá ingresar sin el enlace.")
+
+	if err := enviarEmailHTML(email, "Acceso a sus reclamos - Libro de Reclamaciones", contenido); err != nil {
+		log.Printf("❌ Error enviando enlace de acceso: %v", err)
+		return
+	}
+	log.Printf("✅ Enlace de acceso enviado a: %s", email)
+}
+
+func purgarAccesosVencidos(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	tag, err := pool.Exec(ctx, "DELETE FROM accesos_consumidor WHERE fecha_expiracion <= NOW() - INTERVAL '1 day'")
+	if err != nil {
+		log.Printf("⚠️ Error purgando accesos del portal: %v", err)
+		return
+	}
+	if n := tag.RowsAffected(); n > 0 {
+		log.Printf("🧹 Enlaces de acceso vencidos eliminados: %d", n)
+	}
+}
@@ -16,6 +16,7 @@ type UsuarioResponse struct {
 	Nombre        string     `json:"nombre_completo"`
 	Rol           string     `json:"rol"`
 	Activo        bool       `json:"activo"`
+	PuedeAprobar  bool       `json:"puede_aprobar"`
 	UltimoAcceso  *time.Time `json:"ultimo_acceso"`
 	FechaCreacion time.Time  `json:"fecha_creacion"`
 }
@@ -26,7 +27,7 @@ func listarUsuariosHandler(c *gin.Context) {
 	defer cancel()
 
 	rows, err := pool.Query(ctx, `
-		SELECT id, email, nombre_completo, rol, activo, ultimo_acceso, fecha_creacion 
+		SELECT id, email, nombre_completo, rol, activo, puede_aprobar, ultimo_acceso, fecha_creacion 
 		FROM usuarios_admin ORDER BY fecha_creacion DESC
 	`)
 	if err != nil {
@@ -38,7 +39,7 @@ func listarUsuariosHandler(c *gin.Context) {
 	var usuarios []UsuarioResponse
 	for rows.Next() {
 		var u UsuarioResponse
-		if err := rows.Scan(&u.ID, &u.Email, &u.Nombre, &u.Rol, &u.Activo, &u.UltimoAcceso, &u.FechaCreacion); err == nil {
+		if err := rows.Scan(&u.ID, &u.Email, &u.Nombre, &u.Rol, &u.Activo, &u.PuedeAprobar, &u.UltimoAcceso, &u.FechaCreacion); err == nil {
 			usuarios = append(usuarios, u)
 		}
 	}
@@ -104,6 +105,8 @@ func editarUsuarioHandler(c *gin.Context) {
 		Nombre string `json:"nombre_completo"`
 		Rol    string `json:"rol"`
 		Activo *bool  `json:"activo"` // Puntero para detectar false
+		// Permiso para aprobar respuestas oficiales (los ADMIN siempre pueden)
+		PuedeAprobar *bool `json:"puede_aprobar"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -116,9 +119,10 @@ func editarUsuarioHandler(c *gin.Context) {
 		UPDATE usuarios_admin 
 		SET nombre_completo = COALESCE(NULLIF($1, ''), nombre_completo),
 		    rol = COALESCE(NULLIF($2, ''), rol),
-		    activo = COALESCE($3, activo)
+		    activo = COALESCE($3, activo),
+		    puede_aprobar = COALESCE($5, puede_aprobar)
 		WHERE id = $4
-	`, req.Nombre, req.Rol, req.Activo, id)
+	`, req.Nombre, req.Rol, req.Activo, id, req.PuedeAprobar)
 
 	if err != nil {
 		c.JSON(500, gin.H{"success": false, "message": "Error al actualizar"})
@@ -8,11 +8,16 @@ package main
 
 import (
 	"context"
+	"crypto/rand"
+	"crypto/sha256"
 	"crypto/tls"
 	"database/sql"
 	"sync"
 	"encoding/base64"
+	"encoding/hex"
+	"errors"
 	"fmt"
+	"html"
 	"log"
 	"net/http"
 	"os"
@@ -24,6 +29,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
 	"github.com/jackc/pgx/v5/pgxpool"
 	"github.com/joho/godotenv"
 	"gopkg.in/gomail.v2"
@@ -55,6 +61,52 @@ type Config struct {
 	FrontendURL  string
 	BackendURL   string
 	EmailSoporte string
+
+	BorradorDiasExpiracion int
+
+	// Anti-spam de endpoints públicos (0 desactiva cada límite)
+	CaptchaProveedor             string
+	CaptchaSecret                string
+	AntispamMinSegundos          int
+	AntispamReclamosPorIP        int
+	AntispamReclamosPorDocumento int
+	AntispamMensajesPorIP        int
+	AntispamMensajesPorDocumento int
+	AntispamBorradoresPorIP      int
+
+	RateLimitBackend string
+	TokenAccesoDias  int
+
+	// Portal del consumidor (enlace mágico)
+	AccesoPortalMinutos     int
+	SesionConsumidorMinutos int
+
+	RealtimeBackend string
+
+	NotificacionMensajesMinutos int
+
+	// Respuestas por email (reclamo+<token>@dominio) y secreto del webhook
+	EmailRespuestas    string
+	InboundEmailSecret string
+
+	NotasVentanaEdicionMinutos int
+
+	// Alertas de vencimiento (días hábiles restantes)
+	SLAUmbralesDias       string
+	SLAUmbralSupervisores int
+	SLAFeriados           string
+
+	PlazoAmpliacionMaxDias int
+
+	BusquedaBackend string
+
+	MasivoMaxReclamos int
+	ExportMaxFilas    int
+
+	ReportesDiasRetencion int
+	ReportesDir           string
+
+	AnalyticsCacheSegundos int
 }
 
 func loadConfig() Config {
@@ -83,6 +135,48 @@ func loadConfig() Config {
 		FrontendURL:  getEnv("FRONTEND_URL", "http://localhost:4321"),
 		BackendURL:   getEnv("BACKEND_URL", "http://localhost:3000"),
 		EmailSoporte: getEnv("EMAIL_SOPORTE", "soporte@codeplex.pe"),
+
+		BorradorDiasExpiracion: getEnvInt("BORRADOR_DIAS_EXPIRACION", 7),
+
+		CaptchaProveedor:             getEnv("CAPTCHA_PROVEEDOR", ""),
+		CaptchaSecret:                getEnv("CAPTCHA_SECRET", ""),
+		AntispamMinSegundos:          getEnvInt("ANTISPAM_MIN_SEGUNDOS", 0),
+		AntispamReclamosPorIP:        getEnvInt("ANTISPAM_RECLAMOS_POR_IP_HORA", 5),
+		AntispamReclamosPorDocumento: getEnvInt("ANTISPAM_RECLAMOS_POR_DOCUMENTO_DIA", 3),
+		AntispamMensajesPorIP:        getEnvInt("ANTISPAM_MENSAJES_POR_IP_HORA", 20),
+		AntispamMensajesPorDocumento: getEnvInt("ANTISPAM_MENSAJES_POR_DOCUMENTO_HORA", 10),
+		AntispamBorradoresPorIP:      getEnvInt("ANTISPAM_BORRADORES_POR_IP_HORA", 10),
+
+		RateLimitBackend: getEnv("RATE_LIMIT_BACKEND", "memoria"),
+		TokenAccesoDias:  getEnvInt("TOKEN_ACCESO_DIAS", 30),
+
+		AccesoPortalMinutos:     getEnvInt("ACCESO_PORTAL_MINUTOS", 15),
+		SesionConsumidorMinutos: getEnvInt("SESION_CONSUMIDOR_MINUTOS", 120),
+
+		RealtimeBackend: getEnv("REALTIME_BACKEND", "memoria"),
+
+		NotificacionMensajesMinutos: getEnvInt("NOTIFICACION_MENSAJES_MINUTOS", 5),
+
+		EmailRespuestas:    getEnv("EMAIL_RESPUESTAS", ""),
+		InboundEmailSecret: getEnv("INBOUND_EMAIL_SECRET", ""),
+
+		NotasVentanaEdicionMinutos: getEnvInt("NOTAS_VENTANA_EDICION_MINUTOS", 15),
+
+		SLAUmbralesDias:       getEnv("SLA_UMBRALES_DIAS", "5,3,1"),
+		SLAUmbralSupervisores: getEnvInt("SLA_UMBRAL_SUPERVISORES", 1),
+		SLAFeriados:           getEnv("SLA_FERIADOS", ""),
+
+		PlazoAmpliacionMaxDias: getEnvInt("PLAZO_AMPLIACION_MAX_DIAS", 15),
+
+		BusquedaBackend: getEnv("BUSQUEDA_BACKEND", "auto"),
+
+		MasivoMaxReclamos: getEnvInt("MASIVO_MAX_RECLAMOS", 500),
+		ExportMaxFilas:    getEnvInt("EXPORT_MAX_FILAS", 100000),
+
+		ReportesDiasRetencion: getEnvInt("REPORTES_DIAS_RETENCION", 30),
+		ReportesDir:           getEnv("REPORTES_DIR", os.TempDir()+"/libro-reclamaciones-reportes"),
+
+		AnalyticsCacheSegundos: getEnvInt("ANALYTICS_CACHE_SEGUNDOS", 300),
 	}
 }
 
@@ -93,6 +187,13 @@ func getEnv(key, defaultValue string) string {
 	return defaultValue
 }
 
+func getEnvInt(key string, defaultValue int) int {
+	if n, err := strconv.Atoi(os.Getenv(key)); err == nil {
+		return n
+	}
+	return defaultValue
+}
+
 // =============================================================================
 // ESTRUCTURAS
 // =============================================================================
@@ -119,6 +220,7 @@ type CrearReclamoRequest struct {
 	FirmaDigital         string   `json:"firma_digital"`
 	AceptaTerminos       bool     `json:"acepta_terminos"`
 	AceptaCopia          bool     `json:"acepta_copia"`
+	Establecimiento      *string  `json:"establecimiento"`
 }
 
 type ReclamoCreado struct {
@@ -195,130 +297,175 @@ func crearReclamoHandler(c *gin.Context) {
 		return
 	}
 
+	if msg := validarReclamo(&req); msg != "" {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": msg})
+		return
+	}
+
+	// Iniciar transacción
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Error al iniciar transacción"})
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	reclamo, err := registrarReclamo(ctx, tx, req, c.ClientIP(), c.GetHeader("User-Agent"))
+	if err != nil {
+		responderErrorRegistro(c, err)
+		return
+	}
+
+	if err := tx.Commit(ctx); err != nil {
+        c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Error al confirmar transacción"})
+        return
+    }
+
+	notificarReclamoRegistrado(reclamo, req, c.ClientIP(), c.GetHeader("User-Agent"))
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": "Reclamo registrado exitosamente",
+		"data":    datosReclamoCreado(reclamo),
+	})
+}
+
+var emailRegex = regexp.MustCompile(`^[^\s@]+@[^\s@]+\.[^\s@]+$`)
+
+// validarReclamo aplica las validaciones del formulario público.
+// Devuelve el mensaje para el consumidor, o "" si la solicitud es válida.
+func validarReclamo(req *CrearReclamoRequest) string {
 	// Validaciones (igual que en Node.js)
 	if req.TipoSolicitud != "RECLAMO" && req.TipoSolicitud != "QUEJA" {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Tipo de solicitud inválido"})
-		return
+		return "Tipo de solicitud inválido"
 	}
 
 	if req.FirmaDigital == "" || !strings.HasPrefix(req.FirmaDigital, "data:image") {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Firma digital requerida"})
-		return
+		return "Firma digital requerida"
 	}
 
 	if !req.AceptaTerminos {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Debe aceptar los términos y condiciones"})
-		return
+		return "Debe aceptar los términos y condiciones"
 	}
 
-	emailRegex := regexp.MustCompile(`^[^\s@]+@[^\s@]+\.[^\s@]+$`)
 	if !emailRegex.MatchString(req.Email) {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Formato de correo electrónico inválido"})
-		return
+		return "Formato de correo electrónico inválido"
 	}
 
 	if req.DescripcionBien == "" || req.DetalleReclamo == "" || req.PedidoConsumidor == "" {
-        c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Faltan detalles del reclamo o el pedido del consumidor"})
-        return
-    }
-
-    // CORRECCIÓN: Validar que el monto no exceda el límite de DECIMAL(10,2)
-    if req.MontoReclamado > 9999999.99 {
-        c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "El monto reclamado excede el límite permitido"})
-        return
-    }
+		return "Faltan detalles del reclamo o el pedido del consumidor"
+	}
 
-	if len(req.DetalleReclamo) > 3000 || len(req.PedidoConsumidor) > 2000 || len(req.NombreCompleto) > 200 || len(req.DescripcionBien) > 600 {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Uno de los campos excede el límite permitido de caracteres."})
-		return
+	// CORRECCIÓN: Validar que el monto no exceda el límite de DECIMAL(10,2)
+	if req.MontoReclamado > 9999999.99 {
+		return "El monto reclamado excede el límite permitido"
 	}
 
-	// Iniciar transacción
-	tx, err := pool.Begin(ctx)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Error al iniciar transacción"})
-		return
+	if excedeLimitesReclamo(req) {
+		return "Uno de los campos excede el límite permitido de caracteres."
 	}
-	defer tx.Rollback(ctx)
+
+	return ""
+}
+
+func excedeLimitesReclamo(req *CrearReclamoRequest) bool {
+	return len(req.DetalleReclamo) > 3000 || len(req.PedidoConsumidor) > 2000 || len(req.NombreCompleto) > 200 || len(req.DescripcionBien) > 600 ||
+		(req.Establecimiento != nil && len(*req.Establecimiento) > 255)
+}
+
+// errRegistroCodigo distingue el fallo al generar el código del fallo al insertar.
+var errRegistroCodigo = errors.New("error generando código")
+
+// registrarReclamo asigna el código legal e inserta el reclamo dentro de tx.
+// El llamador es responsable de confirmar la transacción.
+func registrarReclamo(ctx context.Context, tx pgx.Tx, req CrearReclamoRequest, ip, userAgent string) (ReclamoCreado, error) {
+	var reclamo ReclamoCreado
 
 	// Generar código único
 	codigoReclamo, err := generarCodigoReclamo(ctx)
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Error generando código"})
-		return
+		return reclamo, fmt.Errorf("%w: %v", errRegistroCodigo, err)
 	}
 
 	// Insertar reclamo
-	var reclamo ReclamoCreado
 	err = tx.QueryRow(ctx, `
 		INSERT INTO reclamos (
 			codigo_reclamo, tipo_solicitud, nombre_completo, tipo_documento, numero_documento,
 			telefono, email, domicilio, departamento, provincia, distrito,
 			tipo_bien, monto_reclamado, descripcion_bien, area_queja, descripcion_situacion,
 			fecha_incidente, detalle_reclamo, pedido_consumidor, firma_digital,
-			acepta_terminos, acepta_copia, ip_address, user_agent
-		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24)
+			acepta_terminos, acepta_copia, ip_address, user_agent, establecimiento
+		) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17, $18, $19, $20, $21, $22, $23, $24, $25)
 		RETURNING id, codigo_reclamo, fecha_registro, fecha_limite_respuesta
 	`,
 		codigoReclamo, req.TipoSolicitud, req.NombreCompleto, req.TipoDocumento, req.NumeroDocumento,
 		req.Telefono, req.Email, nullString(req.Domicilio), nullString(req.Departamento), nullString(req.Provincia), nullString(req.Distrito),
 		nullString(req.TipoBien), req.MontoReclamado, req.DescripcionBien, nullString(req.AreaQueja), nullString(req.DescripcionSituacion),
 		req.FechaIncidente, req.DetalleReclamo, req.PedidoConsumidor, req.FirmaDigital,
-		req.AceptaTerminos, req.AceptaCopia, c.ClientIP(), c.GetHeader("User-Agent"),
+		req.AceptaTerminos, req.AceptaCopia, ip, userAgent, nullString(req.Establecimiento),
 	).Scan(&reclamo.ID, &reclamo.CodigoReclamo, &reclamo.FechaRegistro, &reclamo.FechaLimiteRespuesta)
 
 	if err != nil {
 		log.Printf("Error insertando reclamo: %v", err)
-		c.JSON(http.StatusBadRequest, gin.H{
-			"success": false,
-			"message": "Error al registrar el reclamo",
-			"error":   errorDetail(err),
-		})
-		return
+		return reclamo, err
 	}
 
-	if err := tx.Commit(ctx); err != nil {
-        c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Error al confirmar transacción"})
-        return
-    }
-
+	return reclamo, nil
+}
 
+func responderErrorRegistro(c *gin.Context, err error) {
+	if errors.Is(err, errRegistroCodigo) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Error generando código"})
+		return
+	}
+	c.JSON(http.StatusBadRequest, gin.H{
+		"success": false,
+		"message": "Error al registrar el reclamo",
+		"error":   errorDetail(err),
+	})
+}
 
-// Registrar en historial
+// notificarReclamoRegistrado registra la creación en el historial, aplica las
+// reglas de triaje y envía los emails de soporte y cliente. Debe llamarse
+// después del commit.
+func notificarReclamoRegistrado(reclamo ReclamoCreado, req CrearReclamoRequest, ip, userAgent string) {
+	// Registrar en historial y luego el triaje, para que el historial quede en orden
+	emailsEnCurso.Add(1)
 	go func() {
+		defer emailsEnCurso.Done()
 		ctx2, cancel2 := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel2()
 		pool.Exec(ctx2, `
 			INSERT INTO historial_reclamos (reclamo_id, estado_nuevo, tipo_accion, comentario, usuario_accion, ip_address, user_agent)
 			VALUES ($1, 'PENDIENTE', 'CREACION', 'Reclamo registrado por el consumidor', 'CLIENTE', $2, $3)
-		`, reclamo.ID, c.ClientIP(), c.GetHeader("User-Agent"))
-	}()
+		`, reclamo.ID, ip, userAgent)
 
+		ctx3, cancel3 := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel3()
+		aplicarTriaje(ctx3, reclamo.ID)
+	}()
 
+	// Unificamos el envío de emails de forma segura después del commit
+	go func() {
+		// Determinamos el tipo de bien (Producto/Servicio)
+		finalTipoBien := "SERVICIO"
+		if req.TipoBien != nil && *req.TipoBien != "" {
+			finalTipoBien = *req.TipoBien
+		}
 
-  // Unificamos el envío de emails de forma segura después del commit
-    go func() {
-        // Determinamos el tipo de bien (Producto/Servicio)
-        finalTipoBien := "SERVICIO"
-        if req.TipoBien != nil && *req.TipoBien != "" {
-            finalTipoBien = *req.TipoBien
-        }
-        
-        if err := enviarEmails(reclamo, req, finalTipoBien); err != nil {
-            log.Printf("Error enviando emails: %v", err)
-        }
-    }()
+		if err := enviarEmails(reclamo, req, finalTipoBien); err != nil {
+			log.Printf("Error enviando emails: %v", err)
+		}
+	}()
+}
 
-	c.JSON(http.StatusCreated, gin.H{
-		"success": true,
-		"message": "Reclamo registrado exitosamente",
-		"data": gin.H{
-			"codigo_reclamo":         reclamo.CodigoReclamo,
-			"fecha_registro":         reclamo.FechaRegistro,
-			"fecha_limite_respuesta": reclamo.FechaLimiteRespuesta,
-			"plazo_dias":             15,
-		},
-	})
+func datosReclamoCreado(reclamo ReclamoCreado) gin.H {
+	return gin.H{
+		"codigo_reclamo":         reclamo.CodigoReclamo,
+		"fecha_registro":         reclamo.FechaRegistro,
+		"fecha_limite_respuesta": reclamo.FechaLimiteRespuesta,
+		"plazo_dias":             15,
+	}
 }
 
 // GET /api/reclamos/:codigo - Consultar reclamo por código
@@ -328,6 +475,10 @@ func obtenerReclamoHandler(c *gin.Context) {
 
 	codigo := c.Param("codigo")
 
+	if !autorizarConsultaPublica(c, ctx, codigo, alcanceReclamo) {
+		return
+	}
+
 	rows, err := pool.Query(ctx, `
 		SELECT 
 			r.id, r.codigo_reclamo, r.tipo_solicitud, r.estado,
@@ -338,10 +489,10 @@ func obtenerReclamoHandler(c *gin.Context) {
 			r.area_queja, r.descripcion_situacion,
 			r.fecha_incidente, r.detalle_reclamo, r.pedido_consumidor,
 			r.acepta_terminos, r.acepta_copia,
-			r.fecha_registro, r.fecha_limite_respuesta, r.fecha_respuesta,
-			res.respuesta_empresa, res.fecha_respuesta as res_fecha, res.respondido_por
+			r.fecha_registro, `+sqlFechaLimite+`, r.fecha_respuesta,
+			res.respuesta_empresa, res.fecha_respuesta as res_fecha, res.respondido_por, res.version
 		FROM reclamos r
-		LEFT JOIN respuestas res ON r.id = res.reclamo_id
+		LEFT JOIN v_respuestas_vigentes res ON r.id = res.reclamo_id
 		WHERE r.codigo_reclamo = $1
 	`, codigo)
 	if err != nil {
@@ -368,6 +519,7 @@ func obtenerReclamoHandler(c *gin.Context) {
 		fechaRegistro, fechaLimiteRespuesta                             time.Time
 		fechaRespuesta                                                  sql.NullTime
 		respuestaEmpresa, resFecha, respondidoPor                       sql.NullString
+		versionRespuesta                                                *int
 	)
 
 	err = rows.Scan(
@@ -380,7 +532,7 @@ func obtenerReclamoHandler(c *gin.Context) {
 		&fechaIncidente, &detalleReclamo, &pedidoConsumidor,
 		&aceptaTerminos, &aceptaCopia,
 		&fechaRegistro, &fechaLimiteRespuesta, &fechaRespuesta,
-		&respuestaEmpresa, &resFecha, &respondidoPor,
+		&respuestaEmpresa, &resFecha, &respondidoPor, &versionRespuesta,
 	)
 	if err != nil {
 		log.Printf("Error escaneando reclamo: %v", err)
@@ -422,6 +574,7 @@ func obtenerReclamoHandler(c *gin.Context) {
 			"fecha_respuesta":        nullTimeToInterface(fechaRespuesta),
 			"respuesta_empresa":      nullToInterface(respuestaEmpresa),
 			"respondido_por":         nullToInterface(respondidoPor),
+			"version_respuesta":      versionRespuesta,
 		},
 	})
 }
@@ -433,6 +586,10 @@ func obtenerFirmaHandler(c *gin.Context) {
 
 	codigo := c.Param("codigo")
 
+	if !autorizarConsultaPublica(c, ctx, codigo, alcanceFirma) {
+		return
+	}
+
 	var firma string
 	err := pool.QueryRow(ctx, "SELECT firma_digital FROM reclamos WHERE codigo_reclamo = $1", codigo).Scan(&firma)
 	if err != nil {
@@ -440,6 +597,11 @@ func obtenerFirmaHandler(c *gin.Context) {
 		return
 	}
 
+	escribirFirma(c, firma)
+}
+
+// escribirFirma decodifica el data URL guardado y lo devuelve como PNG.
+func escribirFirma(c *gin.Context, firma string) {
 	parts := strings.SplitN(firma, ",", 2)
 	if len(parts) != 2 {
 		c.String(http.StatusInternalServerError, "Formato de firma inválido")
@@ -576,6 +738,9 @@ func enviarEmails(reclamo ReclamoCreado, req CrearReclamoRequest, tipoBien strin
 			m2.SetHeader("From", config.SMTPFrom)
 			m2.SetHeader("To", req.Email)
 			m2.SetHeader("Subject", fmt.Sprintf("Confirmación de %s - %s", req.TipoSolicitud, reclamo.CodigoReclamo))
+			if replyTo := direccionRespuesta(reclamo.CodigoReclamo); replyTo != "" {
+				m2.SetHeader("Reply-To", replyTo)
+			}
 			m2.SetBody("text/html", generarEmailCliente(reclamo.CodigoReclamo, req.TipoSolicitud, fechaLimite, fechaRegistro, req.NombreCompleto, tipoBien, req.DescripcionBien))
 			
 			if err := dialer.DialAndSend(m2); err != nil {
@@ -727,7 +892,7 @@ func generarEmailSoporte(codigo, tipo, fechaLimite, nombre, tipoDoc, numDoc, ema
 <tr>
 <td style="padding: 15px; text-align: center;">
 <span style="color: #166534; font-weight: bold;">🖊️ Firma Digital:</span>
-<a href="%s/api/reclamos/%s/firma" target="_blank" style="display: inline-block; margin-left: 8px; padding: 8px 16px; background-color: #1e40af; color: #ffffff; text-decoration: none; border-radius: 4px; font-weight: bold; font-size: 13px;">Ver Firma</a>
+<a href="%s" target="_blank" style="display: inline-block; margin-left: 8px; padding: 8px 16px; background-color: #1e40af; color: #ffffff; text-decoration: none; border-radius: 4px; font-weight: bold; font-size: 13px;">Ver Firma</a>
 </td>
 </tr>
 </table>
@@ -752,7 +917,7 @@ func generarEmailSoporte(codigo, tipo, fechaLimite, nombre, tipoDoc, numDoc, ema
 		nombre, tipoDoc, numDoc, email, email, telefono, telefono, ubicacion,
 		tipoBienBg, tipoBienColor, tipoBien, descripcion, montoHTML,
 		tipo, pedidoHTML,
-		config.BackendURL, codigo)
+		enlaceFirmaFirmado(codigo))
 }
 
 func generarEmailCliente(codigo, tipo, fechaLimite, fechaRegistro, nombre, tipoBien, descripcion string) string {
@@ -850,6 +1015,14 @@ func generarEmailCliente(codigo, tipo, fechaLimite, fechaRegistro, nombre, tipoB
 </td>
 </tr>
 
+<!-- Acceso al Seguimiento -->
+<tr>
+<td style="padding: 0 20px 15px 20px; text-align: center;">
+<a href="%s" target="_blank" style="display: inline-block; padding: 12px 24px; background-color: #059669; color: #ffffff; text-decoration: none; border-radius: 6px; font-weight: bold; font-size: 14px;">Ver el estado de mi solicitud</a>
+<p style="margin: 8px 0 0 0; color: #6b7280; font-size: 11px;">Enlace personal: no lo comparta con terceros</p>
+</td>
+</tr>
+
 <!-- Información Importante -->
 <tr>
 <td style="padding: 0 20px 20px 20px;">
@@ -886,7 +1059,8 @@ func generarEmailCliente(codigo, tipo, fechaLimite, fechaRegistro, nombre, tipoB
 		tipo, codigo,
 		nombre, tipoLower,
 		fechaLimite,
-		tipo, codigo, fechaRegistro, tipo, tipoBien, descripcion)
+		tipo, codigo, fechaRegistro, tipo, tipoBien, descripcion,
+		enlaceSeguimientoFirmado(codigo))
 }
 
 
@@ -1012,6 +1186,87 @@ Ver Reclamo Completo
 	return nil
 }
 
+// generarEmailAviso arma el email corto (encabezado, texto y botón) de las
+// notificaciones al consumidor. cuerpoHTML debe venir ya escapado.
+func generarEmailAviso(titulo, color, cuerpoHTML, textoBoton, enlace, pie string) string {
+	boton := ""
+	if enlace != "" {
+		boton = fmt.Sprintf(`<tr>
+<td style="padding: 0 20px 20px 20px; text-align: center;">
+<a href="%s" target="_blank" style="display: inline-block; padding: 12px 24px; background-color: %s; color: #ffffff; text-decoration: none; border-radius: 6px; font-weight: bold; font-size: 14px;">
+%s
+</a>
+</td>
+</tr>`, html.EscapeString(enlace), color, textoBoton)
+	}
+
+	return fmt.Sprintf(`<!DOCTYPE html>
+<html><head>
+<meta charset="UTF-8">
+<meta name="viewport" content="width=device-width, initial-scale=1.0">
+</head>
+<body style="margin: 0; padding: 0; font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, Arial, sans-serif; background-color: #f3f4f6;">
+
+<table role="presentation" cellspacing="0" cellpadding="0" border="0" width="100%%" style="background-color: #f3f4f6;">
+<tr><td align="center" style="padding: 20px 10px;">
+
+<table role="presentation" cellspacing="0" cellpadding="0" border="0" width="100%%" style="max-width: 600px; background-color: #ffffff; border-radius: 8px; overflow: hidden; box-shadow: 0 2px 8px rgba(0,0,0,0.1);">
+
+<tr>
+<td style="background: %s; padding: 25px 20px; text-align: center;">
+<h1 style="margin: 0; color: #ffffff; font-size: 22px; font-weight: bold;">%s</h1>
+</td>
+</tr>
+
+<tr>
+<td style="padding: 20px; color: #374151; word-break: break-word;">
+%s
+</td>
+</tr>
+
+%s
+
+<tr>
+<td style="background-color: #1f2937; padding: 20px; text-align: center;">
+<p style="margin: 0; color: #9ca3af; font-size: 11px;">%s</p>
+<p style="margin: 6px 0 0 0; color: #6b7280; font-size: 10px;">CODEPLEX SAC | RUC: 20539782232</p>
+</td>
+</tr>
+
+</table>
+</td></tr>
+</table>
+</body></html>`,
+		color, titulo,
+		cuerpoHTML,
+		boton,
+		pie)
+}
+
+// enviarEmailHTML envía un email HTML simple desde la cuenta del sistema.
+func enviarEmailHTML(to, subject, html string) error {
+	m := gomail.NewMessage()
+	m.SetHeader("From", config.SMTPFrom)
+	m.SetHeader("To", to)
+	m.SetHeader("Subject", subject)
+	m.SetBody("text/html", html)
+	return dialer.DialAndSend(m)
+}
+
+// enviarEmailHTMLReclamo envía un email al consumidor sobre un reclamo. Si
+// EMAIL_RESPUESTAS está configurado, su respuesta vuelve al hilo del reclamo.
+func enviarEmailHTMLReclamo(to, subject, html, codigo string) error {
+	m := gomail.NewMessage()
+	m.SetHeader("From", config.SMTPFrom)
+	m.SetHeader("To", to)
+	m.SetHeader("Subject", subject)
+	if replyTo := direccionRespuesta(codigo); replyTo != "" {
+		m.SetHeader("Reply-To", replyTo)
+	}
+	m.SetBody("text/html", html)
+	return dialer.DialAndSend(m)
+}
+
 
 
 
@@ -1051,6 +1306,21 @@ func nullTimeToInterface(nt sql.NullTime) interface{} {
 	return nil
 }
 
+// generarTokenSeguro devuelve un token aleatorio apto para URLs.
+func generarTokenSeguro() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken calcula el SHA256 que se guarda en BD en lugar del token plano.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
 func errorDetail(err error) string {
 	if config.Env == "development" {
 		return err.Error()
@@ -1087,16 +1357,18 @@ func corsMiddleware() gin.HandlerFunc {
 // HANDLERS DE SEGUIMIENTO
 // =============================================================================
 
+// historialInterno son los tipos de historial que solo ve el panel (flujo de
+// trabajo, clasificación); el seguimiento público los omite.
+var historialInterno = []string{"ASIGNACION", "ETIQUETADO", "CATEGORIZACION", "TRIAJE", "RESPUESTA_PROPUESTA", "RESPUESTA_RECHAZADA"}
+
 // GET /api/seguimiento/:codigo - Consultar reclamo con historial
 func seguimientoHandler(c *gin.Context) {
 	ctx, cancel := context.WithTimeout(c.Request.Context(), 15*time.Second)
 	defer cancel()
 
 	codigo := c.Param("codigo")
-	documento := c.Query("documento")
 
-	if documento == "" {
-		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Número de documento requerido"})
+	if !autorizarConsultaPublica(c, ctx, codigo, alcanceReclamo) {
 		return
 	}
 
@@ -1115,22 +1387,27 @@ func seguimientoHandler(c *gin.Context) {
 		PedidoConsumidor     string    `json:"pedido_consumidor"`
 		FechaRegistro        time.Time `json:"fecha_registro"`
 		FechaLimiteRespuesta time.Time `json:"fecha_limite_respuesta"`
+		FechaLimiteOriginal  time.Time `json:"fecha_limite_original"`
+		PlazoAmpliado        bool      `json:"plazo_ampliado"`
+		EsperandoConsumidor  bool      `json:"esperando_consumidor"`
 		DiasRestantes        int       `json:"dias_restantes"`
 	}
 
 	err := pool.QueryRow(ctx, `
-		SELECT id, codigo_reclamo, tipo_solicitud, estado, nombre_completo, 
-			   numero_documento, email, telefono, descripcion_bien, 
-			   detalle_reclamo, pedido_consumidor, fecha_registro, 
-			   fecha_limite_respuesta,
-			   (fecha_limite_respuesta - CURRENT_DATE)::int AS dias_restantes
-		FROM reclamos 
-		WHERE codigo_reclamo = $1 AND numero_documento = $2
-	`, codigo, documento).Scan(
+		SELECT r.id, r.codigo_reclamo, r.tipo_solicitud, r.estado, r.nombre_completo, 
+			   r.numero_documento, r.email, r.telefono, r.descripcion_bien, 
+			   r.detalle_reclamo, r.pedido_consumidor, r.fecha_registro, 
+			   `+sqlFechaLimite+`, r.fecha_limite_respuesta, r.fecha_limite_ampliada IS NOT NULL,
+			   r.pausado_desde IS NOT NULL,
+			   (`+sqlFechaLimite+` - CURRENT_DATE)::int AS dias_restantes
+		FROM reclamos r
+		WHERE r.codigo_reclamo = $1
+	`, codigo).Scan(
 		&reclamo.ID, &reclamo.CodigoReclamo, &reclamo.TipoSolicitud, &reclamo.Estado,
 		&reclamo.NombreCompleto, &reclamo.NumeroDocumento, &reclamo.Email, &reclamo.Telefono,
 		&reclamo.DescripcionBien, &reclamo.DetalleReclamo, &reclamo.PedidoConsumidor,
-		&reclamo.FechaRegistro, &reclamo.FechaLimiteRespuesta, &reclamo.DiasRestantes,
+		&reclamo.FechaRegistro, &reclamo.FechaLimiteRespuesta, &reclamo.FechaLimiteOriginal,
+		&reclamo.PlazoAmpliado, &reclamo.EsperandoConsumidor, &reclamo.DiasRestantes,
 	)
 
 	if err != nil {
@@ -1145,20 +1422,22 @@ func seguimientoHandler(c *gin.Context) {
 		CompensacionOfrecida sql.NullString `json:"compensacion_ofrecida"`
 		RespondidoPor        string         `json:"respondido_por"`
 		FechaRespuesta       time.Time      `json:"fecha_respuesta"`
+		Version              int            `json:"version"`
 	}
 	var tieneRespuesta bool
 
 	err = pool.QueryRow(ctx, `
-		SELECT respuesta_empresa, accion_tomada, compensacion_ofrecida, respondido_por, fecha_respuesta 
-		FROM respuestas WHERE reclamo_id = $1 LIMIT 1
-	`, reclamo.ID).Scan(&respuesta.RespuestaEmpresa, &respuesta.AccionTomada, &respuesta.CompensacionOfrecida, &respuesta.RespondidoPor, &respuesta.FechaRespuesta)
+		SELECT respuesta_empresa, accion_tomada, compensacion_ofrecida, respondido_por, fecha_respuesta, version
+		FROM v_respuestas_vigentes WHERE reclamo_id = $1
+	`, reclamo.ID).Scan(&respuesta.RespuestaEmpresa, &respuesta.AccionTomada, &respuesta.CompensacionOfrecida, &respuesta.RespondidoPor, &respuesta.FechaRespuesta, &respuesta.Version)
 	tieneRespuesta = err == nil
 
 	// Buscar historial
 	historialRows, _ := pool.Query(ctx, `
 		SELECT id, estado_anterior, estado_nuevo, comentario, usuario_accion, tipo_accion, fecha_accion
-		FROM historial_reclamos WHERE reclamo_id = $1 ORDER BY fecha_accion DESC
-	`, reclamo.ID)
+		FROM historial_reclamos WHERE reclamo_id = $1 AND tipo_accion <> ALL($2::text[])
+		ORDER BY fecha_accion DESC
+	`, reclamo.ID, historialInterno)
 	defer historialRows.Close()
 
 	var historial []gin.H
@@ -1187,7 +1466,7 @@ func seguimientoHandler(c *gin.Context) {
 
 	// Buscar mensajes
 	mensajesRows, _ := pool.Query(ctx, `
-		SELECT id, tipo_mensaje, mensaje, fecha_mensaje 
+		SELECT id, tipo_mensaje, mensaje, nombre_archivo, fecha_mensaje, COALESCE(leido, false), fecha_lectura
 		FROM mensajes_seguimiento WHERE reclamo_id = $1 ORDER BY fecha_mensaje ASC
 	`, reclamo.ID)
 	defer mensajesRows.Close()
@@ -1195,21 +1474,32 @@ func seguimientoHandler(c *gin.Context) {
 	var mensajes []gin.H
 	for mensajesRows.Next() {
 		var m struct {
-			ID           string    `json:"id"`
-			TipoMensaje  string    `json:"tipo_mensaje"`
-			Mensaje      string    `json:"mensaje"`
-			FechaMensaje time.Time `json:"fecha_mensaje"`
+			ID            string         `json:"id"`
+			TipoMensaje   string         `json:"tipo_mensaje"`
+			Mensaje       string         `json:"mensaje"`
+			NombreArchivo sql.NullString `json:"nombre_archivo"`
+			FechaMensaje  time.Time      `json:"fecha_mensaje"`
+			Leido         bool           `json:"leido"`
+			FechaLectura  sql.NullTime   `json:"fecha_lectura"`
 		}
-		if err := mensajesRows.Scan(&m.ID, &m.TipoMensaje, &m.Mensaje, &m.FechaMensaje); err == nil {
+		if err := mensajesRows.Scan(&m.ID, &m.TipoMensaje, &m.Mensaje, &m.NombreArchivo, &m.FechaMensaje, &m.Leido, &m.FechaLectura); err == nil {
 			mensajes = append(mensajes, gin.H{
-				"id":            m.ID,
-				"tipo_mensaje":  m.TipoMensaje,
-				"mensaje":       m.Mensaje,
-				"fecha_mensaje": m.FechaMensaje,
+				"id":             m.ID,
+				"tipo_mensaje":   m.TipoMensaje,
+				"mensaje":        m.Mensaje,
+				"nombre_archivo": nullToInterface(m.NombreArchivo),
+				"fecha_mensaje":  m.FechaMensaje,
+				"leido":          m.Leido,
+				"fecha_lectura":  nullTimeToInterface(m.FechaLectura),
 			})
 		}
 	}
 
+	// El consumidor abrió el hilo: las respuestas de la empresa quedan leídas
+	if _, err := marcarMensajesLeidos(ctx, reclamo.ID, "EMPRESA", ""); err != nil {
+		log.Printf("⚠️ Error marcando mensajes leídos de %s: %v", reclamo.CodigoReclamo, err)
+	}
+
 	// Registrar historial de consulta si no existe creación
 	if len(historial) == 0 {
 		pool.Exec(ctx, `
@@ -1257,6 +1547,8 @@ func enviarMensajeSeguimientoHandler(c *gin.Context) {
 	var req struct {
 		Mensaje         string `json:"mensaje"`
 		NumeroDocumento string `json:"numero_documento"`
+		ArchivoAdjunto  string `json:"archivo_adjunto"`
+		NombreArchivo   string `json:"nombre_archivo"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -1269,34 +1561,91 @@ func enviarMensajeSeguimientoHandler(c *gin.Context) {
 		return
 	}
 
-	// Verificar reclamo
+	// Adjunto opcional como data URL (base64), máx ~5MB
+	if len(req.ArchivoAdjunto) > 7*1024*1024 || len(req.NombreArchivo) > 255 {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "El adjunto excede el tamaño permitido (5MB)"})
+		return
+	}
+	if req.ArchivoAdjunto != "" && req.NombreArchivo == "" {
+		req.NombreArchivo = "adjunto"
+	}
+
+	// Verificar reclamo: con la sesión del portal no hace falta el documento
 	var reclamoID string
-	err := pool.QueryRow(ctx, `
-		SELECT id FROM reclamos WHERE codigo_reclamo = $1 AND numero_documento = $2
-	`, codigo, req.NumeroDocumento).Scan(&reclamoID)
+	var err error
+	if sesion, ok := sesionConsumidor(c); ok {
+		// El middleware solo ve el documento del cuerpo; con sesión va el de la sesión
+		if politicaAntispamMensajes().rechazarPorDocumento(c, ctx, sesion.NumeroDocumento) {
+			return
+		}
+		err = pool.QueryRow(ctx, `
+			SELECT id FROM reclamos WHERE codigo_reclamo = $1 AND numero_documento = $2 AND LOWER(email) = $3
+		`, codigo, sesion.NumeroDocumento, sesion.Email).Scan(&reclamoID)
+	} else {
+		err = pool.QueryRow(ctx, `
+			SELECT id FROM reclamos WHERE codigo_reclamo = $1 AND numero_documento = $2
+		`, codigo, req.NumeroDocumento).Scan(&reclamoID)
+	}
 
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Reclamo no encontrado"})
 		return
 	}
 
-	// Insertar mensaje
-	_, err = pool.Exec(ctx, `
-		INSERT INTO mensajes_seguimiento (reclamo_id, tipo_mensaje, mensaje)
-		VALUES ($1, 'CLIENTE', $2)
-	`, reclamoID, req.Mensaje)
-
-	if err != nil {
+	if _, err := registrarMensajeCliente(ctx, reclamoID, req.Mensaje, req.ArchivoAdjunto, req.NombreArchivo, c.ClientIP(), ""); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al guardar mensaje"})
 		return
 	}
 
-// Registrar en historial
+	c.JSON(http.StatusCreated, gin.H{"success": true, "message": "Mensaje enviado correctamente"})
+}
+
+
+
+
+
+// emailsEnCurso permite esperar los avisos y el triaje en curso antes de
+// salir, tanto en el modo importación como al cerrar el servidor.
+var emailsEnCurso sync.WaitGroup
+
+// registrarMensajeCliente guarda un mensaje CLIENTE (del formulario o de una
+// respuesta por email) y dispara lo mismo en ambos casos: evento en tiempo
+// real, historial y email a soporte. messageID deduplica correos reenviados.
+func registrarMensajeCliente(ctx context.Context, reclamoID, mensaje, archivo, nombreArchivo, ip, messageID string) (string, error) {
+	var mensajeID string
+	var fechaMensaje time.Time
+	err := pool.QueryRow(ctx, `
+		INSERT INTO mensajes_seguimiento (reclamo_id, tipo_mensaje, mensaje, archivo_adjunto, nombre_archivo, message_id_email)
+		VALUES ($1, 'CLIENTE', $2, NULLIF($3, ''), NULLIF($4, ''), NULLIF($5, ''))
+		ON CONFLICT (message_id_email) WHERE message_id_email IS NOT NULL DO NOTHING
+		RETURNING id, fecha_mensaje
+	`, reclamoID, mensaje, archivo, nombreArchivo, messageID).Scan(&mensajeID, &fechaMensaje)
+	if errors.Is(err, pgx.ErrNoRows) {
+		// Otro proceso ya registró este correo (reintento concurrente del proveedor)
+		return "", errCorreoDuplicado
+	}
+	if err != nil {
+		return "", err
+	}
+
+	publicarEvento(reclamoID, eventoMensaje, datosEventoMensaje(mensajeID, "CLIENTE", mensaje, nombreArchivo, fechaMensaje))
+
+	// La respuesta del consumidor reanuda el plazo si estaba en pausa
+	if _, err := reanudarPlazo(ctx, reclamoID, "respuesta del consumidor", "CLIENTE"); err != nil {
+		log.Printf("⚠️ Error reanudando plazo de %s: %v", reclamoID, err)
+	}
+
+	comentario := "Cliente envió mensaje adicional"
+	if messageID != "" {
+		comentario = "Cliente respondió por email"
+	}
+
+	// Registrar en historial
 	pool.Exec(ctx, `
 		INSERT INTO historial_reclamos (reclamo_id, estado_nuevo, tipo_accion, comentario, usuario_accion, ip_address)
 		SELECT estado, estado, 'MENSAJE_CLIENTE', $2, 'CLIENTE', $3
 		FROM reclamos WHERE id = $1
-	`, reclamoID, "Cliente envió mensaje adicional", c.ClientIP())
+	`, reclamoID, comentario, nullString(&ip))
 
 	// Obtener datos del reclamo para el email (SÍNCRONO)
 	var nombreCliente, codigoReclamoFull, tipoSolicitud, numeroDoc string
@@ -1309,20 +1658,19 @@ func enviarMensajeSeguimientoHandler(c *gin.Context) {
 		log.Printf("⚠️ Error obteniendo datos del reclamo: %v", err)
 	} else {
 		// Enviar email al administrador (asíncrono SOLO después de obtener los datos)
+		emailsEnCurso.Add(1)
 		go func(codigo, nombre, doc, tipo, mensaje string) {
+			defer emailsEnCurso.Done()
 			if err := enviarEmailMensajeCliente(codigo, nombre, doc, tipo, mensaje); err != nil {
 				log.Printf("❌ Error enviando email de mensaje: %v", err)
 			} else {
 				log.Printf("✅ Email de mensaje enviado correctamente para %s", codigo)
 			}
-		}(codigoReclamoFull, nombreCliente, numeroDoc, tipoSolicitud, req.Mensaje)
+		}(codigoReclamoFull, nombreCliente, numeroDoc, tipoSolicitud, mensaje)
 	}
-	c.JSON(http.StatusCreated, gin.H{"success": true, "message": "Mensaje enviado correctamente"})
-}
-
-
-
 
+	return mensajeID, nil
+}
 
 // =============================================================================
 // HANDLERS DE MENSAJES ADMIN
@@ -1336,7 +1684,7 @@ func obtenerMensajesAdminHandler(c *gin.Context) {
 	id := c.Param("id")
 
 	rows, err := pool.Query(ctx, `
-		SELECT id, tipo_mensaje, mensaje, fecha_mensaje, leido
+		SELECT id, tipo_mensaje, mensaje, fecha_mensaje, COALESCE(leido, false), fecha_lectura
 		FROM mensajes_seguimiento 
 		WHERE reclamo_id = $1 
 		ORDER BY fecha_mensaje ASC
@@ -1355,22 +1703,30 @@ func obtenerMensajesAdminHandler(c *gin.Context) {
 			Mensaje      string
 			FechaMensaje time.Time
 			Leido        bool
+			FechaLectura sql.NullTime
 		}
-		if err := rows.Scan(&m.ID, &m.TipoMensaje, &m.Mensaje, &m.FechaMensaje, &m.Leido); err == nil {
+		if err := rows.Scan(&m.ID, &m.TipoMensaje, &m.Mensaje, &m.FechaMensaje, &m.Leido, &m.FechaLectura); err == nil {
 			mensajes = append(mensajes, gin.H{
 				"id":            m.ID,
 				"tipo_mensaje":  m.TipoMensaje,
 				"mensaje":       m.Mensaje,
 				"fecha_mensaje": m.FechaMensaje,
 				"leido":         m.Leido,
+				"fecha_lectura": nullTimeToInterface(m.FechaLectura),
 			})
 		}
 	}
+	rows.Close()
 
 	if mensajes == nil {
 		mensajes = []gin.H{}
 	}
 
+	// Se devuelve el estado previo (para resaltar los nuevos) y luego se marcan
+	if _, err := marcarMensajesLeidos(ctx, id, "CLIENTE", ""); err != nil {
+		log.Printf("⚠️ Error marcando mensajes leídos del reclamo %s: %v", id, err)
+	}
+
 	c.JSON(http.StatusOK, gin.H{"success": true, "data": mensajes})
 }
 
@@ -1384,6 +1740,8 @@ func enviarMensajeAdminHandler(c *gin.Context) {
 
 	var req struct {
 		Mensaje string `json:"mensaje" binding:"required,min=1,max=1000"`
+		// Pide información al consumidor: el plazo queda en pausa hasta que responda
+		EsperarRespuesta bool `json:"esperar_respuesta"`
 	}
 
 	if err := c.ShouldBindJSON(&req); err != nil {
@@ -1392,16 +1750,22 @@ func enviarMensajeAdminHandler(c *gin.Context) {
 	}
 
 	// Insertar mensaje
-	_, err := pool.Exec(ctx, `
+	var mensajeID string
+	var fechaMensaje time.Time
+	err := pool.QueryRow(ctx, `
 		INSERT INTO mensajes_seguimiento (reclamo_id, tipo_mensaje, mensaje)
 		VALUES ($1, 'EMPRESA', $2)
-	`, id, req.Mensaje)
+		RETURNING id, fecha_mensaje
+	`, id, req.Mensaje).Scan(&mensajeID, &fechaMensaje)
 
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al enviar mensaje"})
 		return
 	}
 
+	publicarEvento(id, eventoMensaje, datosEventoMensaje(mensajeID, "EMPRESA", req.Mensaje, "", fechaMensaje))
+	programarAvisoMensajeEmpresa(id)
+
 	// Registrar en historial
 	pool.Exec(ctx, `
 		INSERT INTO historial_reclamos (reclamo_id, estado_nuevo, tipo_accion, comentario, usuario_accion, ip_address)
@@ -1409,7 +1773,15 @@ func enviarMensajeAdminHandler(c *gin.Context) {
 		FROM reclamos WHERE id = $1
 	`, id, email, c.ClientIP())
 
-	c.JSON(http.StatusCreated, gin.H{"success": true, "message": "Mensaje enviado"})
+	pausado := false
+	if req.EsperarRespuesta {
+		pausado, err = pausarPlazo(ctx, id, mensajeID, "Información solicitada al consumidor", fmt.Sprintf("%v", email))
+		if err != nil {
+			log.Printf("⚠️ Error pausando plazo de %s: %v", id, err)
+		}
+	}
+
+	c.JSON(http.StatusCreated, gin.H{"success": true, "message": "Mensaje enviado", "data": gin.H{"id": mensajeID, "esperando_consumidor": pausado}})
 }
 
 
@@ -1440,6 +1812,8 @@ func main() {
 	}
 	log.Println("✅ Conectado a la base de datos")
 
+	busquedaTextoCompleto = detectarBusquedaTextoCompleto(ctx)
+
 	// Configurar SMTP
 	dialer = gomail.NewDialer(config.SMTPHost, config.SMTPPort, config.SMTPUser, config.SMTPPass)
 	dialer.TLSConfig = &tls.Config{InsecureSkipVerify: true, ServerName: config.SMTPHost}
@@ -1447,6 +1821,27 @@ func main() {
 		dialer.SSL = true
 	}
 
+	// Modo importación de respuestas: libro-reclamaciones importar-correo <mbox|Maildir>
+	if len(os.Args) == 3 && os.Args[1] == "importar-correo" {
+		if err := importarCorreos(context.Background(), os.Args[2]); err != nil {
+			log.Fatalf("❌ Error importando correos: %v", err)
+		}
+		emailsEnCurso.Wait()
+		return
+	}
+
+	captcha = nuevoVerificadorCaptcha(config)
+	limitador = nuevoAlmacenRateLimit(config.RateLimitBackend)
+	rl := politicasRateLimitPorDefecto()
+	politicaConsultasFallidas = rl.ConsultasFallidas
+	politicaEnlacesBorrador = rl.EnlacesBorrador
+
+	// Tareas en segundo plano (se detienen al cerrar el servidor)
+	bgCtx, bgCancel := context.WithCancel(context.Background())
+	defer bgCancel()
+	bus = nuevoBusEventos(bgCtx, config.RealtimeBackend)
+	go iniciarTareasPeriodicas(bgCtx, purgarBorradoresVencidos, purgarAccesosVencidos, reintentarAvisosPendientes, verificarSLA, resumenDiarioSLA, procesarReportesPendientes, enviarDigestsProgramados)
+
 // Router
 	router := gin.New()
 	
@@ -1462,14 +1857,37 @@ func main() {
 	api := router.Group("/api")
 	{
 		api.GET("/health", healthHandler)
-		api.POST("/reclamos", crearReclamoHandler)
+		api.GET("/antispam/formulario", tokenFormularioHandler)
+		api.POST("/reclamos", rateLimitMiddleware(rl.Reclamos), antispamMiddleware(politicaAntispamReclamos()), crearReclamoHandler)
 		api.GET("/reclamos/:codigo", obtenerReclamoHandler)
 		api.GET("/reclamos/:codigo/firma", obtenerFirmaHandler)
 		api.GET("/dashboard", dashboardHandler)
 		
 		// Seguimiento
-		api.GET("/seguimiento/:codigo", seguimientoHandler)
-		api.POST("/seguimiento/:codigo/mensaje", enviarMensajeSeguimientoHandler)
+		api.GET("/seguimiento/:codigo", rateLimitMiddleware(rl.Seguimiento), rateLimitMiddleware(rl.SeguimientoCodigo), seguimientoHandler)
+		api.POST("/seguimiento/:codigo/mensaje", rateLimitMiddleware(rl.Mensajes), antispamMiddleware(politicaAntispamMensajes()), enviarMensajeSeguimientoHandler)
+		api.GET("/seguimiento/:codigo/mensajes/:id/adjunto", rateLimitMiddleware(rl.Seguimiento), descargarAdjuntoSeguimientoHandler)
+		api.GET("/seguimiento/:codigo/pdf", rateLimitMiddleware(rl.Seguimiento), descargarPDFSeguimientoHandler)
+		api.PUT("/seguimiento/:codigo/mensajes/:id/leido", rateLimitMiddleware(rl.Seguimiento), marcarMensajeLeidoSeguimientoHandler)
+		api.POST("/seguimiento/:codigo/eventos/token", rateLimitMiddleware(rl.Seguimiento), tokenEventosSeguimientoHandler)
+		api.GET("/seguimiento/:codigo/eventos", rateLimitMiddleware(rl.Seguimiento), eventosSeguimientoHandler)
+		api.POST("/seguimiento/:codigo/escribiendo", rateLimitMiddleware(rl.Seguimiento), escribiendoSeguimientoHandler)
+
+		// Portal del consumidor (enlace mágico)
+		api.POST("/seguimiento/acceso", rateLimitMiddleware(rl.AccesoPortal), solicitarAccesoPortalHandler)
+		api.POST("/seguimiento/acceso/canjear", rateLimitMiddleware(rl.AccesoPortal), canjearAccesoPortalHandler)
+		api.GET("/seguimiento/mis-reclamos", sesionConsumidorMiddleware(), misReclamosHandler)
+
+		// Respuestas de consumidores por email (webhook del proveedor)
+		api.POST("/correo/entrante", correoEntranteHandler)
+
+		// Borradores
+		// Guardar envía un enlace por email: mismo filtro anti-spam que los reclamos
+		api.POST("/borradores", rateLimitMiddleware(rl.Borradores), antispamMiddleware(politicaAntispamBorradores()), crearBorradorHandler)
+		api.GET("/borradores/:token", obtenerBorradorHandler)
+		api.PUT("/borradores/:token", rateLimitMiddleware(rl.Borradores), antispamMiddleware(politicaAntispamBorradores()), actualizarBorradorHandler)
+		api.DELETE("/borradores/:token", eliminarBorradorHandler)
+		api.POST("/borradores/:token/enviar", rateLimitMiddleware(rl.Reclamos), antispamMiddleware(politicaAntispamReclamos()), enviarBorradorHandler)
 
 
 
@@ -1479,25 +1897,105 @@ func main() {
 		// Rutas admin
 adminAuth := api.Group("/admin/auth")
 {
-    adminAuth.POST("/login", loginAdminHandler)
+    adminAuth.POST("/login", rateLimitMiddleware(rl.Login), loginAdminHandler)
 }
 
+// El stream SSE acepta el token corto por query (EventSource no envía cabeceras)
+api.GET("/admin/reclamos/:id/eventos", authEventosAdminMiddleware(), eventosAdminHandler)
+
 admin := api.Group("/admin")
     admin.Use(authMiddleware())
     {
         // ... (tus rutas existentes de reclamos) ...
         admin.GET("/reclamos", listarReclamosAdminHandler)
+        admin.POST("/reclamos/masivo", operacionMasivaHandler)
+        admin.GET("/reclamos/export", exportarReclamosHandler)
         
         // --- AGREGAR ESTA LÍNEA ---
         admin.GET("/reclamos/:id", obtenerReclamoAdminHandler) 
+        admin.GET("/reclamos/:id/firma", obtenerFirmaAdminHandler)
         // ---------------------------
 
         admin.PUT("/reclamos/:id/estado", cambiarEstadoReclamoHandler)
         admin.POST("/reclamos/:id/respuesta", responderReclamoHandler)
+        admin.POST("/reclamos/:id/respuesta/enmiendas", enmendarRespuestaHandler)
+        admin.GET("/reclamos/:id/respuesta/versiones", listarVersionesRespuestaHandler)
         admin.GET("/dashboard/stats", obtenerEstadisticasHandler)
+        admin.GET("/analytics", analyticsHandler)
 
 		admin.GET("/reclamos/:id/mensajes", obtenerMensajesAdminHandler)
-        admin.POST("/reclamos/:id/mensaje", enviarMensajeAdminHandler)
+        admin.POST("/reclamos/:id/mensaje", rateLimitMiddleware(rl.MensajesAdmin), enviarMensajeAdminHandler)
+        admin.PUT("/reclamos/:id/mensajes/:mensaje_id/leido", marcarMensajeLeidoAdminHandler)
+        admin.POST("/reclamos/:id/eventos/token", tokenEventosAdminHandler)
+        admin.POST("/reclamos/:id/escribiendo", escribiendoAdminHandler)
+
+        // Ampliación del plazo de respuesta (ADMIN o agente asignado)
+        admin.POST("/reclamos/:id/ampliar-plazo", ampliarPlazoHandler)
+        admin.GET("/reclamos/:id/ampliaciones", listarAmpliacionesHandler)
+        admin.GET("/reclamos/:id/pausas", listarPausasHandler)
+        admin.DELETE("/reclamos/:id/pausa", reanudarPlazoHandler)
+
+        // Filtros guardados del listado (por usuario)
+        admin.GET("/filtros", listarFiltrosGuardadosHandler)
+        admin.POST("/filtros", guardarFiltroHandler)
+        admin.DELETE("/filtros/:id", eliminarFiltroHandler)
+
+        // Categorías y etiquetas
+        admin.GET("/categorias", listarCategoriasHandler)
+        admin.POST("/categorias", rolAdminMiddleware(), crearCategoriaHandler)
+        admin.PUT("/categorias/:id", rolAdminMiddleware(), actualizarCategoriaHandler)
+        admin.DELETE("/categorias/:id", rolAdminMiddleware(), eliminarCategoriaHandler)
+        admin.GET("/etiquetas", listarEtiquetasHandler)
+        admin.PUT("/reclamos/:id/categoria", asignarCategoriaHandler)
+        admin.POST("/reclamos/:id/etiquetas", agregarEtiquetasHandler)
+        admin.DELETE("/reclamos/:id/etiquetas/:etiqueta", quitarEtiquetaHandler)
+
+        // Respuestas predefinidas (la biblioteca la mantiene ADMIN)
+        admin.GET("/respuestas-predefinidas", listarPlantillasHandler)
+        admin.POST("/respuestas-predefinidas", rolAdminMiddleware(), crearPlantillaHandler)
+        admin.PUT("/respuestas-predefinidas/:id", rolAdminMiddleware(), actualizarPlantillaHandler)
+        admin.DELETE("/respuestas-predefinidas/:id", rolAdminMiddleware(), eliminarPlantillaHandler)
+        admin.POST("/respuestas-predefinidas/:id/renderizar", renderizarPlantillaHandler)
+
+        // Notas internas (nunca visibles para el consumidor)
+        admin.GET("/reclamos/:id/notas", listarNotasHandler)
+        admin.POST("/reclamos/:id/notas", crearNotaHandler)
+        admin.PUT("/reclamos/:id/notas/:nota_id", editarNotaHandler)
+        admin.GET("/reclamos/:id/notas/:nota_id/historial", historialNotaHandler)
+
+        // Reportes para inspecciones de INDECOPI (datos personales completos: solo ADMIN)
+        admin.POST("/reportes", rolAdminMiddleware(), crearReporteHandler)
+        admin.GET("/reportes", rolAdminMiddleware(), listarReportesHandler)
+        admin.GET("/reportes/:id", rolAdminMiddleware(), obtenerReporteHandler)
+        admin.GET("/reportes/:id/descargar", rolAdminMiddleware(), descargarReporteHandler)
+
+        // Resúmenes programados para gerencia
+        admin.GET("/digests", rolAdminMiddleware(), listarDigestsHandler)
+        admin.POST("/digests", rolAdminMiddleware(), crearDigestHandler)
+        admin.PUT("/digests/:id", rolAdminMiddleware(), actualizarDigestHandler)
+        admin.DELETE("/digests/:id", rolAdminMiddleware(), eliminarDigestHandler)
+        admin.GET("/digests/:id/envios", rolAdminMiddleware(), listarEnviosDigestHandler)
+        admin.POST("/digests/:id/enviar", rolAdminMiddleware(), enviarDigestAhoraHandler)
+
+        // Reglas de triaje automático
+        admin.GET("/reglas-triaje", rolAdminMiddleware(), listarReglasTriajeHandler)
+        admin.POST("/reglas-triaje", rolAdminMiddleware(), crearReglaTriajeHandler)
+        admin.POST("/reglas-triaje/probar", rolAdminMiddleware(), probarReglasTriajeHandler)
+        admin.PUT("/reglas-triaje/:id", rolAdminMiddleware(), actualizarReglaTriajeHandler)
+        admin.DELETE("/reglas-triaje/:id", rolAdminMiddleware(), eliminarReglaTriajeHandler)
+
+        // Aprobación de respuestas (cuatro ojos)
+        admin.GET("/propuestas-respuesta", listarPropuestasHandler)
+        admin.PUT("/propuestas-respuesta/:id", editarPropuestaHandler)
+        admin.POST("/propuestas-respuesta/:id/aprobar", aprobarPropuestaHandler)
+        admin.POST("/propuestas-respuesta/:id/rechazar", rechazarPropuestaHandler)
+        admin.GET("/reclamos/:id/propuestas", listarPropuestasReclamoHandler)
+        admin.GET("/reglas-aprobacion", rolAdminMiddleware(), listarReglasAprobacionHandler)
+        admin.POST("/reglas-aprobacion", rolAdminMiddleware(), crearReglaAprobacionHandler)
+        admin.PUT("/reglas-aprobacion/:id", rolAdminMiddleware(), actualizarReglaAprobacionHandler)
+        admin.DELETE("/reglas-aprobacion/:id", rolAdminMiddleware(), eliminarReglaAprobacionHandler)
+
+        admin.GET("/antispam/rechazos", rolAdminMiddleware(), listarIntentosRechazadosHandler)
 
         // --- NUEVAS RUTAS DE USUARIOS ---
         // Solo admins pueden gestionar usuarios
@@ -1535,7 +2033,20 @@ admin := api.Group("/admin")
 	<-quit
 
 	log.Println("👋 Cerrando servidor...")
+	bgCancel()
 	ctx2, cancel2 := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel2()
 	srv.Shutdown(ctx2)
+
+	// Dejar terminar el triaje y los avisos ya iniciados
+	terminado := make(chan struct{})
+	go func() {
+		emailsEnCurso.Wait()
+		close(terminado)
+	}()
+	select {
+	case <-terminado:
+	case <-ctx2.Done():
+		log.Println("⚠️ Cierre con tareas en segundo plano pendientes")
+	}
 }
@@ -6,12 +6,17 @@
 package main
 
 import (
+	"archive/zip"
+	"bufio"
 	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
+	"net/url"
+	"strings"
 	"testing"
 	"time"
 
@@ -616,6 +621,1004 @@ func TestAuditoriaSeRegistra(t *testing.T) {
 	t.Logf("✅ Auditoría registrada correctamente - Registros: %d", auditCount)
 }
 
+// =============================================================================
+// TESTS DE BORRADORES
+// =============================================================================
+
+func TestValidarBorradorPermiteDatosParciales(t *testing.T) {
+	parcial := CrearReclamoRequest{Email: "cliente@test.com", NombreCompleto: "Cliente de Prueba"}
+	assert.Empty(t, validarBorrador(&parcial), "Un borrador parcial con email válido debe aceptarse")
+	assert.NotEmpty(t, validarReclamo(&parcial), "El mismo borrador no debe pasar la validación de envío")
+
+	sinEmail := CrearReclamoRequest{NombreCompleto: "Cliente de Prueba"}
+	assert.NotEmpty(t, validarBorrador(&sinEmail), "El borrador requiere email para el enlace")
+
+	tipoInvalido := CrearReclamoRequest{Email: "cliente@test.com", TipoSolicitud: "OTRO"}
+	assert.Equal(t, "Tipo de solicitud inválido", validarBorrador(&tipoInvalido))
+}
+
+// =============================================================================
+// TESTS DE ANTI-SPAM
+// =============================================================================
+
+func TestTokenFormularioFirmado(t *testing.T) {
+	ahora := time.Now()
+	token := firmarTokenFormulario(ahora.Add(-10 * time.Second))
+
+	emitido, ok := validarTokenFormulario(token, ahora)
+	assert.True(t, ok, "Un token recién emitido debe ser válido")
+	assert.Equal(t, ahora.Add(-10*time.Second).Unix(), emitido.Unix())
+
+	_, ok = validarTokenFormulario(token+"0", ahora)
+	assert.False(t, ok, "Un token alterado debe rechazarse")
+
+	_, ok = validarTokenFormulario(token, ahora.Add(3*time.Hour))
+	assert.False(t, ok, "Un token vencido debe rechazarse")
+}
+
+func TestHoneypotRechazaSinTocarHandler(t *testing.T) {
+	// Pool sin conexión real: el registro del rechazo falla y solo se loguea
+	anterior := pool
+	pool, _ = pgxpool.New(context.Background(), "postgresql://postgres@127.0.0.1:1/sin_db")
+	t.Cleanup(func() { pool.Close(); pool = anterior })
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	llamado := false
+	router.POST("/reclamos", antispamMiddleware(politicaAntispam{endpoint: "RECLAMO"}), func(c *gin.Context) {
+		llamado = true
+		c.Status(http.StatusCreated)
+	})
+
+	body := bytes.NewBufferString(`{"nombre_completo":"Bot","website":"http://spam.example"}`)
+	req, _ := http.NewRequest("POST", "/reclamos", body)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+	assert.False(t, llamado, "El handler no debe ejecutarse si el honeypot tiene valor")
+}
+
+// =============================================================================
+// TESTS DE RATE LIMITING
+// =============================================================================
+
+func TestConsumirTokenRecarga(t *testing.T) {
+	p := PoliticaRateLimit{Nombre: "test", Capacidad: 2, Periodo: 10 * time.Second}
+	ahora := time.Now()
+	var b bucketRateLimit
+
+	assert.True(t, consumirToken(&b, p, ahora).Permitido)
+	assert.True(t, consumirToken(&b, p, ahora).Permitido)
+
+	res := consumirToken(&b, p, ahora)
+	assert.False(t, res.Permitido, "Con el bucket vacío debe rechazar")
+	assert.Equal(t, 5*time.Second, res.Reintentar, "Un token se repone cada 5s")
+
+	assert.True(t, consumirToken(&b, p, ahora.Add(5*time.Second)).Permitido, "Tras la recarga vuelve a permitir")
+}
+
+func TestRateLimitMiddlewareCabeceras(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limitador = nuevoAlmacenMemoria()
+	defer func() { limitador = nil }()
+
+	p := PoliticaRateLimit{Nombre: "test", Capacidad: 1, Periodo: time.Minute, Clave: clavePorParametro("codigo")}
+	router := gin.New()
+	router.GET("/seguimiento/:codigo", rateLimitMiddleware(p), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/seguimiento/CODEPLEX-2026-00001", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1", w.Header().Get("X-RateLimit-Limit"))
+	assert.Equal(t, "0", w.Header().Get("X-RateLimit-Remaining"))
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/seguimiento/CODEPLEX-2026-00001", nil))
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+
+	// Otro código tiene su propio bucket
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/seguimiento/CODEPLEX-2026-00002", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+// =============================================================================
+// TESTS DE ACCESO PÚBLICO
+// =============================================================================
+
+func TestTokenAccesoReclamo(t *testing.T) {
+	token, err := firmarTokenAcceso("CODEPLEX-2026-00001", alcanceReclamo, time.Hour)
+	assert.NoError(t, err)
+
+	assert.True(t, validarTokenAcceso(token, "CODEPLEX-2026-00001", alcanceReclamo))
+	assert.True(t, validarTokenAcceso(token, "CODEPLEX-2026-00001", alcanceFirma), "El alcance reclamo incluye la firma")
+	assert.False(t, validarTokenAcceso(token, "CODEPLEX-2026-00002", alcanceReclamo), "El token es solo para su código")
+
+	firma, _ := firmarTokenAcceso("CODEPLEX-2026-00001", alcanceFirma, time.Hour)
+	assert.False(t, validarTokenAcceso(firma, "CODEPLEX-2026-00001", alcanceReclamo), "El token de firma no da acceso a los datos")
+
+	vencido, _ := firmarTokenAcceso("CODEPLEX-2026-00001", alcanceReclamo, -time.Minute)
+	assert.False(t, validarTokenAcceso(vencido, "CODEPLEX-2026-00001", alcanceReclamo))
+}
+
+func TestTokenAccesoNoSirveComoJWTAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/admin", authMiddleware(), func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	token, _ := firmarTokenAcceso("CODEPLEX-2026-00001", alcanceReclamo, time.Hour)
+	req := httptest.NewRequest("GET", "/admin", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+// =============================================================================
+// TESTS DEL PORTAL DEL CONSUMIDOR
+// =============================================================================
+
+func TestSesionConsumidorSeparadaDeTokenAcceso(t *testing.T) {
+	sesion, err := firmarSesionConsumidor("Cliente@Mail.com", "12345678", time.Hour)
+	assert.NoError(t, err)
+
+	claims, ok := validarSesionConsumidor(sesion)
+	assert.True(t, ok)
+	assert.Equal(t, "cliente@mail.com", claims.Email)
+	assert.False(t, validarTokenAcceso(sesion, "CODEPLEX-2026-00001", alcanceReclamo), "La sesión no es un token de reclamo")
+
+	acceso, _ := firmarTokenAcceso("CODEPLEX-2026-00001", alcanceReclamo, time.Hour)
+	_, ok = validarSesionConsumidor(acceso)
+	assert.False(t, ok, "Un token de reclamo no abre el portal")
+
+	vencida, _ := firmarSesionConsumidor("cliente@mail.com", "12345678", -time.Minute)
+	_, ok = validarSesionConsumidor(vencida)
+	assert.False(t, ok)
+}
+
+func TestRutasPortalConviven(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	ok := func(c *gin.Context) { c.String(http.StatusOK, c.FullPath()) }
+
+	assert.NotPanics(t, func() {
+		router.GET("/seguimiento/:codigo", ok)
+		router.POST("/seguimiento/:codigo/mensaje", ok)
+		router.POST("/seguimiento/acceso", ok)
+		router.POST("/seguimiento/acceso/canjear", ok)
+		router.GET("/seguimiento/mis-reclamos", sesionConsumidorMiddleware(), ok)
+	})
+
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/seguimiento/mis-reclamos", nil))
+	assert.Equal(t, http.StatusUnauthorized, w.Code, "mis-reclamos exige sesión")
+
+	sesion, _ := firmarSesionConsumidor("cliente@mail.com", "12345678", time.Hour)
+	req := httptest.NewRequest("GET", "/seguimiento/mis-reclamos", nil)
+	req.Header.Set("Authorization", "Bearer "+sesion)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	assert.Equal(t, "/seguimiento/mis-reclamos", w.Body.String())
+
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/seguimiento/CODEPLEX-2026-00001", nil))
+	assert.Equal(t, "/seguimiento/:codigo", w.Body.String())
+}
+
+func TestPDFHojaReclamo(t *testing.T) {
+	d := nuevoPDF()
+	d.Titulo("HOJA DE RECLAMACIÓN - CODEPLEX-2026-00001")
+	d.Campo("Detalle", "Producto (defectuoso) con tildes: ñandú")
+	for i := 0; i < 80; i++ {
+		d.Texto("Línea de relleno para forzar una segunda página")
+	}
+	pdf := d.Bytes()
+
+	assert.True(t, bytes.HasPrefix(pdf, []byte("%PDF-1.4")))
+	assert.Contains(t, string(pdf), "/Count 2")
+	assert.Contains(t, string(pdf), `Producto \(defectuoso\)`)
+	assert.Contains(t, string(pdf), "xref")
+	assert.True(t, bytes.HasSuffix(pdf, []byte("%%EOF\n")))
+}
+
+// =============================================================================
+// TESTS DE TIEMPO REAL
+// =============================================================================
+
+func TestBusMemoriaRepartePorReclamo(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	b := nuevoBusMemoria(ctx)
+
+	ch1, baja1 := b.Suscribir("r1")
+	ch2, _ := b.Suscribir("r2")
+
+	assert.NoError(t, b.Publicar(ctx, EventoReclamo{ReclamoID: "r1", Tipo: eventoMensaje}))
+
+	select {
+	case ev := <-ch1:
+		assert.Equal(t, eventoMensaje, ev.Tipo)
+	case <-time.After(time.Second):
+		t.Fatal("el suscriptor de r1 no recibió el evento")
+	}
+	assert.Len(t, ch2, 0, "r2 no debe recibir eventos de r1")
+
+	baja1()
+	_, abierto := <-ch1
+	assert.False(t, abierto, "la baja cierra el canal")
+
+	cancel()
+	select {
+	case _, abierto = <-ch2:
+		assert.False(t, abierto, "apagar el bus cierra los canales restantes")
+	case <-time.After(time.Second):
+		t.Fatal("el canal de r2 no se cerró al apagar el bus")
+	}
+}
+
+func TestStreamSSEEntregaEventos(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	bus = nuevoBusMemoria(ctx)
+	defer func() { bus = nil }()
+
+	router := gin.New()
+	router.GET("/eventos/:id", func(c *gin.Context) { transmitirEventos(c, c.Param("id"), false) })
+	srv := httptest.NewServer(router)
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + "/eventos/r1")
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+	assert.Equal(t, "text/event-stream", resp.Header.Get("Content-Type"))
+
+	lector := bufio.NewReader(resp.Body)
+	leerEvento := func() string {
+		var bloque strings.Builder
+		for {
+			linea, err := lector.ReadString('\n')
+			if err != nil || linea == "\n" {
+				return bloque.String()
+			}
+			bloque.WriteString(linea)
+		}
+	}
+
+	assert.Contains(t, leerEvento(), "event: conectado")
+
+	// El stream del consumidor no recibe eventos internos
+	publicarEventoInterno("r1", eventoEstado, gin.H{"agente_nuevo": "a1"})
+	publicarEvento("r1", eventoEscribiendo, gin.H{"autor": "EMPRESA"})
+	ev := leerEvento()
+	assert.Contains(t, ev, "event: escribiendo")
+	assert.Contains(t, ev, `"autor":"EMPRESA"`)
+	assert.NotContains(t, ev, "agente_nuevo")
+}
+
+func TestTokenEventosAdmin(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/reclamos/:id/eventos/token", func(c *gin.Context) {
+		c.Set("user_id", "u1")
+		c.Set("email", "agente@empresa.pe")
+		c.Set("rol", "SOPORTE")
+		tokenEventosAdminHandler(c)
+	})
+	router.GET("/reclamos/:id/eventos", authEventosAdminMiddleware(), func(c *gin.Context) {
+		c.String(http.StatusOK, "%v", c.MustGet("email"))
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/reclamos/r1/eventos/token", nil)
+	router.ServeHTTP(w, req)
+	var resp struct {
+		Data struct {
+			Token string `json:"token"`
+		} `json:"data"`
+	}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &resp))
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/reclamos/r1/eventos?token="+resp.Data.Token, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "agente@empresa.pe", w.Body.String())
+
+	// Solo vale para su reclamo y no como Bearer del panel
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/reclamos/r2/eventos?token="+resp.Data.Token, nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/reclamos/r1/eventos", nil)
+	req.Header.Set("Authorization", "Bearer "+resp.Data.Token)
+	router.ServeHTTP(w, req)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+}
+
+// =============================================================================
+// TESTS DE AVISOS DE MENSAJES
+// =============================================================================
+
+func TestAgendaAgrupaMensajesEnUnAviso(t *testing.T) {
+	a := &agendaNotificaciones{pendientes: make(map[string]*time.Timer)}
+	envios := make(chan string, 10)
+	enviar := func(id string) { envios <- id }
+
+	a.programar("r1", 50*time.Millisecond, enviar)
+	a.programar("r1", 50*time.Millisecond, enviar)
+	a.programar("r2", 50*time.Millisecond, enviar)
+
+	recibidos := map[string]int{}
+	for i := 0; i < 2; i++ {
+		select {
+		case id := <-envios:
+			recibidos[id]++
+		case <-time.After(time.Second):
+			t.Fatal("no se enviaron los avisos")
+		}
+	}
+	assert.Equal(t, map[string]int{"r1": 1, "r2": 1}, recibidos)
+
+	// Pasada la ventana, un mensaje nuevo programa otro aviso
+	a.programar("r1", 10*time.Millisecond, enviar)
+	select {
+	case id := <-envios:
+		assert.Equal(t, "r1", id)
+	case <-time.After(time.Second):
+		t.Fatal("no se reprogramó el aviso")
+	}
+}
+
+func TestEmailMensajesEmpresaEscapaContenido(t *testing.T) {
+	contenido := generarEmailMensajesEmpresa("CODEPLEX-2026-00001", "Ana <b>", []mensajeNotificado{
+		{Mensaje: "<script>alert(1)</script>", Fecha: time.Now()},
+		{Mensaje: "Segundo mensaje", Fecha: time.Now()},
+	})
+
+	assert.NotContains(t, contenido, "<script>")
+	assert.Contains(t, contenido, "&lt;script&gt;")
+	assert.Contains(t, contenido, "Segundo mensaje")
+	assert.Contains(t, contenido, "token=", "El botón lleva el enlace firmado")
+}
+
+// =============================================================================
+// TESTS DE RESPUESTAS POR EMAIL
+// =============================================================================
+
+func TestTokenRespuestaPorReclamo(t *testing.T) {
+	token := tokenRespuesta("CODEPLEX-2026-00001")
+
+	codigo, ok := codigoDesdeTokenRespuesta(strings.ToUpper(token))
+	assert.True(t, ok, "El token sobrevive a servidores que cambian mayúsculas")
+	assert.Equal(t, "CODEPLEX-2026-00001", codigo)
+
+	_, ok = codigoDesdeTokenRespuesta(strings.Replace(token, "00001", "00002", 1))
+	assert.False(t, ok, "No se puede adivinar el token de otro reclamo")
+}
+
+func TestLimpiarRespuestaQuitaCitas(t *testing.T) {
+	casos := map[string]string{
+		"Gracias, adjunto la boleta.\n\nEl lun, 5 ene 2026 a las 10:00, CODEPLEX <reclamo@codeplex.pe>\nescribió:\n> Hola": "Gracias, adjunto la boleta.",
+		"Sigo esperando\r\n\r\nOn Mon, Jan 5, 2026 at 10:00 AM Soporte wrote:\r\n> texto":                                  "Sigo esperando",
+		"Ok\n\n-----Mensaje original-----\nDe: soporte":                                                                    "Ok",
+		"Listo\n\nDe: CODEPLEX <reclamo@codeplex.pe>\nEnviado: lunes\nAsunto: Re":                                          "Listo",
+		"Respuesta\n-- \nAna Pérez\nCel. 999":                                                                              "Respuesta",
+	}
+	for entrada, esperado := range casos {
+		assert.Equal(t, esperado, limpiarRespuesta(entrada))
+	}
+}
+
+func TestParsearCorreoMultipartConAdjunto(t *testing.T) {
+	raw := "From: Ana <ana@mail.com>\r\n" +
+		"To: reclamo+" + tokenRespuesta("CODEPLEX-2026-00001") + "@codeplex.pe\r\n" +
+		"Subject: =?UTF-8?Q?Re:_Confirmaci=C3=B3n?=\r\n" +
+		"Message-ID: <abc@mail.com>\r\n" +
+		"Content-Type: multipart/mixed; boundary=XYZ\r\n\r\n" +
+		"--XYZ\r\n" +
+		"Content-Type: text/plain; charset=ISO-8859-1\r\n" +
+		"Content-Transfer-Encoding: quoted-printable\r\n\r\n" +
+		"Env=EDo la foto.\r\n\r\n> cita\r\n" +
+		"--XYZ\r\n" +
+		"Content-Type: image/png\r\n" +
+		"Content-Disposition: attachment; filename=\"foto.png\"\r\n" +
+		"Content-Transfer-Encoding: base64\r\n\r\n" +
+		"iVBORw0K\r\nGgo=\r\n" +
+		"--XYZ--\r\n"
+
+	correo, err := parsearCorreo(strings.NewReader(raw))
+	assert.NoError(t, err)
+	assert.Equal(t, "abc@mail.com", correo.MessageID)
+	assert.Equal(t, "ana@mail.com", correo.De)
+	assert.Equal(t, "Re: Confirmación", correo.Asunto)
+	assert.Equal(t, "Envío la foto.", limpiarRespuesta(correo.Texto))
+	assert.False(t, correo.Automatico)
+	if assert.Len(t, correo.Adjuntos, 1) {
+		assert.Equal(t, "foto.png", correo.Adjuntos[0].Nombre)
+		assert.Equal(t, []byte("\x89PNG\r\n\x1a\n"), correo.Adjuntos[0].Datos)
+	}
+}
+
+func TestLeerMboxSeparaCorreos(t *testing.T) {
+	mbox := "From ana@mail.com Mon Jan  5 10:00:00 2026\n" +
+		"Subject: uno\n\n>From el inicio\n\n" +
+		"From luis@mail.com Mon Jan  5 11:00:00 2026\n" +
+		"Subject: dos\n\ncuerpo\n"
+
+	var correos []string
+	err := leerMbox(strings.NewReader(mbox), func(raw []byte) error {
+		correos = append(correos, string(raw))
+		return nil
+	})
+	assert.NoError(t, err)
+	if assert.Len(t, correos, 2) {
+		assert.Equal(t, "Subject: uno\n\nFrom el inicio", correos[0])
+		assert.Equal(t, "Subject: dos\n\ncuerpo", correos[1])
+	}
+}
+
+// =============================================================================
+// TESTS DE NOTAS INTERNAS
+// =============================================================================
+
+func TestExtraerMenciones(t *testing.T) {
+	menciones := extraerMenciones("Revisar con @jperez y @Ana.Lopez@codeplex.pe. Ticket JIRA-12, correo cliente@mail.com, otra vez @jperez.")
+	assert.Equal(t, []string{"jperez", "ana.lopez@codeplex.pe"}, menciones)
+	assert.Empty(t, extraerMenciones("sin menciones, solo cliente@mail.com"))
+}
+
+// =============================================================================
+// TESTS DE ALERTAS DE VENCIMIENTO
+// =============================================================================
+
+func TestDiasHabilesRestantes(t *testing.T) {
+	dia := func(s string) time.Time {
+		d, _ := time.Parse("2006-01-02", s)
+		return d
+	}
+	sinFeriados := map[string]bool{}
+
+	// Viernes 2026-10-16 -> miércoles 21: lun, mar, mié
+	assert.Equal(t, 3, diasHabilesRestantes(dia("2026-10-16"), dia("2026-10-21"), sinFeriados))
+	assert.Equal(t, 0, diasHabilesRestantes(dia("2026-10-21"), dia("2026-10-21"), sinFeriados))
+	// 28 y 29 de julio son feriados fijos
+	assert.Equal(t, 1, diasHabilesRestantes(dia("2026-07-27"), dia("2026-07-30"), sinFeriados))
+	// Feriado configurado
+	assert.Equal(t, 2, diasHabilesRestantes(dia("2026-10-16"), dia("2026-10-21"), map[string]bool{"2026-10-20": true}))
+	// Vencido: días hábiles de atraso en negativo, mínimo uno
+	assert.Equal(t, -2, diasHabilesRestantes(dia("2026-10-21"), dia("2026-10-19"), sinFeriados))
+	assert.Equal(t, -1, diasHabilesRestantes(dia("2026-10-18"), dia("2026-10-17"), sinFeriados))
+}
+
+func TestNivelSLA(t *testing.T) {
+	umbrales := umbralesSLA("1, 5,3,x")
+	assert.Equal(t, []int{5, 3, 1}, umbrales)
+
+	assert.Equal(t, "", nivelSLA(8, umbrales))
+	assert.Equal(t, "5", nivelSLA(5, umbrales))
+	assert.Equal(t, "3", nivelSLA(2, umbrales))
+	assert.Equal(t, "1", nivelSLA(0, umbrales))
+	assert.Equal(t, nivelVencido, nivelSLA(-1, umbrales))
+}
+
+// =============================================================================
+// TESTS DE AMPLIACIÓN DE PLAZO
+// =============================================================================
+
+func TestAmpliarPlazoValidaDiasYJustificacion(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	config.PlazoAmpliacionMaxDias = 15
+
+	r := gin.New()
+	r.POST("/reclamos/:id/ampliar-plazo", func(c *gin.Context) {
+		c.Set("user_id", "u1")
+		c.Set("email", "agente@codeplex.pe")
+		c.Set("rol", "SOPORTE")
+		ampliarPlazoHandler(c)
+	})
+
+	casos := []map[string]interface{}{
+		{"dias": 10, "justificacion": "corta"},
+		{"dias": 30, "justificacion": "Requiere peritaje técnico del proveedor externo"},
+		{"justificacion": "Requiere peritaje técnico del proveedor externo"},
+	}
+	for _, caso := range casos {
+		body, _ := json.Marshal(caso)
+		req, _ := http.NewRequest("POST", "/reclamos/abc/ampliar-plazo", bytes.NewBuffer(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, "caso %v", caso)
+	}
+}
+
+// =============================================================================
+// TESTS DE BÚSQUEDA
+// =============================================================================
+
+func TestResaltarTerminosSinTildes(t *testing.T) {
+	texto := "El técnico no llegó a la instalación programada y la factura llegó igual."
+	fragmento := fragmentoHTML(resaltarTerminos(texto, []string{"tecnico", "FACTURA"}, 200))
+	assert.Equal(t, "El <mark>técnico</mark> no llegó a la instalación programada y la <mark>factura</mark> llegó igual.", fragmento)
+
+	largo := strings.Repeat("relleno ", 60) + "<script>cobro</script> indebido" + strings.Repeat(" relleno", 60)
+	fragmento = fragmentoHTML(resaltarTerminos(largo, []string{"cobro"}, 80))
+	assert.Contains(t, fragmento, "&lt;script&gt;<mark>cobro</mark>&lt;/script&gt;")
+	assert.True(t, strings.HasPrefix(fragmento, "… "))
+	assert.True(t, strings.HasSuffix(fragmento, " …"))
+}
+
+func TestBusquedaUsaCadaParametroUnaVez(t *testing.T) {
+	for _, textoCompleto := range []bool{true, false} {
+		busquedaTextoCompleto = textoCompleto
+
+		var args []interface{}
+		arg := func(v interface{}) string {
+			args = append(args, v)
+			return fmt.Sprintf("$%d", len(args))
+		}
+		b := construirBusqueda(`cobro "100%" -x`, arg)
+
+		sqlCompleto := b.Join + b.Where + b.Columnas
+		for i := range args {
+			assert.Equal(t, 1, strings.Count(sqlCompleto, fmt.Sprintf("$%d", i+1)), "parámetro $%d", i+1)
+		}
+		if !textoCompleto {
+			assert.Equal(t, []string{"cobro", "100%"}, b.Terminos)
+			assert.Equal(t, `%100\%%`, args[1])
+		}
+	}
+	busquedaTextoCompleto = false
+}
+
+func TestParsearFiltrosValidaValores(t *testing.T) {
+	f, err := parsearFiltros(url.Values{"estado": {"pendiente, EN_PROCESO"}, "monto_min": {"50"}, "agente": {"sin_asignar"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"PENDIENTE", "EN_PROCESO"}, f.Estados)
+	assert.Equal(t, 50.0, *f.MontoMin)
+
+	for _, q := range []url.Values{
+		{"estado": {"BORRADO"}},
+		{"prioridad": {"ALTA"}},
+		{"fecha_desde": {"2024-02-10"}, "fecha_hasta": {"2024-02-01"}},
+		{"monto_max": {"-1"}},
+		{"agente": {"1; DROP TABLE reclamos"}},
+	} {
+		_, err := parsearFiltros(q)
+		assert.Error(t, err, q.Encode())
+	}
+}
+
+func TestCursorKeysetRespetaOrden(t *testing.T) {
+	claves, err := parsearOrden("prioridad,-monto", "")
+	assert.NoError(t, err)
+	assert.Len(t, claves, 3) // + r.id como desempate
+
+	_, err = parsearOrden("relevancia", "")
+	assert.Error(t, err, "relevancia solo con búsqueda")
+
+	cursor := codificarCursor("prioridad,-monto", `[1, 250.5, "0b6f2c1e-4c1a-4d7e-9a51-2f5b8f0c9d11"]`)
+	valores, err := decodificarCursor(cursor, "prioridad,-monto", claves)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"1", "250.5", "0b6f2c1e-4c1a-4d7e-9a51-2f5b8f0c9d11"}, valores)
+
+	_, err = decodificarCursor(cursor, "-fecha_registro", claves)
+	assert.Error(t, err, "el cursor no sirve con otro orden")
+
+	var args []interface{}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+	cond := sqlKeyset(claves, valores, arg)
+	assert.Len(t, args, 3)
+	assert.Contains(t, cond, "COALESCE(r.monto_reclamado, 0)::float8 < $2::text::float8")
+	assert.Contains(t, cond, "r.id > $3::text::uuid")
+}
+
+func TestValidarTransicionEstado(t *testing.T) {
+	assert.NoError(t, validarTransicion("PENDIENTE", "EN_PROCESO", "SOPORTE"))
+	assert.NoError(t, validarTransicion("RESUELTO", "EN_PROCESO", "SOPORTE"), "reabrir un reclamo resuelto")
+
+	assert.ErrorIs(t, validarTransicion("EN_PROCESO", "CERRADO", "SOPORTE"), errSinPermiso)
+	assert.ErrorIs(t, validarTransicion("CERRADO", "PENDIENTE", "ADMIN"), errTransicionInvalida, "CERRADO es final")
+	assert.ErrorIs(t, validarTransicion("RESUELTO", "PENDIENTE", "ADMIN"), errTransicionInvalida)
+	assert.ErrorIs(t, validarTransicion("PENDIENTE", "PENDIENTE", "ADMIN"), errTransicionInvalida)
+}
+
+func TestNormalizarEtiquetas(t *testing.T) {
+	etiquetas, err := normalizarEtiquetas([]string{" Facturación ", "facturación", "envío  tardío", ""})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"facturación", "envío tardío"}, etiquetas)
+
+	_, err = normalizarEtiquetas([]string{" "})
+	assert.Error(t, err)
+	_, err = normalizarEtiquetas([]string{strings.Repeat("x", 51)})
+	assert.Error(t, err)
+}
+
+func TestEnmascararDatosPersonales(t *testing.T) {
+	assert.Equal(t, "Juan P. G.", enmascarar("Juan Pérez Gómez", mascaraNombre))
+	assert.Equal(t, "ju***@correo.pe", enmascarar("juan.perez@correo.pe", mascaraEmail))
+	assert.Equal(t, "*****678", enmascarar("12345678", mascaraFinal))
+	assert.Equal(t, "[oculto]", enmascarar("Av. Lima 123", mascaraOculta))
+	assert.Equal(t, "PENDIENTE", enmascarar("PENDIENTE", mascaraNinguna))
+
+	assert.Equal(t, "'=HYPERLINK(\"x\")", neutralizarFormula("=HYPERLINK(\"x\")"))
+	assert.Equal(t, "Cobro indebido", neutralizarFormula("Cobro indebido"))
+
+	_, err := parsearColumnasExport("codigo,contraseña")
+	assert.Error(t, err)
+}
+
+func TestXLSXEsZipValidoConFilas(t *testing.T) {
+	var buf bytes.Buffer
+	libro, err := nuevoXLSX(&buf)
+	assert.NoError(t, err)
+	assert.NoError(t, libro.Encabezado([]string{"Código", "Monto"}))
+	assert.NoError(t, libro.Fila([]string{"R-001 <a&b>", "150.5"}, []bool{false, true}))
+	assert.NoError(t, libro.Cerrar())
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(t, err)
+	var hoja string
+	for _, f := range zr.File {
+		if f.Name == "xl/worksheets/sheet1.xml" {
+			rc, _ := f.Open()
+			contenido, _ := io.ReadAll(rc)
+			rc.Close()
+			hoja = string(contenido)
+		}
+	}
+	assert.Len(t, zr.File, len(xlsxPartes)+1)
+	assert.Contains(t, hoja, "R-001 &lt;a&amp;b&gt;")
+	assert.Contains(t, hoja, "<c><v>150.5</v></c>")
+}
+
+func TestCumplimientoReporteINDECOPI(t *testing.T) {
+	assert.Nil(t, porcentajeCumplimiento(0, 0, 0), "sin plazos decididos no hay tasa")
+	assert.Equal(t, 75.0, *porcentajeCumplimiento(6, 1, 1))
+	assert.Equal(t, 66.7, *porcentajeCumplimiento(2, 0, 1))
+
+	promedio := 4.5
+	pdf := pdfResumenReporte(resumenReporte{Total: 8, RespondidosEnPlazo: 6, PromedioDias: &promedio, Cumplimiento: porcentajeCumplimiento(6, 1, 1)},
+		time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 3, 31, 0, 0, 0, 0, time.UTC), "")
+	assert.True(t, bytes.HasPrefix(pdf, []byte("%PDF-")))
+	assert.Contains(t, string(pdf), "01/01/2024 al 31/03/2024")
+}
+
+func TestCubetasAnalytics(t *testing.T) {
+	desde := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC) // miércoles
+	hasta := time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)
+
+	assert.Len(t, cubetasAnalytics(desde, hasta, "dia"), 32)
+
+	semanas := cubetasAnalytics(desde, hasta, "semana")
+	assert.Equal(t, time.Date(2024, 1, 29, 0, 0, 0, 0, time.UTC), semanas[0], "la semana empieza el lunes")
+	assert.Len(t, semanas, 5)
+
+	meses := cubetasAnalytics(desde, hasta, "mes")
+	assert.Equal(t, []time.Time{
+		time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC),
+		time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+	}, meses)
+
+	domingo := time.Date(2024, 2, 4, 0, 0, 0, 0, time.UTC)
+	assert.Equal(t, time.Date(2024, 1, 29, 0, 0, 0, 0, time.UTC), truncarCubeta(domingo, "semana"))
+}
+
+func TestPeriodoDigest(t *testing.T) {
+	lima := time.FixedZone("PET", -5*3600)
+	// Miércoles 15/07/2026, 09:30
+	ahora := time.Date(2026, 7, 15, 9, 30, 0, 0, lima)
+	hoy := time.Date(2026, 7, 15, 0, 0, 0, 0, lima)
+
+	diario := digest{Frecuencia: "DIARIO", Hora: 8}
+	desde, hasta, toca := periodoDigest(diario, ahora)
+	assert.True(t, toca)
+	assert.Equal(t, hoy.AddDate(0, 0, -1), desde)
+	assert.Equal(t, hoy, hasta)
+
+	_, _, toca = periodoDigest(digest{Frecuencia: "DIARIO", Hora: 10}, ahora)
+	assert.False(t, toca, "todavía no llega la hora")
+
+	_, _, toca = periodoDigest(digest{Frecuencia: "SEMANAL", DiaSemana: 1, Hora: 8}, ahora)
+	assert.False(t, toca, "el semanal de los lunes no toca un miércoles")
+
+	desde, hasta, toca = periodoDigest(digest{Frecuencia: "SEMANAL", DiaSemana: 3, Hora: 8}, ahora)
+	assert.True(t, toca)
+	assert.Equal(t, hoy.AddDate(0, 0, -7), desde)
+	assert.Equal(t, hoy, hasta)
+
+	// Domingo = 7
+	domingo := time.Date(2026, 7, 19, 23, 0, 0, 0, lima)
+	_, _, toca = periodoDigest(digest{Frecuencia: "SEMANAL", DiaSemana: 7, Hora: 23}, domingo)
+	assert.True(t, toca)
+}
+
+func TestEmailDigestSecciones(t *testing.T) {
+	agente := "Ana <Pérez>"
+	d := digest{Nombre: "Gerencia", Frecuencia: "SEMANAL", Secciones: []string{"vencidos", "sla", "carga"}}
+	datos := datosDigest{
+		Nuevos:             4,
+		Vencidos:           1,
+		VencidosLista:      []reclamoSLA{{ID: "abc", Codigo: "REC-2026-000001", DiasRestantes: -2, AgenteNombre: &agente}},
+		RespondidosEnPlazo: 9,
+		RespondidosFuera:   1,
+		Agentes:            []cargaAgente{{Nombre: agente, Abiertos: 3}},
+	}
+	desde := time.Date(2026, 7, 8, 0, 0, 0, 0, time.UTC)
+	contenido := generarEmailDigest(d, datos, desde, desde.AddDate(0, 0, 7))
+
+	assert.Contains(t, contenido, "Resumen semanal")
+	assert.Contains(t, contenido, "08/07/2026 al 14/07/2026")
+	assert.Contains(t, contenido, "REC-2026-000001")
+	assert.Contains(t, contenido, "90.0 %")
+	assert.Contains(t, contenido, "Ana &lt;Pérez&gt;")
+	assert.NotContains(t, contenido, "Ana <Pérez>")
+	assert.NotContains(t, contenido, "Nuevos registros", "sección no seleccionada")
+}
+
+func TestArbolCategorias(t *testing.T) {
+	padre := "a"
+	hijo := "b"
+	huerfano := "zz"
+	nodos := []categoriaNodo{
+		{ID: "a", Nombre: "Facturación", Ruta: "Facturación", Nivel: 1},
+		{ID: "b", PadreID: &padre, Nombre: "Cobros indebidos", Ruta: "Facturación > Cobros indebidos", Nivel: 2},
+		{ID: "c", PadreID: &hijo, Nombre: "Doble cobro", Ruta: "Facturación > Cobros indebidos > Doble cobro", Nivel: 3},
+		{ID: "d", PadreID: &padre, Nombre: "Comprobantes", Ruta: "Facturación > Comprobantes", Nivel: 2},
+		{ID: "e", PadreID: &huerfano, Nombre: "Suelta", Ruta: "Otra > Suelta", Nivel: 2},
+		{ID: "f", Nombre: "Productos", Ruta: "Productos", Nivel: 1},
+	}
+
+	arbol := construirArbolCategorias(nodos)
+	if assert.Len(t, arbol, 3) {
+		assert.Equal(t, "a", arbol[0]["id"])
+		assert.Equal(t, "e", arbol[1]["id"], "sin padre en la lista queda en la raíz")
+		assert.Equal(t, "f", arbol[2]["id"])
+	}
+	hijos := arbol[0]["hijos"].([]gin.H)
+	if assert.Len(t, hijos, 2) {
+		assert.Equal(t, "b", hijos[0]["id"])
+		assert.Equal(t, "d", hijos[1]["id"])
+		nietos := hijos[0]["hijos"].([]gin.H)
+		assert.Len(t, nietos, 1)
+		assert.Equal(t, "c", nietos[0]["id"])
+	}
+}
+
+func TestFiltroCategoria(t *testing.T) {
+	_, err := parsearFiltros(url.Values{"categoria": {"facturacion"}})
+	assert.Error(t, err)
+
+	f, err := parsearFiltros(url.Values{"categoria": {"sin_categoria"}})
+	assert.NoError(t, err)
+	assert.Contains(t, f.where(func(interface{}) string { return "$1" }), "r.categoria_id IS NULL")
+
+	id := "0b6f3c1e-3d55-4c1a-9d7e-2f1f4f3b9a10"
+	f, err = parsearFiltros(url.Values{"categoria": {id}})
+	assert.NoError(t, err)
+	var args []interface{}
+	where := f.where(func(v interface{}) string { args = append(args, v); return "$1" })
+	assert.Contains(t, where, "ANY(ancestros)", "incluye las subcategorías")
+	assert.Equal(t, []interface{}{id}, args)
+
+	_, err = normalizarNombreCategoria("  Cobros   indebidos ")
+	assert.NoError(t, err)
+	_, err = normalizarNombreCategoria("A > B")
+	assert.Error(t, err)
+}
+
+func TestEvaluarTriaje(t *testing.T) {
+	mil := 1000.0
+	facturacion := "0b6f3c1e-3d55-4c1a-9d7e-2f1f4f3b9a10"
+	otra := "1c7f4d2f-4e66-4d2b-8e8f-3a2a5a4cab21"
+	agente := "2d8a5e3a-5f77-4e3c-9f9a-4b3b6b5dbc32"
+	reglas := []reglaTriaje{
+		{ID: "1", Nombre: "Cobros", Condiciones: condicionesTriaje{PalabrasClave: []string{"cobro indebido", "facturación"}},
+			Acciones: accionesTriaje{CategoriaID: &facturacion, Etiquetas: []string{"facturacion"}}},
+		{ID: "2", Nombre: "Montos altos", Condiciones: condicionesTriaje{MontoMin: &mil, TiposSolicitud: []string{"RECLAMO"}},
+			Acciones: accionesTriaje{Prioridad: "ALTA", AgenteID: &agente, CategoriaID: &otra, Etiquetas: []string{"monto alto", "facturacion"}}, Detener: true},
+		{ID: "3", Nombre: "Sede Centro", Condiciones: condicionesTriaje{Establecimientos: []string{"Sede Centro"}},
+			Acciones: accionesTriaje{Notificar: []string{"centro@empresa.pe"}}},
+	}
+
+	r := reclamoTriaje{Tipo: "RECLAMO", Detalle: "Me hicieron un COBRO INDEBIDO en la factura", Monto: 1500, Establecimiento: "sede centro"}
+	res := evaluarTriaje(reglas, r)
+	assert.Equal(t, []string{"Cobros", "Montos altos"}, res.Reglas, "la segunda regla detiene la evaluación")
+	assert.Equal(t, []string{"1", "2"}, res.ReglaIDs)
+	assert.Equal(t, facturacion, *res.Acciones.CategoriaID, "gana la primera regla que fija la categoría")
+	assert.Equal(t, "ALTA", res.Acciones.Prioridad)
+	assert.Equal(t, agente, *res.Acciones.AgenteID)
+	assert.Equal(t, []string{"facturacion", "monto alto"}, res.Acciones.Etiquetas)
+	assert.Empty(t, res.Acciones.Notificar)
+
+	// Sin tildes ni mayúsculas; QUEJA no cumple la regla de montos
+	r = reclamoTriaje{Tipo: "QUEJA", Detalle: "problema con la facturacion", Monto: 5000, Establecimiento: "Sede Centro"}
+	res = evaluarTriaje(reglas, r)
+	assert.Equal(t, []string{"Cobros", "Sede Centro"}, res.Reglas)
+	assert.Equal(t, []string{"centro@empresa.pe"}, res.Acciones.Notificar)
+
+	res = evaluarTriaje(reglas, reclamoTriaje{Tipo: "RECLAMO", Detalle: "demora en la entrega", Monto: 999.99})
+	assert.Empty(t, res.Reglas)
+}
+
+func TestValidarReglaTriaje(t *testing.T) {
+	cien, diez := 100.0, 10.0
+	sinCondicion := solicitudReglaTriaje{Nombre: "x", Acciones: accionesTriaje{Prioridad: "alta"}}
+	assert.Error(t, sinCondicion.validar())
+
+	sinAccion := solicitudReglaTriaje{Nombre: "x", Condiciones: condicionesTriaje{TiposSolicitud: []string{"QUEJA"}}}
+	assert.Error(t, sinAccion.validar())
+
+	montos := solicitudReglaTriaje{Nombre: "x", Condiciones: condicionesTriaje{MontoMin: &cien, MontoMax: &diez}, Acciones: accionesTriaje{Prioridad: "ALTA"}}
+	assert.Error(t, montos.validar())
+
+	ok := solicitudReglaTriaje{Nombre: "x", Condiciones: condicionesTriaje{MontoMin: &diez}, Acciones: accionesTriaje{Prioridad: "alta", Etiquetas: []string{" Monto  Alto "}}}
+	assert.NoError(t, ok.validar())
+	assert.Equal(t, "ALTA", ok.Acciones.Prioridad)
+	assert.Equal(t, []string{"monto alto"}, ok.Acciones.Etiquetas)
+	assert.True(t, *ok.Activa)
+
+	email := solicitudReglaTriaje{Nombre: "x", Condiciones: condicionesTriaje{MontoMin: &diez}, Acciones: accionesTriaje{Notificar: []string{"no-es-email"}}}
+	assert.Error(t, email.validar())
+}
+
+func TestRenderizarPlantilla(t *testing.T) {
+	valores := map[string]string{"nombre": "María Quispe", "codigo": "REC-2026-000042", "fecha_limite": "30/07/2026"}
+	faltantes := map[string]bool{}
+
+	texto := renderizarPlantilla("Estimada {{nombre}}: sobre su reclamo {{ codigo }}, responderemos antes del {{fecha_limite}}. Monto: {{monto}}.", valores, faltantes)
+	assert.Equal(t, "Estimada María Quispe: sobre su reclamo REC-2026-000042, responderemos antes del 30/07/2026. Monto: .", texto)
+	assert.Equal(t, map[string]bool{"monto": true}, faltantes)
+
+	assert.Empty(t, marcadoresDesconocidos("Hola {{nombre}}, {{codigo}}"))
+	assert.Equal(t, []string{"telefono"}, marcadoresDesconocidos("{{telefono}} y {{telefono}} y {{agente}}"))
+
+	tipo := "queja"
+	s := solicitudPlantilla{Titulo: "x", TipoSolicitud: &tipo, RespuestaEmpresa: "Hola {{dni}}"}
+	assert.Error(t, s.validar())
+	s.RespuestaEmpresa = "Hola {{nombre}}"
+	assert.NoError(t, s.validar())
+	assert.Equal(t, "QUEJA", *s.TipoSolicitud)
+}
+
+func TestReglaAprobacionAplica(t *testing.T) {
+	reclamo := "RECLAMO"
+	mil := 1000.0
+	padre := "0b6f3c1e-3d55-4c1a-9d7e-2f1f4f3b9a10"
+	hija := "1c7f4d2f-4e66-4d2b-8e8f-3a2a5a4cab21"
+
+	todas := reglaAprobacion{Nombre: "Todas"}
+	assert.True(t, todas.aplica("QUEJA", 0, nil), "una regla sin criterios aplica a todos")
+
+	montoAlto := reglaAprobacion{Nombre: "Reclamos > 1000", TipoSolicitud: &reclamo, MontoMin: &mil}
+	assert.True(t, montoAlto.aplica("RECLAMO", 1000, nil))
+	assert.False(t, montoAlto.aplica("RECLAMO", 999.99, nil))
+	assert.False(t, montoAlto.aplica("QUEJA", 5000, nil))
+
+	categoria := reglaAprobacion{Nombre: "Facturación", CategoriaID: &padre}
+	assert.True(t, categoria.aplica("QUEJA", 0, []string{padre, hija}), "incluye las subcategorías")
+	assert.False(t, categoria.aplica("QUEJA", 0, []string{hija}))
+	assert.False(t, categoria.aplica("QUEJA", 0, nil), "sin categoría no aplica")
+}
+
+func TestAplicarEdicionPropuesta(t *testing.T) {
+	d := datosRespuesta{RespuestaEmpresa: "Lamentamos lo ocurrido", AccionTomada: "Reembolso"}
+	assert.False(t, aplicarEdicion(&d, nil))
+	assert.False(t, aplicarEdicion(&d, &datosRespuesta{AccionTomada: "Reembolso"}), "sin cambios reales")
+
+	assert.True(t, aplicarEdicion(&d, &datosRespuesta{CompensacionOfrecida: "Vale de S/ 50"}))
+	assert.Equal(t, "Lamentamos lo ocurrido", d.RespuestaEmpresa, "los campos vacíos se conservan")
+	assert.Equal(t, "Reembolso", d.AccionTomada)
+	assert.Equal(t, "Vale de S/ 50", d.CompensacionOfrecida)
+}
+
+func TestValidarReglaAprobacion(t *testing.T) {
+	queja := "queja"
+	s := solicitudReglaAprobacion{Nombre: "Quejas", TipoSolicitud: &queja}
+	assert.NoError(t, s.validar())
+	assert.Equal(t, "QUEJA", *s.TipoSolicitud)
+	assert.True(t, *s.Activa, "activa por defecto")
+
+	otro := "SUGERENCIA"
+	assert.Error(t, (&solicitudReglaAprobacion{Nombre: "x", TipoSolicitud: &otro}).validar())
+	negativo := -1.0
+	assert.Error(t, (&solicitudReglaAprobacion{Nombre: "x", MontoMin: &negativo}).validar())
+	mala := "no-es-uuid"
+	assert.Error(t, (&solicitudReglaAprobacion{Nombre: "x", CategoriaID: &mala}).validar())
+}
+
+func TestEnmiendaRequiereMotivo(t *testing.T) {
+	// Pool sin conexión real: la validación ocurre antes de tocar la base
+	anterior := pool
+	pool, _ = pgxpool.New(context.Background(), "postgresql://postgres@127.0.0.1:1/sin_db")
+	t.Cleanup(func() { pool.Close(); pool = anterior })
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.POST("/reclamos/:id/respuesta/enmiendas", func(c *gin.Context) {
+		c.Set("user_id", "2d8a5e3a-5f77-4e3c-9f9a-4b3b6b5dbc32")
+		c.Set("email", "agente@empresa.pe")
+		enmendarRespuestaHandler(c)
+	})
+
+	for _, body := range []string{
+		`{"respuesta_empresa":"Corregimos el monto del reembolso a S/ 120."}`,
+		`{"respuesta_empresa":"Corregimos el monto del reembolso a S/ 120.","motivo":"error"}`,
+		`{"motivo":"Monto del reembolso mal calculado"}`,
+	} {
+		req, _ := http.NewRequest("POST", "/reclamos/0b6f3c1e-3d55-4c1a-9d7e-2f1f4f3b9a10/respuesta/enmiendas", bytes.NewBufferString(body))
+		req.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		assert.Equal(t, http.StatusBadRequest, w.Code, body)
+	}
+}
+
+func TestPermitirEnlaceBorradorPorEmail(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	limitador = nuevoAlmacenMemoria()
+	anterior := politicaEnlacesBorrador
+	politicaEnlacesBorrador = PoliticaRateLimit{Nombre: "test_enlaces", Capacidad: 1, Periodo: time.Hour}
+	defer func() { limitador, politicaEnlacesBorrador = nil, anterior }()
+
+	probar := func(email string) int {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("POST", "/api/borradores", nil)
+		if permitirEnlaceBorrador(c, context.Background(), email) {
+			return http.StatusOK
+		}
+		return w.Code
+	}
+
+	assert.Equal(t, http.StatusOK, probar("cliente@example.com"))
+	assert.Equal(t, http.StatusTooManyRequests, probar(" Cliente@Example.com "), "El límite es por email, sin distinguir mayúsculas")
+	assert.Equal(t, http.StatusOK, probar("otro@example.com"))
+}
+
+func TestSha256ArchivoReporte(t *testing.T) {
+	suma, err := sha256Archivo(strings.NewReader("abc"))
+	assert.NoError(t, err)
+	assert.Equal(t, "ba7816bf8f01cfea414140de5dae2223b00361a396177a9cb410ff61f20015ad", suma)
+}
+
+func TestRechazarPorDocumento(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	p := politicaAntispam{
+		endpoint:        "MENSAJE",
+		maxPorDocumento: 2,
+		ventanaDoc:      time.Hour,
+		contarPorDoc: func(ctx context.Context, doc string, ventana time.Duration) (int, error) {
+			if doc == "12345678" {
+				return 2, nil
+			}
+			return 1, nil
+		},
+	}
+
+	probar := func(doc string) (bool, int) {
+		w := httptest.NewRecorder()
+		c, _ := gin.CreateTestContext(w)
+		c.Request = httptest.NewRequest("POST", "/api/seguimiento/CODEPLEX-2026-00001/mensaje", nil)
+		return p.rechazarPorDocumento(c, context.Background(), doc), w.Code
+	}
+
+	rechazado, _ := probar("87654321")
+	assert.False(t, rechazado)
+	rechazado, _ = probar("")
+	assert.False(t, rechazado, "Sin documento no hay límite que aplicar")
+	rechazado, code := probar("12345678")
+	assert.True(t, rechazado)
+	assert.Equal(t, http.StatusTooManyRequests, code)
+}
+
 // =============================================================================
 // TEST RUNNER PRINCIPAL
 // =============================================================================
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// =============================================================================
+// AVISO AL CONSUMIDOR DE MENSAJES DE LA EMPRESA
+// Cada mensaje EMPRESA programa un envío al cabo de la ventana configurada;
+// los mensajes que llegan mientras tanto salen en el mismo email. El reclamo
+// de los mensajes (UPDATE ... RETURNING) hace que cada uno se notifique una
+// sola vez aunque haya varias réplicas.
+// =============================================================================
+
+type agendaNotificaciones struct {
+	mu         sync.Mutex
+	pendientes map[string]*time.Timer
+}
+
+var notificacionesMensajes = &agendaNotificaciones{pendientes: make(map[string]*time.Timer)}
+
+func ventanaNotificacionMensajes() time.Duration {
+	return time.Duration(config.NotificacionMensajesMinutos) * time.Minute
+}
+
+// programar agenda el aviso del reclamo si no hay uno pendiente. El temporizador
+// no se reinicia con cada mensaje, así el primer aviso nunca se retrasa más
+// que la ventana.
+func (a *agendaNotificaciones) programar(reclamoID string, ventana time.Duration, enviar func(reclamoID string)) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if _, ok := a.pendientes[reclamoID]; ok {
+		return
+	}
+	a.pendientes[reclamoID] = time.AfterFunc(ventana, func() {
+		a.mu.Lock()
+		delete(a.pendientes, reclamoID)
+		a.mu.Unlock()
+		enviar(reclamoID)
+	})
+}
+
+// programarAvisoMensajeEmpresa se llama tras insertar un mensaje EMPRESA.
+func programarAvisoMensajeEmpresa(reclamoID string) {
+	notificacionesMensajes.programar(reclamoID, ventanaNotificacionMensajes(), func(id string) {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		notificarMensajesEmpresa(ctx, id)
+	})
+}
+
+type mensajeNotificado struct {
+	Mensaje string
+	Fecha   time.Time
+}
+
+// notificarMensajesEmpresa envía en un solo email los mensajes EMPRESA del
+// reclamo aún no notificados. Los que el consumidor ya leyó (estaba conectado)
+// se dan por notificados sin email.
+func notificarMensajesEmpresa(ctx context.Context, reclamoID string) {
+	var codigo, nombre, email, tipo string
+	var aceptaCopia bool
+	err := pool.QueryRow(ctx, `
+		SELECT codigo_reclamo, nombre_completo, email, tipo_solicitud, COALESCE(acepta_copia, true)
+		FROM reclamos WHERE id = $1
+	`, reclamoID).Scan(&codigo, &nombre, &email, &tipo, &aceptaCopia)
+	if err != nil {
+		log.Printf("⚠️ Error obteniendo reclamo %s para notificar mensajes: %v", reclamoID, err)
+		return
+	}
+
+	rows, err := pool.Query(ctx, `
+		UPDATE mensajes_seguimiento
+		SET fecha_notificacion = NOW()
+		WHERE reclamo_id = $1 AND tipo_mensaje = 'EMPRESA' AND fecha_notificacion IS NULL
+		RETURNING id, mensaje, fecha_mensaje, COALESCE(leido, false)
+	`, reclamoID)
+	if err != nil {
+		log.Printf("❌ Error reclamando mensajes a notificar de %s: %v", codigo, err)
+		return
+	}
+	defer rows.Close()
+
+	var mensajes []mensajeNotificado
+	var ids []string
+	for rows.Next() {
+		var m mensajeNotificado
+		var id string
+		var leido bool
+		if err := rows.Scan(&id, &m.Mensaje, &m.Fecha, &leido); err == nil && !leido {
+			mensajes = append(mensajes, m)
+			ids = append(ids, id)
+		}
+	}
+	if err := rows.Err(); err != nil {
+		log.Printf("❌ Error leyendo mensajes a notificar de %s: %v", codigo, err)
+		return
+	}
+
+	if len(mensajes) == 0 {
+		return
+	}
+	if !aceptaCopia {
+		log.Printf("ℹ️ %s: el consumidor no acepta copias por email, %d mensaje(s) sin aviso", codigo, len(mensajes))
+		return
+	}
+
+	asunto := fmt.Sprintf("Nueva respuesta a su %s %s", strings.ToLower(tipo), codigo)
+	if len(mensajes) > 1 {
+		asunto = fmt.Sprintf("%d nuevos mensajes sobre su %s %s", len(mensajes), strings.ToLower(tipo), codigo)
+	}
+
+	if err := enviarEmailHTMLReclamo(email, asunto, generarEmailMensajesEmpresa(codigo, nombre, mensajes), codigo); err != nil {
+		// Se libera para que el barrido periódico lo reintente
+		log.Printf("❌ Error enviando aviso de mensajes de %s: %v", codigo, err)
+		pool.Exec(ctx, "UPDATE mensajes_seguimiento SET fecha_notificacion = NULL WHERE id::text = ANY($1)", ids)
+		return
+	}
+	log.Printf("✅ Aviso de %d mensaje(s) enviado al consumidor de %s", len(mensajes), codigo)
+}
+
+func generarEmailMensajesEmpresa(codigo, nombre string, mensajes []mensajeNotificado) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, `<p style="margin: 0 0 12px 0; color: #1f2937;">Estimado/a %s,</p>
+<p style="margin: 0 0 16px 0;">Tiene novedades sobre su caso <strong>%s</strong>:</p>`,
+		html.EscapeString(nombre), html.EscapeString(codigo))
+
+	for _, m := range mensajes {
+		fmt.Fprintf(&b, `<div style="margin: 0 0 12px 0; padding: 12px 15px; background-color: #f9fafb; border-left: 4px solid #7c3aed; border-radius: 0 4px 4px 0;">
+<p style="margin: 0 0 6px 0; color: #6b7280; font-size: 12px;">%s</p>
+<div style="white-space: pre-wrap; color: #1f2937; line-height: 1.6; word-break: break-word;">%s</div>
+</div>`, m.Fecha.Format("02/01/2006 15:04"), html.EscapeString(m.Mensaje))
+	}
+
+	return generarEmailAviso("💬 Mensaje de CODEPLEX", "#7c3aed", b.String(),
+		"Ver y responder", enlaceSeguimientoFirmado(codigo),
+		"Recibe este aviso porque aceptó copias por correo al registrar su reclamo.")
+}
+
+// reintentarAvisosPendientes cubre los avisos perdidos por un reinicio o un
+// fallo SMTP: mensajes de las últimas 24h sin notificar y fuera de ventana.
+func reintentarAvisosPendientes(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Minute)
+	defer cancel()
+
+	rows, err := pool.Query(ctx, `
+		SELECT DISTINCT reclamo_id FROM mensajes_seguimiento
+		WHERE tipo_mensaje = 'EMPRESA' AND fecha_notificacion IS NULL
+		  AND fecha_mensaje > NOW() - INTERVAL '1 day'
+		  AND fecha_mensaje < NOW() - ($1::int * INTERVAL '1 minute')
+	`, config.NotificacionMensajesMinutos)
+	if err != nil {
+		log.Printf("⚠️ Error buscando avisos de mensajes pendientes: %v", err)
+		return
+	}
+	var ids []string
+	for rows.Next() {
+		var id string
+		if rows.Scan(&id) == nil {
+			ids = append(ids, id)
+		}
+	}
+	rows.Close()
+
+	for _, id := range ids {
+		notificarMensajesEmpresa(ctx, id)
+	}
+}
@@ -0,0 +1,209 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+)
+
+// =============================================================================
+// GENERADOR PDF MÍNIMO
+// Solo texto, fuentes estándar Helvetica y tamaño A4: suficiente para la hoja
+// de reclamación y los reportes, sin depender de librerías externas.
+// =============================================================================
+
+const (
+	pdfAnchoPagina = 595.0
+	pdfAltoPagina  = 842.0
+	pdfMargen      = 50.0
+	pdfTamañoTexto = 10.0
+	pdfInterlinea  = 14.0
+	pdfMaxCaracter = 95
+)
+
+type lineaPDF struct {
+	texto   string
+	negrita bool
+	tamaño  float64
+}
+
+type documentoPDF struct {
+	paginas [][]lineaPDF
+	y       float64
+}
+
+func nuevoPDF() *documentoPDF {
+	d := &documentoPDF{}
+	d.nuevaPagina()
+	return d
+}
+
+func (d *documentoPDF) nuevaPagina() {
+	d.paginas = append(d.paginas, nil)
+	d.y = pdfAltoPagina - pdfMargen
+}
+
+func (d *documentoPDF) agregar(l lineaPDF) {
+	alto := pdfInterlinea
+	if l.tamaño > pdfTamañoTexto {
+		alto = l.tamaño + 6
+	}
+	if d.y-alto < pdfMargen {
+		d.nuevaPagina()
+	}
+	d.y -= alto
+	i := len(d.paginas) - 1
+	d.paginas[i] = append(d.paginas[i], l)
+}
+
+// Titulo agrega un encabezado en negrita.
+func (d *documentoPDF) Titulo(texto string) {
+	d.agregar(lineaPDF{texto: texto, negrita: true, tamaño: 14})
+}
+
+// Seccion agrega un subtítulo en negrita al tamaño normal.
+func (d *documentoPDF) Seccion(texto string) {
+	d.Espacio()
+	d.agregar(lineaPDF{texto: texto, negrita: true, tamaño: pdfTamañoTexto})
+}
+
+// Texto agrega un párrafo, partiéndolo en líneas que entran en la página.
+func (d *documentoPDF) Texto(texto string) {
+	for _, parrafo := range strings.Split(texto, "\n") {
+		for _, linea := range partirLineas(parrafo, pdfMaxCaracter) {
+			d.agregar(lineaPDF{texto: linea, tamaño: pdfTamañoTexto})
+		}
+	}
+}
+
+// Campo agrega "Etiqueta: valor".
+func (d *documentoPDF) Campo(etiqueta, valor string) {
+	if valor == "" {
+		valor = "-"
+	}
+	d.Texto(etiqueta + ": " + valor)
+}
+
+func (d *documentoPDF) Espacio() {
+	d.agregar(lineaPDF{tamaño: pdfTamañoTexto})
+}
+
+func partirLineas(texto string, max int) []string {
+	palabras := strings.Fields(texto)
+	if len(palabras) == 0 {
+		return []string{""}
+	}
+
+	var lineas []string
+	actual := ""
+	for _, p := range palabras {
+		for len([]rune(p)) > max {
+			r := []rune(p)
+			if actual != "" {
+				lineas = append(lineas, actual)
+				actual = ""
+			}
+			lineas = append(lineas, string(r[:max]))
+			p = string(r[max:])
+		}
+		if actual == "" {
+			actual = p
+		} else if len([]rune(actual))+1+len([]rune(p)) <= max {
+			actual += " " + p
+		} else {
+			lineas = append(lineas, actual)
+			actual = p
+		}
+	}
+	return append(lineas, actual)
+}
+
+// Bytes serializa el documento a PDF 1.4.
+func (d *documentoPDF) Bytes() []byte {
+	var buf bytes.Buffer
+	var offsets []int
+
+	obj := func(contenido string) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", len(offsets), contenido)
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	// 1: catálogo, 2: árbol de páginas, 3-4: fuentes, luego página + contenido
+	n := len(d.paginas)
+	kids := make([]string, n)
+	for i := range d.paginas {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+
+	obj("<< /Type /Catalog /Pages 2 0 R >>")
+	obj(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), n))
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>")
+	obj("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>")
+
+	for i, lineas := range d.paginas {
+		obj(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>",
+			pdfAnchoPagina, pdfAltoPagina, 6+2*i))
+
+		var contenido bytes.Buffer
+		y := pdfAltoPagina - pdfMargen
+		for _, l := range lineas {
+			alto := pdfInterlinea
+			if l.tamaño > pdfTamañoTexto {
+				alto = l.tamaño + 6
+			}
+			y -= alto
+			if l.texto == "" {
+				continue
+			}
+			fuente := "F1"
+			if l.negrita {
+				fuente = "F2"
+			}
+			fmt.Fprintf(&contenido, "BT /%s %.1f Tf %.1f %.1f Td (%s) Tj ET\n", fuente, l.tamaño, pdfMargen, y, escaparTextoPDF(l.texto))
+		}
+		fmt.Fprintf(&contenido, "BT /F1 8 Tf %.1f %.1f Td (Pagina %d de %d) Tj ET\n", pdfAnchoPagina-pdfMargen-60, pdfMargen/2, i+1, n)
+
+		obj(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", contenido.Len(), contenido.String()))
+	}
+
+	inicioXref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, inicioXref)
+
+	return buf.Bytes()
+}
+
+// escaparTextoPDF convierte a WinAnsi (cubre tildes y ñ) y escapa los
+// caracteres especiales de los strings PDF.
+func escaparTextoPDF(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '\t':
+			b.WriteString("    ")
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			b.WriteByte(byte(r))
+		case r == '€':
+			b.WriteByte(0x80)
+		case r == '–' || r == '—':
+			b.WriteByte('-')
+		case r == '“' || r == '”':
+			b.WriteByte('"')
+		case r == '‘' || r == '’':
+			b.WriteByte('\'')
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
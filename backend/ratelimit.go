@@ -0,0 +1,338 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// =============================================================================
+// RATE LIMITING (TOKEN BUCKET)
+// Cada política define una capacidad (ráfaga) que se repone completa en un
+// periodo, y cómo se arma la clave: IP, usuario autenticado o parámetro de ruta.
+// El estado vive en memoria (una réplica) o en Postgres (varias réplicas).
+// =============================================================================
+
+type PoliticaRateLimit struct {
+	Nombre    string
+	Capacidad int
+	Periodo   time.Duration
+	Clave     func(c *gin.Context) string
+}
+
+// tasa devuelve los tokens que se reponen por segundo.
+func (p PoliticaRateLimit) tasa() float64 {
+	return float64(p.Capacidad) / p.Periodo.Seconds()
+}
+
+type ResultadoRateLimit struct {
+	Permitido  bool
+	Restantes  int
+	Reintentar time.Duration // solo si no se permitió
+	Reinicio   time.Duration // hasta que el bucket vuelva a estar lleno
+}
+
+// AlmacenRateLimit guarda los buckets. Consumir debe ser atómico por clave;
+// Consultar indica si habría un token disponible sin tomarlo.
+type AlmacenRateLimit interface {
+	Consumir(ctx context.Context, clave string, p PoliticaRateLimit, ahora time.Time) (ResultadoRateLimit, error)
+	Consultar(ctx context.Context, clave string, p PoliticaRateLimit, ahora time.Time) (ResultadoRateLimit, error)
+}
+
+var limitador AlmacenRateLimit
+
+type bucketRateLimit struct {
+	tokens      float64
+	actualizado time.Time
+}
+
+// consumirToken repone el bucket según el tiempo transcurrido y toma un token
+// si hay disponible. Es la lógica común a todos los almacenes.
+func consumirToken(b *bucketRateLimit, p PoliticaRateLimit, ahora time.Time) ResultadoRateLimit {
+	capacidad := float64(p.Capacidad)
+	if b.actualizado.IsZero() {
+		b.tokens = capacidad
+	} else if transcurrido := ahora.Sub(b.actualizado).Seconds(); transcurrido > 0 {
+		b.tokens = math.Min(capacidad, b.tokens+transcurrido*p.tasa())
+	}
+	b.actualizado = ahora
+
+	res := ResultadoRateLimit{}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Permitido = true
+	} else {
+		res.Reintentar = segundos((1 - b.tokens) / p.tasa())
+	}
+	res.Restantes = int(math.Floor(b.tokens))
+	res.Reinicio = segundos((capacidad - b.tokens) / p.tasa())
+	return res
+}
+
+func segundos(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// =============================================================================
+// ALMACÉN EN MEMORIA
+// =============================================================================
+
+type almacenMemoria struct {
+	mu           sync.Mutex
+	buckets      map[string]*bucketRateLimit
+	ultimaLimpia time.Time
+}
+
+func nuevoAlmacenMemoria() *almacenMemoria {
+	return &almacenMemoria{buckets: make(map[string]*bucketRateLimit)}
+}
+
+func (a *almacenMemoria) Consumir(_ context.Context, clave string, p PoliticaRateLimit, ahora time.Time) (ResultadoRateLimit, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	// Los buckets inactivos por más de una hora ya estarían llenos: se descartan
+	if ahora.Sub(a.ultimaLimpia) > 10*time.Minute {
+		for k, b := range a.buckets {
+			if ahora.Sub(b.actualizado) > time.Hour {
+				delete(a.buckets, k)
+			}
+		}
+		a.ultimaLimpia = ahora
+	}
+
+	b, ok := a.buckets[clave]
+	if !ok {
+		b = &bucketRateLimit{}
+		a.buckets[clave] = b
+	}
+	return consumirToken(b, p, ahora), nil
+}
+
+func (a *almacenMemoria) Consultar(_ context.Context, clave string, p PoliticaRateLimit, ahora time.Time) (ResultadoRateLimit, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	var copia bucketRateLimit
+	if b, ok := a.buckets[clave]; ok {
+		copia = *b
+	}
+	return consumirToken(&copia, p, ahora), nil
+}
+
+// =============================================================================
+// ALMACÉN EN POSTGRES (multi-réplica)
+// =============================================================================
+
+type almacenPostgres struct {
+	pool         *pgxpool.Pool
+	mu           sync.Mutex
+	ultimaLimpia time.Time
+}
+
+func (a *almacenPostgres) Consumir(ctx context.Context, clave string, p PoliticaRateLimit, ahora time.Time) (ResultadoRateLimit, error) {
+	a.limpiarInactivos(ahora)
+
+	tx, err := a.pool.Begin(ctx)
+	if err != nil {
+		return ResultadoRateLimit{}, err
+	}
+	defer tx.Rollback(ctx)
+
+	// Garantiza que la fila exista para poder bloquearla
+	_, err = tx.Exec(ctx, `
+		INSERT INTO rate_limit_buckets (clave, tokens, actualizado)
+		VALUES ($1, $2, $3)
+		ON CONFLICT (clave) DO NOTHING
+	`, clave, float64(p.Capacidad), ahora)
+	if err != nil {
+		return ResultadoRateLimit{}, err
+	}
+
+	var b bucketRateLimit
+	err = tx.QueryRow(ctx, `
+		SELECT tokens, actualizado FROM rate_limit_buckets WHERE clave = $1 FOR UPDATE
+	`, clave).Scan(&b.tokens, &b.actualizado)
+	if err != nil {
+		return ResultadoRateLimit{}, err
+	}
+
+	res := consumirToken(&b, p, ahora)
+
+	_, err = tx.Exec(ctx, `
+		UPDATE rate_limit_buckets SET tokens = $2, actualizado = $3 WHERE clave = $1
+	`, clave, b.tokens, b.actualizado)
+	if err != nil {
+		return ResultadoRateLimit{}, err
+	}
+
+	return res, tx.Commit(ctx)
+}
+
+func (a *almacenPostgres) Consultar(ctx context.Context, clave string, p PoliticaRateLimit, ahora time.Time) (ResultadoRateLimit, error) {
+	var b bucketRateLimit
+	err := a.pool.QueryRow(ctx, `
+		SELECT tokens, actualizado FROM rate_limit_buckets WHERE clave = $1
+	`, clave).Scan(&b.tokens, &b.actualizado)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return ResultadoRateLimit{}, err
+	}
+	return consumirToken(&b, p, ahora), nil
+}
+
+func (a *almacenPostgres) limpiarInactivos(ahora time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if ahora.Sub(a.ultimaLimpia) < 10*time.Minute {
+		return
+	}
+	a.ultimaLimpia = ahora
+
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if _, err := a.pool.Exec(ctx, "DELETE FROM rate_limit_buckets WHERE actualizado < $1", ahora.Add(-time.Hour)); err != nil {
+			log.Printf("⚠️ Error limpiando buckets de rate limit: %v", err)
+		}
+	}()
+}
+
+func nuevoAlmacenRateLimit(backend string) AlmacenRateLimit {
+	switch strings.ToLower(backend) {
+	case "postgres":
+		return &almacenPostgres{pool: pool}
+	case "", "memoria":
+		return nuevoAlmacenMemoria()
+	default:
+		log.Printf("⚠️ RATE_LIMIT_BACKEND desconocido: %q (usando memoria)", backend)
+		return nuevoAlmacenMemoria()
+	}
+}
+
+// =============================================================================
+// CLAVES
+// =============================================================================
+
+func clavePorIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// clavePorUsuario usa el user_id del JWT; sin sesión cae a la IP.
+func clavePorUsuario(c *gin.Context) string {
+	if userID, ok := c.Get("user_id"); ok && userID != nil {
+		return fmt.Sprintf("usuario:%v", userID)
+	}
+	return clavePorIP(c)
+}
+
+func clavePorParametro(nombre string) func(c *gin.Context) string {
+	return func(c *gin.Context) string {
+		return nombre + ":" + c.Param(nombre)
+	}
+}
+
+// =============================================================================
+// MIDDLEWARE
+// =============================================================================
+
+func rateLimitMiddleware(p PoliticaRateLimit) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if limitador == nil || p.Capacidad <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), 2*time.Second)
+		defer cancel()
+
+		res, err := limitador.Consumir(ctx, p.Nombre+"|"+p.Clave(c), p, time.Now())
+		if err != nil {
+			// Si el almacén falla preferimos atender antes que bloquear a todos
+			log.Printf("⚠️ Error en rate limit %s: %v", p.Nombre, err)
+			c.Next()
+			return
+		}
+
+		escribirCabecerasRateLimit(c, p, res)
+
+		if !res.Permitido {
+			c.Header("Retry-After", strconv.Itoa(int(math.Ceil(res.Reintentar.Seconds()))))
+			c.JSON(http.StatusTooManyRequests, gin.H{"success": false, "message": "Demasiadas solicitudes, intente más tarde"})
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// X-RateLimit-Reset va en segundos hasta que el bucket esté lleno otra vez.
+func escribirCabecerasRateLimit(c *gin.Context, p PoliticaRateLimit, res ResultadoRateLimit) {
+	c.Header("X-RateLimit-Limit", strconv.Itoa(p.Capacidad))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(res.Restantes))
+	c.Header("X-RateLimit-Reset", strconv.Itoa(int(math.Ceil(res.Reinicio.Seconds()))))
+}
+
+// =============================================================================
+// POLÍTICAS POR DEFECTO
+// Se pueden ajustar con RATE_LIMIT_<NOMBRE>=capacidad/periodo, p.ej.
+// RATE_LIMIT_LOGIN=10/5m. Capacidad 0 desactiva la política.
+// =============================================================================
+
+type politicasRateLimit struct {
+	Login             PoliticaRateLimit
+	Reclamos          PoliticaRateLimit
+	Seguimiento       PoliticaRateLimit
+	SeguimientoCodigo PoliticaRateLimit
+	Mensajes          PoliticaRateLimit
+	MensajesAdmin     PoliticaRateLimit
+	ConsultasFallidas PoliticaRateLimit
+	AccesoPortal      PoliticaRateLimit
+	Borradores        PoliticaRateLimit
+	EnlacesBorrador   PoliticaRateLimit
+}
+
+func politicasRateLimitPorDefecto() politicasRateLimit {
+	return politicasRateLimit{
+		Login:             politicaDesdeEnv("LOGIN", 5, time.Minute, clavePorIP),
+		Reclamos:          politicaDesdeEnv("RECLAMOS", 5, 10*time.Minute, clavePorIP),
+		Seguimiento:       politicaDesdeEnv("SEGUIMIENTO", 30, time.Minute, clavePorIP),
+		SeguimientoCodigo: politicaDesdeEnv("SEGUIMIENTO_CODIGO", 10, time.Minute, clavePorParametro("codigo")),
+		Mensajes:          politicaDesdeEnv("MENSAJES", 10, time.Minute, clavePorIP),
+		MensajesAdmin:     politicaDesdeEnv("MENSAJES_ADMIN", 30, time.Minute, clavePorUsuario),
+		ConsultasFallidas: politicaDesdeEnv("CONSULTAS_FALLIDAS", 10, 15*time.Minute, clavePorIP),
+		AccesoPortal:      politicaDesdeEnv("ACCESO_PORTAL", 5, 15*time.Minute, clavePorIP),
+		Borradores:        politicaDesdeEnv("BORRADORES", 20, 10*time.Minute, clavePorIP),
+		// Por email: la consume el handler al enviar el enlace, no el middleware
+		EnlacesBorrador: politicaDesdeEnv("ENLACES_BORRADOR", 3, time.Hour, nil),
+	}
+}
+
+func politicaDesdeEnv(nombre string, capacidad int, periodo time.Duration, clave func(c *gin.Context) string) PoliticaRateLimit {
+	p := PoliticaRateLimit{Nombre: strings.ToLower(nombre), Capacidad: capacidad, Periodo: periodo, Clave: clave}
+
+	valor := os.Getenv("RATE_LIMIT_" + nombre)
+	if valor == "" {
+		return p
+	}
+	capacidadStr, periodoStr, ok := strings.Cut(valor, "/")
+	n, errN := strconv.Atoi(capacidadStr)
+	d, errD := time.ParseDuration(periodoStr)
+	if !ok || errN != nil || errD != nil || d <= 0 {
+		log.Printf("⚠️ RATE_LIMIT_%s inválido: %q (usando %d/%s)", nombre, valor, capacidad, periodo)
+		return p
+	}
+	p.Capacidad, p.Periodo = n, d
+	return p
+}
@@ -0,0 +1,447 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// =============================================================================
+// EVENTOS EN TIEMPO REAL POR RECLAMO
+// Mensajes nuevos, cambios de estado y "escribiendo..." se publican en un bus
+// y llegan por SSE al panel y al consumidor. En memoria basta con una réplica;
+// con varias, REALTIME_BACKEND=postgres reparte los eventos con LISTEN/NOTIFY.
+// Los eventos internos (asignación, triaje, aprobaciones...) solo llegan al
+// panel: el stream del consumidor los descarta.
+// =============================================================================
+
+const (
+	eventoMensaje     = "mensaje"
+	eventoEstado      = "estado"
+	eventoEscribiendo = "escribiendo"
+)
+
+type EventoReclamo struct {
+	ReclamoID string    `json:"reclamo_id"`
+	Tipo      string    `json:"tipo"`
+	Datos     gin.H     `json:"datos"`
+	Fecha     time.Time `json:"fecha"`
+	Interno   bool      `json:"interno,omitempty"`
+}
+
+// BusEventos reparte eventos a los suscriptores de cada reclamo. El canal de
+// Suscribir se cierra al llamar la función de baja o al apagarse el bus.
+type BusEventos interface {
+	Publicar(ctx context.Context, evento EventoReclamo) error
+	Suscribir(reclamoID string) (<-chan EventoReclamo, func())
+}
+
+var bus BusEventos
+
+// publicarEvento es best-effort: un fallo del bus nunca debe romper la
+// operación que lo originó. Lo reciben el panel y el consumidor, así que datos
+// no debe llevar nada interno.
+func publicarEvento(reclamoID, tipo string, datos gin.H) {
+	publicar(EventoReclamo{ReclamoID: reclamoID, Tipo: tipo, Datos: datos, Fecha: time.Now()})
+}
+
+// publicarEventoInterno publica un evento que solo ve el panel.
+func publicarEventoInterno(reclamoID, tipo string, datos gin.H) {
+	publicar(EventoReclamo{ReclamoID: reclamoID, Tipo: tipo, Datos: datos, Fecha: time.Now(), Interno: true})
+}
+
+func publicar(evento EventoReclamo) {
+	if bus == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+
+	tipo, reclamoID := evento.Tipo, evento.ReclamoID
+	if err := bus.Publicar(ctx, evento); err != nil {
+		log.Printf("⚠️ Error publicando evento %s de %s: %v", tipo, reclamoID, err)
+	}
+}
+
+func datosEventoMensaje(id, tipo, mensaje, nombreArchivo string, fecha time.Time) gin.H {
+	datos := gin.H{"id": id, "tipo_mensaje": tipo, "mensaje": mensaje, "fecha_mensaje": fecha}
+	if nombreArchivo != "" {
+		datos["nombre_archivo"] = nombreArchivo
+	}
+	return datos
+}
+
+// =============================================================================
+// BUS EN MEMORIA
+// =============================================================================
+
+type busMemoria struct {
+	mu           sync.Mutex
+	suscriptores map[string]map[chan EventoReclamo]struct{}
+	cerrado      bool
+}
+
+func nuevoBusMemoria(ctx context.Context) *busMemoria {
+	b := &busMemoria{suscriptores: make(map[string]map[chan EventoReclamo]struct{})}
+	go func() {
+		<-ctx.Done()
+		b.cerrar()
+	}()
+	return b
+}
+
+func (b *busMemoria) Publicar(_ context.Context, evento EventoReclamo) error {
+	b.repartir(evento)
+	return nil
+}
+
+// repartir no bloquea: si un suscriptor lento tiene el buffer lleno, pierde
+// el evento (el cliente recupera el estado completo al reconectar).
+func (b *busMemoria) repartir(evento EventoReclamo) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for ch := range b.suscriptores[evento.ReclamoID] {
+		select {
+		case ch <- evento:
+		default:
+		}
+	}
+}
+
+func (b *busMemoria) Suscribir(reclamoID string) (<-chan EventoReclamo, func()) {
+	ch := make(chan EventoReclamo, 16)
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.cerrado {
+		close(ch)
+		return ch, func() {}
+	}
+	if b.suscriptores[reclamoID] == nil {
+		b.suscriptores[reclamoID] = make(map[chan EventoReclamo]struct{})
+	}
+	b.suscriptores[reclamoID][ch] = struct{}{}
+
+	var once sync.Once
+	baja := func() {
+		once.Do(func() {
+			b.mu.Lock()
+			defer b.mu.Unlock()
+			if _, ok := b.suscriptores[reclamoID][ch]; !ok {
+				return // ya cerrado por cerrar()
+			}
+			delete(b.suscriptores[reclamoID], ch)
+			if len(b.suscriptores[reclamoID]) == 0 {
+				delete(b.suscriptores, reclamoID)
+			}
+			close(ch)
+		})
+	}
+	return ch, baja
+}
+
+func (b *busMemoria) cerrar() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.cerrado = true
+	for _, subs := range b.suscriptores {
+		for ch := range subs {
+			close(ch)
+		}
+	}
+	b.suscriptores = make(map[string]map[chan EventoReclamo]struct{})
+}
+
+// =============================================================================
+// BUS POSTGRES (LISTEN/NOTIFY)
+// Publicar solo hace NOTIFY; cada réplica (incluida la que publica) recibe el
+// evento por su conexión LISTEN y lo reparte a sus suscriptores locales.
+// =============================================================================
+
+const canalEventosReclamos = "reclamos_eventos"
+
+type busPostgres struct {
+	local *busMemoria
+}
+
+func nuevoBusPostgres(ctx context.Context) *busPostgres {
+	b := &busPostgres{local: nuevoBusMemoria(ctx)}
+	go b.escuchar(ctx)
+	return b
+}
+
+func (b *busPostgres) Publicar(ctx context.Context, evento EventoReclamo) error {
+	payload, err := json.Marshal(evento)
+	if err != nil {
+		return err
+	}
+	// NOTIFY admite hasta 8000 bytes; los mensajes largos viajan sin el texto
+	if len(payload) > 7900 {
+		evento.Datos = gin.H{"id": evento.Datos["id"], "truncado": true}
+		payload, _ = json.Marshal(evento)
+	}
+	_, err = pool.Exec(ctx, "SELECT pg_notify($1, $2)", canalEventosReclamos, string(payload))
+	return err
+}
+
+func (b *busPostgres) Suscribir(reclamoID string) (<-chan EventoReclamo, func()) {
+	return b.local.Suscribir(reclamoID)
+}
+
+// escuchar mantiene una conexión dedicada con LISTEN y se reconecta si cae.
+func (b *busPostgres) escuchar(ctx context.Context) {
+	for {
+		if err := b.escucharConexion(ctx); err != nil && ctx.Err() == nil {
+			log.Printf("⚠️ Conexión LISTEN perdida, reintentando: %v", err)
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(5 * time.Second):
+		}
+	}
+}
+
+func (b *busPostgres) escucharConexion(ctx context.Context) error {
+	conn, err := pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	// La conexión queda en estado LISTEN: no se devuelve al pool
+	defer conn.Conn().Close(context.Background())
+	defer conn.Hijack()
+
+	if _, err := conn.Exec(ctx, "LISTEN "+canalEventosReclamos); err != nil {
+		return err
+	}
+	log.Printf("✅ Escuchando eventos en tiempo real (%s)", canalEventosReclamos)
+
+	for {
+		n, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			return err
+		}
+		var evento EventoReclamo
+		if err := json.Unmarshal([]byte(n.Payload), &evento); err != nil {
+			log.Printf("⚠️ Evento inválido en %s: %v", canalEventosReclamos, err)
+			continue
+		}
+		b.local.repartir(evento)
+	}
+}
+
+func nuevoBusEventos(ctx context.Context, backend string) BusEventos {
+	if backend == "postgres" {
+		log.Println("✅ Eventos en tiempo real: Postgres LISTEN/NOTIFY")
+		return nuevoBusPostgres(ctx)
+	}
+	return nuevoBusMemoria(ctx)
+}
+
+// =============================================================================
+// STREAM SSE
+// =============================================================================
+
+const intervaloHeartbeatSSE = 25 * time.Second
+
+// transmitirEventos mantiene abierta la respuesta SSE hasta que el cliente se
+// desconecte o el servidor se apague. Sin internos se descartan los eventos
+// que solo son para el panel.
+func transmitirEventos(c *gin.Context, reclamoID string, internos bool) {
+	eventos, baja := bus.Suscribir(reclamoID)
+	defer baja()
+
+	// El WriteTimeout del servidor cortaría el stream a los 15s
+	if err := http.NewResponseController(c.Writer).SetWriteDeadline(time.Time{}); err != nil {
+		log.Printf("⚠️ No se pudo quitar el timeout de escritura del SSE: %v", err)
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	fmt.Fprintf(c.Writer, "retry: 3000\nevent: conectado\ndata: {\"reclamo_id\":%q}\n\n", reclamoID)
+	c.Writer.Flush()
+
+	heartbeat := time.NewTicker(intervaloHeartbeatSSE)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(c.Writer, ": ping\n\n")
+		case evento, ok := <-eventos:
+			if !ok {
+				return
+			}
+			if evento.Interno && !internos {
+				continue
+			}
+			datos, err := json.Marshal(evento)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", evento.Tipo, datos)
+		}
+		c.Writer.Flush()
+	}
+}
+
+// EventSource no envía cabeceras: el cliente pide primero un token corto con
+// su sesión o documento y abre el stream con ?token=
+const duracionTokenEventos = 2 * time.Minute
+
+// POST /api/seguimiento/:codigo/eventos/token - Token corto para abrir el stream
+func tokenEventosSeguimientoHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	codigo := c.Param("codigo")
+	if !autorizarConsultaPublica(c, ctx, codigo, alcanceReclamo) {
+		return
+	}
+
+	token, err := firmarTokenAcceso(codigo, alcanceEventos, duracionTokenEventos)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error generando el token"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"token": token, "expira_en": int(duracionTokenEventos.Seconds())}})
+}
+
+// GET /api/seguimiento/:codigo/eventos - Stream SSE para el consumidor
+func eventosSeguimientoHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	codigo := c.Param("codigo")
+	if !autorizarConsultaPublica(c, ctx, codigo, alcanceEventos) {
+		return
+	}
+
+	var reclamoID string
+	if err := pool.QueryRow(ctx, "SELECT id FROM reclamos WHERE codigo_reclamo = $1", codigo).Scan(&reclamoID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Reclamo no encontrado"})
+		return
+	}
+
+	transmitirEventos(c, reclamoID, false)
+}
+
+type claimsEventosAdmin struct {
+	ReclamoID string `json:"reclamo_id"`
+	UserID    string `json:"user_id"`
+	Email     string `json:"email"`
+	Rol       string `json:"rol"`
+	jwt.RegisteredClaims
+}
+
+// claveTokensEventos es distinta a la de los JWT del panel: el token del
+// stream no sirve como Bearer.
+func claveTokensEventos() []byte {
+	return []byte(jwtSecret + "|eventos")
+}
+
+// POST /api/admin/reclamos/:id/eventos/token - Token corto para abrir el stream
+func tokenEventosAdminHandler(c *gin.Context) {
+	ahora := time.Now()
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claimsEventosAdmin{
+		ReclamoID: c.Param("id"),
+		UserID:    fmt.Sprintf("%v", c.MustGet("user_id")),
+		Email:     fmt.Sprintf("%v", c.MustGet("email")),
+		Rol:       fmt.Sprintf("%v", c.MustGet("rol")),
+		RegisteredClaims: jwt.RegisteredClaims{
+			IssuedAt:  jwt.NewNumericDate(ahora),
+			ExpiresAt: jwt.NewNumericDate(ahora.Add(duracionTokenEventos)),
+		},
+	}).SignedString(claveTokensEventos())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error generando el token"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": gin.H{"token": token, "expira_en": int(duracionTokenEventos.Seconds())}})
+}
+
+// authEventosAdminMiddleware acepta ?token= del stream (solo para ese reclamo)
+// o, si no viene, la cabecera Authorization de siempre.
+func authEventosAdminMiddleware() gin.HandlerFunc {
+	auth := authMiddleware()
+	return func(c *gin.Context) {
+		tokenString := c.Query("token")
+		if tokenString == "" {
+			auth(c)
+			return
+		}
+
+		var claims claimsEventosAdmin
+		token, err := jwt.ParseWithClaims(tokenString, &claims, func(t *jwt.Token) (interface{}, error) {
+			return claveTokensEventos(), nil
+		}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}), jwt.WithExpirationRequired())
+		if err != nil || !token.Valid || claims.ReclamoID != c.Param("id") {
+			c.JSON(http.StatusUnauthorized, gin.H{"success": false, "message": "Token inválido"})
+			c.Abort()
+			return
+		}
+
+		c.Set("user_id", claims.UserID)
+		c.Set("email", claims.Email)
+		c.Set("rol", claims.Rol)
+		c.Next()
+	}
+}
+
+// GET /api/admin/reclamos/:id/eventos - Stream SSE para el panel
+func eventosAdminHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	id := c.Param("id")
+	var existe bool
+	if err := pool.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM reclamos WHERE id = $1)", id).Scan(&existe); err != nil || !existe {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Reclamo no encontrado"})
+		return
+	}
+
+	transmitirEventos(c, id, true)
+}
+
+// POST /api/seguimiento/:codigo/escribiendo - Aviso de "escribiendo..." del consumidor
+func escribiendoSeguimientoHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	codigo := c.Param("codigo")
+	if !autorizarConsultaPublica(c, ctx, codigo, alcanceReclamo) {
+		return
+	}
+
+	var reclamoID string
+	if err := pool.QueryRow(ctx, "SELECT id FROM reclamos WHERE codigo_reclamo = $1", codigo).Scan(&reclamoID); err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Reclamo no encontrado"})
+		return
+	}
+
+	publicarEvento(reclamoID, eventoEscribiendo, gin.H{"autor": "CLIENTE"})
+	c.Status(http.StatusNoContent)
+}
+
+// POST /api/admin/reclamos/:id/escribiendo - Aviso de "escribiendo..." del agente
+func escribiendoAdminHandler(c *gin.Context) {
+	// El canal es compartido con el consumidor: no se expone qué agente escribe
+	publicarEvento(c.Param("id"), eventoEscribiendo, gin.H{"autor": "EMPRESA"})
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,544 @@
+package main
+
+import (
+	"archive/zip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// =============================================================================
+// REPORTE PARA INSPECCIONES DE INDECOPI
+// Paquete zip con el resumen en PDF, el detalle en CSV y la hoja de cada
+// reclamo del periodo. Se genera en segundo plano directo a un archivo en
+// REPORTES_DIR (compartido entre réplicas) y la BD guarda la ruta y el SHA-256
+// hasta su expiración; cualquier instancia puede retomarlo si la que lo tomó
+// se cae.
+// =============================================================================
+
+const reporteMaxIntentos = 3
+
+// resumenReporte son los indicadores del periodo; se guarda como JSONB.
+type resumenReporte struct {
+	Total              int64    `json:"total"`
+	Reclamos           int64    `json:"reclamos"`
+	Quejas             int64    `json:"quejas"`
+	Pendientes         int64    `json:"pendientes"`
+	Atendidos          int64    `json:"atendidos"`
+	RespondidosEnPlazo int64    `json:"respondidos_en_plazo"`
+	RespondidosFuera   int64    `json:"respondidos_fuera_plazo"`
+	VencidosSinRespta  int64    `json:"vencidos_sin_respuesta"`
+	Ampliados          int64    `json:"plazos_ampliados"`
+	PromedioDias       *float64 `json:"promedio_dias_respuesta"`
+	MaximoDias         *int64   `json:"maximo_dias_respuesta"`
+	Cumplimiento       *float64 `json:"cumplimiento_plazo"`
+}
+
+// porcentajeCumplimiento: respuestas en plazo sobre todos los reclamos cuyo
+// plazo ya se decidió (respondidos o vencidos sin respuesta).
+func porcentajeCumplimiento(enPlazo, fueraPlazo, vencidos int64) *float64 {
+	base := enPlazo + fueraPlazo + vencidos
+	if base == 0 {
+		return nil
+	}
+	p := math.Round(float64(enPlazo)*1000/float64(base)) / 10
+	return &p
+}
+
+// sqlPeriodoReporte filtra el periodo (fechas inclusive) y el establecimiento.
+const sqlPeriodoReporte = `r.fecha_registro >= $1::date AND r.fecha_registro < $2::date + 1
+	AND ($3 = '' OR r.establecimiento = $3)`
+
+func calcularResumenReporte(ctx context.Context, desde, hasta time.Time, establecimiento string) (resumenReporte, error) {
+	var s resumenReporte
+	err := pool.QueryRow(ctx, `
+		SELECT COUNT(*),
+		       COUNT(*) FILTER (WHERE r.tipo_solicitud = 'RECLAMO'),
+		       COUNT(*) FILTER (WHERE r.tipo_solicitud = 'QUEJA'),
+		       COUNT(*) FILTER (WHERE r.estado IN ('PENDIENTE', 'EN_PROCESO')),
+		       COUNT(*) FILTER (WHERE r.estado IN ('RESUELTO', 'CERRADO')),
+		       COUNT(*) FILTER (WHERE r.fecha_respuesta::date <= `+sqlFechaLimite+`),
+		       COUNT(*) FILTER (WHERE r.fecha_respuesta::date > `+sqlFechaLimite+`),
+		       COUNT(*) FILTER (WHERE r.fecha_respuesta IS NULL AND `+sqlFechaLimite+` < CURRENT_DATE),
+		       COUNT(*) FILTER (WHERE r.fecha_limite_ampliada IS NOT NULL),
+		       ROUND(AVG(r.fecha_respuesta::date - r.fecha_registro::date), 1)::float8,
+		       MAX(r.fecha_respuesta::date - r.fecha_registro::date)::bigint
+		FROM reclamos r
+		WHERE `+sqlPeriodoReporte,
+		desde, hasta, establecimiento,
+	).Scan(&s.Total, &s.Reclamos, &s.Quejas, &s.Pendientes, &s.Atendidos,
+		&s.RespondidosEnPlazo, &s.RespondidosFuera, &s.VencidosSinRespta, &s.Ampliados,
+		&s.PromedioDias, &s.MaximoDias)
+	s.Cumplimiento = porcentajeCumplimiento(s.RespondidosEnPlazo, s.RespondidosFuera, s.VencidosSinRespta)
+	return s, err
+}
+
+func pdfResumenReporte(s resumenReporte, desde, hasta time.Time, establecimiento string) []byte {
+	d := nuevoPDF()
+	d.Titulo("LIBRO DE RECLAMACIONES - REPORTE DEL PERIODO")
+	d.Campo("Periodo", desde.Format("02/01/2006")+" al "+hasta.Format("02/01/2006"))
+	if establecimiento == "" {
+		establecimiento = "Todos"
+	}
+	d.Campo("Establecimiento", establecimiento)
+	d.Campo("Generado", time.Now().Format("02/01/2006 15:04"))
+
+	d.Seccion("1. Registros")
+	d.Campo("Total de hojas registradas", fmt.Sprint(s.Total))
+	d.Campo("Reclamos", fmt.Sprint(s.Reclamos))
+	d.Campo("Quejas", fmt.Sprint(s.Quejas))
+	d.Campo("Atendidos", fmt.Sprint(s.Atendidos))
+	d.Campo("En trámite", fmt.Sprint(s.Pendientes))
+
+	d.Seccion("2. Tiempos de respuesta")
+	promedio, maximo := "-", "-"
+	if s.PromedioDias != nil {
+		promedio = fmt.Sprintf("%.1f días", *s.PromedioDias)
+	}
+	if s.MaximoDias != nil {
+		maximo = fmt.Sprintf("%d días", *s.MaximoDias)
+	}
+	d.Campo("Promedio", promedio)
+	d.Campo("Máximo", maximo)
+
+	d.Seccion("3. Cumplimiento del plazo legal (15 días)")
+	d.Campo("Respondidos dentro del plazo", fmt.Sprint(s.RespondidosEnPlazo))
+	d.Campo("Respondidos fuera del plazo", fmt.Sprint(s.RespondidosFuera))
+	d.Campo("Vencidos sin respuesta", fmt.Sprint(s.VencidosSinRespta))
+	d.Campo("Plazos ampliados", fmt.Sprint(s.Ampliados))
+	cumplimiento := "-"
+	if s.Cumplimiento != nil {
+		cumplimiento = fmt.Sprintf("%.1f %%", *s.Cumplimiento)
+	}
+	d.Campo("Tasa de cumplimiento", cumplimiento)
+
+	d.Espacio()
+	d.Texto("El plazo considera las ampliaciones comunicadas al consumidor y los días en que el reclamo estuvo a la espera de información solicitada al consumidor. El detalle por reclamo está en detalle.csv y cada hoja de reclamación en la carpeta hojas/.")
+	return d.Bytes()
+}
+
+// generarPaqueteReporte escribe el zip en w a medida que lee los reclamos.
+func generarPaqueteReporte(ctx context.Context, w io.Writer, desde, hasta time.Time, establecimiento string) (resumenReporte, error) {
+	resumen, err := calcularResumenReporte(ctx, desde, hasta, establecimiento)
+	if err != nil {
+		return resumen, fmt.Errorf("resumen: %w", err)
+	}
+
+	zw := zip.NewWriter(w)
+
+	f, err := zw.Create("resumen.pdf")
+	if err != nil {
+		return resumen, err
+	}
+	if _, err := f.Write(pdfResumenReporte(resumen, desde, hasta, establecimiento)); err != nil {
+		return resumen, err
+	}
+
+	rows, err := pool.Query(ctx, `
+		SELECT r.codigo_reclamo, r.tipo_solicitud, to_char(r.fecha_registro, 'DD/MM/YYYY HH24:MI'),
+		       COALESCE(r.establecimiento, ''), r.nombre_completo, r.tipo_documento || ' ' || r.numero_documento,
+		       COALESCE(r.tipo_bien, ''), COALESCE(r.monto_reclamado, 0)::text, r.estado,
+		       to_char(`+sqlFechaLimite+`, 'DD/MM/YYYY'),
+		       COALESCE(to_char(r.fecha_respuesta, 'DD/MM/YYYY HH24:MI'), ''),
+		       COALESCE((r.fecha_respuesta::date - r.fecha_registro::date)::text, ''),
+		       CASE WHEN r.fecha_respuesta IS NULL AND `+sqlFechaLimite+` < CURRENT_DATE THEN 'VENCIDO'
+		            WHEN r.fecha_respuesta IS NULL THEN 'EN TRÁMITE'
+		            WHEN r.fecha_respuesta::date <= `+sqlFechaLimite+` THEN 'SI'
+		            ELSE 'NO' END,
+		       CASE WHEN r.fecha_limite_ampliada IS NOT NULL THEN 'SI' ELSE 'NO' END
+		FROM reclamos r
+		WHERE `+sqlPeriodoReporte+`
+		ORDER BY r.fecha_registro ASC
+	`, desde, hasta, establecimiento)
+	if err != nil {
+		return resumen, fmt.Errorf("detalle: %w", err)
+	}
+
+	f, err = zw.Create("detalle.csv")
+	if err != nil {
+		rows.Close()
+		return resumen, err
+	}
+	detalle := nuevoCSV(f)
+	detalle.Encabezado([]string{"Código", "Tipo", "Fecha registro", "Establecimiento", "Consumidor", "Documento",
+		"Tipo de bien", "Monto", "Estado", "Fecha límite", "Fecha respuesta", "Días de respuesta", "Respondido en plazo", "Plazo ampliado"})
+	var codigos []string
+	numericos := []bool{false, false, false, false, false, false, false, true, false, false, false, true}
+	for rows.Next() {
+		valores := make([]string, 14)
+		destinos := make([]interface{}, len(valores))
+		for i := range valores {
+			destinos[i] = &valores[i]
+		}
+		if err := rows.Scan(destinos...); err != nil {
+			rows.Close()
+			return resumen, fmt.Errorf("detalle: %w", err)
+		}
+		codigos = append(codigos, valores[0])
+		detalle.Fila(valores, numericos)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return resumen, fmt.Errorf("detalle: %w", err)
+	}
+	if err := detalle.Cerrar(); err != nil {
+		return resumen, err
+	}
+
+	for _, codigo := range codigos {
+		pdf, err := generarHojaReclamoPDF(ctx, codigo)
+		if err != nil {
+			return resumen, fmt.Errorf("hoja %s: %w", codigo, err)
+		}
+		f, err := zw.Create("hojas/" + codigo + ".pdf")
+		if err != nil {
+			return resumen, err
+		}
+		if _, err := f.Write(pdf); err != nil {
+			return resumen, err
+		}
+	}
+
+	return resumen, zw.Close()
+}
+
+// escribirPaqueteReporte genera el paquete en un temporal de REPORTES_DIR y
+// lo renombra a <id>.zip al terminar, para no dejar nunca un zip a medias.
+// Devuelve la ruta, el tamaño y el SHA-256 del archivo.
+func escribirPaqueteReporte(ctx context.Context, id string, desde, hasta time.Time, establecimiento string) (string, int64, string, resumenReporte, error) {
+	var resumen resumenReporte
+	if err := os.MkdirAll(config.ReportesDir, 0o700); err != nil {
+		return "", 0, "", resumen, err
+	}
+	tmp, err := os.CreateTemp(config.ReportesDir, id+"-*.tmp")
+	if err != nil {
+		return "", 0, "", resumen, err
+	}
+	defer os.Remove(tmp.Name()) // no-op tras el rename
+
+	h := sha256.New()
+	resumen, err = generarPaqueteReporte(ctx, io.MultiWriter(tmp, h), desde, hasta, establecimiento)
+	if err == nil {
+		err = tmp.Sync()
+	}
+	var info os.FileInfo
+	if err == nil {
+		info, err = tmp.Stat()
+	}
+	if errClose := tmp.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return "", 0, "", resumen, err
+	}
+
+	ruta := filepath.Join(config.ReportesDir, id+".zip")
+	if err := os.Rename(tmp.Name(), ruta); err != nil {
+		return "", 0, "", resumen, err
+	}
+	return ruta, info.Size(), hex.EncodeToString(h.Sum(nil)), resumen, nil
+}
+
+// sha256Archivo calcula el checksum de un archivo sin cargarlo en memoria.
+func sha256Archivo(f io.Reader) (string, error) {
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// procesarReporte toma un reporte pendiente (o uno que quedó a medias) y lo
+// genera. Con id vacío toma el más antiguo. Devuelve false si no había nada.
+func procesarReporte(ctx context.Context, id string) bool {
+	var desde, hasta time.Time
+	var establecimiento string
+	err := pool.QueryRow(ctx, `
+		UPDATE reportes_indecopi SET estado = 'PROCESANDO', fecha_inicio = NOW(), intentos = intentos + 1
+		WHERE id = (
+			SELECT id FROM reportes_indecopi
+			WHERE ($1 = '' OR id::text = $1)
+			  AND (estado = 'PENDIENTE' OR (estado = 'PROCESANDO' AND fecha_inicio < NOW() - INTERVAL '30 minutes'))
+			  AND intentos < $2
+			ORDER BY fecha_solicitud ASC
+			LIMIT 1
+			FOR UPDATE SKIP LOCKED
+		)
+		RETURNING id, fecha_desde, fecha_hasta, COALESCE(establecimiento, '')
+	`, id, reporteMaxIntentos).Scan(&id, &desde, &hasta, &establecimiento)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false
+	}
+	if err != nil {
+		log.Printf("⚠️ Error tomando reporte pendiente: %v", err)
+		return false
+	}
+
+	inicio := time.Now()
+	ruta, tamano, checksum, resumen, err := escribirPaqueteReporte(ctx, id, desde, hasta, establecimiento)
+	if err != nil {
+		log.Printf("❌ Error generando reporte %s: %v", id, err)
+		pool.Exec(ctx, `
+			UPDATE reportes_indecopi SET estado = 'ERROR', error = $2, fecha_fin = NOW() WHERE id = $1
+		`, id, "No se pudo generar el reporte")
+		return true
+	}
+
+	_, err = pool.Exec(ctx, `
+		UPDATE reportes_indecopi
+		SET estado = 'COMPLETADO', archivo_ruta = $2, archivo_sha256 = $3, tamano_bytes = $4, total_reclamos = $5, resumen = $6,
+		    fecha_fin = NOW(), fecha_expiracion = NOW() + ($7::int * INTERVAL '1 day')
+		WHERE id = $1
+	`, id, ruta, checksum, tamano, resumen.Total, resumen, config.ReportesDiasRetencion)
+	if err != nil {
+		log.Printf("❌ Error guardando reporte %s: %v", id, err)
+		os.Remove(ruta)
+		return true
+	}
+
+	log.Printf("📑 Reporte %s generado: %d reclamos, %d KB en %s", id, resumen.Total, tamano/1024, time.Since(inicio).Round(time.Millisecond))
+	return true
+}
+
+// procesarReportesPendientes es la tarea periódica: retoma los reportes que
+// ninguna instancia terminó y purga los expirados.
+func procesarReportesPendientes(ctx context.Context) {
+	rows, err := pool.Query(ctx, "DELETE FROM reportes_indecopi WHERE fecha_expiracion <= NOW() RETURNING COALESCE(archivo_ruta, '')")
+	if err == nil {
+		var rutas []string
+		rutas, err = pgx.CollectRows(rows, pgx.RowTo[string])
+		for _, ruta := range rutas {
+			if ruta == "" {
+				continue
+			}
+			if errRm := os.Remove(ruta); errRm != nil && !errors.Is(errRm, os.ErrNotExist) {
+				log.Printf("⚠️ Error borrando %s: %v", ruta, errRm)
+			}
+		}
+		if len(rutas) > 0 {
+			log.Printf("🧹 Reportes expirados eliminados: %d", len(rutas))
+		}
+	}
+	if err != nil {
+		log.Printf("⚠️ Error purgando reportes: %v", err)
+	}
+
+	for procesarReporte(ctx, "") {
+	}
+}
+
+// POST /api/admin/reportes - Solicitar el reporte de un periodo
+func crearReporteHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var req struct {
+		Desde           string `json:"fecha_desde" binding:"required"`
+		Hasta           string `json:"fecha_hasta" binding:"required"`
+		Establecimiento string `json:"establecimiento" binding:"max=255"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Indique fecha_desde y fecha_hasta (AAAA-MM-DD)"})
+		return
+	}
+	desde, errDesde := time.Parse("2006-01-02", req.Desde)
+	hasta, errHasta := time.Parse("2006-01-02", req.Hasta)
+	switch {
+	case errDesde != nil || errHasta != nil:
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Las fechas deben tener formato AAAA-MM-DD"})
+		return
+	case hasta.Before(desde):
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "fecha_hasta no puede ser anterior a fecha_desde"})
+		return
+	case hasta.Sub(desde) > 366*24*time.Hour:
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "El periodo no puede superar un año"})
+		return
+	}
+
+	userID := fmt.Sprintf("%v", c.MustGet("user_id"))
+	email := fmt.Sprintf("%v", c.MustGet("email"))
+
+	var id string
+	err := pool.QueryRow(ctx, `
+		INSERT INTO reportes_indecopi (fecha_desde, fecha_hasta, establecimiento, solicitado_por, solicitado_por_email)
+		VALUES ($1, $2, NULLIF($3, ''), $4::uuid, $5)
+		RETURNING id
+	`, desde, hasta, req.Establecimiento, userID, email).Scan(&id)
+	if err != nil {
+		log.Printf("❌ Error registrando reporte: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al solicitar el reporte"})
+		return
+	}
+
+	_, errAudit := pool.Exec(ctx, `
+		INSERT INTO auditoria_admin (usuario_id, accion, entidad, entidad_id, detalles, ip_address)
+		VALUES ($1::uuid, 'GENERAR_REPORTE', 'REPORTE', $2, jsonb_build_object('desde', $3::text, 'hasta', $4::text, 'establecimiento', $5::text), $6)
+	`, userID, id, req.Desde, req.Hasta, req.Establecimiento, c.ClientIP())
+	if errAudit != nil {
+		log.Printf("⚠️ Error registrando auditoría: %v", errAudit)
+	}
+
+	// Se genera fuera del request; si esta instancia cae, la tarea periódica lo retoma
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Minute)
+		defer cancel()
+		procesarReporte(ctx, id)
+	}()
+
+	c.JSON(http.StatusAccepted, gin.H{
+		"success": true,
+		"message": "El reporte se está generando",
+		"data":    gin.H{"id": id, "estado": "PENDIENTE"},
+	})
+}
+
+func datosReporte(row pgx.Row) (gin.H, error) {
+	var id, estado, solicitadoPor string
+	var establecimiento, errorMsg *string
+	var desde, hasta, fechaSolicitud time.Time
+	var fechaFin, fechaExpiracion *time.Time
+	var tamano, total *int64
+	var resumen []byte
+	if err := row.Scan(&id, &desde, &hasta, &establecimiento, &estado, &errorMsg, &resumen, &tamano, &total,
+		&solicitadoPor, &fechaSolicitud, &fechaFin, &fechaExpiracion); err != nil {
+		return nil, err
+	}
+
+	datos := gin.H{
+		"id":               id,
+		"fecha_desde":      desde.Format("2006-01-02"),
+		"fecha_hasta":      hasta.Format("2006-01-02"),
+		"establecimiento":  establecimiento,
+		"estado":           estado,
+		"error":            errorMsg,
+		"tamano_bytes":     tamano,
+		"total_reclamos":   total,
+		"solicitado_por":   solicitadoPor,
+		"fecha_solicitud":  fechaSolicitud,
+		"fecha_fin":        fechaFin,
+		"fecha_expiracion": fechaExpiracion,
+		"resumen":          nil,
+		"descarga":         nil,
+	}
+	if resumen != nil {
+		datos["resumen"] = json.RawMessage(resumen)
+	}
+	if estado == "COMPLETADO" {
+		datos["descarga"] = "/api/admin/reportes/" + id + "/descargar"
+	}
+	return datos, nil
+}
+
+const sqlColumnasReporte = `id, fecha_desde, fecha_hasta, establecimiento, estado, error, resumen, tamano_bytes,
+	total_reclamos, solicitado_por_email, fecha_solicitud, fecha_fin, fecha_expiracion`
+
+// GET /api/admin/reportes - Reportes generados y en curso
+func listarReportesHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	rows, err := pool.Query(ctx, "SELECT "+sqlColumnasReporte+" FROM reportes_indecopi ORDER BY fecha_solicitud DESC LIMIT 100")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al obtener los reportes"})
+		return
+	}
+	defer rows.Close()
+
+	reportes := []gin.H{}
+	for rows.Next() {
+		datos, err := datosReporte(rows)
+		if err != nil {
+			log.Printf("⚠️ Error leyendo reporte: %v", err)
+			continue
+		}
+		reportes = append(reportes, datos)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": reportes})
+}
+
+// GET /api/admin/reportes/:id - Estado de un reporte
+func obtenerReporteHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	datos, err := datosReporte(pool.QueryRow(ctx, "SELECT "+sqlColumnasReporte+" FROM reportes_indecopi WHERE id::text = $1", c.Param("id")))
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Reporte no encontrado"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": datos})
+}
+
+// GET /api/admin/reportes/:id/descargar - Descargar el paquete zip
+func descargarReporteHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 60*time.Second)
+	defer cancel()
+
+	id := c.Param("id")
+	var desde, hasta time.Time
+	var ruta, checksum string
+	err := pool.QueryRow(ctx, `
+		SELECT fecha_desde, fecha_hasta, COALESCE(archivo_ruta, ''), COALESCE(archivo_sha256, '') FROM reportes_indecopi
+		WHERE id::text = $1 AND estado = 'COMPLETADO'
+	`, id).Scan(&desde, &hasta, &ruta, &checksum)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Reporte no encontrado o aún en proceso"})
+		return
+	}
+
+	archivo, err := os.Open(ruta)
+	if err != nil {
+		log.Printf("⚠️ Archivo del reporte %s no disponible: %v", id, err)
+		c.JSON(http.StatusGone, gin.H{"success": false, "message": "El archivo del reporte ya no está disponible, solicítelo de nuevo"})
+		return
+	}
+	defer archivo.Close()
+
+	// El paquete es evidencia ante INDECOPI: no se entrega si cambió en disco
+	suma, err := sha256Archivo(archivo)
+	if err == nil && suma != checksum {
+		err = fmt.Errorf("checksum %s, esperado %s", suma, checksum)
+	}
+	var info os.FileInfo
+	if err == nil {
+		info, err = archivo.Stat()
+	}
+	if err == nil {
+		_, err = archivo.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		log.Printf("❌ Reporte %s inválido: %v", id, err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al leer el reporte"})
+		return
+	}
+
+	_, errAudit := pool.Exec(ctx, `
+		INSERT INTO auditoria_admin (usuario_id, accion, entidad, entidad_id, ip_address)
+		VALUES ($1::uuid, 'DESCARGAR_REPORTE', 'REPORTE', $2, $3)
+	`, fmt.Sprintf("%v", c.MustGet("user_id")), id, c.ClientIP())
+	if errAudit != nil {
+		log.Printf("⚠️ Error registrando auditoría: %v", errAudit)
+	}
+
+	nombre := fmt.Sprintf("reporte_indecopi_%s_%s.zip", desde.Format("20060102"), hasta.Format("20060102"))
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": nombre}))
+	c.Header("Cache-Control", "private, no-store")
+	c.Header("X-Checksum-SHA256", checksum)
+	c.DataFromReader(http.StatusOK, info.Size(), "application/zip", archivo, nil)
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// =============================================================================
+// RESPUESTAS PREDEFINIDAS
+// Plantillas para respuesta_empresa, accion_tomada y compensacion_ofrecida,
+// clasificadas con el mismo árbol de categorías de los reclamos. Los
+// marcadores {{nombre}}, {{codigo}}, etc. se completan con los datos del
+// reclamo al renderizar; cada renderizado cuenta como un uso.
+// =============================================================================
+
+var reMarcador = regexp.MustCompile(`\{\{\s*([a-z_]+)\s*\}\}`)
+
+// Marcadores disponibles en las plantillas
+var marcadoresPlantilla = []string{
+	"nombre", "codigo", "tipo_solicitud", "fecha_registro", "fecha_limite",
+	"monto", "establecimiento", "agente",
+}
+
+// marcadoresDesconocidos devuelve los marcadores del texto que no existen.
+func marcadoresDesconocidos(texto string) []string {
+	var desconocidos []string
+	for _, m := range reMarcador.FindAllStringSubmatch(texto, -1) {
+		if !contieneTexto(marcadoresPlantilla, m[1]) && !contieneTexto(desconocidos, m[1]) {
+			desconocidos = append(desconocidos, m[1])
+		}
+	}
+	return desconocidos
+}
+
+// renderizarPlantilla reemplaza los marcadores por sus valores; los que no
+// tienen valor quedan vacíos y se informan en faltantes.
+func renderizarPlantilla(texto string, valores map[string]string, faltantes map[string]bool) string {
+	return reMarcador.ReplaceAllStringFunc(texto, func(m string) string {
+		clave := reMarcador.FindStringSubmatch(m)[1]
+		v := valores[clave]
+		if v == "" {
+			faltantes[clave] = true
+		}
+		return v
+	})
+}
+
+// valoresPlantilla obtiene los datos del reclamo para los marcadores.
+func valoresPlantilla(ctx context.Context, reclamoID, userID string) (map[string]string, error) {
+	var codigo, nombre, tipo, establecimiento, agente string
+	var fechaRegistro, fechaLimite time.Time
+	var monto *float64
+	err := pool.QueryRow(ctx, `
+		SELECT r.codigo_reclamo, r.nombre_completo, r.tipo_solicitud, r.fecha_registro, `+sqlFechaLimite+`,
+		       r.monto_reclamado::float8, COALESCE(r.establecimiento, ''),
+		       COALESCE((SELECT nombre_completo FROM usuarios_admin WHERE id::text = $2), '')
+		FROM reclamos r
+		WHERE r.id = $1
+	`, reclamoID, userID).Scan(&codigo, &nombre, &tipo, &fechaRegistro, &fechaLimite, &monto, &establecimiento, &agente)
+	if err != nil {
+		return nil, err
+	}
+
+	valores := map[string]string{
+		"nombre":          nombre,
+		"codigo":          codigo,
+		"tipo_solicitud":  strings.ToLower(tipo),
+		"fecha_registro":  fechaRegistro.Format("02/01/2006"),
+		"fecha_limite":    fechaLimite.Format("02/01/2006"),
+		"establecimiento": establecimiento,
+		"agente":          agente,
+	}
+	if monto != nil && *monto > 0 {
+		valores["monto"] = fmt.Sprintf("S/ %.2f", *monto)
+	}
+	return valores, nil
+}
+
+type solicitudPlantilla struct {
+	Titulo               string  `json:"titulo" binding:"required,max=150"`
+	CategoriaID          *string `json:"categoria_id"`
+	TipoSolicitud        *string `json:"tipo_solicitud"`
+	RespuestaEmpresa     string  `json:"respuesta_empresa" binding:"required,min=10,max=5000"`
+	AccionTomada         string  `json:"accion_tomada" binding:"max=2000"`
+	CompensacionOfrecida string  `json:"compensacion_ofrecida" binding:"max=2000"`
+	Activa               *bool   `json:"activa"`
+}
+
+func (s *solicitudPlantilla) validar() error {
+	if s.CategoriaID != nil && !reUUID.MatchString(*s.CategoriaID) {
+		return errors.New("categoria_id inválido")
+	}
+	if s.TipoSolicitud != nil && !contieneTexto(tiposValidos, *s.TipoSolicitud) {
+		return errors.New("tipo_solicitud debe ser RECLAMO o QUEJA")
+	}
+	if s.TipoSolicitud != nil {
+		tipo := strings.ToUpper(*s.TipoSolicitud)
+		s.TipoSolicitud = &tipo
+	}
+	desconocidos := marcadoresDesconocidos(s.RespuestaEmpresa + s.AccionTomada + s.CompensacionOfrecida)
+	if len(desconocidos) > 0 {
+		return fmt.Errorf("marcadores desconocidos: %s (disponibles: %s)",
+			strings.Join(desconocidos, ", "), strings.Join(marcadoresPlantilla, ", "))
+	}
+	if s.Activa == nil {
+		activa := true
+		s.Activa = &activa
+	}
+	return nil
+}
+
+// GET /api/admin/respuestas-predefinidas - Biblioteca de plantillas
+// Filtros: categoria (incluye subcategorías), tipo_solicitud, q, incluir_inactivas.
+// Con para_reclamo=<id> se devuelven las aplicables a ese reclamo: sin categoría
+// o de su categoría o una superior, y sin tipo o de su tipo.
+func listarPlantillasHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	where := " WHERE (p.activa OR $1)"
+	args := []interface{}{c.Query("incluir_inactivas") == "true"}
+	arg := func(v interface{}) string {
+		args = append(args, v)
+		return fmt.Sprintf("$%d", len(args))
+	}
+
+	if cat := c.Query("categoria"); cat != "" {
+		if !reUUID.MatchString(cat) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "categoria debe ser un ID de categoría"})
+			return
+		}
+		where += " AND p.categoria_id IN (SELECT id FROM v_categorias_ruta WHERE " + arg(cat) + "::uuid = ANY(ancestros))"
+	}
+	if tipo := strings.ToUpper(c.Query("tipo_solicitud")); tipo != "" {
+		where += " AND p.tipo_solicitud = " + arg(tipo)
+	}
+	if q := strings.TrimSpace(c.Query("q")); q != "" {
+		patron := "%" + escaparLike(q) + "%"
+		where += " AND (p.titulo ILIKE " + arg(patron) + " OR p.respuesta_empresa ILIKE " + arg(patron) + ")"
+	}
+	if reclamo := c.Query("para_reclamo"); reclamo != "" {
+		if !reUUID.MatchString(reclamo) {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "para_reclamo debe ser un ID de reclamo"})
+			return
+		}
+		ph := arg(reclamo)
+		where += ` AND (p.tipo_solicitud IS NULL OR p.tipo_solicitud = (SELECT tipo_solicitud FROM reclamos WHERE id = ` + ph + `))
+			AND (p.categoria_id IS NULL OR p.categoria_id = ANY(
+				SELECT unnest(cr.ancestros) FROM reclamos r JOIN v_categorias_ruta cr ON cr.id = r.categoria_id WHERE r.id = ` + ph + `))`
+	}
+
+	rows, err := pool.Query(ctx, `
+		SELECT p.id, p.titulo, p.categoria_id, cat.ruta, p.tipo_solicitud,
+		       p.respuesta_empresa, p.accion_tomada, p.compensacion_ofrecida, p.activa,
+		       p.usos, p.ultimo_uso,
+		       (SELECT COUNT(*) FROM usos_respuestas_predefinidas u WHERE u.plantilla_id = p.id AND u.fecha_uso > NOW() - INTERVAL '30 days'),
+		       p.fecha_creacion, p.fecha_modificacion
+		FROM respuestas_predefinidas p
+		LEFT JOIN v_categorias_ruta cat ON cat.id = p.categoria_id
+	`+where+`
+		ORDER BY p.usos DESC, p.titulo
+	`, args...)
+	if err != nil {
+		log.Printf("❌ Error listando respuestas predefinidas: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al obtener las respuestas predefinidas"})
+		return
+	}
+	defer rows.Close()
+
+	plantillas := []gin.H{}
+	for rows.Next() {
+		var id, titulo, respuesta string
+		var categoriaID, categoria, tipo, accion, compensacion *string
+		var activa bool
+		var usos, usos30 int64
+		var ultimoUso, modificacion *time.Time
+		var creacion time.Time
+		if err := rows.Scan(&id, &titulo, &categoriaID, &categoria, &tipo, &respuesta, &accion, &compensacion,
+			&activa, &usos, &ultimoUso, &usos30, &creacion, &modificacion); err != nil {
+			continue
+		}
+		plantillas = append(plantillas, gin.H{
+			"id":                    id,
+			"titulo":                titulo,
+			"categoria_id":          categoriaID,
+			"categoria":             categoria,
+			"tipo_solicitud":        tipo,
+			"respuesta_empresa":     respuesta,
+			"accion_tomada":         accion,
+			"compensacion_ofrecida": compensacion,
+			"activa":                activa,
+			"usos":                  usos,
+			"usos_30_dias":          usos30,
+			"ultimo_uso":            ultimoUso,
+			"fecha_creacion":        creacion,
+			"fecha_modificacion":    modificacion,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"data":       plantillas,
+		"marcadores": marcadoresPlantilla,
+	})
+}
+
+func registrarAuditoriaPlantilla(ctx context.Context, c *gin.Context, accion, id string, detalles gin.H) {
+	_, err := pool.Exec(ctx, `
+		INSERT INTO auditoria_admin (usuario_id, accion, entidad, entidad_id, detalles, ip_address)
+		VALUES ($1::uuid, $2, 'RESPUESTA_PREDEFINIDA', $3, $4, $5)
+	`, fmt.Sprintf("%v", c.MustGet("user_id")), accion, id, detalles, c.ClientIP())
+	if err != nil {
+		log.Printf("⚠️ Error registrando auditoría: %v", err)
+	}
+}
+
+// POST /api/admin/respuestas-predefinidas - Crear plantilla
+func crearPlantillaHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var req solicitudPlantilla
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Datos inválidos: el título y la respuesta (mín. 10 caracteres) son obligatorios"})
+		return
+	}
+	if err := req.validar(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	var id string
+	err := pool.QueryRow(ctx, `
+		INSERT INTO respuestas_predefinidas (titulo, categoria_id, tipo_solicitud, respuesta_empresa, accion_tomada, compensacion_ofrecida, activa, creado_por)
+		VALUES ($1, $2, $3, $4, NULLIF($5, ''), NULLIF($6, ''), $7, $8::uuid)
+		RETURNING id
+	`, strings.TrimSpace(req.Titulo), req.CategoriaID, req.TipoSolicitud, req.RespuestaEmpresa,
+		req.AccionTomada, req.CompensacionOfrecida, *req.Activa, fmt.Sprintf("%v", c.MustGet("user_id"))).Scan(&id)
+	if err != nil {
+		log.Printf("❌ Error creando respuesta predefinida: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al crear la respuesta predefinida"})
+		return
+	}
+
+	registrarAuditoriaPlantilla(ctx, c, "CREAR_RESPUESTA_PREDEFINIDA", id, gin.H{"titulo": req.Titulo})
+	c.JSON(http.StatusCreated, gin.H{"success": true, "message": "Respuesta predefinida creada", "data": gin.H{"id": id}})
+}
+
+// PUT /api/admin/respuestas-predefinidas/:id - Modificar plantilla
+func actualizarPlantillaHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var req solicitudPlantilla
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Datos inválidos: el título y la respuesta (mín. 10 caracteres) son obligatorios"})
+		return
+	}
+	if err := req.validar(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	id := c.Param("id")
+	tag, err := pool.Exec(ctx, `
+		UPDATE respuestas_predefinidas
+		SET titulo = $2, categoria_id = $3, tipo_solicitud = $4, respuesta_empresa = $5,
+		    accion_tomada = NULLIF($6, ''), compensacion_ofrecida = NULLIF($7, ''), activa = $8, fecha_modificacion = NOW()
+		WHERE id::text = $1
+	`, id, strings.TrimSpace(req.Titulo), req.CategoriaID, req.TipoSolicitud, req.RespuestaEmpresa,
+		req.AccionTomada, req.CompensacionOfrecida, *req.Activa)
+	if err != nil {
+		log.Printf("❌ Error actualizando respuesta predefinida: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al actualizar la respuesta predefinida"})
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Respuesta predefinida no encontrada"})
+		return
+	}
+
+	registrarAuditoriaPlantilla(ctx, c, "EDITAR_RESPUESTA_PREDEFINIDA", id, gin.H{"titulo": req.Titulo, "activa": *req.Activa})
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Respuesta predefinida actualizada"})
+}
+
+// DELETE /api/admin/respuestas-predefinidas/:id - Eliminar plantilla (y su registro de usos)
+func eliminarPlantillaHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	id := c.Param("id")
+	tag, err := pool.Exec(ctx, "DELETE FROM respuestas_predefinidas WHERE id::text = $1", id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al eliminar la respuesta predefinida"})
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Respuesta predefinida no encontrada"})
+		return
+	}
+
+	registrarAuditoriaPlantilla(ctx, c, "ELIMINAR_RESPUESTA_PREDEFINIDA", id, gin.H{})
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Respuesta predefinida eliminada"})
+}
+
+// POST /api/admin/respuestas-predefinidas/:id/renderizar - Completar la plantilla para un reclamo
+func renderizarPlantillaHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var req struct {
+		ReclamoID string `json:"reclamo_id" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil || !reUUID.MatchString(req.ReclamoID) {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "reclamo_id inválido"})
+		return
+	}
+	id := c.Param("id")
+	userID := fmt.Sprintf("%v", c.MustGet("user_id"))
+
+	var respuesta string
+	var accion, compensacion *string
+	err := pool.QueryRow(ctx, `
+		SELECT respuesta_empresa, accion_tomada, compensacion_ofrecida
+		FROM respuestas_predefinidas WHERE id::text = $1 AND activa
+	`, id).Scan(&respuesta, &accion, &compensacion)
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Respuesta predefinida no encontrada o inactiva"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al obtener la respuesta predefinida"})
+		return
+	}
+
+	valores, err := valoresPlantilla(ctx, req.ReclamoID, userID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Reclamo no encontrado"})
+		return
+	}
+	if err != nil {
+		log.Printf("❌ Error obteniendo datos para plantilla: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al completar la plantilla"})
+		return
+	}
+
+	faltantes := map[string]bool{}
+	data := gin.H{"respuesta_empresa": renderizarPlantilla(respuesta, valores, faltantes)}
+	if accion != nil {
+		data["accion_tomada"] = renderizarPlantilla(*accion, valores, faltantes)
+	}
+	if compensacion != nil {
+		data["compensacion_ofrecida"] = renderizarPlantilla(*compensacion, valores, faltantes)
+	}
+	sinValor := []string{}
+	for m := range faltantes {
+		sinValor = append(sinValor, m)
+	}
+	sort.Strings(sinValor)
+	data["marcadores_sin_valor"] = sinValor
+
+	// Registrar el uso
+	tx, err := pool.Begin(ctx)
+	if err == nil {
+		defer tx.Rollback(ctx)
+		_, err = tx.Exec(ctx, `
+			INSERT INTO usos_respuestas_predefinidas (plantilla_id, reclamo_id, usuario_id)
+			VALUES ($1, $2, $3::uuid)
+		`, id, req.ReclamoID, userID)
+		if err == nil {
+			_, err = tx.Exec(ctx, "UPDATE respuestas_predefinidas SET usos = usos + 1, ultimo_uso = NOW() WHERE id = $1", id)
+		}
+		if err == nil {
+			err = tx.Commit(ctx)
+		}
+	}
+	if err != nil {
+		log.Printf("⚠️ Error registrando uso de respuesta predefinida %s: %v", id, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": data})
+}
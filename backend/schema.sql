@@ -169,7 +169,7 @@ CREATE TABLE historial_reclamos (
     
     comentario TEXT,
     usuario_accion VARCHAR(255) DEFAULT 'SISTEMA',
-    tipo_accion VARCHAR(50) NOT NULL CHECK (tipo_accion IN ('CREACION', 'CAMBIO_ESTADO', 'RESPUESTA', 'MENSAJE_CLIENTE', 'ADJUNTO', 'NOTIFICACION')),
+    tipo_accion VARCHAR(50) NOT NULL CHECK (tipo_accion IN ('CREACION', 'CAMBIO_ESTADO', 'RESPUESTA', 'MENSAJE_CLIENTE', 'ADJUNTO', 'NOTIFICACION', 'VENCIMIENTO', 'AMPLIACION_PLAZO', 'PAUSA_PLAZO', 'REANUDACION_PLAZO', 'ASIGNACION', 'ETIQUETADO', 'CATEGORIZACION', 'TRIAJE', 'RESPUESTA_PROPUESTA', 'RESPUESTA_RECHAZADA', 'ENMIENDA_RESPUESTA')),
     
     ip_address INET,
     user_agent TEXT,
@@ -548,3 +548,787 @@ INSERT INTO usuarios_admin (
 -- Para verificar que todo se creó correctamente:
 -- SELECT table_name FROM information_schema.tables WHERE table_schema = 'public' AND table_name LIKE '%admin%';
 
+
+-- ============================================================================
+-- TABLA: borradores_reclamos
+-- Descripción: Formularios guardados a medias por el consumidor.
+-- No tienen código legal ni plazo; se reanudan con el enlace mágico enviado
+-- por email y se eliminan al enviarse o al vencer (purga en el backend).
+-- ============================================================================
+CREATE TABLE IF NOT EXISTS borradores_reclamos (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    token_hash VARCHAR(64) UNIQUE NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    datos JSONB NOT NULL,
+    ip_address VARCHAR(45),
+    fecha_creacion TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    fecha_actualizacion TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    fecha_expiracion TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_borradores_expiracion ON borradores_reclamos(fecha_expiracion);
+CREATE INDEX IF NOT EXISTS idx_borradores_ip_fecha ON borradores_reclamos(ip_address, fecha_creacion DESC);
+
+COMMENT ON COLUMN borradores_reclamos.token_hash IS 'Hash SHA256 del token del enlace mágico';
+COMMENT ON COLUMN borradores_reclamos.datos IS 'CrearReclamoRequest parcial en formato JSON';
+
+-- ============================================================================
+-- TABLA: intentos_rechazados
+-- Descripción: Envíos públicos bloqueados por el anti-spam (honeypot, CAPTCHA,
+-- tiempo de llenado, límites por IP/documento) y consultas de seguimiento con
+-- documento o token inválido. Revisable desde el panel admin.
+-- ============================================================================
+CREATE TABLE IF NOT EXISTS intentos_rechazados (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    endpoint VARCHAR(50) NOT NULL,
+    motivo VARCHAR(50) NOT NULL CHECK (motivo IN ('HONEYPOT', 'CAPTCHA', 'TOKEN_FORMULARIO', 'LLENADO_RAPIDO', 'LIMITE_IP', 'LIMITE_DOCUMENTO', 'CONSULTA_FALLIDA')),
+    ip_address VARCHAR(45) NOT NULL,
+    user_agent TEXT,
+    numero_documento VARCHAR(50),
+    ruta TEXT,
+    fecha TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_intentos_fecha ON intentos_rechazados(fecha DESC);
+CREATE INDEX IF NOT EXISTS idx_intentos_ip ON intentos_rechazados(ip_address, fecha DESC);
+CREATE INDEX IF NOT EXISTS idx_intentos_motivo ON intentos_rechazados(motivo);
+CREATE INDEX IF NOT EXISTS idx_reclamos_ip_fecha ON reclamos(ip_address, fecha_registro DESC);
+
+-- ============================================================================
+-- TABLA: rate_limit_buckets
+-- Descripción: Estado de los token buckets cuando RATE_LIMIT_BACKEND=postgres
+-- (despliegues con varias réplicas). Las filas inactivas se purgan solas.
+-- ============================================================================
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    clave VARCHAR(255) PRIMARY KEY,
+    tokens DOUBLE PRECISION NOT NULL,
+    actualizado TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_limit_actualizado ON rate_limit_buckets(actualizado);
+
+-- ============================================================================
+-- TABLA: accesos_consumidor
+-- Descripción: Enlaces de acceso de un solo uso al portal del consumidor. Se
+-- envían al email registrado del reclamo y al canjearse emiten un token de
+-- sesión para todos los reclamos del par email/documento.
+-- ============================================================================
+CREATE TABLE IF NOT EXISTS accesos_consumidor (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    token_hash VARCHAR(64) NOT NULL UNIQUE,
+    email VARCHAR(255) NOT NULL,
+    numero_documento VARCHAR(50) NOT NULL,
+    ip_solicitud VARCHAR(45),
+    fecha_creacion TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    fecha_expiracion TIMESTAMP NOT NULL,
+    fecha_uso TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_accesos_expiracion ON accesos_consumidor(fecha_expiracion);
+CREATE INDEX IF NOT EXISTS idx_reclamos_documento_email ON reclamos(numero_documento, LOWER(email));
+
+COMMENT ON COLUMN accesos_consumidor.token_hash IS 'Hash SHA256 del token del enlace (el token nunca se guarda)';
+COMMENT ON COLUMN accesos_consumidor.fecha_uso IS 'Momento en que se canjeó; un enlace usado no vuelve a servir';
+
+-- ============================================================================
+-- CONFIRMACIONES DE LECTURA
+-- Los contadores de no leídos del panel filtran por (reclamo, tipo, leido).
+-- ============================================================================
+CREATE INDEX IF NOT EXISTS idx_mensajes_no_leidos ON mensajes_seguimiento(reclamo_id, tipo_mensaje) WHERE leido = false;
+CREATE INDEX IF NOT EXISTS idx_mensajes_reclamo_fecha ON mensajes_seguimiento(reclamo_id, fecha_mensaje DESC);
+
+-- ============================================================================
+-- AVISOS POR EMAIL DE MENSAJES DE LA EMPRESA
+-- fecha_notificacion marca los mensajes EMPRESA ya incluidos en un email al
+-- consumidor (varios mensajes seguidos se agrupan en un solo aviso).
+-- ============================================================================
+ALTER TABLE mensajes_seguimiento ADD COLUMN IF NOT EXISTS fecha_notificacion TIMESTAMP;
+
+CREATE INDEX IF NOT EXISTS idx_mensajes_sin_notificar ON mensajes_seguimiento(fecha_mensaje) WHERE tipo_mensaje = 'EMPRESA' AND fecha_notificacion IS NULL;
+
+-- ============================================================================
+-- RESPUESTAS POR EMAIL
+-- message_id_email evita registrar dos veces el mismo correo cuando el
+-- proveedor reintenta el webhook o se reimporta un mbox.
+-- ============================================================================
+ALTER TABLE mensajes_seguimiento ADD COLUMN IF NOT EXISTS message_id_email VARCHAR(255);
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_mensajes_message_id ON mensajes_seguimiento(message_id_email) WHERE message_id_email IS NOT NULL;
+
+-- ============================================================================
+-- TABLAS: notas_internas, notas_internas_ediciones, notas_internas_menciones
+-- Descripción: Notas privadas de los agentes sobre un reclamo. Separadas de
+-- mensajes_seguimiento para que nunca lleguen a las rutas públicas.
+-- ============================================================================
+CREATE TABLE IF NOT EXISTS notas_internas (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    reclamo_id UUID NOT NULL REFERENCES reclamos(id) ON DELETE CASCADE,
+    autor_id UUID REFERENCES usuarios_admin(id) ON DELETE SET NULL,
+    autor_email VARCHAR(255) NOT NULL,
+    contenido TEXT NOT NULL,
+    fecha_creacion TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    fecha_edicion TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_notas_reclamo ON notas_internas(reclamo_id, fecha_creacion);
+
+CREATE TABLE IF NOT EXISTS notas_internas_ediciones (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    nota_id UUID NOT NULL REFERENCES notas_internas(id) ON DELETE CASCADE,
+    contenido_anterior TEXT NOT NULL,
+    editado_por UUID REFERENCES usuarios_admin(id) ON DELETE SET NULL,
+    fecha_edicion TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_notas_ediciones_nota ON notas_internas_ediciones(nota_id, fecha_edicion DESC);
+
+CREATE TABLE IF NOT EXISTS notas_internas_menciones (
+    nota_id UUID NOT NULL REFERENCES notas_internas(id) ON DELETE CASCADE,
+    usuario_id UUID NOT NULL REFERENCES usuarios_admin(id) ON DELETE CASCADE,
+    PRIMARY KEY (nota_id, usuario_id)
+);
+
+CREATE INDEX IF NOT EXISTS idx_notas_menciones_usuario ON notas_internas_menciones(usuario_id);
+
+COMMENT ON TABLE notas_internas IS 'Notas privadas de agentes; editables solo por el autor dentro de NOTAS_VENTANA_EDICION_MINUTOS';
+
+-- ============================================================================
+-- TABLAS: leases_tareas, alertas_sla
+-- Descripción: Alertas de vencimiento del plazo legal. El lease evita que dos
+-- réplicas corran la misma verificación; alertas_sla evita repetir un aviso
+-- (reclamo, umbral, destino) tras un reinicio.
+-- ============================================================================
+CREATE TABLE IF NOT EXISTS leases_tareas (
+    nombre VARCHAR(100) PRIMARY KEY,
+    propietario VARCHAR(255) NOT NULL,
+    expira TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS alertas_sla (
+    reclamo_id UUID NOT NULL REFERENCES reclamos(id) ON DELETE CASCADE,
+    nivel VARCHAR(20) NOT NULL,
+    destino VARCHAR(20) NOT NULL CHECK (destino IN ('AGENTE', 'SUPERVISORES')),
+    destinatarios TEXT,
+    fecha_envio TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    PRIMARY KEY (reclamo_id, nivel, destino)
+);
+
+-- El historial registra el incumplimiento del plazo
+ALTER TABLE historial_reclamos DROP CONSTRAINT IF EXISTS historial_reclamos_tipo_accion_check;
+ALTER TABLE historial_reclamos ADD CONSTRAINT historial_reclamos_tipo_accion_check
+    CHECK (tipo_accion IN ('CREACION', 'CAMBIO_ESTADO', 'RESPUESTA', 'MENSAJE_CLIENTE', 'ADJUNTO', 'NOTIFICACION', 'VENCIMIENTO'));
+
+-- ============================================================================
+-- AMPLIACIÓN DEL PLAZO DE RESPUESTA
+-- fecha_limite_respuesta es generada; el plazo vigente es
+-- COALESCE(fecha_limite_ampliada, fecha_limite_respuesta)
+-- ============================================================================
+ALTER TABLE reclamos ADD COLUMN IF NOT EXISTS fecha_limite_ampliada DATE;
+
+CREATE TABLE IF NOT EXISTS ampliaciones_plazo (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    reclamo_id UUID NOT NULL REFERENCES reclamos(id) ON DELETE CASCADE,
+    dias INTEGER NOT NULL CHECK (dias > 0),
+    fecha_limite_anterior DATE NOT NULL,
+    fecha_limite_nueva DATE NOT NULL,
+    justificacion TEXT NOT NULL,
+    solicitado_por UUID REFERENCES usuarios_admin(id) ON DELETE SET NULL,
+    solicitado_por_email VARCHAR(255) NOT NULL,
+    fecha_solicitud TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    fecha_notificacion TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_ampliaciones_reclamo ON ampliaciones_plazo(reclamo_id);
+
+ALTER TABLE historial_reclamos DROP CONSTRAINT IF EXISTS historial_reclamos_tipo_accion_check;
+ALTER TABLE historial_reclamos ADD CONSTRAINT historial_reclamos_tipo_accion_check
+    CHECK (tipo_accion IN ('CREACION', 'CAMBIO_ESTADO', 'RESPUESTA', 'MENSAJE_CLIENTE', 'ADJUNTO', 'NOTIFICACION', 'VENCIMIENTO', 'AMPLIACION_PLAZO'));
+
+-- Las vistas pasan a usar el plazo vigente (mismas columnas)
+CREATE OR REPLACE VIEW dashboard_reclamos AS
+SELECT 
+    COUNT(*) FILTER (WHERE estado = 'PENDIENTE') AS pendientes,
+    COUNT(*) FILTER (WHERE estado = 'EN_PROCESO') AS en_proceso,
+    COUNT(*) FILTER (WHERE estado = 'RESUELTO') AS resueltos,
+    COUNT(*) FILTER (WHERE COALESCE(fecha_limite_ampliada, fecha_limite_respuesta) < CURRENT_DATE AND estado = 'PENDIENTE') AS vencidos,
+    COUNT(*) FILTER (WHERE tipo_solicitud = 'RECLAMO') AS total_reclamos,
+    COUNT(*) FILTER (WHERE tipo_solicitud = 'QUEJA') AS total_quejas,
+    COUNT(*) AS total
+FROM reclamos;
+
+CREATE OR REPLACE VIEW reclamos_pendientes AS
+SELECT 
+    id,
+    codigo_reclamo,
+    tipo_solicitud,
+    nombre_completo,
+    email,
+    fecha_registro,
+    COALESCE(fecha_limite_ampliada, fecha_limite_respuesta) AS fecha_limite_respuesta,
+    (COALESCE(fecha_limite_ampliada, fecha_limite_respuesta) - CURRENT_DATE) AS dias_restantes,
+    CASE 
+        WHEN COALESCE(fecha_limite_ampliada, fecha_limite_respuesta) < CURRENT_DATE THEN 'VENCIDO'
+        WHEN COALESCE(fecha_limite_ampliada, fecha_limite_respuesta) - CURRENT_DATE <= 3 THEN 'URGENTE'
+        ELSE 'NORMAL'
+    END AS prioridad
+FROM reclamos
+WHERE estado = 'PENDIENTE'
+ORDER BY COALESCE(fecha_limite_ampliada, fecha_limite_respuesta) ASC;
+
+CREATE OR REPLACE VIEW seguimiento_completo AS
+SELECT 
+    r.id,
+    r.codigo_reclamo,
+    r.tipo_solicitud,
+    r.estado,
+    r.nombre_completo,
+    r.numero_documento,
+    r.email,
+    r.telefono,
+    r.descripcion_bien,
+    r.detalle_reclamo,
+    r.pedido_consumidor,
+    r.fecha_registro,
+    COALESCE(r.fecha_limite_ampliada, r.fecha_limite_respuesta) AS fecha_limite_respuesta,
+    r.fecha_respuesta,
+    r.atendido_por,
+    ua.nombre_completo AS nombre_admin_atendio,
+    (COALESCE(r.fecha_limite_ampliada, r.fecha_limite_respuesta) - CURRENT_DATE) AS dias_restantes,
+    CASE 
+        WHEN r.estado = 'RESUELTO' OR r.estado = 'CERRADO' THEN 'COMPLETADO'
+        WHEN COALESCE(r.fecha_limite_ampliada, r.fecha_limite_respuesta) < CURRENT_DATE THEN 'VENCIDO'
+        WHEN COALESCE(r.fecha_limite_ampliada, r.fecha_limite_respuesta) - CURRENT_DATE <= 3 THEN 'URGENTE'
+        ELSE 'EN_TIEMPO'
+    END AS prioridad,
+    res.respuesta_empresa,
+    res.accion_tomada,
+    res.compensacion_ofrecida,
+    res.respondido_por,
+    res.fecha_respuesta AS fecha_respuesta_empresa
+FROM reclamos r
+LEFT JOIN respuestas res ON r.id = res.reclamo_id
+LEFT JOIN usuarios_admin ua ON r.atendido_por = ua.id;
+
+-- ============================================================================
+-- PAUSA DEL PLAZO: ESPERANDO AL CONSUMIDOR
+-- Mientras pausado_desde no es NULL el plazo no corre; al reanudar, los días
+-- de la pausa se suman a dias_pausados. Plazo vigente:
+-- COALESCE(fecha_limite_ampliada, fecha_limite_respuesta) + dias_pausados
+--   + días de la pausa abierta
+-- ============================================================================
+ALTER TABLE reclamos ADD COLUMN IF NOT EXISTS pausado_desde TIMESTAMP;
+ALTER TABLE reclamos ADD COLUMN IF NOT EXISTS dias_pausados INTEGER DEFAULT 0 NOT NULL;
+
+CREATE INDEX IF NOT EXISTS idx_reclamos_pausados ON reclamos(pausado_desde) WHERE pausado_desde IS NOT NULL;
+
+CREATE TABLE IF NOT EXISTS pausas_plazo (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    reclamo_id UUID NOT NULL REFERENCES reclamos(id) ON DELETE CASCADE,
+    mensaje_id UUID REFERENCES mensajes_seguimiento(id) ON DELETE SET NULL,
+    motivo TEXT NOT NULL,
+    iniciado_por VARCHAR(255) NOT NULL,
+    fecha_inicio TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    fecha_fin TIMESTAMP,
+    dias INTEGER,
+    motivo_fin VARCHAR(255)
+);
+
+CREATE INDEX IF NOT EXISTS idx_pausas_reclamo ON pausas_plazo(reclamo_id, fecha_inicio);
+
+ALTER TABLE historial_reclamos DROP CONSTRAINT IF EXISTS historial_reclamos_tipo_accion_check;
+ALTER TABLE historial_reclamos ADD CONSTRAINT historial_reclamos_tipo_accion_check
+    CHECK (tipo_accion IN ('CREACION', 'CAMBIO_ESTADO', 'RESPUESTA', 'MENSAJE_CLIENTE', 'ADJUNTO', 'NOTIFICACION', 'VENCIMIENTO', 'AMPLIACION_PLAZO', 'PAUSA_PLAZO', 'REANUDACION_PLAZO'));
+
+-- Las vistas descuentan el tiempo en pausa (mismas columnas)
+CREATE OR REPLACE VIEW dashboard_reclamos AS
+SELECT 
+    COUNT(*) FILTER (WHERE estado = 'PENDIENTE') AS pendientes,
+    COUNT(*) FILTER (WHERE estado = 'EN_PROCESO') AS en_proceso,
+    COUNT(*) FILTER (WHERE estado = 'RESUELTO') AS resueltos,
+    COUNT(*) FILTER (WHERE (COALESCE(fecha_limite_ampliada, fecha_limite_respuesta) + dias_pausados + COALESCE(CURRENT_DATE - pausado_desde::date, 0)) < CURRENT_DATE AND estado = 'PENDIENTE') AS vencidos,
+    COUNT(*) FILTER (WHERE tipo_solicitud = 'RECLAMO') AS total_reclamos,
+    COUNT(*) FILTER (WHERE tipo_solicitud = 'QUEJA') AS total_quejas,
+    COUNT(*) AS total
+FROM reclamos;
+
+CREATE OR REPLACE VIEW reclamos_pendientes AS
+SELECT 
+    id,
+    codigo_reclamo,
+    tipo_solicitud,
+    nombre_completo,
+    email,
+    fecha_registro,
+    (COALESCE(fecha_limite_ampliada, fecha_limite_respuesta) + dias_pausados + COALESCE(CURRENT_DATE - pausado_desde::date, 0)) AS fecha_limite_respuesta,
+    ((COALESCE(fecha_limite_ampliada, fecha_limite_respuesta) + dias_pausados + COALESCE(CURRENT_DATE - pausado_desde::date, 0)) - CURRENT_DATE) AS dias_restantes,
+    CASE 
+        WHEN (COALESCE(fecha_limite_ampliada, fecha_limite_respuesta) + dias_pausados + COALESCE(CURRENT_DATE - pausado_desde::date, 0)) < CURRENT_DATE THEN 'VENCIDO'
+        WHEN (COALESCE(fecha_limite_ampliada, fecha_limite_respuesta) + dias_pausados + COALESCE(CURRENT_DATE - pausado_desde::date, 0)) - CURRENT_DATE <= 3 THEN 'URGENTE'
+        ELSE 'NORMAL'
+    END AS prioridad
+FROM reclamos
+WHERE estado = 'PENDIENTE'
+ORDER BY (COALESCE(fecha_limite_ampliada, fecha_limite_respuesta) + dias_pausados + COALESCE(CURRENT_DATE - pausado_desde::date, 0)) ASC;
+
+CREATE OR REPLACE VIEW seguimiento_completo AS
+SELECT 
+    r.id,
+    r.codigo_reclamo,
+    r.tipo_solicitud,
+    r.estado,
+    r.nombre_completo,
+    r.numero_documento,
+    r.email,
+    r.telefono,
+    r.descripcion_bien,
+    r.detalle_reclamo,
+    r.pedido_consumidor,
+    r.fecha_registro,
+    (COALESCE(r.fecha_limite_ampliada, r.fecha_limite_respuesta) + r.dias_pausados + COALESCE(CURRENT_DATE - r.pausado_desde::date, 0)) AS fecha_limite_respuesta,
+    r.fecha_respuesta,
+    r.atendido_por,
+    ua.nombre_completo AS nombre_admin_atendio,
+    ((COALESCE(r.fecha_limite_ampliada, r.fecha_limite_respuesta) + r.dias_pausados + COALESCE(CURRENT_DATE - r.pausado_desde::date, 0)) - CURRENT_DATE) AS dias_restantes,
+    CASE 
+        WHEN r.estado = 'RESUELTO' OR r.estado = 'CERRADO' THEN 'COMPLETADO'
+        WHEN (COALESCE(r.fecha_limite_ampliada, r.fecha_limite_respuesta) + r.dias_pausados + COALESCE(CURRENT_DATE - r.pausado_desde::date, 0)) < CURRENT_DATE THEN 'VENCIDO'
+        WHEN (COALESCE(r.fecha_limite_ampliada, r.fecha_limite_respuesta) + r.dias_pausados + COALESCE(CURRENT_DATE - r.pausado_desde::date, 0)) - CURRENT_DATE <= 3 THEN 'URGENTE'
+        ELSE 'EN_TIEMPO'
+    END AS prioridad,
+    res.respuesta_empresa,
+    res.accion_tomada,
+    res.compensacion_ofrecida,
+    res.respondido_por,
+    res.fecha_respuesta AS fecha_respuesta_empresa
+FROM reclamos r
+LEFT JOIN respuestas res ON r.id = res.reclamo_id
+LEFT JOIN usuarios_admin ua ON r.atendido_por = ua.id;
+
+-- ============================================================================
+-- BÚSQUEDA DE TEXTO COMPLETO (solo PostgreSQL)
+-- Configuración es_unaccent: stemming en español sin distinguir tildes. Los
+-- índices GIN repiten las expresiones de busqueda.go. En CockroachDB el bloque
+-- falla en silencio y el backend usa la búsqueda básica con ILIKE.
+-- ============================================================================
+DO $$
+BEGIN
+    CREATE EXTENSION IF NOT EXISTS unaccent;
+
+    IF NOT EXISTS (SELECT 1 FROM pg_ts_config WHERE cfgname = 'es_unaccent') THEN
+        CREATE TEXT SEARCH CONFIGURATION es_unaccent (COPY = spanish);
+        ALTER TEXT SEARCH CONFIGURATION es_unaccent
+            ALTER MAPPING FOR hword, hword_part, word WITH unaccent, spanish_stem;
+    END IF;
+
+    CREATE INDEX IF NOT EXISTS idx_reclamos_busqueda ON reclamos USING GIN ((
+        setweight(to_tsvector('es_unaccent', coalesce(codigo_reclamo, '') || ' ' || coalesce(nombre_completo, '')), 'A') ||
+        setweight(to_tsvector('es_unaccent', coalesce(descripcion_bien, '') || ' ' || coalesce(detalle_reclamo, '') || ' ' || coalesce(pedido_consumidor, '')), 'B')
+    ));
+    CREATE INDEX IF NOT EXISTS idx_respuestas_busqueda ON respuestas USING GIN ((
+        to_tsvector('es_unaccent', coalesce(respuesta_empresa, '') || ' ' || coalesce(accion_tomada, '') || ' ' || coalesce(compensacion_ofrecida, ''))
+    ));
+    CREATE INDEX IF NOT EXISTS idx_mensajes_busqueda ON mensajes_seguimiento USING GIN ((
+        to_tsvector('es_unaccent', mensaje)
+    ));
+EXCEPTION WHEN OTHERS THEN
+    RAISE NOTICE 'Búsqueda de texto completo no disponible: %', SQLERRM;
+END $$;
+
+-- ============================================================================
+-- FILTROS AVANZADOS DEL LISTADO Y FILTROS GUARDADOS
+-- establecimiento identifica el local donde se presentó el reclamo; los
+-- filtros guardados son por usuario y se guardan como query string.
+-- ============================================================================
+ALTER TABLE reclamos ADD COLUMN IF NOT EXISTS establecimiento VARCHAR(255);
+
+CREATE INDEX IF NOT EXISTS idx_reclamos_establecimiento ON reclamos(establecimiento);
+CREATE INDEX IF NOT EXISTS idx_reclamos_tipo_bien ON reclamos(tipo_bien);
+CREATE INDEX IF NOT EXISTS idx_reclamos_atendido_por ON reclamos(atendido_por);
+CREATE INDEX IF NOT EXISTS idx_reclamos_numero_documento ON reclamos(numero_documento);
+CREATE INDEX IF NOT EXISTS idx_reclamos_fecha_id ON reclamos(fecha_registro DESC, id);
+
+CREATE TABLE IF NOT EXISTS filtros_guardados (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    usuario_id UUID NOT NULL REFERENCES usuarios_admin(id) ON DELETE CASCADE,
+    nombre VARCHAR(100) NOT NULL,
+    parametros TEXT NOT NULL,
+    predeterminado BOOLEAN DEFAULT false NOT NULL,
+    fecha_creacion TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (usuario_id, nombre)
+);
+
+-- ============================================================================
+-- OPERACIONES MASIVAS: ASIGNACIÓN Y ETIQUETAS
+-- Etiquetas libres por reclamo (en minúsculas). La asignación usa la columna
+-- atendido_por y ambas dejan su rastro en el historial.
+-- ============================================================================
+CREATE TABLE IF NOT EXISTS etiquetas_reclamos (
+    reclamo_id UUID NOT NULL REFERENCES reclamos(id) ON DELETE CASCADE,
+    etiqueta VARCHAR(50) NOT NULL,
+    agregada_por VARCHAR(255),
+    fecha_creacion TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    PRIMARY KEY (reclamo_id, etiqueta)
+);
+
+CREATE INDEX IF NOT EXISTS idx_etiquetas_reclamos_etiqueta ON etiquetas_reclamos(etiqueta);
+
+ALTER TABLE historial_reclamos DROP CONSTRAINT IF EXISTS historial_reclamos_tipo_accion_check;
+ALTER TABLE historial_reclamos ADD CONSTRAINT historial_reclamos_tipo_accion_check
+    CHECK (tipo_accion IN ('CREACION', 'CAMBIO_ESTADO', 'RESPUESTA', 'MENSAJE_CLIENTE', 'ADJUNTO', 'NOTIFICACION', 'VENCIMIENTO', 'AMPLIACION_PLAZO', 'PAUSA_PLAZO', 'REANUDACION_PLAZO', 'ASIGNACION', 'ETIQUETADO'));
+
+-- ============================================================================
+-- REPORTES PARA INSPECCIONES DE INDECOPI
+-- El paquete zip se guarda en BD hasta fecha_expiracion. Un reporte en
+-- PROCESANDO por más de 30 minutos se considera abandonado y se reintenta.
+-- ============================================================================
+CREATE TABLE IF NOT EXISTS reportes_indecopi (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    fecha_desde DATE NOT NULL,
+    fecha_hasta DATE NOT NULL,
+    establecimiento VARCHAR(255),
+    estado VARCHAR(20) DEFAULT 'PENDIENTE' NOT NULL CHECK (estado IN ('PENDIENTE', 'PROCESANDO', 'COMPLETADO', 'ERROR')),
+    intentos INTEGER DEFAULT 0 NOT NULL,
+    error TEXT,
+    resumen JSONB,
+    archivo_ruta TEXT,
+    archivo_sha256 CHAR(64),
+    tamano_bytes BIGINT,
+    total_reclamos INTEGER,
+    solicitado_por UUID REFERENCES usuarios_admin(id) ON DELETE SET NULL,
+    solicitado_por_email VARCHAR(255) NOT NULL,
+    fecha_solicitud TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    fecha_inicio TIMESTAMP,
+    fecha_fin TIMESTAMP,
+    fecha_expiracion TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_reportes_indecopi_estado ON reportes_indecopi(estado, fecha_solicitud);
+
+-- El paquete vive en disco (REPORTES_DIR); la BD solo guarda ruta y checksum
+ALTER TABLE reportes_indecopi ADD COLUMN IF NOT EXISTS archivo_ruta TEXT;
+ALTER TABLE reportes_indecopi ADD COLUMN IF NOT EXISTS archivo_sha256 CHAR(64);
+ALTER TABLE reportes_indecopi ALTER COLUMN tamano_bytes TYPE BIGINT;
+ALTER TABLE reportes_indecopi DROP COLUMN IF EXISTS archivo;
+
+-- ============================================================================
+-- ANALÍTICA DEL DASHBOARD
+-- Las series se agrupan por fecha de respuesta y por fecha de los cambios de
+-- estado además de la de registro.
+-- ============================================================================
+CREATE INDEX IF NOT EXISTS idx_reclamos_fecha_respuesta ON reclamos(fecha_respuesta) WHERE fecha_respuesta IS NOT NULL;
+CREATE INDEX IF NOT EXISTS idx_historial_tipo_fecha ON historial_reclamos(tipo_accion, fecha_accion);
+
+-- ============================================================================
+-- RESÚMENES PROGRAMADOS (DIGESTS)
+-- Envío diario o semanal a listas de destinatarios, con registro de envíos.
+-- ============================================================================
+CREATE TABLE IF NOT EXISTS digests (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    nombre VARCHAR(100) NOT NULL,
+    frecuencia VARCHAR(10) NOT NULL CHECK (frecuencia IN ('DIARIO', 'SEMANAL')),
+    dia_semana SMALLINT CHECK (dia_semana BETWEEN 1 AND 7),
+    hora SMALLINT DEFAULT 8 NOT NULL CHECK (hora BETWEEN 0 AND 23),
+    destinatarios TEXT[] NOT NULL,
+    secciones TEXT[] NOT NULL,
+    activo BOOLEAN DEFAULT true NOT NULL,
+    creado_por UUID REFERENCES usuarios_admin(id) ON DELETE SET NULL,
+    fecha_creacion TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    ultimo_envio TIMESTAMP,
+    CONSTRAINT digests_semanal_dia CHECK (frecuencia = 'DIARIO' OR dia_semana IS NOT NULL)
+);
+
+CREATE TABLE IF NOT EXISTS digests_envios (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    digest_id UUID NOT NULL REFERENCES digests(id) ON DELETE CASCADE,
+    periodo_desde TIMESTAMP NOT NULL,
+    periodo_hasta TIMESTAMP NOT NULL,
+    destinatarios TEXT[],
+    estado VARCHAR(10) NOT NULL CHECK (estado IN ('ENVIADO', 'PARCIAL', 'ERROR')),
+    error TEXT,
+    fecha_envio TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_digests_envios_digest ON digests_envios(digest_id, fecha_envio DESC);
+
+-- ============================================================================
+-- CATEGORÍAS DE RECLAMOS
+-- Árbol administrado (hasta tres niveles); cada reclamo tiene a lo sumo una
+-- categoría. Las etiquetas libres siguen en etiquetas_reclamos.
+-- ============================================================================
+CREATE TABLE IF NOT EXISTS categorias (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    nombre VARCHAR(100) NOT NULL,
+    padre_id UUID REFERENCES categorias(id) ON DELETE RESTRICT,
+    descripcion VARCHAR(500),
+    activa BOOLEAN DEFAULT true NOT NULL,
+    creado_por UUID REFERENCES usuarios_admin(id) ON DELETE SET NULL,
+    fecha_creacion TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    CONSTRAINT categorias_no_propio_padre CHECK (padre_id IS NULL OR padre_id <> id)
+);
+
+-- Nombre único entre hermanos (sin distinguir mayúsculas)
+CREATE UNIQUE INDEX IF NOT EXISTS idx_categorias_nombre_hermanos
+    ON categorias (COALESCE(padre_id, '00000000-0000-0000-0000-000000000000'::uuid), LOWER(nombre));
+
+ALTER TABLE reclamos ADD COLUMN IF NOT EXISTS categoria_id UUID REFERENCES categorias(id) ON DELETE SET NULL;
+CREATE INDEX IF NOT EXISTS idx_reclamos_categoria ON reclamos(categoria_id);
+
+-- Ruta legible ("Facturación > Cobros indebidos"), raíz y ancestros (incluida
+-- la propia categoría) para filtrar por subárbol y agrupar por raíz
+CREATE OR REPLACE VIEW v_categorias_ruta AS
+WITH RECURSIVE arbol AS (
+    SELECT id, nombre, padre_id, activa, 1 AS nivel, nombre::text AS ruta,
+           ARRAY[id] AS ancestros, id AS raiz_id, nombre::text AS raiz_nombre
+    FROM categorias
+    WHERE padre_id IS NULL
+    UNION ALL
+    SELECT c.id, c.nombre, c.padre_id, c.activa, a.nivel + 1, a.ruta || ' > ' || c.nombre,
+           a.ancestros || c.id, a.raiz_id, a.raiz_nombre
+    FROM categorias c
+    JOIN arbol a ON c.padre_id = a.id
+)
+SELECT * FROM arbol;
+
+ALTER TABLE historial_reclamos DROP CONSTRAINT IF EXISTS historial_reclamos_tipo_accion_check;
+ALTER TABLE historial_reclamos ADD CONSTRAINT historial_reclamos_tipo_accion_check
+    CHECK (tipo_accion IN ('CREACION', 'CAMBIO_ESTADO', 'RESPUESTA', 'MENSAJE_CLIENTE', 'ADJUNTO', 'NOTIFICACION', 'VENCIMIENTO', 'AMPLIACION_PLAZO', 'PAUSA_PLAZO', 'REANUDACION_PLAZO', 'ASIGNACION', 'ETIQUETADO', 'CATEGORIZACION'));
+
+-- ============================================================================
+-- TRIAJE AUTOMÁTICO
+-- Reglas evaluadas por orden al registrar un reclamo. condiciones y acciones
+-- son JSON validados por el backend (ver triaje.go).
+-- ============================================================================
+CREATE TABLE IF NOT EXISTS reglas_triaje (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    nombre VARCHAR(100) NOT NULL,
+    orden INTEGER DEFAULT 0 NOT NULL,
+    activa BOOLEAN DEFAULT true NOT NULL,
+    detener BOOLEAN DEFAULT false NOT NULL,
+    condiciones JSONB NOT NULL,
+    acciones JSONB NOT NULL,
+    coincidencias BIGINT DEFAULT 0 NOT NULL,
+    ultima_coincidencia TIMESTAMP,
+    creado_por UUID REFERENCES usuarios_admin(id) ON DELETE SET NULL,
+    fecha_creacion TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    fecha_modificacion TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_reglas_triaje_orden ON reglas_triaje(orden, fecha_creacion) WHERE activa;
+
+ALTER TABLE reclamos ADD COLUMN IF NOT EXISTS prioridad_asignada VARCHAR(10)
+    CHECK (prioridad_asignada IN ('BAJA', 'MEDIA', 'ALTA', 'CRITICA'));
+CREATE INDEX IF NOT EXISTS idx_reclamos_prioridad_asignada ON reclamos(prioridad_asignada) WHERE prioridad_asignada IS NOT NULL;
+
+ALTER TABLE historial_reclamos DROP CONSTRAINT IF EXISTS historial_reclamos_tipo_accion_check;
+ALTER TABLE historial_reclamos ADD CONSTRAINT historial_reclamos_tipo_accion_check
+    CHECK (tipo_accion IN ('CREACION', 'CAMBIO_ESTADO', 'RESPUESTA', 'MENSAJE_CLIENTE', 'ADJUNTO', 'NOTIFICACION', 'VENCIMIENTO', 'AMPLIACION_PLAZO', 'PAUSA_PLAZO', 'REANUDACION_PLAZO', 'ASIGNACION', 'ETIQUETADO', 'CATEGORIZACION', 'TRIAJE'));
+
+-- ============================================================================
+-- RESPUESTAS PREDEFINIDAS
+-- Plantillas con marcadores {{...}} clasificadas con el árbol de categorías.
+-- Cada renderizado para un reclamo queda registrado como uso.
+-- ============================================================================
+CREATE TABLE IF NOT EXISTS respuestas_predefinidas (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    titulo VARCHAR(150) NOT NULL,
+    categoria_id UUID REFERENCES categorias(id) ON DELETE SET NULL,
+    tipo_solicitud VARCHAR(20) CHECK (tipo_solicitud IN ('RECLAMO', 'QUEJA')),
+    respuesta_empresa TEXT NOT NULL,
+    accion_tomada TEXT,
+    compensacion_ofrecida TEXT,
+    activa BOOLEAN DEFAULT true NOT NULL,
+    usos BIGINT DEFAULT 0 NOT NULL,
+    ultimo_uso TIMESTAMP,
+    creado_por UUID REFERENCES usuarios_admin(id) ON DELETE SET NULL,
+    fecha_creacion TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    fecha_modificacion TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_respuestas_predefinidas_categoria ON respuestas_predefinidas(categoria_id);
+
+CREATE TABLE IF NOT EXISTS usos_respuestas_predefinidas (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    plantilla_id UUID NOT NULL REFERENCES respuestas_predefinidas(id) ON DELETE CASCADE,
+    reclamo_id UUID REFERENCES reclamos(id) ON DELETE SET NULL,
+    usuario_id UUID REFERENCES usuarios_admin(id) ON DELETE SET NULL,
+    fecha_uso TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_usos_respuestas_plantilla ON usos_respuestas_predefinidas(plantilla_id, fecha_uso);
+
+-- ============================================================================
+-- APROBACIÓN DE RESPUESTAS (CUATRO OJOS)
+-- Si alguna regla activa aplica al reclamo, la respuesta queda como propuesta
+-- PENDIENTE hasta que otro usuario con permiso la apruebe (ver aprobaciones.go).
+-- Una regla sin criterios exige aprobación para todos los reclamos.
+-- ============================================================================
+ALTER TABLE usuarios_admin ADD COLUMN IF NOT EXISTS puede_aprobar BOOLEAN DEFAULT false NOT NULL;
+ALTER TABLE respuestas ADD COLUMN IF NOT EXISTS aprobado_por VARCHAR(255);
+
+CREATE TABLE IF NOT EXISTS reglas_aprobacion (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    nombre VARCHAR(100) NOT NULL,
+    tipo_solicitud VARCHAR(20) CHECK (tipo_solicitud IN ('RECLAMO', 'QUEJA')),
+    monto_min DECIMAL(10, 2) CHECK (monto_min >= 0),
+    categoria_id UUID REFERENCES categorias(id) ON DELETE CASCADE,
+    activa BOOLEAN DEFAULT true NOT NULL,
+    creado_por UUID REFERENCES usuarios_admin(id) ON DELETE SET NULL,
+    fecha_creacion TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS propuestas_respuesta (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+    reclamo_id UUID NOT NULL REFERENCES reclamos(id) ON DELETE CASCADE,
+    respuesta_empresa TEXT NOT NULL,
+    accion_tomada TEXT,
+    compensacion_ofrecida TEXT,
+    estado VARCHAR(20) DEFAULT 'PENDIENTE' NOT NULL CHECK (estado IN ('PENDIENTE', 'APROBADA', 'RECHAZADA')),
+    regla VARCHAR(100) NOT NULL,
+    autor_id UUID REFERENCES usuarios_admin(id) ON DELETE SET NULL,
+    autor_email VARCHAR(255) NOT NULL,
+    revisor_id UUID REFERENCES usuarios_admin(id) ON DELETE SET NULL,
+    revisor_email VARCHAR(255),
+    comentario_revision TEXT,
+    editada_por_revisor BOOLEAN DEFAULT false NOT NULL,
+    fecha_creacion TIMESTAMP DEFAULT CURRENT_TIMESTAMP NOT NULL,
+    fecha_revision TIMESTAMP
+);
+
+-- Una sola propuesta pendiente por reclamo
+CREATE UNIQUE INDEX IF NOT EXISTS idx_propuestas_respuesta_pendiente
+    ON propuestas_respuesta(reclamo_id) WHERE estado = 'PENDIENTE';
+CREATE INDEX IF NOT EXISTS idx_propuestas_respuesta_estado ON propuestas_respuesta(estado, fecha_creacion);
+
+-- Quien escribió el texto vigente (si no fue el autor) tampoco puede aprobarlo
+ALTER TABLE propuestas_respuesta ADD COLUMN IF NOT EXISTS editada_por UUID REFERENCES usuarios_admin(id) ON DELETE SET NULL;
+
+ALTER TABLE historial_reclamos DROP CONSTRAINT IF EXISTS historial_reclamos_tipo_accion_check;
+ALTER TABLE historial_reclamos ADD CONSTRAINT historial_reclamos_tipo_accion_check
+    CHECK (tipo_accion IN ('CREACION', 'CAMBIO_ESTADO', 'RESPUESTA', 'MENSAJE_CLIENTE', 'ADJUNTO', 'NOTIFICACION', 'VENCIMIENTO', 'AMPLIACION_PLAZO', 'PAUSA_PLAZO', 'REANUDACION_PLAZO', 'ASIGNACION', 'ETIQUETADO', 'CATEGORIZACION', 'TRIAJE', 'RESPUESTA_PROPUESTA', 'RESPUESTA_RECHAZADA'));
+
+-- ============================================================================
+-- VERSIONES DE LA RESPUESTA OFICIAL
+-- Las respuestas publicadas son inmutables: una enmienda agrega la versión
+-- siguiente con su motivo y reemplaza_a apuntando a la anterior. Todas las
+-- lecturas usan v_respuestas_vigentes (última versión por reclamo).
+-- ============================================================================
+ALTER TABLE respuestas ADD COLUMN IF NOT EXISTS version INTEGER DEFAULT 1 NOT NULL;
+ALTER TABLE respuestas ADD COLUMN IF NOT EXISTS motivo_enmienda TEXT;
+ALTER TABLE respuestas ADD COLUMN IF NOT EXISTS reemplaza_a UUID REFERENCES respuestas(id);
+ALTER TABLE propuestas_respuesta ADD COLUMN IF NOT EXISTS motivo_enmienda TEXT;
+
+-- Las filas repetidas de antes pasan a ser versiones por orden de fecha
+UPDATE respuestas res
+SET version = v.n, reemplaza_a = v.anterior
+FROM (
+    SELECT id, ROW_NUMBER() OVER w AS n, LAG(id) OVER w AS anterior
+    FROM respuestas
+    WINDOW w AS (PARTITION BY reclamo_id ORDER BY fecha_respuesta, id)
+) v
+WHERE v.id = res.id AND res.version IS DISTINCT FROM v.n;
+
+CREATE UNIQUE INDEX IF NOT EXISTS idx_respuestas_version ON respuestas(reclamo_id, version);
+
+-- Columnas explícitas: una columna nueva en respuestas no cambia la vista
+CREATE OR REPLACE VIEW v_respuestas_vigentes AS
+SELECT DISTINCT ON (reclamo_id)
+    id,
+    reclamo_id,
+    respuesta_empresa,
+    accion_tomada,
+    compensacion_ofrecida,
+    respondido_por,
+    cargo_responsable,
+    fecha_respuesta,
+    archivos_adjuntos,
+    notificado_cliente,
+    fecha_notificacion,
+    aprobado_por,
+    version,
+    motivo_enmienda,
+    reemplaza_a
+FROM respuestas
+ORDER BY reclamo_id, version DESC;
+
+CREATE OR REPLACE VIEW seguimiento_completo AS
+SELECT 
+    r.id,
+    r.codigo_reclamo,
+    r.tipo_solicitud,
+    r.estado,
+    r.nombre_completo,
+    r.numero_documento,
+    r.email,
+    r.telefono,
+    r.descripcion_bien,
+    r.detalle_reclamo,
+    r.pedido_consumidor,
+    r.fecha_registro,
+    (COALESCE(r.fecha_limite_ampliada, r.fecha_limite_respuesta) + r.dias_pausados + COALESCE(CURRENT_DATE - r.pausado_desde::date, 0)) AS fecha_limite_respuesta,
+    r.fecha_respuesta,
+    r.atendido_por,
+    ua.nombre_completo AS nombre_admin_atendio,
+    ((COALESCE(r.fecha_limite_ampliada, r.fecha_limite_respuesta) + r.dias_pausados + COALESCE(CURRENT_DATE - r.pausado_desde::date, 0)) - CURRENT_DATE) AS dias_restantes,
+    CASE 
+        WHEN r.estado = 'RESUELTO' OR r.estado = 'CERRADO' THEN 'COMPLETADO'
+        WHEN (COALESCE(r.fecha_limite_ampliada, r.fecha_limite_respuesta) + r.dias_pausados + COALESCE(CURRENT_DATE - r.pausado_desde::date, 0)) < CURRENT_DATE THEN 'VENCIDO'
+        WHEN (COALESCE(r.fecha_limite_ampliada, r.fecha_limite_respuesta) + r.dias_pausados + COALESCE(CURRENT_DATE - r.pausado_desde::date, 0)) - CURRENT_DATE <= 3 THEN 'URGENTE'
+        ELSE 'EN_TIEMPO'
+    END AS prioridad,
+    res.respuesta_empresa,
+    res.accion_tomada,
+    res.compensacion_ofrecida,
+    res.respondido_por,
+    res.fecha_respuesta AS fecha_respuesta_empresa
+FROM reclamos r
+LEFT JOIN v_respuestas_vigentes res ON r.id = res.reclamo_id
+LEFT JOIN usuarios_admin ua ON r.atendido_por = ua.id;
+
+-- Solo se permite marcar la notificación (notificado_cliente,
+-- fecha_notificacion). Se compara la fila completa para que una columna nueva
+-- quede protegida sin tocar la función. Una respuesta solo se borra en cascada
+-- con su reclamo, y TRUNCATE está bloqueado.
+CREATE OR REPLACE FUNCTION proteger_respuestas()
+RETURNS TRIGGER AS $$
+BEGIN
+    IF TG_OP = 'TRUNCATE' THEN
+        RAISE EXCEPTION 'Las respuestas publicadas no se eliminan; registre una enmienda';
+    END IF;
+    IF TG_OP = 'DELETE' THEN
+        IF EXISTS (SELECT 1 FROM reclamos WHERE id = OLD.reclamo_id) THEN
+            RAISE EXCEPTION 'Las respuestas publicadas no se eliminan; registre una enmienda';
+        END IF;
+        RETURN OLD;
+    END IF;
+    IF (to_jsonb(NEW) - 'notificado_cliente' - 'fecha_notificacion')
+       IS DISTINCT FROM
+       (to_jsonb(OLD) - 'notificado_cliente' - 'fecha_notificacion') THEN
+        RAISE EXCEPTION 'Las respuestas publicadas son inmutables; registre una enmienda';
+    END IF;
+    RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+
+-- Como los demás triggers, CockroachDB lo omite; en PostgreSQL un fallo queda
+-- como WARNING en vez de perderse en silencio
+DO $$
+BEGIN
+    IF EXISTS (SELECT 1 FROM pg_proc WHERE proname = 'proteger_respuestas') THEN
+        DROP TRIGGER IF EXISTS trigger_respuestas_inmutables ON respuestas;
+        CREATE TRIGGER trigger_respuestas_inmutables
+            BEFORE UPDATE OR DELETE ON respuestas
+            FOR EACH ROW
+            EXECUTE FUNCTION proteger_respuestas();
+        DROP TRIGGER IF EXISTS trigger_respuestas_sin_truncate ON respuestas;
+        CREATE TRIGGER trigger_respuestas_sin_truncate
+            BEFORE TRUNCATE ON respuestas
+            FOR EACH STATEMENT
+            EXECUTE FUNCTION proteger_respuestas();
+    END IF;
+EXCEPTION WHEN OTHERS THEN
+    RAISE WARNING 'No se pudo proteger la tabla respuestas: %', SQLERRM;
+END $$;
+
+ALTER TABLE historial_reclamos DROP CONSTRAINT IF EXISTS historial_reclamos_tipo_accion_check;
+ALTER TABLE historial_reclamos ADD CONSTRAINT historial_reclamos_tipo_accion_check
+    CHECK (tipo_accion IN ('CREACION', 'CAMBIO_ESTADO', 'RESPUESTA', 'MENSAJE_CLIENTE', 'ADJUNTO', 'NOTIFICACION', 'VENCIMIENTO', 'AMPLIACION_PLAZO', 'PAUSA_PLAZO', 'REANUDACION_PLAZO', 'ASIGNACION', 'ETIQUETADO', 'CATEGORIZACION', 'TRIAJE', 'RESPUESTA_PROPUESTA', 'RESPUESTA_RECHAZADA', 'ENMIENDA_RESPUESTA'));
@@ -0,0 +1,437 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+)
+
+// =============================================================================
+// LEASES DE TAREAS
+// Con varias réplicas (o tras un reinicio) cada tarea periódica debe correr una
+// sola vez por ventana. El lease es una fila en la BD: quien la toma mientras
+// está vencida ejecuta la tarea; el resto la salta.
+// =============================================================================
+
+var instanciaID = func() string {
+	host, _ := os.Hostname()
+	return fmt.Sprintf("%s-%d-%d", host, os.Getpid(), time.Now().UnixNano())
+}()
+
+// adquirirLease devuelve true si esta instancia obtuvo el lease por duracion.
+func adquirirLease(ctx context.Context, nombre string, duracion time.Duration) (bool, error) {
+	var propietario string
+	err := pool.QueryRow(ctx, `
+		INSERT INTO leases_tareas (nombre, propietario, expira)
+		VALUES ($1, $2, NOW() + ($3::int * INTERVAL '1 second'))
+		ON CONFLICT (nombre) DO UPDATE
+		SET propietario = EXCLUDED.propietario, expira = EXCLUDED.expira
+		WHERE leases_tareas.expira <= NOW()
+		RETURNING propietario
+	`, nombre, instanciaID, int(duracion.Seconds())).Scan(&propietario)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// =============================================================================
+// DÍAS HÁBILES
+// El plazo legal se cuenta en días calendario (sqlFechaLimite), pero
+// los avisos se configuran en días hábiles: sin sábados, domingos ni feriados.
+// =============================================================================
+
+// Feriados nacionales de fecha fija; los móviles (Semana Santa) y los
+// extraordinarios se agregan con SLA_FERIADOS=AAAA-MM-DD,AAAA-MM-DD
+var feriadosFijos = map[string]bool{
+	"01-01": true, "05-01": true, "06-07": true, "06-29": true, "07-23": true,
+	"07-28": true, "07-29": true, "08-06": true, "08-30": true, "10-08": true,
+	"11-01": true, "12-08": true, "12-09": true, "12-25": true,
+}
+
+func esDiaHabil(dia time.Time, feriados map[string]bool) bool {
+	if dia.Weekday() == time.Saturday || dia.Weekday() == time.Sunday {
+		return false
+	}
+	return !feriadosFijos[dia.Format("01-02")] && !feriados[dia.Format("2006-01-02")]
+}
+
+// diasHabilesRestantes cuenta los días hábiles de (hoy, limite]. Si el plazo
+// ya pasó devuelve el número de días hábiles de atraso en negativo; el mismo
+// día del vencimiento devuelve 0.
+func diasHabilesRestantes(hoy, limite time.Time, feriados map[string]bool) int {
+	hoy = time.Date(hoy.Year(), hoy.Month(), hoy.Day(), 0, 0, 0, 0, time.UTC)
+	limite = time.Date(limite.Year(), limite.Month(), limite.Day(), 0, 0, 0, 0, time.UTC)
+
+	desde, hasta, signo := hoy, limite, 1
+	if limite.Before(hoy) {
+		desde, hasta, signo = limite, hoy, -1
+	}
+
+	dias := 0
+	for d := desde.AddDate(0, 0, 1); !d.After(hasta); d = d.AddDate(0, 0, 1) {
+		if esDiaHabil(d, feriados) {
+			dias++
+		}
+	}
+	if signo < 0 && dias == 0 {
+		// Vencido durante un fin de semana o feriado: igual cuenta como atraso
+		dias = 1
+	}
+	return signo * dias
+}
+
+func feriadosConfigurados() map[string]bool {
+	feriados := make(map[string]bool)
+	for _, f := range strings.Split(config.SLAFeriados, ",") {
+		if f = strings.TrimSpace(f); f != "" {
+			feriados[f] = true
+		}
+	}
+	return feriados
+}
+
+// umbralesSLA interpreta SLA_UMBRALES_DIAS ("5,3,1") de mayor a menor.
+func umbralesSLA(valor string) []int {
+	var umbrales []int
+	for _, u := range strings.Split(valor, ",") {
+		if n, err := strconv.Atoi(strings.TrimSpace(u)); err == nil && n >= 0 {
+			umbrales = append(umbrales, n)
+		}
+	}
+	sort.Sort(sort.Reverse(sort.IntSlice(umbrales)))
+	return umbrales
+}
+
+const nivelVencido = "VENCIDO"
+
+// nivelSLA devuelve el umbral alcanzado ("5", "3", "1", "VENCIDO") o "" si el
+// reclamo aún no entra en ninguno. Si se cruzaron varios de golpe (p. ej. tras
+// una caída del servidor) solo cuenta el más bajo: no se envían avisos viejos.
+func nivelSLA(diasRestantes int, umbrales []int) string {
+	if diasRestantes < 0 {
+		return nivelVencido
+	}
+	nivel := ""
+	for _, u := range umbrales {
+		if diasRestantes <= u {
+			nivel = strconv.Itoa(u)
+		}
+	}
+	return nivel
+}
+
+// escalaASupervisores indica si el nivel se avisa también a los supervisores.
+func escalaASupervisores(nivel string, diasRestantes int, conAgente bool) bool {
+	return !conAgente || nivel == nivelVencido || diasRestantes <= config.SLAUmbralSupervisores
+}
+
+// =============================================================================
+// VERIFICACIÓN HORARIA: avisos por umbral y marca de vencimiento
+// =============================================================================
+
+const (
+	destinoAgente       = "AGENTE"
+	destinoSupervisores = "SUPERVISORES"
+)
+
+type reclamoSLA struct {
+	ID            string
+	Codigo        string
+	Tipo          string
+	Estado        string
+	Consumidor    string
+	FechaLimite   time.Time
+	DiasRestantes int
+	AgenteEmail   *string
+	AgenteNombre  *string
+}
+
+// reclamosEnRiesgo devuelve los reclamos abiertos que ya entraron en algún
+// umbral, con los días hábiles restantes calculados. Los que esperan al
+// consumidor no corren plazo y no se avisan.
+func reclamosEnRiesgo(ctx context.Context, umbrales []int, feriados map[string]bool) ([]reclamoSLA, error) {
+	// Margen en días calendario para no perder fines de semana ni feriados
+	margen := 7
+	if len(umbrales) > 0 {
+		margen += umbrales[0] * 2
+	}
+
+	rows, err := pool.Query(ctx, `
+		SELECT r.id, r.codigo_reclamo, r.tipo_solicitud, r.estado, r.nombre_completo,
+		       `+sqlFechaLimite+`, CURRENT_DATE, ua.email, ua.nombre_completo
+		FROM reclamos r
+		LEFT JOIN usuarios_admin ua ON ua.id = r.atendido_por AND ua.activo = true
+		WHERE r.estado IN ('PENDIENTE', 'EN_PROCESO') AND r.pausado_desde IS NULL
+		  AND `+sqlFechaLimite+` <= CURRENT_DATE + $1::int
+		ORDER BY `+sqlFechaLimite+` ASC
+	`, margen)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reclamos []reclamoSLA
+	for rows.Next() {
+		var r reclamoSLA
+		var hoy time.Time
+		if err := rows.Scan(&r.ID, &r.Codigo, &r.Tipo, &r.Estado, &r.Consumidor,
+			&r.FechaLimite, &hoy, &r.AgenteEmail, &r.AgenteNombre); err != nil {
+			return nil, err
+		}
+		r.DiasRestantes = diasHabilesRestantes(hoy, r.FechaLimite, feriados)
+		if nivelSLA(r.DiasRestantes, umbrales) != "" {
+			reclamos = append(reclamos, r)
+		}
+	}
+	return reclamos, rows.Err()
+}
+
+// verificarSLA corre cada hora en una sola réplica.
+func verificarSLA(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	ok, err := adquirirLease(ctx, "sla_horario", 55*time.Minute)
+	if err != nil {
+		log.Printf("⚠️ Error tomando lease de SLA: %v", err)
+		return
+	}
+	if !ok {
+		return
+	}
+
+	umbrales := umbralesSLA(config.SLAUmbralesDias)
+	reclamos, err := reclamosEnRiesgo(ctx, umbrales, feriadosConfigurados())
+	if err != nil {
+		log.Printf("❌ Error buscando reclamos en riesgo de vencer: %v", err)
+		return
+	}
+
+	supervisores, err := emailsSupervisores(ctx)
+	if err != nil {
+		log.Printf("⚠️ Error obteniendo supervisores: %v", err)
+	}
+
+	for _, r := range reclamos {
+		nivel := nivelSLA(r.DiasRestantes, umbrales)
+		if nivel == nivelVencido {
+			marcarVencimiento(ctx, r)
+		}
+
+		if r.AgenteEmail != nil {
+			enviarAlertaSLA(ctx, r, nivel, destinoAgente, []string{*r.AgenteEmail})
+		}
+		if escalaASupervisores(nivel, r.DiasRestantes, r.AgenteEmail != nil) {
+			enviarAlertaSLA(ctx, r, nivel, destinoSupervisores, supervisores)
+		}
+	}
+}
+
+// emailsSupervisores son los ADMIN activos; sin ninguno, el buzón de soporte.
+func emailsSupervisores(ctx context.Context) ([]string, error) {
+	rows, err := pool.Query(ctx, "SELECT email FROM usuarios_admin WHERE rol = 'ADMIN' AND activo = true ORDER BY email")
+	if err != nil {
+		return []string{config.EmailSoporte}, err
+	}
+	defer rows.Close()
+
+	var emails []string
+	for rows.Next() {
+		var email string
+		if rows.Scan(&email) == nil {
+			emails = append(emails, email)
+		}
+	}
+	if len(emails) == 0 {
+		emails = []string{config.EmailSoporte}
+	}
+	return emails, rows.Err()
+}
+
+// marcarVencimiento deja constancia del incumplimiento en el historial una
+// sola vez por reclamo.
+func marcarVencimiento(ctx context.Context, r reclamoSLA) {
+	tag, err := pool.Exec(ctx, `
+		INSERT INTO historial_reclamos (reclamo_id, estado_anterior, estado_nuevo, tipo_accion, comentario, usuario_accion)
+		SELECT $1, $2, $2, 'VENCIMIENTO', $3, 'SISTEMA'
+		WHERE NOT EXISTS (SELECT 1 FROM historial_reclamos WHERE reclamo_id = $1 AND tipo_accion = 'VENCIMIENTO')
+	`, r.ID, r.Estado, fmt.Sprintf("Plazo legal de respuesta vencido el %s sin respuesta", r.FechaLimite.Format("02/01/2006")))
+	if err != nil {
+		log.Printf("❌ Error marcando vencimiento de %s: %v", r.Codigo, err)
+		return
+	}
+	if tag.RowsAffected() > 0 {
+		log.Printf("⚠️ %s: plazo legal vencido, registrado en el historial", r.Codigo)
+	}
+}
+
+// enviarAlertaSLA reserva el aviso (reclamo, nivel, destino) antes de enviar,
+// así un reinicio o una réplica tardía no lo repiten. Si el envío falla se
+// libera para la próxima vuelta.
+func enviarAlertaSLA(ctx context.Context, r reclamoSLA, nivel, destino string, emails []string) {
+	if len(emails) == 0 {
+		return
+	}
+
+	tag, err := pool.Exec(ctx, `
+		INSERT INTO alertas_sla (reclamo_id, nivel, destino, destinatarios)
+		VALUES ($1, $2, $3, $4)
+		ON CONFLICT (reclamo_id, nivel, destino) DO NOTHING
+	`, r.ID, nivel, destino, strings.Join(emails, ", "))
+	if err != nil {
+		log.Printf("❌ Error reservando alerta SLA de %s: %v", r.Codigo, err)
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		return
+	}
+
+	asunto, contenido := generarEmailAlertaSLA(r, nivel, destino)
+	enviados := 0
+	for _, email := range emails {
+		if err := enviarEmailHTML(email, asunto, contenido); err != nil {
+			log.Printf("❌ Error enviando alerta SLA de %s a %s: %v", r.Codigo, email, err)
+			continue
+		}
+		enviados++
+	}
+
+	if enviados == 0 {
+		pool.Exec(ctx, "DELETE FROM alertas_sla WHERE reclamo_id = $1 AND nivel = $2 AND destino = $3", r.ID, nivel, destino)
+		return
+	}
+	log.Printf("✅ Alerta SLA %s de %s enviada a %s (%d)", nivel, r.Codigo, strings.ToLower(destino), enviados)
+}
+
+func describirPlazo(dias int) string {
+	switch {
+	case dias < 0:
+		return fmt.Sprintf("venció hace %d día(s) hábil(es)", -dias)
+	case dias == 0:
+		return "vence hoy"
+	default:
+		return fmt.Sprintf("vence en %d día(s) hábil(es)", dias)
+	}
+}
+
+func generarEmailAlertaSLA(r reclamoSLA, nivel, destino string) (string, string) {
+	plazo := describirPlazo(r.DiasRestantes)
+	asunto := fmt.Sprintf("⏰ %s %s %s", r.Tipo, r.Codigo, plazo)
+	titulo, color := "⏰ Plazo por vencer", "#d97706"
+	if nivel == nivelVencido {
+		asunto = fmt.Sprintf("🚨 %s %s: plazo legal vencido", r.Tipo, r.Codigo)
+		titulo, color = "🚨 Plazo legal vencido", "#dc2626"
+	}
+
+	agente := "sin asignar"
+	if r.AgenteNombre != nil {
+		agente = *r.AgenteNombre
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, `<p style="margin: 0 0 12px 0; color: #1f2937;">El %s <strong>%s</strong> de %s %s (fecha límite: %s).</p>
+<p style="margin: 0 0 12px 0;">Estado: <strong>%s</strong> · Agente: <strong>%s</strong></p>`,
+		strings.ToLower(r.Tipo), html.EscapeString(r.Codigo), html.EscapeString(r.Consumidor),
+		plazo, r.FechaLimite.Format("02/01/2006"), r.Estado, html.EscapeString(agente))
+	if destino == destinoSupervisores {
+		b.WriteString(`<p style="margin: 0 0 12px 0;">Se escala a supervisión para asegurar la respuesta dentro del plazo legal.</p>`)
+	}
+
+	contenido := generarEmailAviso(titulo, color, b.String(), "Abrir reclamo",
+		fmt.Sprintf("%s/admin/reclamos/%s", config.FrontendURL, r.ID),
+		"Aviso automático de vencimiento de plazos del Libro de Reclamaciones.")
+	return asunto, contenido
+}
+
+// =============================================================================
+// RESUMEN DIARIO A SUPERVISORES
+// =============================================================================
+
+// resumenDiarioSLA envía una vez al día la lista de reclamos vencidos o en el
+// umbral más bajo. El lease lleva la fecha: se toma una vez por día aunque el
+// servidor se reinicie.
+func resumenDiarioSLA(ctx context.Context) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Minute)
+	defer cancel()
+
+	ok, err := adquirirLease(ctx, "sla_diario:"+time.Now().Format("2006-01-02"), 36*time.Hour)
+	if err != nil {
+		log.Printf("⚠️ Error tomando lease del resumen SLA: %v", err)
+		return
+	}
+	if !ok {
+		return
+	}
+	pool.Exec(ctx, "DELETE FROM leases_tareas WHERE expira < NOW() - INTERVAL '7 days'")
+
+	umbrales := umbralesSLA(config.SLAUmbralesDias)
+	reclamos, err := reclamosEnRiesgo(ctx, umbrales, feriadosConfigurados())
+	if err != nil {
+		log.Printf("❌ Error preparando resumen SLA: %v", err)
+		return
+	}
+
+	minimo := 0
+	if len(umbrales) > 0 {
+		minimo = umbrales[len(umbrales)-1]
+	}
+	var criticos []reclamoSLA
+	for _, r := range reclamos {
+		if r.DiasRestantes <= minimo {
+			criticos = append(criticos, r)
+		}
+	}
+	if len(criticos) == 0 {
+		return
+	}
+
+	supervisores, err := emailsSupervisores(ctx)
+	if err != nil {
+		log.Printf("⚠️ Error obteniendo supervisores: %v", err)
+	}
+
+	asunto := fmt.Sprintf("📋 Resumen SLA: %d reclamo(s) vencidos o por vencer", len(criticos))
+	contenido := generarEmailResumenSLA(criticos)
+	for _, email := range supervisores {
+		if err := enviarEmailHTML(email, asunto, contenido); err != nil {
+			log.Printf("❌ Error enviando resumen SLA a %s: %v", email, err)
+		}
+	}
+	log.Printf("✅ Resumen SLA enviado: %d reclamo(s) críticos", len(criticos))
+}
+
+func generarEmailResumenSLA(reclamos []reclamoSLA) string {
+	var b strings.Builder
+	b.WriteString(`<table style="width: 100%; border-collapse: collapse; font-size: 13px;">
+<tr style="background-color: #f3f4f6; text-align: left;"><th style="padding: 8px;">Código</th><th style="padding: 8px;">Plazo</th><th style="padding: 8px;">Estado</th><th style="padding: 8px;">Agente</th></tr>`)
+	for _, r := range reclamos {
+		agente := "sin asignar"
+		if r.AgenteNombre != nil {
+			agente = *r.AgenteNombre
+		}
+		color := "#d97706"
+		if r.DiasRestantes < 0 {
+			color = "#dc2626"
+		}
+		fmt.Fprintf(&b, `<tr style="border-top: 1px solid #e5e7eb;"><td style="padding: 8px;"><a href="%s/admin/reclamos/%s">%s</a></td><td style="padding: 8px; color: %s;">%s</td><td style="padding: 8px;">%s</td><td style="padding: 8px;">%s</td></tr>`,
+			config.FrontendURL, r.ID, html.EscapeString(r.Codigo), color, describirPlazo(r.DiasRestantes), r.Estado, html.EscapeString(agente))
+	}
+	b.WriteString(`</table>`)
+
+	return generarEmailAviso("📋 Resumen diario de plazos", "#1e40af", b.String(),
+		"Ir al panel", config.FrontendURL+"/admin/dashboard",
+		"Resumen automático diario de vencimiento de plazos del Libro de Reclamaciones.")
+}
@@ -0,0 +1,620 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// =============================================================================
+// TRIAJE AUTOMÁTICO
+// Reglas evaluadas en orden sobre cada reclamo recién registrado. Todas las
+// condiciones indicadas deben cumplirse (dentro de una lista basta con una).
+// Para categoría, prioridad y agente gana la primera regla que los fija; las
+// etiquetas y los avisos se acumulan. Una regla con "detener" corta la
+// evaluación. Cada acción se aplica en su propia transacción: si una falla
+// (categoría desactivada, agente inactivo) las demás siguen.
+// =============================================================================
+
+var prioridadesAsignables = []string{"BAJA", "MEDIA", "ALTA", "CRITICA"}
+
+type condicionesTriaje struct {
+	PalabrasClave    []string `json:"palabras_clave,omitempty"` // en detalle_reclamo, sin tildes ni mayúsculas
+	MontoMin         *float64 `json:"monto_min,omitempty"`
+	MontoMax         *float64 `json:"monto_max,omitempty"`
+	TiposSolicitud   []string `json:"tipos_solicitud,omitempty"`
+	AreasQueja       []string `json:"areas_queja,omitempty"`
+	Establecimientos []string `json:"establecimientos,omitempty"`
+}
+
+type accionesTriaje struct {
+	CategoriaID *string  `json:"categoria_id,omitempty"`
+	Prioridad   string   `json:"prioridad,omitempty"`
+	AgenteID    *string  `json:"agente_id,omitempty"`
+	Etiquetas   []string `json:"etiquetas,omitempty"`
+	Notificar   []string `json:"notificar,omitempty"` // emails que reciben un aviso
+}
+
+type reglaTriaje struct {
+	ID          string            `json:"id"`
+	Nombre      string            `json:"nombre"`
+	Orden       int               `json:"orden"`
+	Detener     bool              `json:"detener"`
+	Condiciones condicionesTriaje `json:"condiciones"`
+	Acciones    accionesTriaje    `json:"acciones"`
+}
+
+// reclamoTriaje son los datos del reclamo que pueden evaluar las reglas.
+type reclamoTriaje struct {
+	ID, Codigo, Tipo      string
+	Detalle               string
+	Monto                 float64
+	Area, Establecimiento string
+}
+
+func contieneTexto(lista []string, valor string) bool {
+	for _, v := range lista {
+		if strings.EqualFold(strings.TrimSpace(v), strings.TrimSpace(valor)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (c condicionesTriaje) validar() error {
+	if len(c.PalabrasClave) == 0 && c.MontoMin == nil && c.MontoMax == nil && len(c.TiposSolicitud) == 0 &&
+		len(c.AreasQueja) == 0 && len(c.Establecimientos) == 0 {
+		return errors.New("la regla necesita al menos una condición")
+	}
+	if len(c.PalabrasClave) > 50 {
+		return errors.New("se permiten como máximo 50 palabras clave")
+	}
+	for _, p := range c.PalabrasClave {
+		if strings.TrimSpace(p) == "" || len([]rune(p)) > 100 {
+			return errors.New("las palabras clave no pueden estar vacías ni superar 100 caracteres")
+		}
+	}
+	if (c.MontoMin != nil && *c.MontoMin < 0) || (c.MontoMax != nil && *c.MontoMax < 0) {
+		return errors.New("los montos deben ser positivos")
+	}
+	if c.MontoMin != nil && c.MontoMax != nil && *c.MontoMax < *c.MontoMin {
+		return errors.New("monto_max no puede ser menor que monto_min")
+	}
+	for _, t := range c.TiposSolicitud {
+		if !contieneTexto(tiposValidos, t) {
+			return fmt.Errorf("tipo de solicitud inválido: %s", t)
+		}
+	}
+	return nil
+}
+
+func (a *accionesTriaje) validar() error {
+	if a.CategoriaID == nil && a.Prioridad == "" && a.AgenteID == nil && len(a.Etiquetas) == 0 && len(a.Notificar) == 0 {
+		return errors.New("la regla necesita al menos una acción")
+	}
+	if a.CategoriaID != nil && !reUUID.MatchString(*a.CategoriaID) {
+		return errors.New("categoria_id inválido")
+	}
+	if a.AgenteID != nil && !reUUID.MatchString(*a.AgenteID) {
+		return errors.New("agente_id inválido")
+	}
+	if a.Prioridad != "" {
+		a.Prioridad = strings.ToUpper(a.Prioridad)
+		if !contieneTexto(prioridadesAsignables, a.Prioridad) {
+			return errors.New("prioridad debe ser BAJA, MEDIA, ALTA o CRITICA")
+		}
+	}
+	if len(a.Etiquetas) > 0 {
+		etiquetas, err := normalizarEtiquetas(a.Etiquetas)
+		if err != nil {
+			return err
+		}
+		a.Etiquetas = etiquetas
+	}
+	if len(a.Notificar) > 10 {
+		return errors.New("se puede notificar como máximo a 10 destinatarios")
+	}
+	for _, e := range a.Notificar {
+		if !emailRegex.MatchString(e) {
+			return fmt.Errorf("email inválido: %s", e)
+		}
+	}
+	return nil
+}
+
+// coincide evalúa las condiciones sobre el reclamo.
+func (c condicionesTriaje) coincide(r reclamoTriaje) bool {
+	if len(c.PalabrasClave) > 0 {
+		detalle := plegarTexto([]rune(r.Detalle))
+		encontrada := false
+		for _, p := range c.PalabrasClave {
+			patron := plegarTexto([]rune(strings.TrimSpace(p)))
+			if len(patron) > 0 && indiceRunas(detalle, patron, 0) >= 0 {
+				encontrada = true
+				break
+			}
+		}
+		if !encontrada {
+			return false
+		}
+	}
+	if c.MontoMin != nil && r.Monto < *c.MontoMin {
+		return false
+	}
+	if c.MontoMax != nil && r.Monto > *c.MontoMax {
+		return false
+	}
+	if len(c.TiposSolicitud) > 0 && !contieneTexto(c.TiposSolicitud, r.Tipo) {
+		return false
+	}
+	if len(c.AreasQueja) > 0 && !contieneTexto(c.AreasQueja, r.Area) {
+		return false
+	}
+	if len(c.Establecimientos) > 0 && !contieneTexto(c.Establecimientos, r.Establecimiento) {
+		return false
+	}
+	return true
+}
+
+type resultadoTriaje struct {
+	ReglaIDs []string       `json:"-"`
+	Reglas   []string       `json:"reglas"`
+	Acciones accionesTriaje `json:"acciones"`
+}
+
+// evaluarTriaje recorre las reglas (ya ordenadas) y combina sus acciones.
+func evaluarTriaje(reglas []reglaTriaje, r reclamoTriaje) resultadoTriaje {
+	var res resultadoTriaje
+	for _, regla := range reglas {
+		if !regla.Condiciones.coincide(r) {
+			continue
+		}
+		res.ReglaIDs = append(res.ReglaIDs, regla.ID)
+		res.Reglas = append(res.Reglas, regla.Nombre)
+
+		a := regla.Acciones
+		if res.Acciones.CategoriaID == nil {
+			res.Acciones.CategoriaID = a.CategoriaID
+		}
+		if res.Acciones.Prioridad == "" {
+			res.Acciones.Prioridad = a.Prioridad
+		}
+		if res.Acciones.AgenteID == nil {
+			res.Acciones.AgenteID = a.AgenteID
+		}
+		for _, e := range a.Etiquetas {
+			if !contieneTexto(res.Acciones.Etiquetas, e) {
+				res.Acciones.Etiquetas = append(res.Acciones.Etiquetas, e)
+			}
+		}
+		for _, e := range a.Notificar {
+			if !contieneTexto(res.Acciones.Notificar, e) {
+				res.Acciones.Notificar = append(res.Acciones.Notificar, e)
+			}
+		}
+		if regla.Detener {
+			break
+		}
+	}
+	return res
+}
+
+// -----------------------------------------------------------------------------
+// Aplicación sobre reclamos nuevos
+// -----------------------------------------------------------------------------
+
+func cargarReglasTriaje(ctx context.Context) ([]reglaTriaje, error) {
+	rows, err := pool.Query(ctx, `
+		SELECT id, nombre, orden, detener, condiciones, acciones
+		FROM reglas_triaje
+		WHERE activa = true
+		ORDER BY orden, fecha_creacion
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var reglas []reglaTriaje
+	for rows.Next() {
+		var r reglaTriaje
+		if err := rows.Scan(&r.ID, &r.Nombre, &r.Orden, &r.Detener, &r.Condiciones, &r.Acciones); err != nil {
+			return nil, err
+		}
+		reglas = append(reglas, r)
+	}
+	return reglas, rows.Err()
+}
+
+const sqlReclamoTriaje = `SELECT r.id, r.codigo_reclamo, r.tipo_solicitud, r.detalle_reclamo,
+	COALESCE(r.monto_reclamado, 0)::float8, COALESCE(r.area_queja, ''), COALESCE(r.establecimiento, '')
+	FROM reclamos r`
+
+func escanearReclamoTriaje(row pgx.Row) (reclamoTriaje, error) {
+	var r reclamoTriaje
+	err := row.Scan(&r.ID, &r.Codigo, &r.Tipo, &r.Detalle, &r.Monto, &r.Area, &r.Establecimiento)
+	return r, err
+}
+
+// accionTriaje aplica una acción en su propia transacción.
+func accionTriaje(ctx context.Context, id, nombre string, operacion operacionMasiva) {
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		log.Printf("⚠️ Triaje de %s: %s: %v", id, nombre, err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	_, _, err = operacion(ctx, tx, id)
+	if err == nil {
+		err = tx.Commit(ctx)
+	}
+	if err != nil {
+		log.Printf("⚠️ Triaje de %s: %s: %v", id, nombre, err)
+	}
+}
+
+// aplicarTriaje evalúa las reglas activas sobre un reclamo recién registrado.
+func aplicarTriaje(ctx context.Context, id string) {
+	reglas, err := cargarReglasTriaje(ctx)
+	if err != nil {
+		log.Printf("⚠️ Error cargando reglas de triaje: %v", err)
+		return
+	}
+	if len(reglas) == 0 {
+		return
+	}
+	r, err := escanearReclamoTriaje(pool.QueryRow(ctx, sqlReclamoTriaje+" WHERE r.id = $1", id))
+	if err != nil {
+		log.Printf("⚠️ Triaje: no se pudo leer el reclamo %s: %v", id, err)
+		return
+	}
+
+	res := evaluarTriaje(reglas, r)
+	if len(res.Reglas) == 0 {
+		return
+	}
+	a := res.Acciones
+
+	if a.CategoriaID != nil {
+		accionTriaje(ctx, id, "categoría", func(ctx context.Context, tx pgx.Tx, id string) (string, gin.H, error) {
+			return categorizarEnTx(ctx, tx, id, a.CategoriaID, "SISTEMA")
+		})
+	}
+	if a.AgenteID != nil {
+		agente, nombre, err := agenteAsignable(ctx, *a.AgenteID, "", "ADMIN")
+		if err != nil {
+			log.Printf("⚠️ Triaje de %s: asignación: %v", id, err)
+		} else {
+			accionTriaje(ctx, id, "asignación", func(ctx context.Context, tx pgx.Tx, id string) (string, gin.H, error) {
+				return asignarEnTx(ctx, tx, id, agente, nombre, "SISTEMA")
+			})
+		}
+	}
+	if len(a.Etiquetas) > 0 {
+		accionTriaje(ctx, id, "etiquetas", func(ctx context.Context, tx pgx.Tx, id string) (string, gin.H, error) {
+			return etiquetarEnTx(ctx, tx, id, a.Etiquetas, "SISTEMA")
+		})
+	}
+
+	// Prioridad, historial y contadores de las reglas
+	accionTriaje(ctx, id, "registro", func(ctx context.Context, tx pgx.Tx, id string) (string, gin.H, error) {
+		comentario := "Reglas de triaje: " + strings.Join(res.Reglas, ", ")
+		if a.Prioridad != "" {
+			if _, err := tx.Exec(ctx, "UPDATE reclamos SET prioridad_asignada = $2 WHERE id = $1", id, a.Prioridad); err != nil {
+				return "", nil, err
+			}
+			comentario += ". Prioridad: " + a.Prioridad
+		}
+		_, err := tx.Exec(ctx, `
+			INSERT INTO historial_reclamos (reclamo_id, estado_anterior, estado_nuevo, tipo_accion, comentario, usuario_accion)
+			SELECT id, estado, estado, 'TRIAJE', $2, 'SISTEMA' FROM reclamos WHERE id = $1
+		`, id, comentario)
+		if err != nil {
+			return "", nil, err
+		}
+		_, err = tx.Exec(ctx, `
+			UPDATE reglas_triaje SET coincidencias = coincidencias + 1, ultima_coincidencia = NOW()
+			WHERE id = ANY($1::uuid[])
+		`, res.ReglaIDs)
+		return "", nil, err
+	})
+
+	// Reglas y acciones son internas: solo el panel recibe el resultado
+	publicarEventoInterno(id, eventoEstado, gin.H{"triaje": res})
+
+	if len(a.Notificar) > 0 {
+		contenido := generarEmailTriaje(r, res)
+		for _, email := range a.Notificar {
+			if err := enviarEmailHTML(email, fmt.Sprintf("🔔 %s requiere atención", r.Codigo), contenido); err != nil {
+				log.Printf("❌ Error enviando aviso de triaje a %s: %v", email, err)
+			}
+		}
+	}
+	log.Printf("🧭 Triaje de %s: %s", r.Codigo, strings.Join(res.Reglas, ", "))
+}
+
+func generarEmailTriaje(r reclamoTriaje, res resultadoTriaje) string {
+	detalle := []rune(r.Detalle)
+	if len(detalle) > 300 {
+		detalle = append(detalle[:300], '…')
+	}
+	cuerpo := fmt.Sprintf(`<p style="margin: 0 0 12px 0;">El %s <strong>%s</strong> coincidió con: %s.</p>
+		<p style="margin: 0 0 12px 0;">Monto reclamado: S/ %.2f</p>
+		<p style="margin: 0; color: #4b5563;">%s</p>`,
+		strings.ToLower(r.Tipo), html.EscapeString(r.Codigo), html.EscapeString(strings.Join(res.Reglas, ", ")),
+		r.Monto, html.EscapeString(string(detalle)))
+	if res.Acciones.Prioridad != "" {
+		cuerpo += fmt.Sprintf(`<p style="margin: 12px 0 0 0;">Prioridad asignada: <strong>%s</strong></p>`, res.Acciones.Prioridad)
+	}
+	return generarEmailAviso("🔔 Reclamo destacado por triaje", "#d97706", cuerpo,
+		"Ver reclamo", config.FrontendURL+"/admin/reclamos/"+r.ID,
+		"Aviso automático de las reglas de triaje del Libro de Reclamaciones.")
+}
+
+// -----------------------------------------------------------------------------
+// API
+// -----------------------------------------------------------------------------
+
+type solicitudReglaTriaje struct {
+	Nombre      string            `json:"nombre" binding:"required,max=100"`
+	Orden       int               `json:"orden"`
+	Detener     bool              `json:"detener"`
+	Activa      *bool             `json:"activa"`
+	Condiciones condicionesTriaje `json:"condiciones"`
+	Acciones    accionesTriaje    `json:"acciones"`
+}
+
+func (s *solicitudReglaTriaje) validar() error {
+	if err := s.Condiciones.validar(); err != nil {
+		return err
+	}
+	if err := s.Acciones.validar(); err != nil {
+		return err
+	}
+	if s.Activa == nil {
+		activa := true
+		s.Activa = &activa
+	}
+	return nil
+}
+
+// GET /api/admin/reglas-triaje - Reglas en orden de evaluación
+func listarReglasTriajeHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	rows, err := pool.Query(ctx, `
+		SELECT id, nombre, orden, detener, activa, condiciones, acciones, coincidencias, ultima_coincidencia, fecha_creacion
+		FROM reglas_triaje
+		ORDER BY orden, fecha_creacion
+	`)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al obtener las reglas"})
+		return
+	}
+	defer rows.Close()
+
+	reglas := []gin.H{}
+	for rows.Next() {
+		var r reglaTriaje
+		var activa bool
+		var coincidencias int64
+		var ultima *time.Time
+		var fecha time.Time
+		if err := rows.Scan(&r.ID, &r.Nombre, &r.Orden, &r.Detener, &activa, &r.Condiciones, &r.Acciones, &coincidencias, &ultima, &fecha); err != nil {
+			continue
+		}
+		reglas = append(reglas, gin.H{
+			"id":                  r.ID,
+			"nombre":              r.Nombre,
+			"orden":               r.Orden,
+			"detener":             r.Detener,
+			"activa":              activa,
+			"condiciones":         r.Condiciones,
+			"acciones":            r.Acciones,
+			"coincidencias":       coincidencias,
+			"ultima_coincidencia": ultima,
+			"fecha_creacion":      fecha,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": reglas})
+}
+
+func registrarAuditoriaTriaje(ctx context.Context, c *gin.Context, accion, id string, detalles interface{}) {
+	_, err := pool.Exec(ctx, `
+		INSERT INTO auditoria_admin (usuario_id, accion, entidad, entidad_id, detalles, ip_address)
+		VALUES ($1::uuid, $2, 'REGLA_TRIAJE', $3, $4, $5)
+	`, fmt.Sprintf("%v", c.MustGet("user_id")), accion, id, detalles, c.ClientIP())
+	if err != nil {
+		log.Printf("⚠️ Error registrando auditoría: %v", err)
+	}
+}
+
+// POST /api/admin/reglas-triaje - Crear regla
+func crearReglaTriajeHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var req solicitudReglaTriaje
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Datos inválidos"})
+		return
+	}
+	if err := req.validar(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	var id string
+	err := pool.QueryRow(ctx, `
+		INSERT INTO reglas_triaje (nombre, orden, detener, activa, condiciones, acciones, creado_por)
+		VALUES ($1, $2, $3, $4, $5, $6, $7::uuid)
+		RETURNING id
+	`, strings.TrimSpace(req.Nombre), req.Orden, req.Detener, *req.Activa, req.Condiciones, req.Acciones,
+		fmt.Sprintf("%v", c.MustGet("user_id"))).Scan(&id)
+	if err != nil {
+		log.Printf("❌ Error creando regla de triaje: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al crear la regla"})
+		return
+	}
+
+	registrarAuditoriaTriaje(ctx, c, "CREAR_REGLA_TRIAJE", id, req)
+	c.JSON(http.StatusCreated, gin.H{"success": true, "message": "Regla creada", "data": gin.H{"id": id}})
+}
+
+// PUT /api/admin/reglas-triaje/:id - Modificar regla
+func actualizarReglaTriajeHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	var req solicitudReglaTriaje
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Datos inválidos"})
+		return
+	}
+	if err := req.validar(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+		return
+	}
+
+	id := c.Param("id")
+	tag, err := pool.Exec(ctx, `
+		UPDATE reglas_triaje
+		SET nombre = $2, orden = $3, detener = $4, activa = $5, condiciones = $6, acciones = $7, fecha_modificacion = NOW()
+		WHERE id::text = $1
+	`, id, strings.TrimSpace(req.Nombre), req.Orden, req.Detener, *req.Activa, req.Condiciones, req.Acciones)
+	if err != nil {
+		log.Printf("❌ Error actualizando regla de triaje: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al actualizar la regla"})
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Regla no encontrada"})
+		return
+	}
+
+	registrarAuditoriaTriaje(ctx, c, "EDITAR_REGLA_TRIAJE", id, req)
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Regla actualizada"})
+}
+
+// DELETE /api/admin/reglas-triaje/:id - Eliminar regla
+func eliminarReglaTriajeHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	id := c.Param("id")
+	tag, err := pool.Exec(ctx, "DELETE FROM reglas_triaje WHERE id::text = $1", id)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al eliminar la regla"})
+		return
+	}
+	if tag.RowsAffected() == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"success": false, "message": "Regla no encontrada"})
+		return
+	}
+
+	registrarAuditoriaTriaje(ctx, c, "ELIMINAR_REGLA_TRIAJE", id, gin.H{})
+	c.JSON(http.StatusOK, gin.H{"success": true, "message": "Regla eliminada"})
+}
+
+// POST /api/admin/reglas-triaje/probar - Simulación sin aplicar cambios
+// Con "regla" se prueba esa definición sola; sin ella, las reglas activas.
+// Los reclamos se eligen con ids o filtro (como en las operaciones masivas) o
+// son los más recientes.
+func probarReglasTriajeHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 30*time.Second)
+	defer cancel()
+
+	var req struct {
+		Regla  *solicitudReglaTriaje `json:"regla"`
+		IDs    []string              `json:"ids"`
+		Filtro map[string]string     `json:"filtro"`
+		Limite int                   `json:"limite"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Datos inválidos"})
+		return
+	}
+	if req.Limite <= 0 || req.Limite > config.MasivoMaxReclamos {
+		req.Limite = config.MasivoMaxReclamos
+	}
+
+	var reglas []reglaTriaje
+	var err error
+	if req.Regla != nil {
+		if err := req.Regla.validar(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		reglas = []reglaTriaje{{
+			Nombre: req.Regla.Nombre, Detener: req.Regla.Detener,
+			Condiciones: req.Regla.Condiciones, Acciones: req.Regla.Acciones,
+		}}
+	} else if reglas, err = cargarReglasTriaje(ctx); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al cargar las reglas"})
+		return
+	}
+
+	var rows pgx.Rows
+	if len(req.IDs) > 0 || len(req.Filtro) > 0 {
+		var ids []string
+		ids, err = seleccionarReclamos(ctx, fmt.Sprintf("%v", c.MustGet("user_id")), solicitudMasiva{IDs: req.IDs, Filtro: req.Filtro})
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": err.Error()})
+			return
+		}
+		rows, err = pool.Query(ctx, sqlReclamoTriaje+" WHERE r.id = ANY($1::uuid[]) ORDER BY r.fecha_registro DESC LIMIT $2", ids, req.Limite)
+	} else {
+		rows, err = pool.Query(ctx, sqlReclamoTriaje+" ORDER BY r.fecha_registro DESC LIMIT $1", req.Limite)
+	}
+	if err != nil {
+		log.Printf("❌ Error en simulación de triaje: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al obtener los reclamos"})
+		return
+	}
+	defer rows.Close()
+
+	evaluados := 0
+	porRegla := map[string]int{}
+	coincidencias := []gin.H{}
+	for rows.Next() {
+		r, err := escanearReclamoTriaje(rows)
+		if err != nil {
+			continue
+		}
+		evaluados++
+		res := evaluarTriaje(reglas, r)
+		if len(res.Reglas) == 0 {
+			continue
+		}
+		for _, nombre := range res.Reglas {
+			porRegla[nombre]++
+		}
+		coincidencias = append(coincidencias, gin.H{
+			"id":             r.ID,
+			"codigo_reclamo": r.Codigo,
+			"reglas":         res.Reglas,
+			"acciones":       res.Acciones,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"data": gin.H{
+			"evaluados":     evaluados,
+			"coincidencias": len(coincidencias),
+			"por_regla":     porRegla,
+			"reclamos":      coincidencias,
+		},
+	})
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jackc/pgx/v5"
+)
+
+// =============================================================================
+// VERSIONES DE LA RESPUESTA OFICIAL
+// Las filas de respuestas son inmutables: cada enmienda agrega una versión
+// nueva que reemplaza a la anterior y exige un motivo. Las lecturas usan
+// v_respuestas_vigentes, que deja solo la última versión de cada reclamo.
+// =============================================================================
+
+// Motivo que se registra cuando un reclamo reabierto vuelve a responderse
+const motivoNuevaRespuesta = "Nueva respuesta tras reabrir el reclamo"
+
+// insertarVersionRespuestaEnTx agrega la siguiente versión de la respuesta.
+// El llamador debe tener bloqueado el reclamo. Devuelve el número de versión.
+func insertarVersionRespuestaEnTx(ctx context.Context, tx pgx.Tx, id string, r datosRespuesta, autorEmail, aprobadoPor, motivo string) (int, error) {
+	var anteriorID *string
+	version := 1
+	err := tx.QueryRow(ctx, `
+		SELECT id::text, version + 1 FROM respuestas
+		WHERE reclamo_id = $1 ORDER BY version DESC LIMIT 1
+	`, id).Scan(&anteriorID, &version)
+	if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+		return 0, err
+	}
+	if anteriorID != nil && motivo == "" {
+		motivo = motivoNuevaRespuesta
+	}
+
+	_, err = tx.Exec(ctx, `
+		INSERT INTO respuestas (reclamo_id, respuesta_empresa, accion_tomada, compensacion_ofrecida, respondido_por, aprobado_por,
+		                        version, motivo_enmienda, reemplaza_a)
+		VALUES ($1, $2, $3, $4, $5, NULLIF($6, ''), $7, NULLIF($8, ''), $9::uuid)
+	`, id, r.RespuestaEmpresa, nullString(&r.AccionTomada), nullString(&r.CompensacionOfrecida), autorEmail, aprobadoPor,
+		version, motivo, anteriorID)
+	return version, err
+}
+
+// validarEnmienda comprueba que el reclamo (ya bloqueado) tenga una
+// respuesta publicada que enmendar.
+func validarEnmienda(ctx context.Context, tx pgx.Tx, id string) error {
+	var existe bool
+	if err := tx.QueryRow(ctx, "SELECT EXISTS (SELECT 1 FROM respuestas WHERE reclamo_id = $1)", id).Scan(&existe); err != nil {
+		return err
+	}
+	if !existe {
+		return fmt.Errorf("%w: el reclamo aún no tiene una respuesta publicada", errTransicionInvalida)
+	}
+	return nil
+}
+
+// enmendarRespuestaEnTx publica una versión nueva sin tocar el estado del
+// reclamo ni la fecha de la respuesta original. Devuelve la versión creada.
+func enmendarRespuestaEnTx(ctx context.Context, tx pgx.Tx, id string, r datosRespuesta, motivo, autorEmail, aprobadoPor string) (int, error) {
+	var estado string
+	err := tx.QueryRow(ctx, "SELECT estado FROM reclamos WHERE id = $1 FOR UPDATE", id).Scan(&estado)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return 0, errReclamoNoEncontrado
+	}
+	if err != nil {
+		return 0, err
+	}
+	if err := validarEnmienda(ctx, tx, id); err != nil {
+		return 0, err
+	}
+
+	version, err := insertarVersionRespuestaEnTx(ctx, tx, id, r, autorEmail, aprobadoPor, motivo)
+	if err != nil {
+		return 0, err
+	}
+
+	// Como en RESPUESTA, el aprobador queda en la versión, no en el historial público
+	_, err = tx.Exec(ctx, `
+		INSERT INTO historial_reclamos (reclamo_id, estado_anterior, estado_nuevo, tipo_accion, comentario, usuario_accion)
+		VALUES ($1, $2, $2, 'ENMIENDA_RESPUESTA', $3, $4)
+	`, id, estado, fmt.Sprintf("Respuesta enmendada (versión %d): %s", version, motivo), autorEmail)
+	return version, err
+}
+
+// respuestaEnmendada avisa en tiempo real y al consumidor de la nueva versión.
+func respuestaEnmendada(id string, version int) {
+	publicarEvento(id, eventoEstado, gin.H{"respuesta": true, "version_respuesta": version})
+
+	emailsEnCurso.Add(1)
+	go func() {
+		defer emailsEnCurso.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		defer cancel()
+		notificarRespuestaConsumidor(ctx, id)
+	}()
+}
+
+// POST /api/admin/reclamos/:id/respuesta/enmiendas - Enmendar la respuesta publicada
+func enmendarRespuestaHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	id := c.Param("id")
+	userID := fmt.Sprintf("%v", c.MustGet("user_id"))
+	email := fmt.Sprintf("%v", c.MustGet("email"))
+
+	var req struct {
+		RespuestaEmpresa     string `json:"respuesta_empresa" binding:"required,min=10"`
+		AccionTomada         string `json:"accion_tomada"`
+		CompensacionOfrecida string `json:"compensacion_ofrecida"`
+		Motivo               string `json:"motivo" binding:"required,min=10,max=1000"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"success": false, "message": "Datos inválidos: la enmienda requiere la respuesta y un motivo (mín. 10 caracteres)"})
+		return
+	}
+	motivo := strings.TrimSpace(req.Motivo)
+	datos := datosRespuesta{RespuestaEmpresa: req.RespuestaEmpresa, AccionTomada: req.AccionTomada, CompensacionOfrecida: req.CompensacionOfrecida}
+
+	// Las enmiendas pasan por las mismas reglas de aprobación que la respuesta
+	regla, err := reglaAprobacionAplicable(ctx, id)
+	if err != nil {
+		responderErrorRespuesta(c, id, "Reclamo no encontrado", err)
+		return
+	}
+
+	tx, err := pool.Begin(ctx)
+	if err != nil {
+		responderErrorRespuesta(c, id, "Reclamo no encontrado", err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	if regla != "" {
+		propuestaID, err := proponerRespuestaEnTx(ctx, tx, id, datos, motivo, userID, email, regla)
+		if err == nil {
+			err = tx.Commit(ctx)
+		}
+		if err != nil {
+			responderErrorRespuesta(c, id, "Reclamo no encontrado", err)
+			return
+		}
+
+		publicarEventoInterno(id, eventoEstado, gin.H{"propuesta_respuesta": propuestaID})
+		emailsEnCurso.Add(1)
+		go func() {
+			defer emailsEnCurso.Done()
+			ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+			defer cancel()
+			avisarRevisores(ctx, id, email, regla)
+		}()
+		registrarAuditoriaAprobacion(ctx, c, "PROPONER_ENMIENDA", "RECLAMO", id, gin.H{"propuesta_id": propuestaID, "regla": regla, "motivo": motivo})
+
+		c.JSON(http.StatusAccepted, gin.H{
+			"success": true,
+			"message": "Enmienda enviada a aprobación",
+			"data":    gin.H{"estado": "PENDIENTE_APROBACION", "propuesta_id": propuestaID, "regla": regla},
+		})
+		return
+	}
+
+	version, err := enmendarRespuestaEnTx(ctx, tx, id, datos, motivo, email, "")
+	if err == nil {
+		err = tx.Commit(ctx)
+	}
+	if err != nil {
+		responderErrorRespuesta(c, id, "Reclamo no encontrado", err)
+		return
+	}
+
+	respuestaEnmendada(id, version)
+	registrarAuditoriaAprobacion(ctx, c, "ENMENDAR_RESPUESTA", "RECLAMO", id, gin.H{"version": version, "motivo": motivo})
+
+	c.JSON(http.StatusCreated, gin.H{"success": true, "message": "Respuesta enmendada", "data": gin.H{"version": version}})
+}
+
+// GET /api/admin/reclamos/:id/respuesta/versiones - Historial de versiones, la vigente primero
+func listarVersionesRespuestaHandler(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), 10*time.Second)
+	defer cancel()
+
+	rows, err := pool.Query(ctx, `
+		SELECT id, version, respuesta_empresa, accion_tomada, compensacion_ofrecida,
+		       respondido_por, aprobado_por, motivo_enmienda, reemplaza_a::text, fecha_respuesta
+		FROM respuestas
+		WHERE reclamo_id::text = $1
+		ORDER BY version DESC
+	`, c.Param("id"))
+	if err != nil {
+		log.Printf("❌ Error listando versiones de respuesta: %v", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"success": false, "message": "Error al obtener las versiones"})
+		return
+	}
+	defer rows.Close()
+
+	versiones := []gin.H{}
+	for rows.Next() {
+		var id, respuesta, respondidoPor string
+		var version int
+		var accion, compensacion, aprobadoPor, motivo, reemplazaA *string
+		var fecha time.Time
+		if err := rows.Scan(&id, &version, &respuesta, &accion, &compensacion, &respondidoPor, &aprobadoPor, &motivo, &reemplazaA, &fecha); err != nil {
+			continue
+		}
+		versiones = append(versiones, gin.H{
+			"id":                    id,
+			"version":               version,
+			"vigente":               len(versiones) == 0,
+			"respuesta_empresa":     respuesta,
+			"accion_tomada":         accion,
+			"compensacion_ofrecida": compensacion,
+			"respondido_por":        respondidoPor,
+			"aprobado_por":          aprobadoPor,
+			"motivo_enmienda":       motivo,
+			"reemplaza_a":           reemplazaA,
+			"fecha_respuesta":       fecha,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true, "data": versiones})
+}
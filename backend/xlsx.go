@@ -0,0 +1,115 @@
+package main
+
+import (
+	"archive/zip"
+	"encoding/xml"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// =============================================================================
+// GENERADOR XLSX MÍNIMO
+// Una sola hoja con cadenas en línea y números, escrita fila por fila dentro
+// del zip: no mantiene el libro en memoria ni depende de librerías externas.
+// =============================================================================
+
+var xlsxPartes = []struct{ nombre, contenido string }{
+	{"[Content_Types].xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">
+<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>
+<Default Extension="xml" ContentType="application/xml"/>
+<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>
+<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>
+<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>
+</Types>`},
+	{"_rels/.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>
+</Relationships>`},
+	{"xl/workbook.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">
+<sheets><sheet name="Reclamos" sheetId="1" r:id="rId1"/></sheets>
+</workbook>`},
+	{"xl/_rels/workbook.xml.rels", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">
+<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>
+<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>
+</Relationships>`},
+	// Estilo 1: negrita para la fila de encabezados
+	{"xl/styles.xml", `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>
+<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">
+<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>
+<fills count="2"><fill><patternFill patternType="none"/></fill><fill><patternFill patternType="gray125"/></fill></fills>
+<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>
+<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>
+<cellXfs count="2"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/><xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>
+</styleSheet>`},
+}
+
+type libroXLSX struct {
+	zip  *zip.Writer
+	hoja io.Writer
+}
+
+// nuevoXLSX escribe las partes fijas y abre la hoja para agregar filas.
+func nuevoXLSX(w io.Writer) (*libroXLSX, error) {
+	zw := zip.NewWriter(w)
+	for _, p := range xlsxPartes {
+		f, err := zw.Create(p.nombre)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := io.WriteString(f, p.contenido); err != nil {
+			return nil, err
+		}
+	}
+
+	hoja, err := zw.Create("xl/worksheets/sheet1.xml")
+	if err != nil {
+		return nil, err
+	}
+	_, err = io.WriteString(hoja, `<?xml version="1.0" encoding="UTF-8" standalone="yes"?>`+
+		`<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main"><sheetData>`)
+	if err != nil {
+		return nil, err
+	}
+	return &libroXLSX{zip: zw, hoja: hoja}, nil
+}
+
+// Encabezado agrega una fila de texto en negrita.
+func (l *libroXLSX) Encabezado(titulos []string) error {
+	return l.fila(titulos, nil, ` s="1"`)
+}
+
+// Fila agrega una fila; las celdas marcadas en numericos se escriben como número.
+func (l *libroXLSX) Fila(valores []string, numericos []bool) error {
+	return l.fila(valores, numericos, "")
+}
+
+func (l *libroXLSX) fila(valores []string, numericos []bool, estilo string) error {
+	var b strings.Builder
+	b.WriteString("<row>")
+	for i, v := range valores {
+		if i < len(numericos) && numericos[i] {
+			if _, err := strconv.ParseFloat(v, 64); err == nil {
+				b.WriteString("<c" + estilo + "><v>" + v + "</v></c>")
+				continue
+			}
+		}
+		b.WriteString(`<c t="inlineStr"` + estilo + `><is><t xml:space="preserve">`)
+		xml.EscapeText(&b, []byte(v))
+		b.WriteString("</t></is></c>")
+	}
+	b.WriteString("</row>")
+	_, err := io.WriteString(l.hoja, b.String())
+	return err
+}
+
+// Cerrar termina la hoja y el zip.
+func (l *libroXLSX) Cerrar() error {
+	if _, err := io.WriteString(l.hoja, "</sheetData></worksheet>"); err != nil {
+		return err
+	}
+	return l.zip.Close()
+}